@@ -0,0 +1,87 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package v1
+
+import (
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterObjectStoreKind is the kind of the cluster-scoped object store
+const ClusterObjectStoreKind = "ClusterObjectStore"
+
+// ClusterObjectStoreSpec defines the desired state of ClusterObjectStore.
+// +kubebuilder:validation:XValidation:rule="!has(self.instanceSidecarConfiguration) || !has(self.instanceSidecarConfiguration.credentialsMode) || self.instanceSidecarConfiguration.credentialsMode == 'API'",reason="FieldValueForbidden",message="the File credentials mode is not supported by ClusterObjectStores"
+type ClusterObjectStoreSpec struct {
+	ObjectStoreSpec `json:",inline"`
+
+	// CredentialsNamespace is the namespace containing the secrets
+	// referenced by the configuration, such as the credentials and
+	// the endpoint CA.
+	// +kubebuilder:validation:MinLength=1
+	CredentialsNamespace string `json:"credentialsNamespace"`
+
+	// AllowedNamespaces is the list of namespaces whose Clusters are
+	// allowed to use this object store. The plugin grants the Clusters
+	// in these namespaces read access to the referenced secrets.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	AllowedNamespaces []string `json:"allowedNamespaces"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +genclient
+// +genclient:nonNamespaced
+// +kubebuilder:storageversion
+
+// ClusterObjectStore is the Schema for the clusterobjectstores API.
+// It defines an object store shared by the Clusters of multiple
+// namespaces.
+type ClusterObjectStore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+
+	// Specification of the desired behavior of the ClusterObjectStore.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
+	Spec ClusterObjectStoreSpec `json:"spec"`
+	// Most recently observed status of the ClusterObjectStore. This data may not be up to
+	// date. Populated by the system. Read-only.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#spec-and-status
+	// +optional
+	Status ObjectStoreStatus `json:"status,omitempty"`
+}
+
+// IsNamespaceAllowed checks if the Clusters of the passed namespace
+// are allowed to use this object store
+func (store *ClusterObjectStore) IsNamespaceAllowed(namespace string) bool {
+	return slices.Contains(store.Spec.AllowedNamespaces, namespace)
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterObjectStoreList contains a list of ClusterObjectStore.
+type ClusterObjectStoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterObjectStore `json:"items"`
+}
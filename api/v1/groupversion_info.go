@@ -32,7 +32,8 @@ var GroupVersion = schema.GroupVersion{Group: "barmancloud.cnpg.io", Version: "v
 // exposed by this package.
 func AddKnownTypes(scheme *runtime.Scheme) {
 	scheme.AddKnownTypes(GroupVersion,
-		&ObjectStore{}, &ObjectStoreList{})
+		&ObjectStore{}, &ObjectStoreList{},
+		&ClusterObjectStore{}, &ClusterObjectStoreList{})
 
 	metav1.AddToGroupVersion(scheme, GroupVersion)
 }
@@ -0,0 +1,111 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package v1
+
+// The condition types reported in the ObjectStore status
+const (
+	// ConditionReady is true when the credentials are resolved and,
+	// when the probe is enabled, the object store is reachable
+	ConditionReady = "Ready"
+
+	// ConditionCredentialsResolved is true when every Secret key
+	// referenced by the credentials exists
+	ConditionCredentialsResolved = "CredentialsResolved"
+
+	// ConditionReachable is true when the last connectivity probe
+	// succeeded
+	ConditionReachable = "Reachable"
+
+	// ConditionDeletionBlocked is true when the ObjectStore has been
+	// deleted, but its removal is blocked by the Clusters using it
+	ConditionDeletionBlocked = "DeletionBlocked"
+)
+
+// The reasons of the ObjectStore conditions
+const (
+	// ReasonReady is used when the ObjectStore is ready to be used
+	ReasonReady = "Ready"
+
+	// ReasonCredentialsResolved is used when every Secret key
+	// referenced by the credentials has been found
+	ReasonCredentialsResolved = "CredentialsResolved"
+
+	// ReasonCredentialsInherited is used when the credentials are
+	// provided by the environment, i.e. by an IAM role or by the
+	// workload identity
+	ReasonCredentialsInherited = "CredentialsInherited"
+
+	// ReasonMissingCredentials is used when the configuration does not
+	// define the credentials required by the cloud provider
+	ReasonMissingCredentials = "MissingCredentials"
+
+	// ReasonSecretNotFound is used when a Secret referenced by the
+	// credentials does not exist
+	ReasonSecretNotFound = "SecretNotFound"
+
+	// ReasonSecretKeyNotFound is used when a key referenced by the
+	// credentials is not present in its Secret
+	ReasonSecretKeyNotFound = "SecretKeyNotFound"
+
+	// ReasonCredentialsNotResolved is used when a condition cannot be
+	// evaluated because the credentials are not resolved
+	ReasonCredentialsNotResolved = "CredentialsNotResolved"
+
+	// ReasonProbeSucceeded is used when the last probe succeeded
+	ReasonProbeSucceeded = "ProbeSucceeded"
+
+	// ReasonProbePending is used while the probe for the current
+	// generation of the ObjectStore is running or yet to be started
+	ReasonProbePending = "ProbePending"
+
+	// ReasonProbeDisabled is used when the probe is not enabled
+	ReasonProbeDisabled = "ProbeDisabled"
+
+	// ReasonProbeUnavailable is used when the plugin operator has not
+	// been configured with the sidecar image required to run the probe
+	ReasonProbeUnavailable = "ProbeUnavailable"
+
+	// ReasonProbeFailed is used when the probe failed for an unexpected
+	// reason, i.e. it could not be started or it timed out
+	ReasonProbeFailed = "ProbeFailed"
+
+	// ReasonConnectionFailed is used when the object store endpoint
+	// could not be reached
+	ReasonConnectionFailed = "ConnectionFailed"
+
+	// ReasonBucketNotFound is used when the bucket or container of the
+	// destination path does not exist
+	ReasonBucketNotFound = "BucketNotFound"
+
+	// ReasonListFailed is used when the destination path could not be
+	// listed
+	ReasonListFailed = "ListFailed"
+
+	// ReasonWriteFailed is used when the probe object could not be
+	// uploaded or removed
+	ReasonWriteFailed = "WriteFailed"
+
+	// ReasonNotReachable is used when the object store is not reachable
+	ReasonNotReachable = "NotReachable"
+
+	// ReasonInUse is used when the deletion of the ObjectStore is
+	// blocked by the Clusters still using it
+	ReasonInUse = "InUse"
+)
@@ -22,15 +22,54 @@ package v1
 import (
 	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// ObjectStoreKind is the kind of the namespaced object store
+const ObjectStoreKind = "ObjectStore"
+
+// CredentialsMode defines how the sidecar reads the Secrets referenced
+// by the credentials of an object store
+// +kubebuilder:validation:Enum:=API;File
+type CredentialsMode string
+
+const (
+	// CredentialsModeAPI reads the Secrets through the Kubernetes API
+	CredentialsModeAPI CredentialsMode = "API"
+
+	// CredentialsModeFile reads the Secrets from a projected volume
+	// mounted in the sidecar
+	CredentialsModeFile CredentialsMode = "File"
+)
+
+// BackupDeletionPolicy defines what happens to the data of a Backup
+// when the Backup is deleted
+// +kubebuilder:validation:Enum:=Retain;Delete
+type BackupDeletionPolicy string
+
+const (
+	// BackupDeletionPolicyRetain keeps the data of the deleted Backups
+	// in the object store, until the retention policy removes it
+	BackupDeletionPolicyRetain BackupDeletionPolicy = "Retain"
+
+	// BackupDeletionPolicyDelete removes the data of the deleted
+	// Backups from the object store
+	BackupDeletionPolicyDelete BackupDeletionPolicy = "Delete"
+)
+
 // InstanceSidecarConfiguration defines the configuration for the sidecar that runs in the instance pods.
 type InstanceSidecarConfiguration struct {
 	// The environment to be explicitly passed to the sidecar
 	// +optional
 	Env []corev1.EnvVar `json:"env,omitempty"`
 
+	// Image is the image of the sidecar, overriding the default one of the
+	// plugin. It is used only when it matches the sidecar image allow-list
+	// of the plugin operator.
+	// +optional
+	Image string `json:"image,omitempty"`
+
 	// The retentionCheckInterval defines the frequency at which the
 	// system checks and enforces retention policies.
 	// +kubebuilder:default:=1800
@@ -41,6 +80,18 @@ type InstanceSidecarConfiguration struct {
 	// +optional
 	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 
+	// PrimaryResources define cpu/memory requests and limits for the
+	// sidecar of the primary instance, which runs the backups, the WAL
+	// archiving and the retention policy. Defaults to `resources`.
+	// +optional
+	PrimaryResources *corev1.ResourceRequirements `json:"primaryResources,omitempty"`
+
+	// ReplicaResources define cpu/memory requests and limits for the
+	// sidecar of the replicas, which mostly restore WAL files.
+	// Defaults to `resources`.
+	// +optional
+	ReplicaResources *corev1.ResourceRequirements `json:"replicaResources,omitempty"`
+
 	// AdditionalContainerArgs is an optional list of command-line arguments
 	// to be passed to the sidecar container when it starts.
 	// The provided arguments are appended to the container’s default arguments.
@@ -53,6 +104,220 @@ type InstanceSidecarConfiguration struct {
 	// +kubebuilder:validation:Enum:=error;warning;info;debug;trace
 	// +optional
 	LogLevel string `json:"logLevel,omitempty"`
+
+	// CredentialsMode defines how the sidecar reads the Secrets referenced
+	// by the credentials. With `API` (default), they are read through the
+	// Kubernetes API. With `File`, they are mounted in the sidecar through
+	// a projected volume and reloaded when they change, and the Role of
+	// the instances no longer grants access to them. `File` is not
+	// supported by ClusterObjectStores.
+	// +kubebuilder:default:=API
+	// +optional
+	CredentialsMode CredentialsMode `json:"credentialsMode,omitempty"`
+
+	// ContainerTemplate is applied as a strategic merge patch over the
+	// `plugin-barman-cloud` container generated by the plugin, allowing
+	// to add volume mounts, and to change the probe timings and the
+	// security context. The name, image, command, arguments and restart
+	// policy of the container, the handler of its startup probe, and the
+	// environment variables and volume mounts set by the plugin are
+	// always preserved. The volume mounts must refer to volumes of the
+	// pod, such as the one defined by the `projectedVolumeTemplate` of
+	// the Cluster.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type:=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	ContainerTemplate *corev1.Container `json:"containerTemplate,omitempty"`
+
+	// SpoolVolume is a dedicated volume hosting the spool of the WAL
+	// files prefetched during the restore, which otherwise lives in
+	// the volume shared with the instance manager.
+	// +optional
+	SpoolVolume *SpoolVolumeConfiguration `json:"spoolVolume,omitempty"`
+}
+
+// GetPrimaryResources returns the resources of the sidecar of the
+// primary instance
+func (configuration *InstanceSidecarConfiguration) GetPrimaryResources() corev1.ResourceRequirements {
+	if configuration.PrimaryResources != nil {
+		return *configuration.PrimaryResources
+	}
+
+	return configuration.Resources
+}
+
+// GetReplicaResources returns the resources of the sidecar of the replicas
+func (configuration *InstanceSidecarConfiguration) GetReplicaResources() corev1.ResourceRequirements {
+	if configuration.ReplicaResources != nil {
+		return *configuration.ReplicaResources
+	}
+
+	return configuration.Resources
+}
+
+// RecoveryJobSidecarConfiguration defines the configuration for the sidecar
+// that runs in the Job restoring a cluster from the object store. Each
+// setting overrides the corresponding one of the instance sidecar
+// configuration. The image is always the one of the instance sidecar, as
+// the recovery Job must run the same plugin version as the instances it
+// bootstraps, while the credentials and the certificates are derived from
+// the object store configuration and are shared by every sidecar.
+type RecoveryJobSidecarConfiguration struct {
+	// The environment to be explicitly passed to the sidecar, overriding
+	// the variables with the same name defined for the instance pods
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// Resources define cpu/memory requests and limits for the sidecar.
+	// Defaults to the resources of the instance sidecar.
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// AdditionalContainerArgs is an optional list of command-line arguments
+	// to be passed to the sidecar container when it starts, replacing the
+	// ones defined for the instance pods.
+	// +kubebuilder:validation:XValidation:rule="!self.exists(a, a.startsWith('--log-level'))",reason="FieldValueForbidden",message="do not set --log-level in additionalContainerArgs; use spec.recoveryJobSidecarConfiguration.logLevel"
+	// +optional
+	AdditionalContainerArgs []string `json:"additionalContainerArgs,omitempty"`
+
+	// The log level of the sidecar. Valid values are: `error`, `warning`,
+	// `info`, `debug`, `trace`. Defaults to the log level of the instance
+	// sidecar.
+	// +kubebuilder:validation:Enum:=error;warning;info;debug;trace
+	// +optional
+	LogLevel string `json:"logLevel,omitempty"`
+
+	// ContainerTemplate is applied as a strategic merge patch over the
+	// `plugin-barman-cloud` container of the recovery Job, following the
+	// same rules of the one of the instance sidecar, which is used when
+	// this is not set.
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type:=object
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +optional
+	ContainerTemplate *corev1.Container `json:"containerTemplate,omitempty"`
+
+	// SpoolVolume is a dedicated volume hosting the spool of the WAL
+	// files prefetched during the restore. Defaults to the spool volume
+	// of the instance sidecar.
+	// +optional
+	SpoolVolume *SpoolVolumeConfiguration `json:"spoolVolume,omitempty"`
+}
+
+// SpoolVolumeConfiguration defines the dedicated volume hosting the spool
+// of the WAL files prefetched during the restore. Exactly one of
+// `emptyDir` and `ephemeral` must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.emptyDir) != has(self.ephemeral)",message="exactly one of emptyDir and ephemeral must be set"
+type SpoolVolumeConfiguration struct {
+	// EmptyDir hosts the spool in an emptyDir volume, whose medium and
+	// size limit can be chosen
+	// +optional
+	EmptyDir *corev1.EmptyDirVolumeSource `json:"emptyDir,omitempty"`
+
+	// Ephemeral hosts the spool in a generic ephemeral volume, backed
+	// by a PersistentVolumeClaim sharing the lifetime of the pod
+	// +optional
+	Ephemeral *SpoolEphemeralVolumeConfiguration `json:"ephemeral,omitempty"`
+}
+
+// SpoolEphemeralVolumeConfiguration defines the PersistentVolumeClaim
+// backing the ephemeral spool volume
+type SpoolEphemeralVolumeConfiguration struct {
+	// StorageClassName is the storage class of the PersistentVolumeClaim.
+	// The default storage class is used when not set.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size is the requested size of the PersistentVolumeClaim
+	Size resource.Quantity `json:"size"`
+}
+
+// GetCredentialsMode returns the credentials mode, defaulting to `API`
+func (configuration *InstanceSidecarConfiguration) GetCredentialsMode() CredentialsMode {
+	if len(configuration.CredentialsMode) == 0 {
+		return CredentialsModeAPI
+	}
+
+	return configuration.CredentialsMode
+}
+
+// ProbeConfiguration defines how the plugin operator periodically checks
+// that the object store can be reached with the configured credentials.
+type ProbeConfiguration struct {
+	// Enabled starts the periodic connectivity probe. When not set, the
+	// Reachable condition is reported as Unknown and the Ready condition
+	// only depends on the credentials being resolved.
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// IntervalSeconds is the time between two consecutive probes.
+	// +kubebuilder:default:=300
+	// +kubebuilder:validation:Minimum:=30
+	// +optional
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+
+	// WriteCheck enables the upload and removal of a small object
+	// under the destination path, in addition to listing it.
+	// This requires write and delete permissions on the object store.
+	// +optional
+	WriteCheck bool `json:"writeCheck,omitempty"`
+
+	// ServiceAccountName is the name of the ServiceAccount used by the
+	// probe Job. Set it when the credentials are inherited from the
+	// workload identity of a ServiceAccount.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+}
+
+// WorkloadIdentityConfiguration defines the workload identity used to
+// access the object store without static credentials, through a
+// service account token projected in the containers running the
+// plugin image
+type WorkloadIdentityConfiguration struct {
+	// Audience is the intended audience of the projected service account
+	// token, such as `sts.amazonaws.com` for AWS or
+	// `api://AzureADTokenExchange` for Azure
+	// +kubebuilder:validation:MinLength=1
+	Audience string `json:"audience"`
+
+	// ExpirationSeconds is the requested validity of the projected
+	// service account token, which is rotated by the kubelet before
+	// its expiration
+	// +kubebuilder:default:=3600
+	// +kubebuilder:validation:Minimum:=600
+	// +optional
+	ExpirationSeconds int64 `json:"expirationSeconds,omitempty"`
+
+	// ServiceAccountName is the name of a dedicated ServiceAccount,
+	// created by the plugin in the namespace of the ObjectStore with
+	// the given annotations, and used by the probe Job when
+	// `.spec.probe.serviceAccountName` is not set.
+	// The instance pods and the recovery Jobs keep using the
+	// ServiceAccount of their Cluster, whose token is projected in
+	// the sidecar.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Annotations identify the cloud identity to be assumed, using the
+	// same annotations understood by the workload identity webhooks of
+	// the cloud providers: `eks.amazonaws.com/role-arn` for AWS, and
+	// `azure.workload.identity/client-id` and
+	// `azure.workload.identity/tenant-id` for Azure.
+	// They are applied to the dedicated ServiceAccount, if any, and
+	// translated into the environment of the sidecar.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// GetExpirationSeconds returns the requested validity of the projected
+// service account token, defaulting to one hour
+func (configuration *WorkloadIdentityConfiguration) GetExpirationSeconds() int64 {
+	if configuration.ExpirationSeconds <= 0 {
+		return 3600
+	}
+
+	return configuration.ExpirationSeconds
 }
 
 // ObjectStoreSpec defines the desired state of ObjectStore.
@@ -69,15 +334,165 @@ type ObjectStoreSpec struct {
 	// +optional
 	RetentionPolicy string `json:"retentionPolicy,omitempty"`
 
+	// BackupDeletionPolicy defines what happens to the data of a Backup
+	// taken by the plugin when the Backup is deleted. With `Retain`
+	// (default), the data is kept until the retention policy removes
+	// it. With `Delete`, the plugin removes it from the object store,
+	// unless it is the oldest backup of the server, whose removal
+	// would move the first recoverability point.
+	// +optional
+	BackupDeletionPolicy BackupDeletionPolicy `json:"backupDeletionPolicy,omitempty"`
+
 	// The configuration for the sidecar that runs in the instance pods
 	// +optional
 	InstanceSidecarConfiguration InstanceSidecarConfiguration `json:"instanceSidecarConfiguration,omitempty"`
+
+	// The configuration for the sidecar that runs in the Job restoring
+	// a cluster from the object store
+	// +optional
+	RecoveryJobSidecarConfiguration *RecoveryJobSidecarConfiguration `json:"recoveryJobSidecarConfiguration,omitempty"`
+
+	// The configuration of the periodic connectivity probe
+	// +optional
+	Probe ProbeConfiguration `json:"probe,omitempty"`
+
+	// WorkloadIdentity defines the workload identity used to access the
+	// object store. When set, a service account token with the given
+	// audience is projected in the sidecar, together with the
+	// environment variables the cloud provider SDKs need to exchange
+	// it for temporary credentials.
+	// +optional
+	WorkloadIdentity *WorkloadIdentityConfiguration `json:"workloadIdentity,omitempty"`
 }
 
 // ObjectStoreStatus defines the observed state of ObjectStore.
 type ObjectStoreStatus struct {
 	// ServerRecoveryWindow maps each server to its recovery window
 	ServerRecoveryWindow map[string]RecoveryWindow `json:"serverRecoveryWindow,omitempty"`
+
+	// Conditions represent the latest available observations of the
+	// ObjectStore state: Ready, CredentialsResolved, Reachable and
+	// DeletionBlocked
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the most recent generation of the ObjectStore
+	// spec evaluated by the plugin operator
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// SecretsResourceVersion maps the name of each Secret referenced by
+	// the credentials and the endpoint CA to its last observed resource
+	// version
+	// +optional
+	SecretsResourceVersion map[string]string `json:"secretsResourceVersion,omitempty"`
+
+	// LastSecretsRotationTime is the time when a change to the Secrets
+	// referenced by the credentials and the endpoint CA has been last
+	// observed
+	// +optional
+	LastSecretsRotationTime *metav1.Time `json:"lastSecretsRotationTime,omitempty"`
+
+	// SidecarImage is the image of the sidecar used by the instance pods
+	// and the recovery Jobs, taking into account the sidecar image
+	// allow-list of the plugin operator
+	// +optional
+	SidecarImage string `json:"sidecarImage,omitempty"`
+
+	// ServerPurges records the purges of the data archived by the
+	// deleted Clusters having the Delete purge policy
+	// +optional
+	ServerPurges []ServerPurge `json:"serverPurges,omitempty"`
+
+	// ServerOwners maps each server to the Cluster owning it, which is
+	// the only one allowed to archive WAL files and take backups with
+	// that server name in the object store
+	// +optional
+	ServerOwners map[string]ServerOwner `json:"serverOwners,omitempty"`
+}
+
+// ServerPurgePhase is the phase of the purge of the data of a server
+type ServerPurgePhase string
+
+const (
+	// ServerPurgePhasePending means that the purge is waiting for the
+	// end of the grace period, or for a retry after a failure
+	ServerPurgePhasePending ServerPurgePhase = "Pending"
+
+	// ServerPurgePhaseFailed means that the purge failed too many
+	// times, and will not be retried
+	ServerPurgePhaseFailed ServerPurgePhase = "Failed"
+
+	// ServerPurgePhaseCompleted means that the data of the server has
+	// been deleted
+	ServerPurgePhaseCompleted ServerPurgePhase = "Completed"
+
+	// ServerPurgePhaseRefused means that the purge has been refused,
+	// because another Cluster writes to the same server name
+	ServerPurgePhaseRefused ServerPurgePhase = "Refused"
+)
+
+// ServerPurge records the purge of the data archived in the object
+// store by a deleted Cluster
+type ServerPurge struct {
+	// ServerName is the name of the server whose data is purged
+	ServerName string `json:"serverName"`
+
+	// ClusterNamespace is the namespace of the deleted Cluster
+	ClusterNamespace string `json:"clusterNamespace"`
+
+	// ClusterName is the name of the deleted Cluster
+	ClusterName string `json:"clusterName"`
+
+	// ClusterUID is the UID of the deleted Cluster
+	ClusterUID string `json:"clusterUID"`
+
+	// DestinationPath is the destination path the deleted Cluster
+	// archived into. The purge is refused if the object store no
+	// longer points to it.
+	DestinationPath string `json:"destinationPath"`
+
+	// EndpointURL is the endpoint the deleted Cluster archived into
+	// +optional
+	EndpointURL string `json:"endpointURL,omitempty"`
+
+	// ScheduledTime is the time after which the data is purged
+	ScheduledTime metav1.Time `json:"scheduledTime"`
+
+	// Phase is the phase of the purge
+	Phase ServerPurgePhase `json:"phase"`
+
+	// Message describes the deleted data, or the reason why the purge
+	// has been refused or failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Attempts is the number of runs of the purge that failed
+	// +optional
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// CompletionTime is the time when the purge completed, failed, or
+	// has been refused
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// ServerOwner identifies the Cluster owning a server in the object store
+type ServerOwner struct {
+	// ClusterNamespace is the namespace of the owning Cluster
+	ClusterNamespace string `json:"clusterNamespace"`
+
+	// ClusterName is the name of the owning Cluster
+	ClusterName string `json:"clusterName"`
+
+	// ClusterUID is the UID of the owning Cluster
+	ClusterUID string `json:"clusterUID"`
+
+	// Timeline is the latest timeline archived by the owning Cluster
+	// +optional
+	Timeline int `json:"timeline,omitempty"`
 }
 
 // RecoveryWindow represents the time span between the first
@@ -25,9 +25,90 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectStore) DeepCopyInto(out *ClusterObjectStore) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectStore.
+func (in *ClusterObjectStore) DeepCopy() *ClusterObjectStore {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectStore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterObjectStore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectStoreList) DeepCopyInto(out *ClusterObjectStoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterObjectStore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectStoreList.
+func (in *ClusterObjectStoreList) DeepCopy() *ClusterObjectStoreList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectStoreList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterObjectStoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterObjectStoreSpec) DeepCopyInto(out *ClusterObjectStoreSpec) {
+	*out = *in
+	in.ObjectStoreSpec.DeepCopyInto(&out.ObjectStoreSpec)
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterObjectStoreSpec.
+func (in *ClusterObjectStoreSpec) DeepCopy() *ClusterObjectStoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterObjectStoreSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InstanceSidecarConfiguration) DeepCopyInto(out *InstanceSidecarConfiguration) {
 	*out = *in
@@ -39,11 +120,31 @@ func (in *InstanceSidecarConfiguration) DeepCopyInto(out *InstanceSidecarConfigu
 		}
 	}
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.PrimaryResources != nil {
+		in, out := &in.PrimaryResources, &out.PrimaryResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReplicaResources != nil {
+		in, out := &in.ReplicaResources, &out.ReplicaResources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.AdditionalContainerArgs != nil {
 		in, out := &in.AdditionalContainerArgs, &out.AdditionalContainerArgs
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ContainerTemplate != nil {
+		in, out := &in.ContainerTemplate, &out.ContainerTemplate
+		*out = new(corev1.Container)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpoolVolume != nil {
+		in, out := &in.SpoolVolume, &out.SpoolVolume
+		*out = new(SpoolVolumeConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceSidecarConfiguration.
@@ -120,6 +221,17 @@ func (in *ObjectStoreSpec) DeepCopyInto(out *ObjectStoreSpec) {
 	*out = *in
 	in.Configuration.DeepCopyInto(&out.Configuration)
 	in.InstanceSidecarConfiguration.DeepCopyInto(&out.InstanceSidecarConfiguration)
+	out.Probe = in.Probe
+	if in.RecoveryJobSidecarConfiguration != nil {
+		in, out := &in.RecoveryJobSidecarConfiguration, &out.RecoveryJobSidecarConfiguration
+		*out = new(RecoveryJobSidecarConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WorkloadIdentity != nil {
+		in, out := &in.WorkloadIdentity, &out.WorkloadIdentity
+		*out = new(WorkloadIdentityConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectStoreSpec.
@@ -142,6 +254,38 @@ func (in *ObjectStoreStatus) DeepCopyInto(out *ObjectStoreStatus) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SecretsResourceVersion != nil {
+		in, out := &in.SecretsResourceVersion, &out.SecretsResourceVersion
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastSecretsRotationTime != nil {
+		in, out := &in.LastSecretsRotationTime, &out.LastSecretsRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ServerPurges != nil {
+		in, out := &in.ServerPurges, &out.ServerPurges
+		*out = make([]ServerPurge, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ServerOwners != nil {
+		in, out := &in.ServerOwners, &out.ServerOwners
+		*out = make(map[string]ServerOwner, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectStoreStatus.
@@ -154,6 +298,63 @@ func (in *ObjectStoreStatus) DeepCopy() *ObjectStoreStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeConfiguration) DeepCopyInto(out *ProbeConfiguration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeConfiguration.
+func (in *ProbeConfiguration) DeepCopy() *ProbeConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecoveryJobSidecarConfiguration) DeepCopyInto(out *RecoveryJobSidecarConfiguration) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalContainerArgs != nil {
+		in, out := &in.AdditionalContainerArgs, &out.AdditionalContainerArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ContainerTemplate != nil {
+		in, out := &in.ContainerTemplate, &out.ContainerTemplate
+		*out = new(corev1.Container)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SpoolVolume != nil {
+		in, out := &in.SpoolVolume, &out.SpoolVolume
+		*out = new(SpoolVolumeConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecoveryJobSidecarConfiguration.
+func (in *RecoveryJobSidecarConfiguration) DeepCopy() *RecoveryJobSidecarConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(RecoveryJobSidecarConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RecoveryWindow) DeepCopyInto(out *RecoveryWindow) {
 	*out = *in
@@ -180,3 +381,106 @@ func (in *RecoveryWindow) DeepCopy() *RecoveryWindow {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerOwner) DeepCopyInto(out *ServerOwner) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerOwner.
+func (in *ServerOwner) DeepCopy() *ServerOwner {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerOwner)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerPurge) DeepCopyInto(out *ServerPurge) {
+	*out = *in
+	in.ScheduledTime.DeepCopyInto(&out.ScheduledTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerPurge.
+func (in *ServerPurge) DeepCopy() *ServerPurge {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerPurge)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpoolEphemeralVolumeConfiguration) DeepCopyInto(out *SpoolEphemeralVolumeConfiguration) {
+	*out = *in
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	out.Size = in.Size.DeepCopy()
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpoolEphemeralVolumeConfiguration.
+func (in *SpoolEphemeralVolumeConfiguration) DeepCopy() *SpoolEphemeralVolumeConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(SpoolEphemeralVolumeConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SpoolVolumeConfiguration) DeepCopyInto(out *SpoolVolumeConfiguration) {
+	*out = *in
+	if in.EmptyDir != nil {
+		in, out := &in.EmptyDir, &out.EmptyDir
+		*out = new(corev1.EmptyDirVolumeSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Ephemeral != nil {
+		in, out := &in.Ephemeral, &out.Ephemeral
+		*out = new(SpoolEphemeralVolumeConfiguration)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SpoolVolumeConfiguration.
+func (in *SpoolVolumeConfiguration) DeepCopy() *SpoolVolumeConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(SpoolVolumeConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadIdentityConfiguration) DeepCopyInto(out *WorkloadIdentityConfiguration) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadIdentityConfiguration.
+func (in *WorkloadIdentityConfiguration) DeepCopy() *WorkloadIdentityConfiguration {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadIdentityConfiguration)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -29,10 +29,14 @@ import (
 	"github.com/spf13/cobra"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/backupdeletion"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/healthcheck"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/instance"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/maintenance"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/operator"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/probe"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/restore"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cmd/serverpurge"
 )
 
 func main() {
@@ -53,6 +57,10 @@ func main() {
 	rootCmd.AddCommand(operator.NewCmd())
 	rootCmd.AddCommand(restore.NewCmd())
 	rootCmd.AddCommand(healthcheck.NewCmd())
+	rootCmd.AddCommand(maintenance.NewCmd())
+	rootCmd.AddCommand(probe.NewCmd())
+	rootCmd.AddCommand(backupdeletion.NewCmd())
+	rootCmd.AddCommand(serverpurge.NewCmd())
 
 	if err := rootCmd.ExecuteContext(ctrl.SetupSignalHandler()); err != nil {
 		if !errors.Is(err, context.Canceled) {
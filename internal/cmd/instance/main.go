@@ -59,11 +59,18 @@ func NewCmd() *cobra.Command {
 	)
 	_ = viper.BindPFlag("pprof-server", cmd.Flags().Lookup("pprof-server"))
 
+	cmd.Flags().Int64("cache-ttl-seconds",
+		instance.DefaultCacheTTLSeconds,
+		"The TTL of the cached Secrets and object stores, used when they can't be watched",
+	)
+	_ = viper.BindPFlag("cache-ttl-seconds", cmd.Flags().Lookup("cache-ttl-seconds"))
+
 	_ = viper.BindEnv("namespace", "NAMESPACE")
 	_ = viper.BindEnv("cluster-name", "CLUSTER_NAME")
 	_ = viper.BindEnv("pod-name", "POD_NAME")
 	_ = viper.BindEnv("pgdata", "PGDATA")
 	_ = viper.BindEnv("spool-directory", "SPOOL_DIRECTORY")
+	_ = viper.BindEnv("cache-ttl-seconds", "CACHE_TTL_SECONDS")
 	_ = viper.BindEnv("custom-cnpg-group", "CUSTOM_CNPG_GROUP")
 	_ = viper.BindEnv("custom-cnpg-version", "CUSTOM_CNPG_VERSION")
 
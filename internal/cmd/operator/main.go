@@ -66,6 +66,15 @@ func NewCmd() *cobra.Command {
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
 	_ = viper.BindPFlag("enable-http2", cmd.Flags().Lookup("enable-http2"))
 
+	cmd.Flags().Bool("enable-webhooks", false,
+		"If set, the admission webhooks are served. They require a serving certificate in the webhook-cert-dir.")
+	_ = viper.BindPFlag("enable-webhooks", cmd.Flags().Lookup("enable-webhooks"))
+
+	cmd.Flags().String("webhook-cert-dir", "",
+		"The directory containing tls.crt and tls.key for the webhook server. "+
+			"Defaults to /tmp/k8s-webhook-server/serving-certs.")
+	_ = viper.BindPFlag("webhook-cert-dir", cmd.Flags().Lookup("webhook-cert-dir"))
+
 	cmd.Flags().String(
 		"plugin-path",
 		"",
@@ -102,6 +111,10 @@ func NewCmd() *cobra.Command {
 	_ = viper.BindPFlag("server-address", cmd.Flags().Lookup("server-address"))
 
 	_ = viper.BindEnv("sidecar-image", "SIDECAR_IMAGE")
+	_ = viper.BindEnv("sidecar-image-allow-list", "SIDECAR_IMAGE_ALLOW_LIST")
+	_ = viper.BindEnv("server-name-collision-policy", "SERVER_NAME_COLLISION_POLICY")
+	_ = viper.BindEnv("custom-cnpg-group", "CUSTOM_CNPG_GROUP")
+	_ = viper.BindEnv("custom-cnpg-version", "CUSTOM_CNPG_VERSION")
 
 	return cmd
 }
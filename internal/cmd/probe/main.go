@@ -0,0 +1,50 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package probe is the entrypoint of the object store connectivity probe
+package probe
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/probe"
+)
+
+// NewCmd creates the "probe" subcommand
+func NewCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "probe",
+		Short: "Checks the connectivity to an object store",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if len(viper.GetString("object-store-configuration")) == 0 {
+				return fmt.Errorf("missing required object-store-configuration setting")
+			}
+
+			return probe.Start(cmd.Context())
+		},
+	}
+
+	_ = viper.BindEnv("object-store-configuration", "OBJECT_STORE_CONFIGURATION")
+	_ = viper.BindEnv("write-check", "WRITE_CHECK")
+
+	return cmd
+}
@@ -0,0 +1,196 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backupdeletion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+	barmanUtils "github.com/cloudnative-pg/barman-cloud/pkg/utils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/probe"
+)
+
+const (
+	// ReasonOldestBackup is the reason of a refused deletion, because
+	// the backup is the oldest one of the server and removing it would
+	// move the first recoverability point
+	ReasonOldestBackup = "OldestBackup"
+
+	// ReasonBackupDeletionFailed is the reason of a failed deletion
+	ReasonBackupDeletionFailed = "BackupDeletionFailed"
+)
+
+// Start deletes the backup passed in the environment from the object
+// store, recording the reason of a failure in the termination message.
+// The Result is written in the same format used by the probe Job.
+func Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx)
+
+	var configuration barmanapi.BarmanObjectStoreConfiguration
+	if err := json.Unmarshal(
+		[]byte(viper.GetString("object-store-configuration")),
+		&configuration,
+	); err != nil {
+		return fmt.Errorf("while decoding the object store configuration: %w", err)
+	}
+
+	serverName := viper.GetString("server-name")
+	backupID := viper.GetString("backup-id")
+
+	result := Run(ctx, &configuration, serverName, backupID)
+	if result == nil {
+		contextLogger.Info("Backup deleted from the object store",
+			"destinationPath", configuration.DestinationPath,
+			"serverName", serverName,
+			"backupID", backupID)
+		return nil
+	}
+
+	if err := probe.WriteTerminationMessage(probe.TerminationMessagePath, result); err != nil {
+		contextLogger.Error(err, "while writing the termination message")
+	}
+
+	return fmt.Errorf("%s: %s", result.Reason, result.Message)
+}
+
+// Run deletes the backup having the passed ID from the catalog of the
+// passed server. A backup missing from the catalog is considered
+// already deleted. It returns nil if the backup is not in the catalog
+// anymore.
+func Run(
+	ctx context.Context,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	serverName string,
+	backupID string,
+) *probe.Result {
+	contextLogger := log.FromContext(ctx)
+	env := os.Environ()
+
+	backupList, err := barmanCommand.GetBackupList(ctx, configuration, serverName, env)
+	if err != nil {
+		return &probe.Result{
+			Reason:  ReasonBackupDeletionFailed,
+			Message: fmt.Sprintf("while reading the backup catalog: %v", err),
+		}
+	}
+
+	found, result := checkDeletion(backupList, serverName, backupID)
+	if result != nil {
+		return result
+	}
+	if !found {
+		contextLogger.Info("Backup not found in the catalog, nothing to delete",
+			"serverName", serverName,
+			"backupID", backupID)
+		return nil
+	}
+
+	options, err := buildDeleteOptions(ctx, configuration, serverName, backupID)
+	if err != nil {
+		return &probe.Result{Reason: ReasonBackupDeletionFailed, Message: err.Error()}
+	}
+
+	var stderrBuffer bytes.Buffer
+	cmd := exec.CommandContext(ctx, barmanUtils.BarmanCloudBackupDelete, options...) // #nosec G204
+	cmd.Env = env
+	cmd.Stderr = &stderrBuffer
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderrBuffer.String())
+		if len(message) == 0 {
+			message = err.Error()
+		}
+		return &probe.Result{Reason: ReasonBackupDeletionFailed, Message: message}
+	}
+
+	return nil
+}
+
+// checkDeletion checks whether the backup having the passed ID can be
+// removed from the passed catalog. It reports whether the backup is
+// in the catalog, and the Result refusing the deletion, if any.
+func checkDeletion(
+	backupList *barmanCatalog.Catalog,
+	serverName string,
+	backupID string,
+) (bool, *probe.Result) {
+	if !slices.Contains(backupList.GetBackupIDs(), backupID) {
+		return false, nil
+	}
+
+	// The catalog is sorted by time, and the first recoverability
+	// point is the end of the oldest successful backup
+	for _, backup := range backupList.List {
+		if backup.BeginTime.IsZero() || backup.EndTime.IsZero() {
+			continue
+		}
+
+		if backup.ID == backupID {
+			return true, &probe.Result{
+				Reason: ReasonOldestBackup,
+				Message: fmt.Sprintf(
+					"backup %s is the oldest one of the server %s: deleting it "+
+						"would move the first recoverability point",
+					backupID, serverName),
+			}
+		}
+		break
+	}
+
+	return true, nil
+}
+
+// buildDeleteOptions builds the options of barman-cloud-backup-delete
+// removing the backup having the passed ID
+func buildDeleteOptions(
+	ctx context.Context,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	serverName string,
+	backupID string,
+) ([]string, error) {
+	var options []string
+	if len(configuration.EndpointURL) > 0 {
+		options = append(options, "--endpoint-url", configuration.EndpointURL)
+	}
+
+	options, err := barmanCommand.AppendCloudProviderOptionsFromConfiguration(ctx, options, configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(
+		options,
+		"--backup-id",
+		backupID,
+		configuration.DestinationPath,
+		serverName,
+	), nil
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backupdeletion
+
+import (
+	"context"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backup deletion", func() {
+	var backupList *barmanCatalog.Catalog
+
+	BeforeEach(func() {
+		now := time.Now()
+		backupList = barmanCatalog.NewCatalog([]barmanCatalog.BarmanBackup{
+			{
+				ID:        "20250103T000000",
+				BeginTime: now.Add(-time.Hour),
+				EndTime:   now,
+			},
+			{
+				// A failed backup, older than every successful one
+				ID:        "20250101T000000",
+				BeginTime: now.Add(-72 * time.Hour),
+			},
+			{
+				ID:        "20250102T000000",
+				BeginTime: now.Add(-48 * time.Hour),
+				EndTime:   now.Add(-47 * time.Hour),
+			},
+		})
+	})
+
+	It("considers the backups missing from the catalog already deleted", func() {
+		found, result := checkDeletion(backupList, "cluster-example", "20241231T000000")
+		Expect(found).To(BeFalse())
+		Expect(result).To(BeNil())
+	})
+
+	It("allows deleting the backups after the oldest successful one", func() {
+		found, result := checkDeletion(backupList, "cluster-example", "20250103T000000")
+		Expect(found).To(BeTrue())
+		Expect(result).To(BeNil())
+	})
+
+	It("allows deleting the failed backups", func() {
+		found, result := checkDeletion(backupList, "cluster-example", "20250101T000000")
+		Expect(found).To(BeTrue())
+		Expect(result).To(BeNil())
+	})
+
+	It("refuses to delete the oldest successful backup", func() {
+		found, result := checkDeletion(backupList, "cluster-example", "20250102T000000")
+		Expect(found).To(BeTrue())
+		Expect(result).ToNot(BeNil())
+		Expect(result.Reason).To(Equal(ReasonOldestBackup))
+		Expect(result.Message).To(ContainSubstring("20250102T000000"))
+		Expect(result.Message).To(ContainSubstring("cluster-example"))
+	})
+
+	It("passes the backup ID to barman-cloud-backup-delete", func() {
+		configuration := &barmanapi.BarmanObjectStoreConfiguration{
+			DestinationPath: "s3://bucket/path",
+			EndpointURL:     "https://s3.example.com",
+			BarmanCredentials: barmanapi.BarmanCredentials{
+				AWS: &barmanapi.S3Credentials{InheritFromIAMRole: true},
+			},
+		}
+
+		options, err := buildDeleteOptions(
+			context.Background(),
+			configuration,
+			"cluster-example",
+			"20250103T000000",
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(options).To(HaveExactElements(
+			"--endpoint-url", "https://s3.example.com",
+			"--cloud-provider", "aws-s3",
+			"--backup-id", "20250103T000000",
+			"s3://bucket/path", "cluster-example",
+		))
+	})
+})
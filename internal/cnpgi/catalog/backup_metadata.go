@@ -0,0 +1,158 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// BackupResultMetadata is the metadata the plugin attaches to the
+// Backup objects it creates, stored in the PluginMetadata field
+type BackupResultMetadata struct {
+	timeline    string
+	version     string
+	name        string
+	displayName string
+	clusterUID  string
+	pluginName  string
+
+	// the location where the backup has been stored. Empty for
+	// the backups taken by older versions of the plugin.
+	objectStoreName string
+	destinationPath string
+	endpointURL     string
+	serverName      string
+}
+
+// ToMap converts the metadata into the map stored in the Backup status
+func (b BackupResultMetadata) ToMap() map[string]string {
+	return map[string]string{
+		"timeline":    b.timeline,
+		"version":     b.version,
+		"name":        b.name,
+		"displayName": b.displayName,
+		"clusterUID":  b.clusterUID,
+		"pluginName":  b.pluginName,
+
+		"objectStoreName": b.objectStoreName,
+		"destinationPath": b.destinationPath,
+		"endpointURL":     b.endpointURL,
+		"serverName":      b.serverName,
+	}
+}
+
+// NewBackupResultMetadata creates the metadata of a backup taken
+// for the Cluster having the passed UID, and stored in the passed
+// ObjectStore using the passed server name
+func NewBackupResultMetadata(
+	clusterUID types.UID,
+	timeline int,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+) BackupResultMetadata {
+	return BackupResultMetadata{
+		timeline:        strconv.Itoa(timeline),
+		clusterUID:      string(clusterUID),
+		objectStoreName: objectStore.Name,
+		destinationPath: objectStore.Spec.Configuration.DestinationPath,
+		endpointURL:     objectStore.Spec.Configuration.EndpointURL,
+		serverName:      serverName,
+		// static values
+		version:     metadata.Data.Version,
+		name:        metadata.Data.Name,
+		displayName: metadata.Data.DisplayName,
+		pluginName:  metadata.PluginName,
+	}
+}
+
+// NewBackupResultMetadataFromMap parses the metadata stored in the
+// Backup status
+func NewBackupResultMetadataFromMap(m map[string]string) BackupResultMetadata {
+	if m == nil {
+		return BackupResultMetadata{}
+	}
+
+	return BackupResultMetadata{
+		timeline:    m["timeline"],
+		version:     m["version"],
+		name:        m["name"],
+		displayName: m["displayName"],
+		clusterUID:  m["clusterUID"],
+		pluginName:  m["pluginName"],
+
+		objectStoreName: m["objectStoreName"],
+		destinationPath: m["destinationPath"],
+		endpointURL:     m["endpointURL"],
+		serverName:      m["serverName"],
+	}
+}
+
+// GetObjectStoreName gets the name of the object store where the
+// backup has been stored, empty for the backups taken by older
+// versions of the plugin
+func (b BackupResultMetadata) GetObjectStoreName() string {
+	return b.objectStoreName
+}
+
+// GetBackupLocation gets the URL of the directory holding the backup
+// having the passed ID inside the object store, or an empty string
+// when it is not known
+func (b BackupResultMetadata) GetBackupLocation(backupID string) string {
+	if len(b.destinationPath) == 0 || len(b.serverName) == 0 || len(backupID) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s/%s/base/%s", strings.TrimSuffix(b.destinationPath, "/"), b.serverName, backupID)
+}
+
+// GetServerName gets the server name used to store the backup, empty
+// for the backups taken by older versions of the plugin
+func (b BackupResultMetadata) GetServerName() string {
+	return b.serverName
+}
+
+// IsLocatedIn checks whether the backup has been stored in the
+// destination of the passed ObjectStore. The backups not recording
+// their location are never located in any ObjectStore.
+func (b BackupResultMetadata) IsLocatedIn(objectStore *barmancloudv1.ObjectStore) bool {
+	return len(b.objectStoreName) > 0 &&
+		b.objectStoreName == objectStore.Name &&
+		b.destinationPath == objectStore.Spec.Configuration.DestinationPath &&
+		b.endpointURL == objectStore.Spec.Configuration.EndpointURL
+}
+
+// isStoredIn checks whether the backup has been stored in the passed
+// ObjectStore using the passed server name. The backups taken by
+// older versions of the plugin don't record their location: they are
+// considered stored in any ObjectStore.
+func (b BackupResultMetadata) isStoredIn(objectStore *barmancloudv1.ObjectStore, serverName string) bool {
+	if len(b.objectStoreName) == 0 {
+		return true
+	}
+
+	return b.IsLocatedIn(objectStore) && b.serverName == serverName
+}
@@ -0,0 +1,182 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// importBackupsFromCatalog creates a Backup object for every backup
+// in the catalog that is not known to Kubernetes yet.
+// The Backup objects are created with the reconciliation loop
+// disabled, so that the operator never tries to execute them, and
+// then marked as completed.
+func importBackupsFromCatalog(
+	ctx context.Context,
+	cli client.Client,
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+	backupList *barmanCatalog.Catalog,
+) error {
+	contextLogger := log.FromContext(ctx)
+	contextLogger.Debug("Checking the catalog to import backups missing in Kubernetes")
+
+	backups := cnpgv1.BackupList{}
+	if err := cli.List(ctx, &backups, client.InNamespace(cluster.GetNamespace())); err != nil {
+		return fmt.Errorf("while getting backups: %w", err)
+	}
+
+	knownBackupIDs := make(map[string]struct{}, len(backups.Items))
+	for _, backup := range backups.Items {
+		if backup.Spec.Cluster.Name != cluster.GetName() || len(backup.Status.BackupID) == 0 {
+			continue
+		}
+		knownBackupIDs[backup.Status.BackupID] = struct{}{}
+	}
+
+	var errors []error
+	for idx := range backupList.List {
+		barmanBackup := &backupList.List[idx]
+		if _, ok := knownBackupIDs[barmanBackup.ID]; ok {
+			continue
+		}
+
+		// Skip the backups that didn't complete
+		if barmanBackup.BeginTime.IsZero() || barmanBackup.EndTime.IsZero() {
+			continue
+		}
+
+		backup := buildImportedBackup(cluster, objectStore, serverName, barmanBackup)
+		contextLogger.Info("Importing backup from the catalog",
+			"backup", backup.Name, "backupID", barmanBackup.ID)
+		if err := createImportedBackup(ctx, cli, backup); err != nil {
+			errors = append(errors, fmt.Errorf(
+				"while importing backup %s: %w",
+				barmanBackup.ID,
+				err,
+			))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("got errors while importing Backups from the catalog: %v", errors)
+	}
+
+	return nil
+}
+
+// createImportedBackup creates the passed Backup and then sets its
+// status, which the API server ignores on creation. A Backup left
+// without a status by a previous attempt gets it set now.
+func createImportedBackup(ctx context.Context, cli client.Client, backup *cnpgv1.Backup) error {
+	status := backup.Status
+	err := cli.Create(ctx, backup)
+	if apierrs.IsAlreadyExists(err) {
+		err = cli.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+		if err == nil && (len(backup.Status.Phase) > 0 ||
+			backup.Annotations[utils.ReconciliationLoopAnnotationName] != "disabled") {
+			log.FromContext(ctx).Info(
+				"Skipping backup import, a Backup with the same name already exists",
+				"backup", backup.Name, "backupID", status.BackupID)
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	backup.Status = status
+	return cli.Status().Update(ctx, backup)
+}
+
+// buildImportedBackup builds the Backup object representing the
+// passed catalog entry
+func buildImportedBackup(
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+	barmanBackup *barmanCatalog.BarmanBackup,
+) *cnpgv1.Backup {
+	return &cnpgv1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      getImportedBackupName(cluster, barmanBackup),
+			Annotations: map[string]string{
+				utils.ReconciliationLoopAnnotationName: "disabled",
+			},
+		},
+		Spec: cnpgv1.BackupSpec{
+			Cluster: cnpgv1.LocalObjectReference{
+				Name: cluster.Name,
+			},
+			Method: cnpgv1.BackupMethodPlugin,
+			PluginConfiguration: &cnpgv1.BackupPluginConfiguration{
+				Name: metadata.PluginName,
+			},
+		},
+		Status: cnpgv1.BackupStatus{
+			Phase:      cnpgv1.BackupPhaseCompleted,
+			Method:     cnpgv1.BackupMethodPlugin,
+			BackupID:   barmanBackup.ID,
+			BackupName: barmanBackup.BackupName,
+			StartedAt:  ptr.To(metav1.NewTime(barmanBackup.BeginTime)),
+			StoppedAt:  ptr.To(metav1.NewTime(barmanBackup.EndTime)),
+			BeginWal:   barmanBackup.BeginWal,
+			EndWal:     barmanBackup.EndWal,
+			BeginLSN:   barmanBackup.BeginLSN,
+			EndLSN:     barmanBackup.EndLSN,
+			Online:     ptr.To(true),
+			PluginMetadata: NewBackupResultMetadata(
+				cluster.UID,
+				barmanBackup.TimeLine,
+				objectStore,
+				serverName,
+			).ToMap(),
+		},
+	}
+}
+
+// getImportedBackupName gets the name of the Backup object for the
+// passed catalog entry. The original backup name is used when
+// available, as it is the name of the Backup object that took it.
+func getImportedBackupName(cluster *cnpgv1.Cluster, barmanBackup *barmanCatalog.BarmanBackup) string {
+	if len(barmanBackup.BackupName) > 0 &&
+		len(validation.IsDNS1123Subdomain(barmanBackup.BackupName)) == 0 {
+		return barmanBackup.BackupName
+	}
+
+	return fmt.Sprintf("%s-%s", cluster.Name, strings.ToLower(barmanBackup.ID))
+}
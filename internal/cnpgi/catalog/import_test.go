@@ -0,0 +1,184 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("importBackupsFromCatalog", func() {
+	var (
+		ctx         context.Context
+		cluster     *cnpgv1.Cluster
+		objectStore *barmancloudv1.ObjectStore
+		backupList  *barmanCatalog.Catalog
+		beginTime   time.Time
+		endTime     time.Time
+	)
+
+	newClient := func(objs ...client.Object) client.Client {
+		s := runtime.NewScheme()
+		utilruntime.Must(cnpgv1.AddToScheme(s))
+		return fake.NewClientBuilder().
+			WithScheme(s).
+			WithObjects(objs...).
+			WithStatusSubresource(&cnpgv1.Backup{}).
+			Build()
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		beginTime = time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+		endTime = beginTime.Add(time.Hour)
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster-example",
+				Namespace: "default",
+				UID:       "cluster-uid",
+			},
+		}
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+				},
+			},
+		}
+		backupList = &barmanCatalog.Catalog{
+			List: []barmanCatalog.BarmanBackup{
+				{
+					ID:         "20250101T100000",
+					BackupName: "backup-example",
+					BeginTime:  beginTime,
+					EndTime:    endTime,
+					BeginWal:   "000000010000000000000002",
+					EndWal:     "000000010000000000000003",
+					BeginLSN:   "0/2000028",
+					EndLSN:     "0/3000000",
+					TimeLine:   1,
+				},
+				{
+					ID:        "20250102T100000",
+					BeginTime: beginTime.Add(24 * time.Hour),
+					EndTime:   endTime.Add(24 * time.Hour),
+					TimeLine:  1,
+				},
+				{
+					ID:        "20250103T100000",
+					BeginTime: beginTime.Add(48 * time.Hour),
+					TimeLine:  1,
+				},
+			},
+		}
+	})
+
+	It("creates completed Backups for the catalog entries", func() {
+		cli := newClient()
+		Expect(importBackupsFromCatalog(ctx, cli, cluster, objectStore, "cluster-example", backupList)).To(Succeed())
+
+		var backups cnpgv1.BackupList
+		Expect(cli.List(ctx, &backups)).To(Succeed())
+		Expect(backups.Items).To(HaveLen(2))
+
+		var backup cnpgv1.Backup
+		Expect(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "backup-example"}, &backup)).To(Succeed())
+		Expect(utils.IsReconciliationDisabled(&backup.ObjectMeta)).To(BeTrue())
+		Expect(backup.Spec.Cluster.Name).To(Equal("cluster-example"))
+		Expect(backup.Spec.Method).To(Equal(cnpgv1.BackupMethodPlugin))
+		Expect(backup.Spec.PluginConfiguration.Name).To(Equal(metadata.PluginName))
+		Expect(backup.Status.Phase).To(BeEquivalentTo(cnpgv1.BackupPhaseCompleted))
+		Expect(backup.Status.BackupID).To(Equal("20250101T100000"))
+		Expect(backup.Status.StartedAt.Time).To(BeTemporally("==", beginTime))
+		Expect(backup.Status.StoppedAt.Time).To(BeTemporally("==", endTime))
+		Expect(backup.Status.BeginWal).To(Equal("000000010000000000000002"))
+		Expect(backup.Status.EndLSN).To(Equal("0/3000000"))
+		Expect(useSameBackupLocation(&backup.Status, cluster, objectStore, "cluster-example")).To(BeTrue())
+
+		Expect(backup.Status.PluginMetadata).To(HaveKeyWithValue("objectStoreName", "my-store"))
+		Expect(backup.Status.PluginMetadata).To(HaveKeyWithValue("destinationPath", "s3://bucket/path"))
+		Expect(backup.Status.PluginMetadata).To(HaveKeyWithValue("serverName", "cluster-example"))
+
+		Expect(cli.Get(ctx, client.ObjectKey{
+			Namespace: "default",
+			Name:      "cluster-example-20250102t100000",
+		}, &backup)).To(Succeed())
+		Expect(backup.Status.BackupID).To(Equal("20250102T100000"))
+	})
+
+	It("skips the backups already known to Kubernetes", func() {
+		existing := &cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "scheduled-backup-1"},
+			Spec: cnpgv1.BackupSpec{
+				Cluster: cnpgv1.LocalObjectReference{Name: "cluster-example"},
+			},
+			Status: cnpgv1.BackupStatus{BackupID: "20250101T100000"},
+		}
+		cli := newClient(existing)
+		Expect(importBackupsFromCatalog(ctx, cli, cluster, objectStore, "cluster-example", backupList)).To(Succeed())
+
+		var backups cnpgv1.BackupList
+		Expect(cli.List(ctx, &backups)).To(Succeed())
+		Expect(backups.Items).To(HaveLen(2))
+		Expect(backups.Items).To(ContainElement(HaveField("Name", "cluster-example-20250102t100000")))
+		Expect(backups.Items).NotTo(ContainElement(HaveField("Name", "backup-example")))
+	})
+
+	It("sets the status of a Backup left without it by a previous import", func() {
+		leftover := &cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "backup-example",
+				Annotations: map[string]string{
+					utils.ReconciliationLoopAnnotationName: "disabled",
+				},
+			},
+			Spec: cnpgv1.BackupSpec{
+				Cluster: cnpgv1.LocalObjectReference{Name: "cluster-example"},
+			},
+		}
+		cli := newClient(leftover)
+		Expect(importBackupsFromCatalog(ctx, cli, cluster, objectStore, "cluster-example", backupList)).To(Succeed())
+
+		var backup cnpgv1.Backup
+		Expect(cli.Get(ctx, client.ObjectKey{Namespace: "default", Name: "backup-example"}, &backup)).To(Succeed())
+		Expect(backup.Status.Phase).To(BeEquivalentTo(cnpgv1.BackupPhaseCompleted))
+		Expect(backup.Status.BackupID).To(Equal("20250101T100000"))
+	})
+})
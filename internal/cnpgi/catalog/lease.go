@@ -0,0 +1,119 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AcquireLease tries to acquire (or renew) the catalog maintenance
+// Lease identified by the passed key on behalf of holder, for the
+// passed duration.
+// The Lease is acquired when it is not held, when it is already held
+// by holder, when the current holder failed to renew it in time or
+// when canPreempt, if not nil, allows taking it over from the current
+// holder.
+// Returns true if the Lease has been acquired. A concurrent update
+// of the Lease is not considered an error: the Lease is just not acquired.
+func AcquireLease(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	holder string,
+	duration time.Duration,
+	canPreempt func(currentHolder string) bool,
+) (bool, error) {
+	var lease coordinationv1.Lease
+	if err := c.Get(ctx, key, &lease); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	currentHolder := ptr.Deref(lease.Spec.HolderIdentity, "")
+	if currentHolder != "" && currentHolder != holder && !IsLeaseExpired(&lease, now) &&
+		(canPreempt == nil || !canPreempt(currentHolder)) {
+		return false, nil
+	}
+
+	if currentHolder != holder {
+		lease.Spec.HolderIdentity = ptr.To(holder)
+		lease.Spec.AcquireTime = &metav1.MicroTime{Time: now}
+	}
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: now}
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(duration.Seconds()))
+
+	if err := c.Update(ctx, &lease); err != nil {
+		if apierrs.IsConflict(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReleaseLease releases the catalog maintenance Lease identified by
+// the passed key, if it is held by holder. The renew time is set to
+// the current time, and records when the last maintenance cycle ended.
+func ReleaseLease(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	holder string,
+) error {
+	var lease coordinationv1.Lease
+	if err := c.Get(ctx, key, &lease); err != nil {
+		return err
+	}
+
+	if ptr.Deref(lease.Spec.HolderIdentity, "") != holder {
+		return nil
+	}
+
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+
+	return c.Update(ctx, &lease)
+}
+
+// IsLeaseExpired checks whether the holder of the passed Lease
+// failed to renew it in time
+func IsLeaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+
+	expiration := lease.Spec.RenewTime.Add(
+		time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.After(expiration)
+}
+
+// IsLeaseHeld checks whether the passed Lease is currently held
+func IsLeaseHeld(lease *coordinationv1.Lease, now time.Time) bool {
+	return ptr.Deref(lease.Spec.HolderIdentity, "") != "" && !IsLeaseExpired(lease, now)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newLeaseScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(coordinationv1.AddToScheme(s))
+	return s
+}
+
+var _ = Describe("AcquireLease", func() {
+	var (
+		ctx        context.Context
+		fakeClient client.Client
+		leaseKey   client.ObjectKey
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		leaseKey = client.ObjectKey{Namespace: "default", Name: "cluster-example-barman-cloud-maintenance"}
+	})
+
+	getLease := func() *coordinationv1.Lease {
+		var lease coordinationv1.Lease
+		Expect(fakeClient.Get(ctx, leaseKey, &lease)).To(Succeed())
+		return &lease
+	}
+
+	newLease := func(holder string, renewTime time.Time, duration time.Duration) *coordinationv1.Lease {
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: leaseKey.Namespace,
+				Name:      leaseKey.Name,
+			},
+		}
+		if holder != "" {
+			lease.Spec.HolderIdentity = ptr.To(holder)
+			lease.Spec.RenewTime = &metav1.MicroTime{Time: renewTime}
+			lease.Spec.LeaseDurationSeconds = ptr.To(int32(duration.Seconds()))
+		}
+		return lease
+	}
+
+	It("acquires a lease that is not held", func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+			WithObjects(newLease("", time.Time{}, 0)).Build()
+
+		acquired, err := AcquireLease(ctx, fakeClient, leaseKey, "cluster-example-1", time.Minute, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+
+		lease := getLease()
+		Expect(lease.Spec.HolderIdentity).To(HaveValue(Equal("cluster-example-1")))
+		Expect(lease.Spec.LeaseDurationSeconds).To(HaveValue(BeEquivalentTo(60)))
+		Expect(lease.Spec.AcquireTime).NotTo(BeNil())
+		Expect(lease.Spec.RenewTime).NotTo(BeNil())
+	})
+
+	It("renews a lease held by the same holder", func() {
+		renewTime := time.Now().Add(-30 * time.Second)
+		fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+			WithObjects(newLease("cluster-example-1", renewTime, time.Minute)).Build()
+
+		acquired, err := AcquireLease(ctx, fakeClient, leaseKey, "cluster-example-1", time.Minute, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(getLease().Spec.RenewTime.Time).To(BeTemporally(">", renewTime))
+	})
+
+	It("does not acquire a lease held by someone else", func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+			WithObjects(newLease("cluster-example-1", time.Now(), time.Minute)).Build()
+
+		acquired, err := AcquireLease(ctx, fakeClient, leaseKey, "cluster-example-2", time.Minute, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeFalse())
+		Expect(getLease().Spec.HolderIdentity).To(HaveValue(Equal("cluster-example-1")))
+	})
+
+	It("acquires an expired lease held by someone else", func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+			WithObjects(newLease("cluster-example-1", time.Now().Add(-2*time.Minute), time.Minute)).Build()
+
+		acquired, err := AcquireLease(ctx, fakeClient, leaseKey, "cluster-example-2", time.Minute, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(getLease().Spec.HolderIdentity).To(HaveValue(Equal("cluster-example-2")))
+	})
+
+	It("preempts a lease when allowed", func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+			WithObjects(newLease("cluster-example-1", time.Now(), time.Minute)).Build()
+
+		canPreempt := func(currentHolder string) bool {
+			return currentHolder == "cluster-example-1"
+		}
+		acquired, err := AcquireLease(ctx, fakeClient, leaseKey, "cluster-example-2", time.Minute, canPreempt)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acquired).To(BeTrue())
+		Expect(getLease().Spec.HolderIdentity).To(HaveValue(Equal("cluster-example-2")))
+	})
+
+	It("returns NotFound when the lease does not exist", func() {
+		fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).Build()
+
+		acquired, err := AcquireLease(ctx, fakeClient, leaseKey, "cluster-example-1", time.Minute, nil)
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		Expect(acquired).To(BeFalse())
+	})
+
+	Describe("ReleaseLease", func() {
+		It("releases a lease held by the holder, recording the renew time", func() {
+			renewTime := time.Now().Add(-30 * time.Second)
+			fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+				WithObjects(newLease("cluster-example-1", renewTime, time.Minute)).Build()
+
+			Expect(ReleaseLease(ctx, fakeClient, leaseKey, "cluster-example-1")).To(Succeed())
+
+			lease := getLease()
+			Expect(lease.Spec.HolderIdentity).To(BeNil())
+			Expect(lease.Spec.RenewTime.Time).To(BeTemporally(">", renewTime))
+			Expect(IsLeaseHeld(lease, time.Now())).To(BeFalse())
+		})
+
+		It("does not release a lease held by someone else", func() {
+			fakeClient = fake.NewClientBuilder().WithScheme(newLeaseScheme()).
+				WithObjects(newLease("cluster-example-1", time.Now(), time.Minute)).Build()
+
+			Expect(ReleaseLease(ctx, fakeClient, leaseKey, "cluster-example-2")).To(Succeed())
+			Expect(getLease().Spec.HolderIdentity).To(HaveValue(Equal("cluster-example-1")))
+		})
+	})
+})
+
+var _ = Describe("IsLeaseExpired", func() {
+	It("considers a lease never renewed as expired", func() {
+		Expect(IsLeaseExpired(&coordinationv1.Lease{}, time.Now())).To(BeTrue())
+	})
+
+	It("checks the renew time against the lease duration", func() {
+		now := time.Now()
+		lease := &coordinationv1.Lease{
+			Spec: coordinationv1.LeaseSpec{
+				RenewTime:            &metav1.MicroTime{Time: now.Add(-30 * time.Second)},
+				LeaseDurationSeconds: ptr.To(int32(60)),
+			},
+		}
+		Expect(IsLeaseExpired(lease, now)).To(BeFalse())
+		Expect(IsLeaseExpired(lease, now.Add(time.Minute))).To(BeTrue())
+	})
+})
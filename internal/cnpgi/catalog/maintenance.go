@@ -0,0 +1,235 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+	barmanCredentials "github.com/cloudnative-pg/barman-cloud/pkg/credentials"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+)
+
+// Maintain executes a collection of operations:
+//
+// - applies the retention policy to the object. When the WAL archive
+// has a dedicated destination, the WAL files preceding the oldest
+// retained backup are removed from there.
+//
+// - store and deletes the stale Kubernetes backup objects.
+//
+// - creates the Kubernetes backup objects missing from the catalog,
+// when requested.
+//
+// - updates the first recoverability point.
+//
+// The caller is responsible for ensuring that only one maintenance
+// cycle is running for the passed cluster.
+func Maintain(
+	ctx context.Context,
+	c client.Client,
+	recorder record.EventRecorder,
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+) error {
+	contextLogger := log.FromContext(ctx)
+	configuration := config.NewFromCluster(cluster)
+	retentionPolicy := objectStore.Spec.RetentionPolicy
+
+	env, err := barmanCredentials.EnvSetCloudCredentialsAndCertificates(
+		ctx,
+		c,
+		objectStore.Namespace,
+		&objectStore.Spec.Configuration,
+		os.Environ(),
+		common.BuildCertificateFilePath(objectStore.Name),
+	)
+	if err != nil {
+		contextLogger.Error(err, "while setting backup cloud credentials")
+		return err
+	}
+
+	if len(retentionPolicy) == 0 {
+		contextLogger.Info("Skipping retention policy enforcement, no retention policy specified")
+	} else {
+		contextLogger.Info("Applying backup retention policy",
+			"retentionPolicy", retentionPolicy)
+
+		if err := barmanCommand.DeleteBackupsByPolicy(
+			ctx,
+			&objectStore.Spec.Configuration,
+			configuration.ServerName,
+			env,
+			retentionPolicy,
+		); err != nil {
+			contextLogger.Error(err, "while enforcing retention policies")
+			recorder.Event(cluster, "Warning", "RetentionPolicyFailed", "Retention policy failed")
+			return err
+		}
+	}
+
+	backupList, err := barmanCommand.GetBackupList(
+		ctx,
+		&objectStore.Spec.Configuration,
+		configuration.ServerName,
+		env,
+	)
+	if err != nil {
+		contextLogger.Error(err, "while reading the backup list")
+		return err
+	}
+
+	// barman-cloud-backup-delete only removes the WAL files stored
+	// together with the backups
+	if len(retentionPolicy) > 0 && configuration.GetWALBarmanObjectKey() != configuration.GetBarmanObjectKey() {
+		if err := applyWALRetention(ctx, c, configuration, backupList); err != nil {
+			contextLogger.Error(err, "while enforcing the retention policy on the WAL archive")
+			recorder.Event(cluster, "Warning", "RetentionPolicyFailed", "Retention policy failed on the WAL archive")
+			return err
+		}
+	}
+
+	if err := deleteBackupsNotInCatalog(
+		ctx,
+		c,
+		cluster,
+		objectStore,
+		configuration.ServerName,
+		backupList.GetBackupIDs(),
+	); err != nil {
+		contextLogger.Error(err, "while deleting Backups not present in the catalog")
+		return err
+	}
+
+	if configuration.ImportBackups {
+		if err := importBackupsFromCatalog(
+			ctx,
+			c,
+			cluster,
+			objectStore,
+			configuration.ServerName,
+			backupList,
+		); err != nil {
+			contextLogger.Error(err, "while importing Backups from the catalog")
+			return err
+		}
+	}
+
+	return UpdateRecoveryWindow(ctx, c, backupList, configuration.GetBarmanObjectKey(), configuration.ServerName)
+}
+
+// deleteBackupsNotInCatalog deletes all Backup objects pointing to the given cluster that are not
+// present in the backup anymore. Only the Backups stored in the given ObjectStore and server name
+// are considered.
+func deleteBackupsNotInCatalog(
+	ctx context.Context,
+	cli client.Client,
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+	backupIDs []string,
+) error {
+	// We had two options:
+	//
+	// A. quicker
+	// get policy checker function
+	// get all backups in the namespace for this cluster
+	// check with policy checker function if backup should be deleted, then delete it if true
+	//
+	// B. more precise
+	// get the catalog (GetBackupList)
+	// get all backups in the namespace for this cluster
+	// go through all backups and delete them if not in the catalog
+	//
+	// 1: all backups in the bucket should be also in the cluster
+	// 2: all backups in the cluster should be in the bucket
+	//
+	// A can violate 1 and 2
+	// A + B can still violate 2
+	// B satisfies 1 and 2
+	//
+	// We chose to go with B
+
+	contextLogger := log.FromContext(ctx)
+	contextLogger.Debug("Checking the catalog to delete backups not present anymore")
+
+	backups := cnpgv1.BackupList{}
+	if err := cli.List(ctx, &backups, client.InNamespace(cluster.GetNamespace())); err != nil {
+		return fmt.Errorf("while getting backups: %w", err)
+	}
+
+	var errors []error
+	for id, backup := range backups.Items {
+		if backup.Spec.Cluster.Name != cluster.GetName() ||
+			backup.Status.Phase != cnpgv1.BackupPhaseCompleted ||
+			!useSameBackupLocation(&backup.Status, cluster, objectStore, serverName) {
+			continue
+		}
+
+		// here we could add further checks, e.g. if the backup is not found but would still
+		// be in the retention policy we could either not delete it or update it is status
+		if !slices.Contains(backupIDs, backup.Status.BackupID) {
+			contextLogger.Info("Deleting backup not in the catalog", "backup", backup.Name)
+			if err := cli.Delete(ctx, &backups.Items[id]); err != nil {
+				errors = append(errors, fmt.Errorf(
+					"while deleting backup %s/%s: %w",
+					backup.Namespace,
+					backup.Name,
+					err,
+				))
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("got errors while deleting Backups not in the cluster: %v", errors)
+	}
+
+	return nil
+}
+
+// useSameBackupLocation checks whether the given backup was taken by this plugin for the given
+// cluster, and stored in the given ObjectStore using the given server name
+func useSameBackupLocation(
+	backup *cnpgv1.BackupStatus,
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+) bool {
+	if backup.Method != cnpgv1.BackupMethodPlugin {
+		return false
+	}
+
+	meta := NewBackupResultMetadataFromMap(backup.PluginMetadata)
+	return meta.clusterUID == string(cluster.UID) &&
+		meta.pluginName == metadata.PluginName &&
+		meta.isStoredIn(objectStore, serverName)
+}
@@ -0,0 +1,155 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+func newTestObjectStore(name, destinationPath string) *barmancloudv1.ObjectStore {
+	return &barmancloudv1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: barmancloudv1.ObjectStoreSpec{
+			Configuration: barmanapi.BarmanObjectStoreConfiguration{
+				DestinationPath: destinationPath,
+			},
+		},
+	}
+}
+
+var _ = Describe("useSameBackupLocation", func() {
+	var (
+		cluster     *cnpgv1.Cluster
+		objectStore *barmancloudv1.ObjectStore
+	)
+
+	BeforeEach(func() {
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster-example",
+				Namespace: "default",
+				UID:       "cluster-uid",
+			},
+		}
+		objectStore = newTestObjectStore("my-store", "s3://bucket/path")
+	})
+
+	newStatus := func(pluginMetadata map[string]string) *cnpgv1.BackupStatus {
+		return &cnpgv1.BackupStatus{
+			Method:         cnpgv1.BackupMethodPlugin,
+			PluginMetadata: pluginMetadata,
+		}
+	}
+
+	It("matches the backups stored in the same location", func() {
+		status := newStatus(NewBackupResultMetadata(cluster.UID, 1, objectStore, "cluster-example").ToMap())
+		Expect(useSameBackupLocation(status, cluster, objectStore, "cluster-example")).To(BeTrue())
+	})
+
+	It("does not match the backups stored in another ObjectStore", func() {
+		oldStore := newTestObjectStore("old-store", "s3://old-bucket/path")
+		status := newStatus(NewBackupResultMetadata(cluster.UID, 1, oldStore, "cluster-example").ToMap())
+		Expect(useSameBackupLocation(status, cluster, objectStore, "cluster-example")).To(BeFalse())
+	})
+
+	It("does not match the backups stored in another destination path of the same ObjectStore", func() {
+		oldStore := newTestObjectStore("my-store", "s3://old-bucket/path")
+		status := newStatus(NewBackupResultMetadata(cluster.UID, 1, oldStore, "cluster-example").ToMap())
+		Expect(useSameBackupLocation(status, cluster, objectStore, "cluster-example")).To(BeFalse())
+	})
+
+	It("does not match the backups stored with another server name", func() {
+		status := newStatus(NewBackupResultMetadata(cluster.UID, 1, objectStore, "old-server").ToMap())
+		Expect(useSameBackupLocation(status, cluster, objectStore, "cluster-example")).To(BeFalse())
+	})
+
+	It("matches the backups not recording their location", func() {
+		status := newStatus(map[string]string{
+			"clusterUID": string(cluster.UID),
+			"pluginName": metadata.PluginName,
+		})
+		Expect(useSameBackupLocation(status, cluster, objectStore, "cluster-example")).To(BeTrue())
+	})
+
+	It("does not match the backups of another cluster", func() {
+		status := newStatus(NewBackupResultMetadata("another-uid", 1, objectStore, "cluster-example").ToMap())
+		Expect(useSameBackupLocation(status, cluster, objectStore, "cluster-example")).To(BeFalse())
+	})
+})
+
+var _ = Describe("deleteBackupsNotInCatalog", func() {
+	It("keeps the Backups stored in another ObjectStore", func() {
+		ctx := context.Background()
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster-example",
+				Namespace: "default",
+				UID:       "cluster-uid",
+			},
+		}
+		objectStore := newTestObjectStore("new-store", "s3://new-bucket/path")
+		oldStore := newTestObjectStore("old-store", "s3://old-bucket/path")
+
+		newBackup := func(name string, store *barmancloudv1.ObjectStore) *cnpgv1.Backup {
+			return &cnpgv1.Backup{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: cnpgv1.BackupSpec{
+					Cluster: cnpgv1.LocalObjectReference{Name: "cluster-example"},
+				},
+				Status: cnpgv1.BackupStatus{
+					Phase:    cnpgv1.BackupPhaseCompleted,
+					Method:   cnpgv1.BackupMethodPlugin,
+					BackupID: name,
+					PluginMetadata: NewBackupResultMetadata(
+						cluster.UID, 1, store, "cluster-example").ToMap(),
+				},
+			}
+		}
+
+		s := runtime.NewScheme()
+		utilruntime.Must(cnpgv1.AddToScheme(s))
+		cli := fake.NewClientBuilder().WithScheme(s).WithObjects(
+			newBackup("old-backup", oldStore),
+			newBackup("new-backup", objectStore),
+		).Build()
+
+		Expect(deleteBackupsNotInCatalog(ctx, cli, cluster, objectStore, "cluster-example", nil)).To(Succeed())
+
+		var backups cnpgv1.BackupList
+		Expect(cli.List(ctx, &backups)).To(Succeed())
+		Expect(backups.Items).To(HaveLen(1))
+		Expect(backups.Items[0].Name).To(Equal("old-backup"))
+	})
+})
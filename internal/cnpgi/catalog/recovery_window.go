@@ -0,0 +1,82 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"time"
+
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+)
+
+// UpdateRecoveryWindow updates the recovery window inside the status
+// subresource of the object store having the passed key
+func UpdateRecoveryWindow(
+	ctx context.Context,
+	c client.Client,
+	backupList *barmanCatalog.Catalog,
+	objectStoreKey client.ObjectKey,
+	serverName string,
+) error {
+	// Set the recovery window inside the barman object store object
+	convertTime := func(t *time.Time) *metav1.Time {
+		if t == nil {
+			return nil
+		}
+		return ptr.To(metav1.NewTime(*t))
+	}
+
+	return common.UpdateObjectStoreStatus(ctx, c, objectStoreKey, func(status *barmancloudv1.ObjectStoreStatus) {
+		recoveryWindow := status.ServerRecoveryWindow[serverName]
+		recoveryWindow.FirstRecoverabilityPoint = convertTime(backupList.GetFirstRecoverabilityPoint())
+		recoveryWindow.LastSuccessfulBackupTime = convertTime(backupList.GetLastSuccessfulBackupTime())
+
+		if status.ServerRecoveryWindow == nil {
+			status.ServerRecoveryWindow = make(map[string]barmancloudv1.RecoveryWindow)
+		}
+		status.ServerRecoveryWindow[serverName] = recoveryWindow
+	})
+}
+
+// SetLastFailedBackupTime sets the last failed backup time in the
+// passed object store, for the passed server name.
+func SetLastFailedBackupTime(
+	ctx context.Context,
+	c client.Client,
+	objectStoreKey client.ObjectKey,
+	serverName string,
+	lastFailedBackupTime time.Time,
+) error {
+	return common.UpdateObjectStoreStatus(ctx, c, objectStoreKey, func(status *barmancloudv1.ObjectStoreStatus) {
+		recoveryWindow := status.ServerRecoveryWindow[serverName]
+		recoveryWindow.LastFailedBackupTime = ptr.To(metav1.NewTime(lastFailedBackupTime))
+
+		if status.ServerRecoveryWindow == nil {
+			status.ServerRecoveryWindow = make(map[string]barmancloudv1.RecoveryWindow)
+		}
+		status.ServerRecoveryWindow[serverName] = recoveryWindow
+	})
+}
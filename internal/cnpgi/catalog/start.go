@@ -0,0 +1,159 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/spf13/viper"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// Start runs a single catalog maintenance cycle for the configured
+// cluster, on behalf of the operator, and returns its result.
+// It is meant to be used when the cluster instances are not running.
+func Start(ctx context.Context) error {
+	setupLog := log.FromContext(ctx)
+	setupLog.Info("Starting barman cloud catalog maintenance")
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+		Scheme: common.GenerateScheme(ctx),
+		Metrics: metricsserver.Options{
+			BindAddress: "0",
+		},
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{
+					&corev1.Secret{},
+					&barmancloudv1.ObjectStore{},
+					&barmancloudv1.ClusterObjectStore{},
+					&cnpgv1.Cluster{},
+					&cnpgv1.Backup{},
+					&coordinationv1.Lease{},
+				},
+			},
+		},
+	})
+	if err != nil {
+		setupLog.Error(err, "unable to start manager")
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	runnable := &maintenanceRunnable{
+		Client: mgr.GetClient(),
+		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
+		Recorder: mgr.GetEventRecorderFor("catalog-maintenance"),
+		ClusterKey: types.NamespacedName{
+			Namespace: viper.GetString("namespace"),
+			Name:      viper.GetString("cluster-name"),
+		},
+		done: cancel,
+	}
+	if err := mgr.Add(runnable); err != nil {
+		setupLog.Error(err, "unable to create catalog maintenance runnable")
+		return err
+	}
+
+	if err := mgr.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return runnable.err
+}
+
+// maintenanceRunnable executes one catalog maintenance cycle, and
+// then stops the manager
+type maintenanceRunnable struct {
+	Client     client.Client
+	Recorder   record.EventRecorder
+	ClusterKey types.NamespacedName
+
+	done context.CancelFunc
+	err  error
+}
+
+// Start implements the manager.Runnable interface
+func (r *maintenanceRunnable) Start(ctx context.Context) error {
+	defer r.done()
+	r.err = r.run(ctx)
+	return nil
+}
+
+func (r *maintenanceRunnable) run(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx).WithValues(
+		"clusterName", r.ClusterKey.Name,
+		"namespace", r.ClusterKey.Namespace,
+	)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Client.Get(ctx, r.ClusterKey, &cluster); err != nil {
+		return fmt.Errorf("while getting cluster: %w", err)
+	}
+
+	configuration := config.NewFromCluster(&cluster)
+	if configuration == nil || len(configuration.BarmanObjectName) == 0 {
+		return fmt.Errorf("invalid configuration, missing barman object store reference")
+	}
+
+	objectStore, err := common.GetObjectStore(ctx, r.Client, configuration.GetBarmanObjectKey())
+	if err != nil {
+		return fmt.Errorf("while getting barman object store: %w", err)
+	}
+
+	leaseKey := client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      specs.GetCatalogMaintenanceName(cluster.Name),
+	}
+	holder := specs.GetCatalogMaintenanceName(cluster.Name)
+	acquired, err := AcquireLease(ctx, r.Client, leaseKey, holder, specs.CatalogMaintenanceJobDeadline, nil)
+	if err != nil {
+		return fmt.Errorf("while acquiring the catalog maintenance lease: %w", err)
+	}
+	if !acquired {
+		contextLogger.Info("Skipping catalog maintenance, the lease is held by a running instance")
+		return nil
+	}
+
+	maintenanceErr := Maintain(ctx, r.Client, r.Recorder, &cluster, objectStore)
+	if err := ReleaseLease(ctx, r.Client, leaseKey, holder); err != nil {
+		contextLogger.Error(err, "while releasing the catalog maintenance lease")
+	}
+
+	return maintenanceErr
+}
@@ -0,0 +1,134 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	barmanCredentials "github.com/cloudnative-pg/barman-cloud/pkg/credentials"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/cloudscript"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+)
+
+// walRetentionScript is the Python script removing the old WAL files
+// from a dedicated destination through the barman cloud interface
+//
+//go:embed walretention.py
+var walRetentionScript string
+
+// applyWALRetention removes from the dedicated WAL destination of the
+// passed configuration the WAL files preceding the oldest backup of the
+// passed catalog, which are not needed by any retained backup anymore.
+// Nothing is removed when the catalog has no completed backup.
+func applyWALRetention(
+	ctx context.Context,
+	c client.Client,
+	configuration *config.PluginConfiguration,
+	backupList *barmanCatalog.Catalog,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	beginWAL := getOldestBeginWAL(backupList)
+	if len(beginWAL) == 0 {
+		contextLogger.Info("Skipping the WAL archive retention, no completed backup in the catalog")
+		return nil
+	}
+
+	walObjectStore, err := common.GetObjectStore(ctx, c, configuration.GetWALBarmanObjectKey())
+	if err != nil {
+		return fmt.Errorf("while getting the WAL archive object store: %w", err)
+	}
+
+	env, err := barmanCredentials.EnvSetCloudCredentialsAndCertificates(
+		ctx,
+		c,
+		walObjectStore.Namespace,
+		&walObjectStore.Spec.Configuration,
+		os.Environ(),
+		common.BuildCertificateFilePath(walObjectStore.Name),
+	)
+	if err != nil {
+		return fmt.Errorf("while setting the WAL archive cloud credentials: %w", err)
+	}
+
+	deletedWALs, err := deleteWALsBefore(
+		ctx,
+		&walObjectStore.Spec.Configuration,
+		configuration.ServerName,
+		beginWAL,
+		env,
+	)
+	if err != nil {
+		return err
+	}
+
+	contextLogger.Info("Applied the retention policy to the WAL archive",
+		"walObjectStoreName", walObjectStore.Name,
+		"beginWAL", beginWAL,
+		"deletedWALs", deletedWALs)
+	return nil
+}
+
+// getOldestBeginWAL returns the begin WAL of the oldest completed
+// backup of the passed catalog, or an empty string if there is none
+func getOldestBeginWAL(backupList *barmanCatalog.Catalog) string {
+	var oldest *barmanCatalog.BarmanBackup
+	for idx := range backupList.List {
+		backup := &backupList.List[idx]
+		if backup.BeginTime.IsZero() || backup.EndTime.IsZero() || len(backup.BeginWal) == 0 {
+			continue
+		}
+		if oldest == nil || backup.BeginTime.Before(oldest.BeginTime) {
+			oldest = backup
+		}
+	}
+
+	if oldest == nil {
+		return ""
+	}
+	return oldest.BeginWal
+}
+
+// deleteWALsBefore deletes the WAL files of the passed server preceding
+// the passed begin WAL from the passed destination, returning how many
+// of them have been deleted
+func deleteWALsBefore(
+	ctx context.Context,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	serverName string,
+	beginWAL string,
+	env []string,
+) (int, error) {
+	var result cloudscript.DeletionResult
+	if err := cloudscript.Run(ctx, walRetentionScript, configuration, serverName, env, &result, beginWAL); err != nil {
+		return 0, fmt.Errorf("while deleting the WAL files before %s: %w", beginWAL, err)
+	}
+
+	return result.DeletedObjects, nil
+}
@@ -0,0 +1,60 @@
+# Copyright © contributors to CloudNativePG, established as
+# CloudNativePG a Series of LF Projects, LLC.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# SPDX-License-Identifier: Apache-2.0
+
+
+# Removal of the WAL files archived by a server in a dedicated destination,
+# which are older than the oldest base backup retained elsewhere, run after
+# the cloudscript prelude.
+#
+# Arguments: <begin WAL>
+#
+# The WAL files preceding the passed begin WAL are deleted, except for the
+# history files, and their number is returned as deletedObjects.
+
+from barman import xlog
+from barman.cloud import CloudBackupCatalog
+
+
+def main(config, cloud_interface, begin_wal):
+    if not xlog.is_wal_file(begin_wal):
+        raise ScriptError(EXIT_INVALID_ARGUMENT, "invalid begin WAL %r" % begin_wal)
+
+    try:
+        catalog = CloudBackupCatalog(cloud_interface, config.server_name)
+        wal_paths = catalog.get_wal_paths()
+    except Exception as exc:
+        raise ScriptError(EXIT_LIST_FAILED, "cannot list the WAL files: %s" % exc)
+
+    # The names of the WAL segments, as well as the ones of the partial
+    # and backup label files, sort like the WAL positions they refer to
+    objects = [
+        path
+        for wal_name, path in wal_paths.items()
+        if xlog.is_any_xlog_file(wal_name)
+        and not xlog.is_history_file(wal_name)
+        and wal_name < begin_wal
+    ]
+    if objects:
+        try:
+            cloud_interface.delete_objects(objects)
+        except Exception as exc:
+            raise ScriptError(EXIT_DELETE_FAILED, "cannot delete the WAL files: %s" % exc)
+
+    return {"deletedObjects": len(objects)}
+
+
+run(main, 1)
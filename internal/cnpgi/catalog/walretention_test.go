@@ -0,0 +1,70 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package catalog
+
+import (
+	"time"
+
+	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("getOldestBeginWAL", func() {
+	beginTime := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	It("returns the begin WAL of the oldest completed backup", func() {
+		backupList := &barmanCatalog.Catalog{
+			List: []barmanCatalog.BarmanBackup{
+				{
+					ID:        "20250102T100000",
+					BeginTime: beginTime.Add(24 * time.Hour),
+					EndTime:   beginTime.Add(25 * time.Hour),
+					BeginWal:  "000000010000000000000010",
+				},
+				{
+					ID:        "20250101T100000",
+					BeginTime: beginTime,
+					EndTime:   beginTime.Add(time.Hour),
+					BeginWal:  "000000010000000000000002",
+				},
+				{
+					ID:        "20241231T100000",
+					BeginTime: beginTime.Add(-24 * time.Hour),
+					BeginWal:  "000000010000000000000001",
+				},
+			},
+		}
+		Expect(getOldestBeginWAL(backupList)).To(Equal("000000010000000000000002"))
+	})
+
+	It("returns an empty string without completed backups", func() {
+		backupList := &barmanCatalog.Catalog{
+			List: []barmanCatalog.BarmanBackup{
+				{
+					ID:        "20250101T100000",
+					BeginTime: beginTime,
+					BeginWal:  "000000010000000000000002",
+				},
+			},
+		}
+		Expect(getOldestBeginWAL(backupList)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,188 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudscript
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
+)
+
+// prelude is the Python code prepended to every script, connecting to
+// the object store and reporting the outcome of the script
+//
+//go:embed prelude.py
+var prelude string
+
+// The exit codes of the scripts, identifying the failed step
+const (
+	// ExitConnectionFailed is the exit code of a script that could not
+	// connect to the object store
+	ExitConnectionFailed = 10
+
+	// ExitBucketNotFound is the exit code of a script whose bucket
+	// does not exist
+	ExitBucketNotFound = 11
+
+	// ExitListFailed is the exit code of a script that could not list
+	// the objects in the destination path
+	ExitListFailed = 12
+
+	// ExitWriteFailed is the exit code of a script that could not
+	// write in the destination path
+	ExitWriteFailed = 13
+
+	// ExitDeleteFailed is the exit code of a script that could not
+	// delete the objects in the destination path
+	ExitDeleteFailed = 14
+
+	// ExitInvalidArgument is the exit code of a script receiving an
+	// invalid argument
+	ExitInvalidArgument = 15
+)
+
+// interpreter is the command running the scripts
+const interpreter = "python3"
+
+// Error is the failure of a script
+type Error struct {
+	// ExitCode is the exit code of the script, identifying the failed
+	// step, or -1 when the script did not run at all
+	ExitCode int
+
+	// Message is the reason of the failure printed by the script
+	Message string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// GetExitCode returns the exit code of the script failing with the
+// passed error, or -1 when the error is not the failure of a script
+func GetExitCode(err error) int {
+	var scriptError *Error
+	if errors.As(err, &scriptError) {
+		return scriptError.ExitCode
+	}
+	return -1
+}
+
+// Run runs the passed script with the passed arguments on the passed
+// server of the passed object store, decoding its JSON output into the
+// passed result, when not nil. The failures of the script are reported
+// as an *Error.
+func Run(
+	ctx context.Context,
+	script string,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	serverName string,
+	env []string,
+	result any,
+	args ...string,
+) error {
+	options, err := buildOptions(ctx, script, configuration, serverName, args)
+	if err != nil {
+		return err
+	}
+
+	return execute(ctx, interpreter, options, env, result)
+}
+
+// buildOptions builds the options of the interpreter running the
+// passed script: its source, its arguments and the options of the
+// barman cloud interface
+func buildOptions(
+	ctx context.Context,
+	script string,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	serverName string,
+	args []string,
+) ([]string, error) {
+	options := []string{"-c", prelude + "\n" + script}
+	options = append(options, args...)
+	if len(configuration.EndpointURL) > 0 {
+		options = append(options, "--endpoint-url", configuration.EndpointURL)
+	}
+
+	options, err := barmanCommand.AppendCloudProviderOptionsFromConfiguration(ctx, options, configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(options, configuration.DestinationPath, serverName), nil
+}
+
+// execute runs the passed command, decoding its JSON output into the
+// passed result, when not nil
+func execute(ctx context.Context, name string, options []string, env []string, result any) error {
+	var stdoutBuffer bytes.Buffer
+	var stderrBuffer bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, options...) // #nosec G204
+	cmd.Env = env
+	cmd.Stdout = &stdoutBuffer
+	cmd.Stderr = &stderrBuffer
+	if err := cmd.Run(); err != nil {
+		return newError(err, stderrBuffer.String())
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(stdoutBuffer.Bytes(), result); err != nil {
+		return fmt.Errorf("unexpected output of the script: %q", stdoutBuffer.String())
+	}
+
+	return nil
+}
+
+// newError builds the Error of a failed run of a script
+func newError(err error, stderr string) *Error {
+	result := &Error{
+		ExitCode: -1,
+		Message:  strings.TrimSpace(stderr),
+	}
+	if len(result.Message) == 0 {
+		result.Message = err.Error()
+	}
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		result.ExitCode = exitError.ExitCode()
+	}
+
+	return result
+}
+
+// DeletionResult is the result of the scripts deleting objects from the
+// object store
+type DeletionResult struct {
+	// DeletedObjects is the number of deleted objects
+	DeletedObjects int `json:"deletedObjects"`
+}
@@ -0,0 +1,183 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudscript
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// fakeBarmanModules are the barman modules used by the prelude, replaced
+// by a cloud interface whose bucket exists when FAKE_BUCKET_EXISTS is set
+var fakeBarmanModules = map[string]string{
+	"barman/__init__.py":         "",
+	"barman/clients/__init__.py": "",
+	"barman/clients/cloud_backup_list.py": `
+import argparse
+
+def parse_arguments(args):
+    parser = argparse.ArgumentParser()
+    parser.add_argument("--endpoint-url")
+    parser.add_argument("--cloud-provider")
+    parser.add_argument("source_url")
+    parser.add_argument("server_name")
+    return parser.parse_args(args)
+`,
+	"barman/cloud_providers.py": `
+import os
+
+class FakeCloudInterface:
+    path = "/path/"
+    bucket_name = "bucket"
+
+    def __init__(self):
+        self.bucket_exists = "FAKE_BUCKET_EXISTS" in os.environ
+
+    def test_connectivity(self):
+        return True
+
+    def close(self):
+        pass
+
+def get_cloud_interface(config):
+    return FakeCloudInterface()
+`,
+}
+
+// testScript returns the server prefix and its argument, or fails when
+// the argument is "fail"
+const testScript = `
+def main(config, cloud_interface, argument):
+    if argument == "fail":
+        raise ScriptError(EXIT_LIST_FAILED, "cannot list %s" % get_prefix(cloud_interface))
+    return {"prefix": get_prefix(cloud_interface, config.server_name), "argument": argument}
+
+
+run(main, 1)
+`
+
+var _ = Describe("Cloud scripts", func() {
+	var (
+		ctx           context.Context
+		configuration *barmanapi.BarmanObjectStoreConfiguration
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		configuration = &barmanapi.BarmanObjectStoreConfiguration{
+			DestinationPath: "s3://bucket/path",
+			EndpointURL:     "https://minio:9000",
+			BarmanCredentials: barmanapi.BarmanCredentials{
+				AWS: &barmanapi.S3Credentials{},
+			},
+		}
+	})
+
+	It("passes the script arguments before the barman options", func() {
+		options, err := buildOptions(ctx, "run(main, 1)", configuration, "cluster-example", []string{"true"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(options[0]).To(Equal("-c"))
+		Expect(options[1]).To(HavePrefix(prelude))
+		Expect(options[1]).To(HaveSuffix("run(main, 1)"))
+		Expect(options[2:]).To(Equal([]string{
+			"true",
+			"--endpoint-url", "https://minio:9000",
+			"--cloud-provider", "aws-s3",
+			"s3://bucket/path", "cluster-example",
+		}))
+	})
+
+	It("decodes the output of the script", func() {
+		var result DeletionResult
+		Expect(execute(ctx, "sh", []string{"-c", `echo '{"deletedObjects": 42}'`}, nil, &result)).
+			To(Succeed())
+		Expect(result.DeletedObjects).To(Equal(42))
+
+		err := execute(ctx, "sh", []string{"-c", "echo Traceback"}, nil, &result)
+		Expect(err).To(MatchError(ContainSubstring("unexpected output of the script")))
+		Expect(GetExitCode(err)).To(Equal(-1))
+	})
+
+	It("reports the exit code and the message of a failed script", func() {
+		err := execute(ctx, "sh", []string{"-c", "echo 'bucket my-bucket does not exist' >&2; exit 11"}, nil, nil)
+		Expect(err).To(MatchError("bucket my-bucket does not exist"))
+		Expect(GetExitCode(err)).To(Equal(ExitBucketNotFound))
+	})
+
+	It("reports a script that could not run", func() {
+		err := execute(ctx, "/nonexistent/python3", nil, nil, nil)
+		Expect(err).To(MatchError(ContainSubstring("no such file or directory")))
+		Expect(GetExitCode(err)).To(Equal(-1))
+	})
+
+	Context("with the prelude", func() {
+		var env []string
+
+		BeforeEach(func() {
+			if _, err := exec.LookPath(interpreter); err != nil {
+				Skip("python3 is not available")
+			}
+
+			modulesPath := GinkgoT().TempDir()
+			for name, content := range fakeBarmanModules {
+				Expect(os.MkdirAll(path.Dir(path.Join(modulesPath, name)), 0o750)).To(Succeed())
+				Expect(os.WriteFile(path.Join(modulesPath, name), []byte(content), 0o600)).To(Succeed())
+			}
+			env = []string{"PYTHONPATH=" + modulesPath, "PATH=" + os.Getenv("PATH")}
+		})
+
+		runTestScript := func(argument string, result any) error {
+			options, err := buildOptions(ctx, testScript, configuration, "cluster-example", []string{argument})
+			Expect(err).NotTo(HaveOccurred())
+			return execute(ctx, interpreter, options, env, result)
+		}
+
+		It("checks the bucket before running the script", func() {
+			err := runTestScript("value", nil)
+			Expect(GetExitCode(err)).To(Equal(ExitBucketNotFound))
+			Expect(err).To(MatchError("bucket bucket does not exist"))
+		})
+
+		It("prints the result of the script", func() {
+			env = append(env, "FAKE_BUCKET_EXISTS=true")
+
+			var result map[string]string
+			Expect(runTestScript("value", &result)).To(Succeed())
+			Expect(result).To(Equal(map[string]string{
+				"prefix":   "path/cluster-example",
+				"argument": "value",
+			}))
+		})
+
+		It("exits with the code of the failed step", func() {
+			env = append(env, "FAKE_BUCKET_EXISTS=true")
+
+			err := runTestScript("fail", nil)
+			Expect(GetExitCode(err)).To(Equal(ExitListFailed))
+			Expect(err).To(MatchError("cannot list path"))
+		})
+	})
+})
@@ -0,0 +1,90 @@
+# Copyright © contributors to CloudNativePG, established as
+# CloudNativePG a Series of LF Projects, LLC.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# SPDX-License-Identifier: Apache-2.0
+
+
+# Prelude shared by the scripts working on an object store through the
+# barman cloud interface, which is prepended to each of them.
+#
+# Usage: python3 -c <prelude and script> <script arguments> <barman-cloud-backup-list options>
+#
+# A script defines a main function receiving the barman configuration,
+# the cloud interface and its own arguments, and ends with a call to run.
+# The options are parsed by barman itself, so that the cloud interface is
+# configured exactly like the one used by the barman-cloud commands. The
+# result returned by main is printed on standard output as JSON. A failed
+# step raises a ScriptError: its exit code identifies the step, and the
+# reason is printed on standard error. Exit codes lower than 10 are
+# reserved to the Python interpreter and to the argument parser.
+
+import json
+import sys
+from contextlib import closing
+
+from barman.clients.cloud_backup_list import parse_arguments
+from barman.cloud_providers import get_cloud_interface
+
+EXIT_CONNECTION_FAILED = 10
+EXIT_BUCKET_NOT_FOUND = 11
+EXIT_LIST_FAILED = 12
+EXIT_WRITE_FAILED = 13
+EXIT_DELETE_FAILED = 14
+EXIT_INVALID_ARGUMENT = 15
+
+
+class ScriptError(Exception):
+    def __init__(self, code, message):
+        super().__init__(message)
+        self.code = code
+
+
+def get_prefix(cloud_interface, *parts):
+    """Join the path of the destination with the passed parts"""
+    return "/".join(
+        part for part in ((cloud_interface.path or "").strip("/"),) + parts if part
+    )
+
+
+def run(main, arguments_count):
+    arguments = sys.argv[1 : 1 + arguments_count]
+    config = parse_arguments(sys.argv[1 + arguments_count :])
+
+    try:
+        try:
+            cloud_interface = get_cloud_interface(config)
+        except Exception as exc:
+            raise ScriptError(
+                EXIT_CONNECTION_FAILED, "cannot configure the cloud interface: %s" % exc
+            )
+
+        with closing(cloud_interface):
+            if not cloud_interface.test_connectivity():
+                raise ScriptError(
+                    EXIT_CONNECTION_FAILED, "cannot connect to the object store"
+                )
+
+            if not cloud_interface.bucket_exists:
+                raise ScriptError(
+                    EXIT_BUCKET_NOT_FOUND,
+                    "bucket %s does not exist" % cloud_interface.bucket_name,
+                )
+
+            result = main(config, cloud_interface, *arguments)
+    except ScriptError as exc:
+        print(exc, file=sys.stderr)
+        sys.exit(exc.code)
+
+    print(json.dumps(result or {}))
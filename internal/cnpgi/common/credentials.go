@@ -0,0 +1,124 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// FileCredentialsClient is a client reading the Secrets mounted by the
+// File credentials mode from the filesystem. The Secrets are read on
+// every call, so that the updates applied by the kubelet are used as
+// soon as they are available.
+//
+// The Secrets that are not mounted, and every other object, are read
+// through the wrapped client.
+type FileCredentialsClient struct {
+	client.Client
+	namespace string
+	basePath  string
+}
+
+// NewFileCredentialsClient returns a client reading the Secrets of the
+// passed namespace from the barman credentials volume, if mounted
+func NewFileCredentialsClient(baseClient client.Client, namespace string) *FileCredentialsClient {
+	return &FileCredentialsClient{
+		Client:    baseClient,
+		namespace: namespace,
+		basePath:  metadata.BarmanCredentialsPath,
+	}
+}
+
+// Get behaves like the original Get method, but reads the mounted
+// Secrets from the filesystem
+func (c *FileCredentialsClient) Get(
+	ctx context.Context,
+	key client.ObjectKey,
+	obj client.Object,
+	opts ...client.GetOption,
+) error {
+	secret, isSecret := obj.(*corev1.Secret)
+	if !isSecret || key.Namespace != c.namespace || strings.ContainsRune(key.Name, '/') {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+
+	data, err := readSecretDirectory(path.Join(c.basePath, key.Name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return c.Client.Get(ctx, key, obj, opts...)
+	}
+	if err != nil {
+		return err
+	}
+
+	*secret = corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: key.Namespace,
+			Name:      key.Name,
+		},
+		Data: data,
+	}
+	return nil
+}
+
+// readSecretDirectory reads the keys of a Secret projected in the
+// passed directory
+func readSecretDirectory(directory string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make(map[string][]byte, len(entries))
+	for _, entry := range entries {
+		// The kubelet updates the projected files atomically through
+		// hidden directories, which are not keys
+		if strings.HasPrefix(entry.Name(), "..") {
+			continue
+		}
+
+		fileName := path.Join(directory, entry.Name())
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		content, err := os.ReadFile(fileName) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		data[entry.Name()] = content
+	}
+
+	return data, nil
+}
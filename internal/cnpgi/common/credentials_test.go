@@ -0,0 +1,115 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("FileCredentialsClient", func() {
+	const namespace = "default"
+
+	var (
+		credentialsClient *FileCredentialsClient
+		basePath          string
+	)
+
+	// projectSecret mimics the layout of a projected volume, where
+	// the keys are symlinks to a hidden directory swapped atomically
+	projectSecret := func(secretName string, data map[string]string) {
+		secretPath := path.Join(basePath, secretName)
+		dataPath := path.Join(secretPath, "..2026_01_01_00_00_00.000000000")
+		Expect(os.MkdirAll(dataPath, 0o700)).To(Succeed())
+		for key, value := range data {
+			Expect(os.WriteFile(path.Join(dataPath, key), []byte(value), 0o600)).To(Succeed())
+		}
+		Expect(os.Symlink(path.Base(dataPath), path.Join(secretPath, "..data"))).To(Succeed())
+		for key := range data {
+			Expect(os.Symlink(path.Join("..data", key), path.Join(secretPath, key))).To(Succeed())
+		}
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+		baseClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "api-secret", Namespace: namespace},
+				Data:       map[string][]byte{"key": []byte("from-api")},
+			}).
+			Build()
+
+		basePath = GinkgoT().TempDir()
+		credentialsClient = NewFileCredentialsClient(baseClient, namespace)
+		credentialsClient.basePath = basePath
+	})
+
+	It("reads the mounted Secrets from the filesystem", func(ctx SpecContext) {
+		projectSecret("file-secret", map[string]string{
+			"ACCESS_KEY_ID":     "access",
+			"SECRET_ACCESS_KEY": "secret",
+		})
+
+		var secret corev1.Secret
+		Expect(credentialsClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "file-secret"}, &secret)).
+			To(Succeed())
+		Expect(secret.Name).To(Equal("file-secret"))
+		Expect(secret.Data).To(Equal(map[string][]byte{
+			"ACCESS_KEY_ID":     []byte("access"),
+			"SECRET_ACCESS_KEY": []byte("secret"),
+		}))
+	})
+
+	It("reads the changes applied to the mounted Secrets", func(ctx SpecContext) {
+		projectSecret("file-secret", map[string]string{"key": "old"})
+		Expect(os.WriteFile(path.Join(basePath, "file-secret", "key"), []byte("new"), 0o600)).To(Succeed())
+
+		var secret corev1.Secret
+		Expect(credentialsClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "file-secret"}, &secret)).
+			To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("key", []byte("new")))
+	})
+
+	It("reads the Secrets that are not mounted through the API", func(ctx SpecContext) {
+		var secret corev1.Secret
+		Expect(credentialsClient.Get(ctx, client.ObjectKey{Namespace: namespace, Name: "api-secret"}, &secret)).
+			To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("key", []byte("from-api")))
+	})
+
+	It("reads the Secrets of other namespaces through the API", func(ctx SpecContext) {
+		projectSecret("api-secret", map[string]string{"key": "from-file"})
+
+		var secret corev1.Secret
+		err := credentialsClient.Get(ctx, client.ObjectKey{Namespace: "other", Name: "api-secret"}, &secret)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,349 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+)
+
+// GetObjectStore gets the object store having the passed key, as
+// returned by the PluginConfiguration.
+//
+// Keys without a namespace refer to a ClusterObjectStore. Its definition
+// is returned as an ObjectStore living in the namespace containing its
+// credentials, so that the referenced secrets are resolved from there.
+func GetObjectStore(
+	ctx context.Context,
+	c client.Reader,
+	key client.ObjectKey,
+) (*barmancloudv1.ObjectStore, error) {
+	if len(key.Namespace) > 0 {
+		var objectStore barmancloudv1.ObjectStore
+		if err := c.Get(ctx, key, &objectStore); err != nil {
+			return nil, err
+		}
+		return &objectStore, nil
+	}
+
+	var clusterObjectStore barmancloudv1.ClusterObjectStore
+	if err := c.Get(ctx, key, &clusterObjectStore); err != nil {
+		return nil, err
+	}
+
+	return &barmancloudv1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       clusterObjectStore.Name,
+			Namespace:  clusterObjectStore.Spec.CredentialsNamespace,
+			Generation: clusterObjectStore.Generation,
+		},
+		Spec:   clusterObjectStore.Spec.ObjectStoreSpec,
+		Status: clusterObjectStore.Status,
+	}, nil
+}
+
+// UpdateObjectStoreStatus applies the passed function to the status of
+// the object store having the passed key, which may refer to either an
+// ObjectStore or a ClusterObjectStore, retrying on conflicts.
+func UpdateObjectStoreStatus(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	update func(status *barmancloudv1.ObjectStoreStatus),
+) error {
+	return TryUpdateObjectStoreStatus(ctx, c, key, func(status *barmancloudv1.ObjectStoreStatus) (bool, error) {
+		update(status)
+		return true, nil
+	})
+}
+
+// TryUpdateObjectStoreStatus applies the passed function to the status
+// of the object store having the passed key, which may refer to either
+// an ObjectStore or a ClusterObjectStore. The status is only updated
+// when the function returns true, and the errors returned by the
+// function are returned as they are. The update is conditional on the
+// resource version read: on conflicts, the status is read again and
+// the function is applied again.
+func TryUpdateObjectStoreStatus(
+	ctx context.Context,
+	c client.Client,
+	key client.ObjectKey,
+	update func(status *barmancloudv1.ObjectStoreStatus) (bool, error),
+) error {
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if len(key.Namespace) > 0 {
+			var objectStore barmancloudv1.ObjectStore
+			if err := c.Get(ctx, key, &objectStore); err != nil {
+				return err
+			}
+			if changed, err := update(&objectStore.Status); err != nil || !changed {
+				return err
+			}
+			return c.Status().Update(ctx, &objectStore)
+		}
+
+		var clusterObjectStore barmancloudv1.ClusterObjectStore
+		if err := c.Get(ctx, key, &clusterObjectStore); err != nil {
+			return err
+		}
+		if changed, err := update(&clusterObjectStore.Status); err != nil || !changed {
+			return err
+		}
+		return c.Status().Update(ctx, &clusterObjectStore)
+	})
+}
+
+// ClusterArchiveObjectStoreField is the name of the field index of the
+// Clusters archiving with this plugin, by the keys of the object stores
+// they archive into. The keys of the ClusterObjectStores have no
+// namespace.
+const ClusterArchiveObjectStoreField = "barmancloud.cnpg.io/archiveObjectStore"
+
+// IndexClusterArchiveObjectStore is the indexer of the
+// ClusterArchiveObjectStoreField field, which is only set on the
+// Clusters enabling this plugin
+func IndexClusterArchiveObjectStore(obj client.Object) []string {
+	cluster, ok := obj.(*cnpgv1.Cluster)
+	if !ok {
+		return nil
+	}
+
+	enabledPlugins := cnpgv1.GetPluginConfigurationEnabledPluginNames(cluster.Spec.Plugins)
+	if !slices.Contains(enabledPlugins, metadata.PluginName) {
+		return nil
+	}
+
+	keys := config.NewFromCluster(cluster).GetArchiveBarmanObjectsKey()
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.String())
+	}
+
+	return result
+}
+
+// listObjectStoresSharingDestination lists the keys of the ObjectStores
+// and of the ClusterObjectStores sharing the destination path and the
+// endpoint of the passed object store, including its own
+func listObjectStoresSharingDestination(
+	ctx context.Context,
+	c client.Reader,
+	objectStore *barmancloudv1.ObjectStore,
+) ([]client.ObjectKey, error) {
+	var objectStoreList barmancloudv1.ObjectStoreList
+	if err := c.List(ctx, &objectStoreList); err != nil {
+		return nil, fmt.Errorf("while listing the object stores: %w", err)
+	}
+
+	var clusterObjectStoreList barmancloudv1.ClusterObjectStoreList
+	if err := c.List(ctx, &clusterObjectStoreList); err != nil {
+		return nil, fmt.Errorf("while listing the cluster object stores: %w", err)
+	}
+
+	var result []client.ObjectKey
+	for i := range objectStoreList.Items {
+		if isSameDestination(&objectStoreList.Items[i], objectStore) {
+			result = append(result, client.ObjectKeyFromObject(&objectStoreList.Items[i]))
+		}
+	}
+	for i := range clusterObjectStoreList.Items {
+		clusterObjectStore := &barmancloudv1.ObjectStore{Spec: clusterObjectStoreList.Items[i].Spec.ObjectStoreSpec}
+		if isSameDestination(clusterObjectStore, objectStore) {
+			result = append(result, client.ObjectKey{Name: clusterObjectStoreList.Items[i].Name})
+		}
+	}
+
+	return result, nil
+}
+
+// ListClustersArchivingTo lists the Clusters archiving with the passed
+// server name into the destination of the passed object store, be it
+// through the same object store or through another one sharing its
+// destination path and endpoint. The Cluster having the passed UID and
+// the Clusters being deleted are skipped.
+//
+// The Clusters are looked up through the ClusterArchiveObjectStoreField
+// index, which must be registered in the passed reader.
+func ListClustersArchivingTo(
+	ctx context.Context,
+	c client.Reader,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+	excludedUID types.UID,
+) ([]cnpgv1.Cluster, error) {
+	objectStoreKeys, err := listObjectStoresSharingDestination(ctx, c, objectStore)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []cnpgv1.Cluster
+	for _, key := range objectStoreKeys {
+		var clusterList cnpgv1.ClusterList
+		if err := c.List(
+			ctx,
+			&clusterList,
+			client.MatchingFields{ClusterArchiveObjectStoreField: key.String()},
+		); err != nil {
+			return nil, fmt.Errorf("while listing the clusters archiving into %s: %w", key, err)
+		}
+
+		for i := range clusterList.Items {
+			cluster := &clusterList.Items[i]
+			if cluster.UID == excludedUID || !cluster.DeletionTimestamp.IsZero() {
+				continue
+			}
+			if config.NewFromCluster(cluster).ServerName != serverName {
+				continue
+			}
+			if slices.ContainsFunc(result, func(existing cnpgv1.Cluster) bool {
+				return existing.UID == cluster.UID
+			}) {
+				continue
+			}
+			result = append(result, *cluster)
+		}
+	}
+
+	return result, nil
+}
+
+// ListClustersReadingFrom lists the Clusters replicating from the
+// passed server name, through their replica source, out of the
+// destination of the passed object store. The Cluster having the passed
+// UID and the Clusters being deleted are skipped.
+func ListClustersReadingFrom(
+	ctx context.Context,
+	c client.Reader,
+	objectStore *barmancloudv1.ObjectStore,
+	serverName string,
+	excludedUID types.UID,
+) ([]cnpgv1.Cluster, error) {
+	var clusterList cnpgv1.ClusterList
+	if err := c.List(ctx, &clusterList); err != nil {
+		return nil, fmt.Errorf("while listing the clusters: %w", err)
+	}
+
+	var result []cnpgv1.Cluster
+	for i := range clusterList.Items {
+		cluster := &clusterList.Items[i]
+		if cluster.UID == excludedUID || !cluster.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		configuration := config.NewFromCluster(cluster)
+		if len(configuration.ReplicaSourceBarmanObjectName) == 0 ||
+			configuration.ReplicaSourceServerName != serverName {
+			continue
+		}
+
+		for _, key := range []client.ObjectKey{
+			configuration.GetReplicaSourceBarmanObjectKey(),
+			configuration.GetReplicaSourceWALBarmanObjectKey(),
+		} {
+			sourceObjectStore, err := GetObjectStore(ctx, c, key)
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("while getting the object store of cluster %s/%s: %w",
+					cluster.Namespace, cluster.Name, err)
+			}
+
+			if isSameDestination(sourceObjectStore, objectStore) {
+				result = append(result, *cluster)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// GetImagePullSecrets gets the image pull secrets of the Clusters
+// referring to the passed object store and living in its namespace,
+// where the Jobs working directly on the object store run. As the
+// object store may be a ClusterObjectStore seen from its credentials
+// namespace, the Clusters are matched by the object store name only.
+func GetImagePullSecrets(
+	ctx context.Context,
+	c client.Reader,
+	objectStore *barmancloudv1.ObjectStore,
+) ([]corev1.LocalObjectReference, error) {
+	var clusterList cnpgv1.ClusterList
+	if err := c.List(ctx, &clusterList, client.InNamespace(objectStore.Namespace)); err != nil {
+		return nil, fmt.Errorf("while listing the clusters: %w", err)
+	}
+
+	var result []corev1.LocalObjectReference
+	for i := range clusterList.Items {
+		cluster := &clusterList.Items[i]
+		refersToObjectStore := slices.ContainsFunc(
+			config.NewFromCluster(cluster).GetReferredBarmanObjectsKey(),
+			func(key types.NamespacedName) bool {
+				return key.Name == objectStore.Name
+			},
+		)
+		if !refersToObjectStore {
+			continue
+		}
+
+		for _, secret := range cluster.Spec.ImagePullSecrets {
+			reference := corev1.LocalObjectReference{Name: secret.Name}
+			if !slices.Contains(result, reference) {
+				result = append(result, reference)
+			}
+		}
+	}
+
+	slices.SortFunc(result, func(a, b corev1.LocalObjectReference) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return result, nil
+}
+
+// HasDestination checks whether the passed object store points to the
+// passed destination path on the passed endpoint. The trailing slashes
+// of the destination paths are not significant.
+func HasDestination(objectStore *barmancloudv1.ObjectStore, destinationPath, endpointURL string) bool {
+	return strings.TrimRight(objectStore.Spec.Configuration.DestinationPath, "/") ==
+		strings.TrimRight(destinationPath, "/") &&
+		objectStore.Spec.Configuration.EndpointURL == endpointURL
+}
+
+// isSameDestination checks whether the passed object stores share the
+// same destination path on the same endpoint
+func isSameDestination(a, b *barmancloudv1.ObjectStore) bool {
+	return HasDestination(a, b.Spec.Configuration.DestinationPath, b.Spec.Configuration.EndpointURL)
+}
@@ -0,0 +1,247 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var (
+	verifiedServerOwnersMutex sync.Mutex
+	verifiedServerOwners      = make(map[string]barmancloudv1.ServerOwner)
+)
+
+// NewServerOwner builds the ServerOwner describing the passed Cluster
+// archiving on the passed timeline
+func NewServerOwner(cluster *cnpgv1.Cluster, timeline int) barmancloudv1.ServerOwner {
+	return barmancloudv1.ServerOwner{
+		ClusterNamespace: cluster.Namespace,
+		ClusterName:      cluster.Name,
+		ClusterUID:       string(cluster.UID),
+		Timeline:         timeline,
+	}
+}
+
+// EnsureServerOwnershipOnce is like EnsureServerOwnership, but only checks the ownership of the
+// server the first time it is called by this process for a Cluster
+// and a timeline. It is meant for the WAL archiving hot path, where
+// the ownership is checked again only when the Cluster archives on a
+// newer timeline.
+func EnsureServerOwnershipOnce(
+	ctx context.Context,
+	c client.Client,
+	objectStoreKey client.ObjectKey,
+	serverName string,
+	cluster *cnpgv1.Cluster,
+	timeline int,
+) error {
+	verifiedKey := getVerifiedServerOwnerKey(objectStoreKey, serverName)
+	if isServerOwnerVerified(verifiedKey, NewServerOwner(cluster, timeline)) {
+		return nil
+	}
+
+	return EnsureServerOwnership(ctx, c, objectStoreKey, serverName, cluster, timeline)
+}
+
+// EnsureServerOwnership checks that the passed Cluster owns the passed server in the
+// object store before writing there. The owners are recorded in the
+// status of the object store: the server is claimed when it has no
+// owner yet, and the timeline is updated when the Cluster archives on
+// a newer one. The status update is conditional on the version of the
+// object store that has been read, so that two Clusters cannot claim
+// the same server. Writes from a foreign Cluster are refused with a
+// FailedPrecondition error, unless the Cluster is annotated to take
+// over the server from its current owner.
+func EnsureServerOwnership(
+	ctx context.Context,
+	c client.Client,
+	objectStoreKey client.ObjectKey,
+	serverName string,
+	cluster *cnpgv1.Cluster,
+	timeline int,
+) error {
+	contextLogger := log.FromContext(ctx).WithValues("serverName", serverName)
+
+	verifiedKey := getVerifiedServerOwnerKey(objectStoreKey, serverName)
+	location := getServerLocation(objectStoreKey, serverName)
+	wanted := NewServerOwner(cluster, timeline)
+	takeoverUID := cluster.Annotations[metadata.ServerTakeoverAnnotationName]
+
+	var previous *barmancloudv1.ServerOwner
+	var owner barmancloudv1.ServerOwner
+	var written bool
+	err := TryUpdateObjectStoreStatus(
+		ctx,
+		c,
+		objectStoreKey,
+		func(objectStoreStatus *barmancloudv1.ObjectStoreStatus) (bool, error) {
+			previous = nil
+			written = false
+			if current, ok := objectStoreStatus.ServerOwners[serverName]; ok {
+				previous = &current
+			}
+
+			write, err := reconcileServerOwner(previous, wanted, takeoverUID, location)
+			if err != nil || !write {
+				if previous != nil {
+					owner = *previous
+				}
+				return false, err
+			}
+
+			if objectStoreStatus.ServerOwners == nil {
+				objectStoreStatus.ServerOwners = make(map[string]barmancloudv1.ServerOwner)
+			}
+			objectStoreStatus.ServerOwners[serverName] = wanted
+			owner = wanted
+			written = true
+			return true, nil
+		},
+	)
+	if status.Code(err) == codes.FailedPrecondition {
+		forgetServerOwnerVerified(verifiedKey)
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("while checking the ownership of %s: %w", location, err)
+	}
+
+	switch {
+	case !written:
+		// The ownership was already recorded
+	case previous == nil:
+		contextLogger.Info("Claimed the ownership of the server", "location", location)
+	case previous.ClusterUID != wanted.ClusterUID:
+		contextLogger.Info("Took over the ownership of the server",
+			"location", location,
+			"previousOwnerUID", previous.ClusterUID,
+			"previousOwnerNamespace", previous.ClusterNamespace,
+			"previousOwnerName", previous.ClusterName)
+	default:
+		contextLogger.Info("Recorded the new timeline in the ownership of the server",
+			"location", location,
+			"timeline", wanted.Timeline)
+	}
+
+	setServerOwnerVerified(verifiedKey, owner)
+	return nil
+}
+
+// reconcileServerOwner compares the current owner of the server, which is
+// nil when there is none, with the wanted one. It returns whether the
+// owner must be written, or the error refusing the write when the
+// server belongs to another Cluster.
+func reconcileServerOwner(
+	current *barmancloudv1.ServerOwner,
+	wanted barmancloudv1.ServerOwner,
+	takeoverUID string,
+	location string,
+) (bool, error) {
+	switch {
+	case current == nil:
+		return true, nil
+
+	case current.ClusterUID == wanted.ClusterUID:
+		return wanted.Timeline > current.Timeline, nil
+
+	case len(takeoverUID) > 0 && takeoverUID == current.ClusterUID:
+		return true, nil
+
+	default:
+		return false, newForeignServerOwnerError(current, wanted, location)
+	}
+}
+
+// newForeignServerOwnerError reports that the server belongs to another
+// Cluster, explaining how to take it over. Emits
+// codes.FailedPrecondition: the write can only succeed after an
+// explicit action of the user.
+func newForeignServerOwnerError(
+	current *barmancloudv1.ServerOwner,
+	wanted barmancloudv1.ServerOwner,
+	location string,
+) error {
+	return status.Errorf(
+		codes.FailedPrecondition,
+		"%s belongs to the cluster %s/%s (UID %s, timeline %d), refusing to write from "+
+			"the cluster %s/%s (UID %s). If the owner does not archive there anymore, "+
+			"annotate this cluster with %s=%s to take it over, otherwise change the "+
+			"serverName plugin parameter",
+		location,
+		current.ClusterNamespace, current.ClusterName, current.ClusterUID, current.Timeline,
+		wanted.ClusterNamespace, wanted.ClusterName, wanted.ClusterUID,
+		metadata.ServerTakeoverAnnotationName, current.ClusterUID,
+	)
+}
+
+// getServerLocation describes the passed server of the passed object store
+// in the messages
+func getServerLocation(objectStoreKey client.ObjectKey, serverName string) string {
+	if len(objectStoreKey.Namespace) == 0 {
+		return fmt.Sprintf("the server %q of the ClusterObjectStore %s", serverName, objectStoreKey.Name)
+	}
+	return fmt.Sprintf("the server %q of the ObjectStore %s", serverName, objectStoreKey)
+}
+
+// getVerifiedServerOwnerKey returns the key of the passed server of the passed
+// object store in the cache of the verified owners
+func getVerifiedServerOwnerKey(objectStoreKey client.ObjectKey, serverName string) string {
+	return fmt.Sprintf("%s/%s", objectStoreKey, serverName)
+}
+
+// isServerOwnerVerified checks whether the passed ownership has already been
+// verified by this process
+func isServerOwnerVerified(key string, wanted barmancloudv1.ServerOwner) bool {
+	verifiedServerOwnersMutex.Lock()
+	defer verifiedServerOwnersMutex.Unlock()
+
+	verified, ok := verifiedServerOwners[key]
+	return ok &&
+		verified.ClusterUID == wanted.ClusterUID &&
+		verified.Timeline >= wanted.Timeline
+}
+
+// setServerOwnerVerified records the ownership just verified
+func setServerOwnerVerified(key string, owner barmancloudv1.ServerOwner) {
+	verifiedServerOwnersMutex.Lock()
+	defer verifiedServerOwnersMutex.Unlock()
+
+	verifiedServerOwners[key] = owner
+}
+
+// forgetServerOwnerVerified drops the ownership verified for the passed key, after
+// the server has been taken over by another Cluster
+func forgetServerOwnerVerified(key string) {
+	verifiedServerOwnersMutex.Lock()
+	defer verifiedServerOwnersMutex.Unlock()
+
+	delete(verifiedServerOwners, key)
+}
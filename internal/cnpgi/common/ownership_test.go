@@ -0,0 +1,187 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package common
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("Server ownership", func() {
+	const location = `the server "cluster-example" of the ObjectStore default/store`
+
+	owner := barmancloudv1.ServerOwner{
+		ClusterNamespace: "default",
+		ClusterName:      "cluster-example",
+		ClusterUID:       "owner-uid",
+		Timeline:         2,
+	}
+
+	newCluster := func(name, uid string) *cnpgv1.Cluster {
+		return &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      name,
+				UID:       types.UID(uid),
+			},
+		}
+	}
+
+	It("describes the Cluster archiving", func() {
+		Expect(NewServerOwner(newCluster("cluster-example", "owner-uid"), 2)).To(Equal(owner))
+	})
+
+	It("claims a server without an owner", func() {
+		write, err := reconcileServerOwner(nil, owner, "", location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(write).To(BeTrue())
+	})
+
+	It("records a newer timeline of the owner", func() {
+		wanted := owner
+		wanted.Timeline = 3
+		write, err := reconcileServerOwner(&owner, wanted, "", location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(write).To(BeTrue())
+	})
+
+	It("does not rewrite the owner for the same or an older timeline", func() {
+		wanted := owner
+		wanted.Timeline = 1
+		write, err := reconcileServerOwner(&owner, wanted, "", location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(write).To(BeFalse())
+	})
+
+	It("refuses the writes of a foreign Cluster, explaining how to take over", func() {
+		foreign := barmancloudv1.ServerOwner{
+			ClusterNamespace: "default",
+			ClusterName:      "cluster-restored",
+			ClusterUID:       "foreign-uid",
+			Timeline:         3,
+		}
+		write, err := reconcileServerOwner(&owner, foreign, "", location)
+		Expect(write).To(BeFalse())
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		Expect(err.Error()).To(ContainSubstring("default/cluster-example (UID owner-uid, timeline 2)"))
+		Expect(err.Error()).To(ContainSubstring(metadata.ServerTakeoverAnnotationName + "=owner-uid"))
+	})
+
+	It("lets a Cluster take over the server from the named owner only", func() {
+		foreign := owner
+		foreign.ClusterUID = "foreign-uid"
+
+		write, err := reconcileServerOwner(&owner, foreign, "owner-uid", location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(write).To(BeTrue())
+
+		_, err = reconcileServerOwner(&owner, foreign, "another-uid", location)
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+	})
+
+	Context("in the status of the object store", func() {
+		var (
+			ctx            context.Context
+			fakeClient     client.Client
+			objectStoreKey client.ObjectKey
+		)
+
+		getServerOwners := func() map[string]barmancloudv1.ServerOwner {
+			var objectStore barmancloudv1.ObjectStore
+			Expect(fakeClient.Get(ctx, objectStoreKey, &objectStore)).To(Succeed())
+			return objectStore.Status.ServerOwners
+		}
+
+		BeforeEach(func() {
+			ctx = context.Background()
+			scheme := runtime.NewScheme()
+			barmancloudv1.AddKnownTypes(scheme)
+
+			objectStore := &barmancloudv1.ObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "store"},
+			}
+			objectStoreKey = client.ObjectKeyFromObject(objectStore)
+			fakeClient = fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(objectStore).
+				WithStatusSubresource(objectStore).
+				Build()
+		})
+
+		It("claims the server and records the newer timelines", func() {
+			cluster := newCluster("cluster-example", "owner-uid")
+			Expect(EnsureServerOwnership(ctx, fakeClient, objectStoreKey, "cluster-example", cluster, 2)).
+				To(Succeed())
+			Expect(getServerOwners()).To(HaveKeyWithValue("cluster-example", owner))
+
+			Expect(EnsureServerOwnership(ctx, fakeClient, objectStoreKey, "cluster-example", cluster, 3)).
+				To(Succeed())
+			Expect(getServerOwners()["cluster-example"].Timeline).To(Equal(3))
+		})
+
+		It("refuses a second Cluster and lets it take over when annotated", func() {
+			Expect(EnsureServerOwnership(
+				ctx, fakeClient, objectStoreKey, "cluster-example", newCluster("cluster-example", "owner-uid"), 2,
+			)).To(Succeed())
+
+			foreign := newCluster("cluster-restored", "foreign-uid")
+			err := EnsureServerOwnership(ctx, fakeClient, objectStoreKey, "cluster-example", foreign, 3)
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+			Expect(getServerOwners()).To(HaveKeyWithValue("cluster-example", owner))
+
+			foreign.Annotations = map[string]string{metadata.ServerTakeoverAnnotationName: "owner-uid"}
+			Expect(EnsureServerOwnership(ctx, fakeClient, objectStoreKey, "cluster-example", foreign, 3)).
+				To(Succeed())
+			Expect(getServerOwners()["cluster-example"].ClusterUID).To(Equal("foreign-uid"))
+		})
+
+		It("only checks the ownership again for a newer timeline", func() {
+			cluster := newCluster("cluster-once", "once-uid")
+			Expect(EnsureServerOwnershipOnce(ctx, fakeClient, objectStoreKey, "cluster-once", cluster, 2)).
+				To(Succeed())
+
+			// Another Cluster takes over the server: the verified
+			// ownership is trusted until the timeline changes
+			Expect(TryUpdateObjectStoreStatus(ctx, fakeClient, objectStoreKey,
+				func(objectStoreStatus *barmancloudv1.ObjectStoreStatus) (bool, error) {
+					objectStoreStatus.ServerOwners["cluster-once"] = owner
+					return true, nil
+				})).To(Succeed())
+			Expect(EnsureServerOwnershipOnce(ctx, fakeClient, objectStoreKey, "cluster-once", cluster, 2)).
+				To(Succeed())
+
+			err := EnsureServerOwnershipOnce(ctx, fakeClient, objectStoreKey, "cluster-once", cluster, 3)
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		})
+	})
+})
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"time"
 
 	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
@@ -123,8 +124,8 @@ func (w WALServiceImplementation) Archive(
 		return nil, err
 	}
 
-	var objectStore barmancloudv1.ObjectStore
-	if err := w.Client.Get(ctx, configuration.GetBarmanObjectKey(), &objectStore); err != nil {
+	objectStore, err := GetObjectStore(ctx, w.Client, configuration.GetWALBarmanObjectKey())
+	if err != nil {
 		return nil, err
 	}
 
@@ -177,7 +178,20 @@ func (w WALServiceImplementation) Archive(
 		}
 	}
 
-	// Step 3: check if this WAL file has not been already archived
+	// Step 3: check that no other cluster is archiving with the same
+	// server name in the same location
+	if err := EnsureServerOwnershipOnce(
+		ctx,
+		w.Client,
+		configuration.GetWALBarmanObjectKey(),
+		configuration.ServerName,
+		configuration.Cluster,
+		getWALTimeline(baseWalName, configuration.Cluster.Status.TimelineID),
+	); err != nil {
+		return nil, err
+	}
+
+	// Step 4: check if this WAL file has not been already archived
 	var isDeletedFromSpool bool
 	isDeletedFromSpool, err = arch.DeleteFromSpool(baseWalName)
 	if err != nil {
@@ -192,7 +206,7 @@ func (w WALServiceImplementation) Archive(
 		return nil, nil
 	}
 
-	// Step 4: gather the WAL files names to archive
+	// Step 5: gather the WAL files names to archive
 	options, err := arch.BarmanCloudWalArchiveOptions(ctx, &objectStore.Spec.Configuration, configuration.ServerName)
 	if err != nil {
 		return nil, err
@@ -228,6 +242,22 @@ func (w WALServiceImplementation) Archive(
 	return &wal.WALArchiveResult{}, nil
 }
 
+// getWALTimeline returns the timeline of the passed WAL file, history
+// file or backup label, whose name starts with it, or the passed
+// default when the name cannot be parsed
+func getWALTimeline(walName string, defaultTimeline int) int {
+	if len(walName) < 8 {
+		return defaultTimeline
+	}
+
+	timeline, err := strconv.ParseInt(walName[:8], 16, 32)
+	if err != nil || timeline == 0 {
+		return defaultTimeline
+	}
+
+	return int(timeline)
+}
+
 // resolveArchiveEmptyWalArchiveCheck reports whether the WAL archive
 // destination must be verified before archiving this segment.
 //
@@ -270,8 +300,8 @@ func (w WALServiceImplementation) Restore(
 
 	serverName, objectStoreKey := resolveRestoreObjectStore(configuration, w.InstanceName)
 
-	var objectStore barmancloudv1.ObjectStore
-	if err := w.Client.Get(ctx, objectStoreKey, &objectStore); err != nil {
+	objectStore, err := GetObjectStore(ctx, w.Client, objectStoreKey)
+	if err != nil {
 		return nil, err
 	}
 
@@ -282,7 +312,7 @@ func (w WALServiceImplementation) Restore(
 		"walName", walName,
 		"mode", request.GetMode())
 	return &wal.WALRestoreResult{}, w.restoreFromBarmanObjectStore(
-		ctx, configuration.Cluster, &objectStore, serverName, walName, destinationPath,
+		ctx, configuration.Cluster, objectStore, serverName, walName, destinationPath,
 		request.GetMode() == wal.WALRestoreRequest_MODE_REWIND)
 }
 
@@ -301,15 +331,15 @@ func resolveRestoreObjectStore(
 		// source configured can only be a designated primary that has not finished
 		// promoting, and it must keep fetching WAL from the replica source.
 		// Token-agnostic: covers both switchover and failover.
-		return configuration.ReplicaSourceServerName, configuration.GetReplicaSourceBarmanObjectKey()
+		return configuration.ReplicaSourceServerName, configuration.GetReplicaSourceWALBarmanObjectKey()
 
 	case configuration.Cluster.Status.CurrentPrimary == "":
 		// Recovery from object store, using recovery object store
-		return configuration.RecoveryServerName, configuration.GetRecoveryBarmanObjectKey()
+		return configuration.RecoveryServerName, configuration.GetRecoveryWALBarmanObjectKey()
 
 	default:
 		// Using cluster object store
-		return configuration.ServerName, configuration.GetBarmanObjectKey()
+		return configuration.ServerName, configuration.GetWALBarmanObjectKey()
 	}
 }
 
@@ -104,6 +104,24 @@ var _ = Describe("resolveRestoreObjectStore", func() {
 			newConfig("cluster-2", "replica-store"),
 			"cluster-server", "cluster-store"),
 	)
+
+	It("prefers the dedicated WAL archive destinations", func() {
+		cfg := newConfig(instance, "replica-store")
+		cfg.WALBarmanObjectName = "cluster-wal-store"
+		cfg.RecoveryWALBarmanObjectName = "recovery-wal-store"
+		cfg.ReplicaSourceWALBarmanObjectName = "replica-wal-store"
+
+		_, key := resolveRestoreObjectStore(cfg, instance)
+		Expect(key.Name).To(Equal("replica-wal-store"))
+
+		cfg.Cluster.Status.CurrentPrimary = ""
+		_, key = resolveRestoreObjectStore(cfg, instance)
+		Expect(key.Name).To(Equal("recovery-wal-store"))
+
+		cfg.Cluster.Status.CurrentPrimary = "cluster-2"
+		_, key = resolveRestoreObjectStore(cfg, instance)
+		Expect(key.Name).To(Equal("cluster-wal-store"))
+	})
 })
 
 var _ = Describe("maxWALFilesPerInvocation", func() {
@@ -244,3 +262,17 @@ var _ = Describe("resolveArchiveEmptyWalArchiveCheck", func() {
 		)
 	})
 })
+
+var _ = Describe("getWALTimeline", func() {
+	DescribeTable("extracts the timeline from the file name",
+		func(walName string, expected int) {
+			Expect(getWALTimeline(walName, 7)).To(Equal(expected))
+		},
+		Entry("WAL file", "0000000A0000000100000002", 10),
+		Entry("partial WAL file", "000000030000000100000002.partial", 3),
+		Entry("history file", "00000002.history", 2),
+		Entry("backup label", "000000010000000100000002.00000028.backup", 1),
+		Entry("invalid name", "not-a-wal-file", 7),
+		Entry("short name", "0001", 7),
+	)
+})
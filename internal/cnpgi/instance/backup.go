@@ -37,7 +37,7 @@ import (
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/catalog"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
 )
@@ -81,9 +81,9 @@ func (b BackupServiceImplementation) Backup(
 		return nil, err
 	}
 
-	var objectStore barmancloudv1.ObjectStore
-	if err := b.Client.Get(ctx, configuration.GetBarmanObjectKey(), &objectStore); err != nil {
-		contextLogger.Error(err, "while getting object store", "key", configuration.GetRecoveryBarmanObjectKey())
+	objectStore, err := common.GetObjectStore(ctx, b.Client, configuration.GetBarmanObjectKey())
+	if err != nil {
+		contextLogger.Error(err, "while getting object store", "key", configuration.GetBarmanObjectKey())
 		return nil, err
 	}
 
@@ -111,6 +111,18 @@ func (b BackupServiceImplementation) Backup(
 		return nil, err
 	}
 
+	if err := common.EnsureServerOwnership(
+		ctx,
+		b.Client,
+		configuration.GetBarmanObjectKey(),
+		configuration.ServerName,
+		configuration.Cluster,
+		configuration.Cluster.Status.TimelineID,
+	); err != nil {
+		contextLogger.Error(err, "while checking the ownership of the server")
+		return nil, err
+	}
+
 	backupName := fmt.Sprintf("backup-%v", pgTime.ToCompactISO8601(time.Now()))
 
 	if err = backupCmd.Take(
@@ -159,11 +171,11 @@ func (b BackupServiceImplementation) Backup(
 		return nil, err
 	}
 
-	if err := updateRecoveryWindow(
+	if err := catalog.UpdateRecoveryWindow(
 		ctx,
 		b.Client,
 		backupList,
-		&objectStore,
+		configuration.GetBarmanObjectKey(),
 		configuration.ServerName,
 	); err != nil {
 		contextLogger.Error(
@@ -175,7 +187,6 @@ func (b BackupServiceImplementation) Backup(
 		contextLogger.Debug(
 			"backupName", executedBackupInfo.BackupName,
 			"Updated the recovery window in the ObjectStore status stanza",
-			"serverRecoveryWindow", objectStore.Status.ServerRecoveryWindow,
 		)
 	}
 
@@ -190,7 +201,12 @@ func (b BackupServiceImplementation) Backup(
 		EndLsn:     executedBackupInfo.EndLSN,
 		InstanceId: b.InstanceName,
 		Online:     true,
-		Metadata:   newBackupResultMetadata(configuration.Cluster.ObjectMeta.UID, executedBackupInfo.TimeLine).toMap(),
+		Metadata: catalog.NewBackupResultMetadata(
+			configuration.Cluster.ObjectMeta.UID,
+			executedBackupInfo.TimeLine,
+			objectStore,
+			configuration.ServerName,
+		).ToMap(),
 	}, nil
 }
 
@@ -198,7 +214,7 @@ func (b BackupServiceImplementation) handleBackupError(ctx context.Context, cfg
 	return retry.RetryOnConflict(
 		retry.DefaultBackoff,
 		func() error {
-			return setLastFailedBackupTime(
+			return catalog.SetLastFailedBackupTime(
 				ctx,
 				b.Client,
 				cfg.GetBarmanObjectKey(),
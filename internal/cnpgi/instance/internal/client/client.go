@@ -21,45 +21,147 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	pluginBarman "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
 )
 
-// DefaultTTLSeconds is the default TTL in seconds of cache entries
+// DefaultTTLSeconds is the default TTL in seconds of the cache entries
+// that are not kept up to date by a watch
 const DefaultTTLSeconds = 10
 
+// watchRetrySeconds is the time to wait, after a watch failed,
+// before watching the same object again
+const watchRetrySeconds = 60
+
+// errObjectDeleted is returned when the watched object is deleted
+var errObjectDeleted = errors.New("object deleted")
+
+// Statistics reports the usage of the cache
+type Statistics struct {
+	// Hits is the number of Get calls served from the cache
+	Hits uint64
+
+	// Misses is the number of Get calls served by the API server,
+	// because the object was not cached or its entry was expired
+	Misses uint64
+
+	// Refreshes is the number of cache entries updated by a watch
+	Refreshes uint64
+}
+
+// cacheKey identifies a cached object by type and name
+type cacheKey struct {
+	gvk       schema.GroupVersionKind
+	namespace string
+	name      string
+}
+
 type cachedEntry struct {
 	entry         client.Object
 	fetchUnixTime int64
-	ttlSeconds    int64
+
+	// stopWatch stops the watch keeping the entry up to date, and is
+	// nil when the entry is not watched
+	stopWatch context.CancelFunc
+
+	// watchFailedUnixTime is the last time the watch failed
+	watchFailedUnixTime int64
 }
 
-func (e *cachedEntry) isExpired() bool {
-	return time.Now().Unix()-e.fetchUnixTime > e.ttlSeconds
+func (e *cachedEntry) isExpired(ttlSeconds int64) bool {
+	if e.stopWatch != nil {
+		return false
+	}
+
+	return time.Now().Unix()-e.fetchUnixTime > ttlSeconds
 }
 
-// ExtendedClient is an extended client that is capable of caching multiple secrets without relying on informers
+// ExtendedClient is an extended client that is capable of caching
+// multiple secrets without relying on informers.
+//
+// Once started, every cached object is kept up to date by a watch
+// limited to its name, which is allowed by the Role of the instance.
+// When the watch is not available, the cached object expires after
+// a TTL.
 type ExtendedClient struct {
 	client.Client
-	cachedObjects []cachedEntry
-	mux           *sync.Mutex
+	watchClient   client.WithWatch
+	ttlSeconds    int64
+	cachedObjects map[cacheKey]*cachedEntry
+	mux           *sync.RWMutex
+
+	// watchContext is the context of the watches, and is nil
+	// when the client is not started
+	watchContext context.Context
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	refreshes atomic.Uint64
 }
 
-// NewExtendedClient returns an extended client capable of caching secrets on the 'Get' operation
+// NewExtendedClient returns an extended client capable of caching
+// secrets on the 'Get' operation. The cached objects are watched
+// through the passed watch client, if any, and otherwise expire after
+// the passed TTL.
 func NewExtendedClient(
 	baseClient client.Client,
-) client.Client {
+	watchClient client.WithWatch,
+	ttlSeconds int64,
+) *ExtendedClient {
+	if ttlSeconds <= 0 {
+		ttlSeconds = DefaultTTLSeconds
+	}
+
 	return &ExtendedClient{
-		Client: baseClient,
-		mux:    &sync.Mutex{},
+		Client:        baseClient,
+		watchClient:   watchClient,
+		ttlSeconds:    ttlSeconds,
+		cachedObjects: make(map[cacheKey]*cachedEntry),
+		mux:           &sync.RWMutex{},
+	}
+}
+
+// Start enables the watches on the cached objects until the
+// context is cancelled
+func (e *ExtendedClient) Start(ctx context.Context) error {
+	e.mux.Lock()
+	e.watchContext = ctx
+	e.mux.Unlock()
+
+	<-ctx.Done()
+
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.watchContext = nil
+	for _, cacheEntry := range e.cachedObjects {
+		if cacheEntry.stopWatch != nil {
+			cacheEntry.stopWatch()
+			cacheEntry.stopWatch = nil
+		}
+	}
+
+	return nil
+}
+
+// Statistics returns the usage of the cache
+func (e *ExtendedClient) Statistics() Statistics {
+	return Statistics{
+		Hits:      e.hits.Load(),
+		Misses:    e.misses.Load(),
+		Refreshes: e.refreshes.Load(),
 	}
 }
 
@@ -72,9 +174,26 @@ func (e *ExtendedClient) isObjectCached(obj client.Object) bool {
 		return true
 	}
 
+	if _, isClusterObjectStore := obj.(*pluginBarman.ClusterObjectStore); isClusterObjectStore {
+		return true
+	}
+
 	return false
 }
 
+func (e *ExtendedClient) keyFor(key client.ObjectKey, obj client.Object) (cacheKey, error) {
+	gvk, err := e.GroupVersionKindFor(obj)
+	if err != nil {
+		return cacheKey{}, err
+	}
+
+	return cacheKey{
+		gvk:       gvk,
+		namespace: key.Namespace,
+		name:      key.Name,
+	}, nil
+}
+
 // Get behaves like the original Get method, but uses a cache for secrets
 func (e *ExtendedClient) Get(
 	ctx context.Context,
@@ -99,73 +218,225 @@ func (e *ExtendedClient) getCachedObject(
 		WithName("extended_client").
 		WithValues("name", key.Name, "namespace", key.Namespace)
 
-	contextLogger.Trace("locking the cache")
+	cacheKey, err := e.keyFor(key, obj)
+	if err != nil {
+		return err
+	}
+
+	if found, err := e.loadCachedObject(cacheKey, obj); found || err != nil {
+		contextLogger.Debug("object found, loading it from cache")
+		e.hits.Add(1)
+		return err
+	}
+
+	e.misses.Add(1)
+	if err := e.Client.Get(ctx, key, obj, opts...); err != nil {
+		return err
+	}
+
+	contextLogger.Debug("setting object in the cache")
+	e.storeCachedObject(ctx, cacheKey, obj)
+
+	return nil
+}
+
+// loadCachedObject copies the cached object having the passed key
+// into obj, if it is cached and not expired
+func (e *ExtendedClient) loadCachedObject(key cacheKey, obj client.Object) (bool, error) {
+	e.mux.RLock()
+	defer e.mux.RUnlock()
+
+	cacheEntry, ok := e.cachedObjects[key]
+	if !ok || cacheEntry.isExpired(e.ttlSeconds) {
+		return false, nil
+	}
+
+	// Yes, this is a terrible hack, but that's exactly the way
+	// controller-runtime works.
+	// https://github.com/kubernetes-sigs/controller-runtime/blob/
+	// 717b32aede14c921d239cf1b974a11e718949865/pkg/cache/internal/cache_reader.go#L92
+	outVal := reflect.ValueOf(obj)
+	objVal := reflect.ValueOf(cacheEntry.entry.DeepCopyObject())
+	if !objVal.Type().AssignableTo(outVal.Type()) {
+		return true, fmt.Errorf("cache had type %s, but %s was asked for", objVal.Type(), outVal.Type())
+	}
+
+	reflect.Indirect(outVal).Set(reflect.Indirect(objVal))
+	return true, nil
+}
+
+// storeCachedObject sets the passed object in the cache, starting
+// a watch on it when the client is started
+func (e *ExtendedClient) storeCachedObject(ctx context.Context, key cacheKey, obj client.Object) {
 	e.mux.Lock()
 	defer e.mux.Unlock()
 
-	// check if in cache
-	expiredObjectIndex := -1
-	for idx, cacheEntry := range e.cachedObjects {
-		if cacheEntry.entry.GetNamespace() != key.Namespace || cacheEntry.entry.GetName() != key.Name {
-			continue
-		}
-		if reflect.TypeOf(cacheEntry.entry) != reflect.TypeOf(obj) {
-			continue
-		}
-		if cacheEntry.isExpired() {
-			contextLogger.Trace("expired object found")
-			expiredObjectIndex = idx
-			break
-		}
+	cacheEntry, ok := e.cachedObjects[key]
+	if !ok {
+		cacheEntry = &cachedEntry{}
+		e.cachedObjects[key] = cacheEntry
+	}
+	cacheEntry.entry = obj.DeepCopyObject().(client.Object)
+	cacheEntry.fetchUnixTime = time.Now().Unix()
 
-		contextLogger.Debug("object found, loading it from cache")
+	if cacheEntry.stopWatch != nil || e.watchClient == nil || e.watchContext == nil {
+		return
+	}
+	if time.Now().Unix()-cacheEntry.watchFailedUnixTime < watchRetrySeconds {
+		return
+	}
+
+	watchContext, cancel := context.WithCancel(e.watchContext)
+	cacheEntry.stopWatch = cancel
+	go e.watchObject(
+		log.IntoContext(watchContext, log.FromContext(ctx)),
+		key,
+		cacheEntry,
+		obj.GetResourceVersion(),
+	)
+}
+
+// watchObject keeps the passed cache entry up to date until the
+// context is cancelled. When the watch fails, the entry is left to
+// expire after the TTL.
+func (e *ExtendedClient) watchObject(
+	ctx context.Context,
+	key cacheKey,
+	cacheEntry *cachedEntry,
+	resourceVersion string,
+) {
+	contextLogger := log.FromContext(ctx).
+		WithName("extended_client").
+		WithValues("kind", key.gvk.Kind, "name", key.name, "namespace", key.namespace)
+
+	defer e.stopWatching(cacheEntry)
+
+	for {
+		var err error
+		resourceVersion, err = e.watchObjectOnce(ctx, key, cacheEntry, resourceVersion)
+		switch {
+		case errors.Is(err, errObjectDeleted):
+			contextLogger.Debug("object deleted, removing it from the cache")
+			e.removeCacheEntry(key, cacheEntry)
+			return
 
-		// Yes, this is a terrible hack, but that's exactly the way
-		// controller-runtime works.
-		// https://github.com/kubernetes-sigs/controller-runtime/blob/
-		// 717b32aede14c921d239cf1b974a11e718949865/pkg/cache/internal/cache_reader.go#L92
-		outVal := reflect.ValueOf(obj)
-		objVal := reflect.ValueOf(cacheEntry.entry)
-		if !objVal.Type().AssignableTo(outVal.Type()) {
-			return fmt.Errorf("cache had type %s, but %s was asked for", objVal.Type(), outVal.Type())
+		case err != nil:
+			contextLogger.Debug("watch failed, the cache entry will expire", "error", err.Error())
+			return
 		}
 
-		reflect.Indirect(outVal).Set(reflect.Indirect(objVal))
-		return nil
+		if ctx.Err() != nil {
+			return
+		}
 	}
+}
 
-	if err := e.Client.Get(ctx, key, obj, opts...); err != nil {
-		return err
+// watchObjectOnce runs a watch on the passed cache entry until it is
+// closed by the API server, returning the last seen resource version
+func (e *ExtendedClient) watchObjectOnce(
+	ctx context.Context,
+	key cacheKey,
+	cacheEntry *cachedEntry,
+	resourceVersion string,
+) (string, error) {
+	list, err := e.Scheme().New(key.gvk.GroupVersion().WithKind(key.gvk.Kind + "List"))
+	if err != nil {
+		return resourceVersion, err
 	}
 
-	cs := cachedEntry{
-		entry:         obj.DeepCopyObject().(client.Object),
-		fetchUnixTime: time.Now().Unix(),
-		ttlSeconds:    DefaultTTLSeconds,
+	opts := []client.ListOption{
+		client.MatchingFields{"metadata.name": key.name},
+		&client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: resourceVersion}},
+	}
+	if len(key.namespace) > 0 {
+		opts = append(opts, client.InNamespace(key.namespace))
 	}
 
-	contextLogger.Debug("setting object in the cache")
-	if expiredObjectIndex != -1 {
-		e.cachedObjects[expiredObjectIndex] = cs
-	} else {
-		e.cachedObjects = append(e.cachedObjects, cs)
+	watcher, err := e.watchClient.Watch(ctx, list.(client.ObjectList), opts...)
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer watcher.Stop()
+
+	for event := range watcher.ResultChan() {
+		switch event.Type {
+		case watch.Added, watch.Modified:
+			obj, ok := event.Object.(client.Object)
+			if !ok || obj.GetName() != key.name {
+				continue
+			}
+			resourceVersion = obj.GetResourceVersion()
+			e.refreshCachedObject(key, cacheEntry, obj)
+
+		case watch.Deleted:
+			if obj, ok := event.Object.(client.Object); ok && obj.GetName() != key.name {
+				continue
+			}
+			return resourceVersion, errObjectDeleted
+
+		case watch.Error:
+			return resourceVersion, fmt.Errorf("watch error: %v", event.Object)
+		}
 	}
 
-	return nil
+	return resourceVersion, nil
+}
+
+// refreshCachedObject replaces the object of the passed cache entry,
+// unless the entry has been removed from the cache
+func (e *ExtendedClient) refreshCachedObject(key cacheKey, cacheEntry *cachedEntry, obj client.Object) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if e.cachedObjects[key] != cacheEntry {
+		return
+	}
+
+	cacheEntry.entry = obj.DeepCopyObject().(client.Object)
+	cacheEntry.fetchUnixTime = time.Now().Unix()
+	e.refreshes.Add(1)
+}
+
+// stopWatching marks the passed cache entry as not watched, so that
+// it expires after the TTL
+func (e *ExtendedClient) stopWatching(cacheEntry *cachedEntry) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if cacheEntry.stopWatch != nil {
+		cacheEntry.stopWatch()
+		cacheEntry.stopWatch = nil
+		cacheEntry.watchFailedUnixTime = time.Now().Unix()
+	}
+}
+
+// removeCacheEntry removes the passed cache entry, unless it has
+// already been replaced
+func (e *ExtendedClient) removeCacheEntry(key cacheKey, cacheEntry *cachedEntry) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	if e.cachedObjects[key] == cacheEntry {
+		delete(e.cachedObjects, key)
+	}
 }
 
 // removeObject ensures that a object is not present in the cache
 func (e *ExtendedClient) removeObject(object client.Object) {
+	key, err := e.keyFor(client.ObjectKeyFromObject(object), object)
+	if err != nil {
+		return
+	}
+
 	e.mux.Lock()
 	defer e.mux.Unlock()
 
-	for i, cache := range e.cachedObjects {
-		if cache.entry.GetNamespace() == object.GetNamespace() &&
-			cache.entry.GetName() == object.GetName() &&
-			reflect.TypeOf(cache.entry) == reflect.TypeOf(object) {
-			e.cachedObjects = append(e.cachedObjects[:i], e.cachedObjects[i+1:]...)
-			return
+	if cacheEntry, ok := e.cachedObjects[key]; ok {
+		if cacheEntry.stopWatch != nil {
+			cacheEntry.stopWatch()
+			cacheEntry.stopWatch = nil
 		}
+		delete(e.cachedObjects, key)
 	}
 }
 
@@ -20,9 +20,11 @@ SPDX-License-Identifier: Apache-2.0
 package client
 
 import (
+	"context"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -44,14 +46,19 @@ func buildScheme() *runtime.Scheme {
 	return scheme
 }
 
+func cacheKeyOf(c *ExtendedClient, obj client.Object) cacheKey {
+	key, err := c.keyFor(client.ObjectKeyFromObject(obj), obj)
+	Expect(err).NotTo(HaveOccurred())
+	return key
+}
+
 func addToCache(c *ExtendedClient, obj client.Object, fetchUnixTime int64) {
-	ce := cachedEntry{
+	ce := &cachedEntry{
 		entry:         obj.DeepCopyObject().(client.Object),
 		fetchUnixTime: fetchUnixTime,
-		ttlSeconds:    DefaultTTLSeconds,
 	}
 	ce.entry.SetResourceVersion("from cache")
-	c.cachedObjects = append(c.cachedObjects, ce)
+	c.cachedObjects[cacheKeyOf(c, obj)] = ce
 }
 
 var _ = Describe("ExtendedClient Get", func() {
@@ -79,7 +86,7 @@ var _ = Describe("ExtendedClient Get", func() {
 		baseClient := fake.NewClientBuilder().
 			WithScheme(scheme).
 			WithObjects(secretInClient, objectStore).Build()
-		extendedClient = NewExtendedClient(baseClient).(*ExtendedClient)
+		extendedClient = NewExtendedClient(baseClient, nil, DefaultTTLSeconds)
 	})
 
 	It("returns secret from cache if not expired", func(ctx SpecContext) {
@@ -96,8 +103,9 @@ var _ = Describe("ExtendedClient Get", func() {
 
 		err := extendedClient.Get(ctx, client.ObjectKeyFromObject(secretNotInClient), secretInClient)
 		Expect(err).NotTo(HaveOccurred())
-		Expect(secretInClient).To(Equal(extendedClient.cachedObjects[0].entry))
+		Expect(secretInClient.GetName()).To(Equal(secretNotInClient.GetName()))
 		Expect(secretInClient.GetResourceVersion()).To(Equal("from cache"))
+		Expect(extendedClient.Statistics().Hits).To(BeEquivalentTo(1))
 	})
 
 	It("fetches secret from base client if cache is expired", func(ctx SpecContext) {
@@ -109,7 +117,9 @@ var _ = Describe("ExtendedClient Get", func() {
 
 		// the cache is updated with the new value
 		Expect(extendedClient.cachedObjects).To(HaveLen(1))
-		Expect(extendedClient.cachedObjects[0].entry.GetResourceVersion()).NotTo(Equal("from cache"))
+		Expect(extendedClient.cachedObjects[cacheKeyOf(extendedClient, secretInClient)].entry.GetResourceVersion()).
+			NotTo(Equal("from cache"))
+		Expect(extendedClient.Statistics().Misses).To(BeEquivalentTo(1))
 	})
 
 	It("fetches secret from base client if not in cache", func(ctx SpecContext) {
@@ -164,3 +174,109 @@ var _ = Describe("ExtendedClient Get", func() {
 			Expect(objectStore.GetResourceVersion()).To(Equal("from cache"))
 		})
 })
+
+var _ = Describe("ExtendedClient watches", func() {
+	var (
+		baseClient     client.WithWatch
+		extendedClient *ExtendedClient
+		secret         *corev1.Secret
+	)
+
+	readSecretData := func(ctx context.Context) string {
+		var cached corev1.Secret
+		Expect(extendedClient.Get(ctx, client.ObjectKeyFromObject(secret), &cached)).To(Succeed())
+		return string(cached.Data["key"])
+	}
+
+	BeforeEach(func(ctx SpecContext) {
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test-secret",
+			},
+			Data: map[string][]byte{"key": []byte("old")},
+		}
+		baseClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+		extendedClient = NewExtendedClient(baseClient, baseClient, DefaultTTLSeconds)
+
+		startContext, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		go func() {
+			defer GinkgoRecover()
+			Expect(extendedClient.Start(startContext)).To(Succeed())
+		}()
+		Eventually(func() bool {
+			extendedClient.mux.RLock()
+			defer extendedClient.mux.RUnlock()
+			return extendedClient.watchContext != nil
+		}).Should(BeTrue())
+
+		Expect(readSecretData(ctx)).To(Equal("old"))
+	})
+
+	It("refreshes the cached objects when they change", func(ctx SpecContext) {
+		// The watch is started in the background, so the change is
+		// repeated until it is observed
+		Eventually(func() string {
+			var current corev1.Secret
+			Expect(baseClient.Get(ctx, client.ObjectKeyFromObject(secret), &current)).To(Succeed())
+			current.Data["key"] = []byte("new")
+			current.Annotations = map[string]string{"updated": time.Now().String()}
+			Expect(baseClient.Update(ctx, &current)).To(Succeed())
+			return readSecretData(ctx)
+		}).Should(Equal("new"))
+		Expect(extendedClient.Statistics().Misses).To(BeEquivalentTo(1))
+		Expect(extendedClient.Statistics().Refreshes).To(BeNumerically(">=", 1))
+	})
+
+	It("does not expire the watched objects", func(ctx SpecContext) {
+		extendedClient.mux.Lock()
+		extendedClient.cachedObjects[cacheKeyOf(extendedClient, secret)].fetchUnixTime = 0
+		extendedClient.mux.Unlock()
+
+		Expect(readSecretData(ctx)).To(Equal("old"))
+		Expect(extendedClient.Statistics().Misses).To(BeEquivalentTo(1))
+	})
+
+	It("ignores the changes to other objects of the same type", func(ctx SpecContext) {
+		Expect(baseClient.Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "other-secret",
+			},
+			Data: map[string][]byte{"key": []byte("other")},
+		})).To(Succeed())
+
+		Consistently(func() string { return readSecretData(ctx) }, "200ms").Should(Equal("old"))
+	})
+
+	It("serves a rotated secret replaced by a new one", func(ctx SpecContext) {
+		Eventually(func() string {
+			var current corev1.Secret
+			Expect(baseClient.Get(ctx, client.ObjectKeyFromObject(secret), &current)).To(Succeed())
+			Expect(baseClient.Delete(ctx, &current)).To(Succeed())
+			Expect(baseClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: secret.Namespace,
+					Name:      secret.Name,
+				},
+				Data: map[string][]byte{"key": []byte("rotated")},
+			})).To(Succeed())
+			return readSecretData(ctx)
+		}).Should(Equal("rotated"))
+	})
+
+	It("removes the deleted objects from the cache", func(ctx SpecContext) {
+		Eventually(func() bool {
+			_ = baseClient.Create(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+				Namespace: secret.Namespace,
+				Name:      secret.Name,
+			}})
+			Expect(baseClient.Delete(ctx, secret)).To(Succeed())
+
+			var cached corev1.Secret
+			err := extendedClient.Get(ctx, client.ObjectKeyFromObject(secret), &cached)
+			return apierrs.IsNotFound(err)
+		}).Should(BeTrue())
+	})
+})
@@ -18,5 +18,5 @@ SPDX-License-Identifier: Apache-2.0
 */
 
 // Package client provides an extended client that is capable of caching multiple secrets without relying on
-// informers
+// informers, keeping them up to date through watches limited to their names
 package client
@@ -26,6 +26,7 @@ import (
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"github.com/spf13/viper"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -36,6 +37,10 @@ import (
 	extendedclient "github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/instance/internal/client"
 )
 
+// DefaultCacheTTLSeconds is the default TTL of the cached Secrets and
+// object stores that can't be watched
+const DefaultCacheTTLSeconds = extendedclient.DefaultTTLSeconds
+
 // Start starts the sidecar informers and CNPG-i server
 func Start(ctx context.Context) error {
 	scheme := common.GenerateScheme(ctx)
@@ -67,8 +72,10 @@ func Start(ctx context.Context) error {
 				DisableFor: []client.Object{
 					&corev1.Secret{},
 					&barmancloudv1.ObjectStore{},
+					&barmancloudv1.ClusterObjectStore{},
 					&cnpgv1.Cluster{},
 					&cnpgv1.Backup{},
+					&coordinationv1.Lease{},
 				},
 			},
 		},
@@ -80,10 +87,29 @@ func Start(ctx context.Context) error {
 		return err
 	}
 
-	customCacheClient := extendedclient.NewExtendedClient(mgr.GetClient())
+	watchClient, err := client.NewWithWatch(mgr.GetConfig(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create the watch client")
+		return err
+	}
+
+	customCacheClient := extendedclient.NewExtendedClient(
+		mgr.GetClient(),
+		watchClient,
+		viper.GetInt64("cache-ttl-seconds"),
+	)
+	if err := mgr.Add(customCacheClient); err != nil {
+		setupLog.Error(err, "unable to create the cache runnable")
+		return err
+	}
+
+	// The Secrets mounted by the File credentials mode are read
+	// from the filesystem, and never cached
+	credentialsClient := common.NewFileCredentialsClient(customCacheClient, namespace)
 
 	if err := mgr.Add(&CNPGI{
-		Client:         customCacheClient,
+		Client:         credentialsClient,
+		Cache:          customCacheClient,
 		InstanceName:   podName,
 		PGDataPath:     viper.GetString("pgdata"),
 		PGWALPath:      path.Join(viper.GetString("pgdata"), "pg_wal"),
@@ -95,7 +121,7 @@ func Start(ctx context.Context) error {
 	}
 
 	if err := mgr.Add(&CatalogMaintenanceRunnable{
-		Client: customCacheClient,
+		Client: credentialsClient,
 		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
 		Recorder: mgr.GetEventRecorderFor("policy-runnable"),
 		ClusterKey: types.NamespacedName{
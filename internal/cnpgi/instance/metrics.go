@@ -28,7 +28,8 @@ import (
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
-	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	extendedclient "github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/instance/internal/client"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
 )
@@ -36,9 +37,16 @@ import (
 // Sanitize the plugin name to be a valid Prometheus metric namespace
 var metricsDomain = strings.NewReplacer(".", "_", "-", "_").Replace(metadata.PluginName)
 
+// cacheStatisticsProvider reports the usage of the sidecar cache
+type cacheStatisticsProvider interface {
+	Statistics() extendedclient.Statistics
+}
+
 type metricsImpl struct {
 	// important the client should be one with a underlying cache
 	Client client.Client
+	// Cache reports the usage of the client cache, if any
+	Cache cacheStatisticsProvider
 	metrics.UnimplementedMetricsServer
 }
 
@@ -51,6 +59,9 @@ var (
 	firstRecoverabilityPointMetricName     = buildFqName("first_recoverability_point")
 	lastAvailableBackupTimestampMetricName = buildFqName("last_available_backup_timestamp")
 	lastFailedBackupTimestampMetricName    = buildFqName("last_failed_backup_timestamp")
+	cacheHitsMetricName                    = buildFqName("cache_hits_total")
+	cacheMissesMetricName                  = buildFqName("cache_misses_total")
+	cacheRefreshesMetricName               = buildFqName("cache_refreshes_total")
 )
 
 func (m metricsImpl) GetCapabilities(
@@ -97,6 +108,21 @@ func (m metricsImpl) Define(
 				Help:      "The last failed backup as a unix timestamp",
 				ValueType: &metrics.MetricType{Type: metrics.MetricType_TYPE_GAUGE},
 			},
+			{
+				FqName:    cacheHitsMetricName,
+				Help:      "The number of Secrets and object stores read from the sidecar cache",
+				ValueType: &metrics.MetricType{Type: metrics.MetricType_TYPE_COUNTER},
+			},
+			{
+				FqName:    cacheMissesMetricName,
+				Help:      "The number of Secrets and object stores read from the API server",
+				ValueType: &metrics.MetricType{Type: metrics.MetricType_TYPE_COUNTER},
+			},
+			{
+				FqName:    cacheRefreshesMetricName,
+				Help:      "The number of sidecar cache entries refreshed by a watch",
+				ValueType: &metrics.MetricType{Type: metrics.MetricType_TYPE_COUNTER},
+			},
 		},
 	}, nil
 }
@@ -114,16 +140,16 @@ func (m metricsImpl) Collect(
 		return nil, fmt.Errorf("while creating configuration from cluster definition: %w", err)
 	}
 
-	var objectStore barmancloudv1.ObjectStore
-	if err := m.Client.Get(ctx, configuration.GetBarmanObjectKey(), &objectStore); err != nil {
-		contextLogger.Error(err, "while getting object store", "key", configuration.GetRecoveryBarmanObjectKey())
+	objectStore, err := common.GetObjectStore(ctx, m.Client, configuration.GetBarmanObjectKey())
+	if err != nil {
+		contextLogger.Error(err, "while getting object store", "key", configuration.GetBarmanObjectKey())
 		return nil, err
 	}
 
 	x, ok := objectStore.Status.ServerRecoveryWindow[configuration.ServerName]
 	if !ok {
 		return &metrics.CollectMetricsResult{
-			Metrics: []*metrics.CollectMetric{
+			Metrics: append([]*metrics.CollectMetric{
 				{
 					FqName: firstRecoverabilityPointMetricName,
 					Value:  0,
@@ -136,7 +162,7 @@ func (m metricsImpl) Collect(
 					FqName: lastFailedBackupTimestampMetricName,
 					Value:  0,
 				},
-			},
+			}, m.collectCacheMetrics()...),
 		}, nil
 	}
 
@@ -154,7 +180,7 @@ func (m metricsImpl) Collect(
 	}
 
 	return &metrics.CollectMetricsResult{
-		Metrics: []*metrics.CollectMetric{
+		Metrics: append([]*metrics.CollectMetric{
 			{
 				FqName: firstRecoverabilityPointMetricName,
 				Value:  firstRecoverabilityPoint,
@@ -167,6 +193,29 @@ func (m metricsImpl) Collect(
 				FqName: lastFailedBackupTimestampMetricName,
 				Value:  lastFailedBackup,
 			},
-		},
+		}, m.collectCacheMetrics()...),
 	}, nil
 }
+
+// collectCacheMetrics reports the usage of the client cache, if any
+func (m metricsImpl) collectCacheMetrics() []*metrics.CollectMetric {
+	if m.Cache == nil {
+		return nil
+	}
+
+	statistics := m.Cache.Statistics()
+	return []*metrics.CollectMetric{
+		{
+			FqName: cacheHitsMetricName,
+			Value:  float64(statistics.Hits),
+		},
+		{
+			FqName: cacheMissesMetricName,
+			Value:  float64(statistics.Misses),
+		},
+		{
+			FqName: cacheRefreshesMetricName,
+			Value:  float64(statistics.Refreshes),
+		},
+	}
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/cloudnative-pg/cnpg-i/pkg/metrics"
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	extendedclient "github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/instance/internal/client"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -139,4 +140,25 @@ var _ = Describe("Metrics Collect method", func() {
 		_, err := m.Collect(ctx, req)
 		Expect(err).To(HaveOccurred())
 	})
+
+	It("should report the usage of the client cache", func() {
+		m.Cache = fakeCacheStatistics{Hits: 5, Misses: 2, Refreshes: 1}
+		res, err := m.Collect(ctx, req)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Metrics).To(HaveLen(6))
+
+		metricsMap := make(map[string]float64)
+		for _, metric := range res.Metrics {
+			metricsMap[metric.FqName] = metric.Value
+		}
+		Expect(metricsMap).To(HaveKeyWithValue(cacheHitsMetricName, float64(5)))
+		Expect(metricsMap).To(HaveKeyWithValue(cacheMissesMetricName, float64(2)))
+		Expect(metricsMap).To(HaveKeyWithValue(cacheRefreshesMetricName, float64(1)))
+	})
 })
+
+type fakeCacheStatistics extendedclient.Statistics
+
+func (f fakeCacheStatistics) Statistics() extendedclient.Statistics {
+	return extendedclient.Statistics(f)
+}
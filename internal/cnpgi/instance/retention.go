@@ -22,22 +22,22 @@ package instance
 import (
 	"context"
 	"fmt"
-	"os"
 	"slices"
 	"time"
 
-	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
-	barmanCredentials "github.com/cloudnative-pg/barman-cloud/pkg/credentials"
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/log"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/catalog"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
 )
 
 // defaultRetentionPolicyInterval is the retention policy interval
@@ -84,7 +84,6 @@ func (c *CatalogMaintenanceRunnable) cycle(ctx context.Context) (time.Duration,
 	contextLogger := log.FromContext(ctx)
 
 	var cluster cnpgv1.Cluster
-	var barmanObjectStore barmancloudv1.ObjectStore
 
 	if err := c.Client.Get(ctx, c.ClusterKey, &cluster); err != nil {
 		return 0, err
@@ -101,11 +100,12 @@ func (c *CatalogMaintenanceRunnable) cycle(ctx context.Context) (time.Duration,
 		return 0, fmt.Errorf("invalid configuration, missing barman object store reference")
 	}
 
-	if err := c.Client.Get(ctx, configuration.GetBarmanObjectKey(), &barmanObjectStore); err != nil {
+	barmanObjectStore, err := common.GetObjectStore(ctx, c.Client, configuration.GetBarmanObjectKey())
+	if err != nil {
 		return 0, err
 	}
 
-	if err := c.maintenance(ctx, &cluster, &barmanObjectStore); err != nil {
+	if err := c.maintenance(ctx, &cluster, barmanObjectStore); err != nil {
 		return 0, err
 	}
 
@@ -114,153 +114,66 @@ func (c *CatalogMaintenanceRunnable) cycle(ctx context.Context) (time.Duration,
 	return nextCheckInterval, nil
 }
 
-// maintenance executes a collection of operations:
-//
-// - applies the retention policy to the object.
-//
-// - store and deletes the stale Kubernetes backup objects.
-//
-// - updates the first recoverability point.
+// maintenance runs the catalog maintenance if the current pod is the
+// primary instance. The primary holds the catalog maintenance Lease
+// for as long as it is running, so that the operator never runs
+// the maintenance concurrently.
 func (c *CatalogMaintenanceRunnable) maintenance(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
 	objectStore *barmancloudv1.ObjectStore,
 ) error {
 	contextLogger := log.FromContext(ctx)
-	configuration := config.NewFromCluster(cluster)
-	retentionPolicy := objectStore.Spec.RetentionPolicy
+	leaseKey := client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      specs.GetCatalogMaintenanceName(cluster.Name),
+	}
 
 	if cluster.Status.CurrentPrimary != c.CurrentPodName {
 		contextLogger.Info(
 			"Skipping retention policy enforcement, not the current primary",
 			"currentPrimary", cluster.Status.CurrentPrimary, "podName", c.CurrentPodName)
+		if err := catalog.ReleaseLease(ctx, c.Client, leaseKey, c.CurrentPodName); err != nil &&
+			!isLeaseUnavailable(err) {
+			contextLogger.Error(err, "while releasing the catalog maintenance lease")
+		}
 		return nil
 	}
 
-	env, err := barmanCredentials.EnvSetCloudCredentialsAndCertificates(
+	// The lease is held for two maintenance periods, and renewed on every cycle.
+	// Other instances of the same cluster can be preempted, as only the current
+	// primary runs the maintenance. The catalog maintenance Job can't.
+	leaseDuration := 2 * time.Second * time.Duration(
+		objectStore.Spec.InstanceSidecarConfiguration.RetentionPolicyIntervalSeconds)
+	acquired, err := catalog.AcquireLease(
 		ctx,
 		c.Client,
-		objectStore.Namespace,
-		&objectStore.Spec.Configuration,
-		os.Environ(),
-		common.BuildCertificateFilePath(objectStore.Name),
-	)
-	if err != nil {
-		contextLogger.Error(err, "while setting backup cloud credentials")
-		return err
-	}
-
-	if len(retentionPolicy) == 0 {
-		contextLogger.Info("Skipping retention policy enforcement, no retention policy specified")
-	} else {
-		contextLogger.Info("Applying backup retention policy",
-			"retentionPolicy", retentionPolicy)
-
-		if err := barmanCommand.DeleteBackupsByPolicy(
-			ctx,
-			&objectStore.Spec.Configuration,
-			configuration.ServerName,
-			env,
-			retentionPolicy,
-		); err != nil {
-			contextLogger.Error(err, "while enforcing retention policies")
-			c.Recorder.Event(cluster, "Warning", "RetentionPolicyFailed", "Retention policy failed")
-			return err
-		}
-	}
-
-	backupList, err := barmanCommand.GetBackupList(
-		ctx,
-		&objectStore.Spec.Configuration,
-		configuration.ServerName,
-		env,
+		leaseKey,
+		c.CurrentPodName,
+		leaseDuration,
+		func(currentHolder string) bool {
+			return currentHolder != specs.GetCatalogMaintenanceName(cluster.Name)
+		},
 	)
-	if err != nil {
-		contextLogger.Error(err, "while reading the backup list")
-		return err
-	}
-
-	if err := deleteBackupsNotInCatalog(ctx, c.Client, cluster, backupList.GetBackupIDs()); err != nil {
-		contextLogger.Error(err, "while deleting Backups not present in the catalog")
-		return err
-	}
-
-	return updateRecoveryWindow(ctx, c.Client, backupList, objectStore, configuration.ServerName)
-}
-
-// deleteBackupsNotInCatalog deletes all Backup objects pointing to the given cluster that are not
-// present in the backup anymore
-func deleteBackupsNotInCatalog(
-	ctx context.Context,
-	cli client.Client,
-	cluster *cnpgv1.Cluster,
-	backupIDs []string,
-) error {
-	// We had two options:
-	//
-	// A. quicker
-	// get policy checker function
-	// get all backups in the namespace for this cluster
-	// check with policy checker function if backup should be deleted, then delete it if true
-	//
-	// B. more precise
-	// get the catalog (GetBackupList)
-	// get all backups in the namespace for this cluster
-	// go through all backups and delete them if not in the catalog
-	//
-	// 1: all backups in the bucket should be also in the cluster
-	// 2: all backups in the cluster should be in the bucket
-	//
-	// A can violate 1 and 2
-	// A + B can still violate 2
-	// B satisfies 1 and 2
-	//
-	// We chose to go with B
-
-	contextLogger := log.FromContext(ctx)
-	contextLogger.Debug("Checking the catalog to delete backups not present anymore")
-
-	backups := cnpgv1.BackupList{}
-	if err := cli.List(ctx, &backups, client.InNamespace(cluster.GetNamespace())); err != nil {
-		return fmt.Errorf("while getting backups: %w", err)
-	}
-
-	var errors []error
-	for id, backup := range backups.Items {
-		if backup.Spec.Cluster.Name != cluster.GetName() ||
-			backup.Status.Phase != cnpgv1.BackupPhaseCompleted ||
-			!useSameBackupLocation(&backup.Status, cluster) {
-			continue
-		}
-
-		// here we could add further checks, e.g. if the backup is not found but would still
-		// be in the retention policy we could either not delete it or update it is status
-		if !slices.Contains(backupIDs, backup.Status.BackupID) {
-			contextLogger.Info("Deleting backup not in the catalog", "backup", backup.Name)
-			if err := cli.Delete(ctx, &backups.Items[id]); err != nil {
-				errors = append(errors, fmt.Errorf(
-					"while deleting backup %s/%s: %w",
-					backup.Namespace,
-					backup.Name,
-					err,
-				))
-			}
-		}
-	}
-
-	if len(errors) > 0 {
-		return fmt.Errorf("got errors while deleting Backups not in the cluster: %v", errors)
+	switch {
+	case isLeaseUnavailable(err):
+		// The lease has not been created by the operator, or we
+		// lack the permissions to use it. This happens when the
+		// operator and the sidecar are running different versions.
+		contextLogger.Debug("Catalog maintenance lease not available, proceeding without it",
+			"error", err.Error())
+	case err != nil:
+		return fmt.Errorf("while acquiring the catalog maintenance lease: %w", err)
+	case !acquired:
+		contextLogger.Info("Skipping catalog maintenance, the lease is held by the catalog maintenance job")
+		return nil
 	}
 
-	return nil
+	return catalog.Maintain(ctx, c.Client, c.Recorder, cluster, objectStore)
 }
 
-// useSameBackupLocation checks whether the given backup was taken using the same configuration as provided
-func useSameBackupLocation(backup *cnpgv1.BackupStatus, cluster *cnpgv1.Cluster) bool {
-	if backup.Method != cnpgv1.BackupMethodPlugin {
-		return false
-	}
-
-	meta := newBackupResultMetadataFromMap(backup.PluginMetadata)
-	return meta.clusterUID == string(cluster.UID) && meta.pluginName == metadata.PluginName
+// isLeaseUnavailable checks whether the passed error means that the
+// catalog maintenance lease can't be used
+func isLeaseUnavailable(err error) bool {
+	return apierrs.IsNotFound(err) || apierrs.IsForbidden(err)
 }
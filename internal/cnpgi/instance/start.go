@@ -35,6 +35,7 @@ import (
 // CNPGI is the implementation of the PostgreSQL sidecar
 type CNPGI struct {
 	Client         client.Client
+	Cache          cacheStatisticsProvider
 	PGDataPath     string
 	PGWALPath      string
 	SpoolDirectory string
@@ -59,6 +60,7 @@ func (c *CNPGI) Start(ctx context.Context) error {
 		})
 		metrics.RegisterMetricsServer(server, &metricsImpl{
 			Client: c.Client,
+			Cache:  c.Cache,
 		})
 		common.AddHealthCheck(server)
 		return nil
@@ -36,6 +36,76 @@ const (
 	// of it.
 	ClusterLabelName = "barmancloud.cnpg.io/cluster"
 
+	// ClusterNamespaceLabelName is the label applied to the objects
+	// created by this plugin outside the namespace of the owning Cluster,
+	// such as the RBAC resources granting access to a ClusterObjectStore.
+	// Its value is the namespace of the owning Cluster.
+	ClusterNamespaceLabelName = "barmancloud.cnpg.io/clusterNamespace"
+
+	// ObjectStoreLabelName is the label applied to the objects created
+	// by this plugin on behalf of an ObjectStore, such as the probe
+	// Job. Its value is the name of the ObjectStore.
+	ObjectStoreLabelName = "barmancloud.cnpg.io/objectStore"
+
+	// ObjectStoreFinalizerName is the finalizer applied to the
+	// ObjectStores, preventing their deletion while any Cluster
+	// is still using them
+	ObjectStoreFinalizerName = "barmancloud.cnpg.io/objectstore-protection"
+
+	// BackupDataDeletionFinalizerName is the finalizer applied to the
+	// Backups stored in an object store having the Delete backup
+	// deletion policy, holding their deletion until their data has
+	// been removed from the object store
+	BackupDataDeletionFinalizerName = "barmancloud.cnpg.io/backup-data-deletion"
+
+	// BackupKeepDataAnnotationName is the annotation allowing a Backup
+	// holding the data deletion finalizer to be deleted while keeping
+	// its data in the object store, for example when the plugin refuses
+	// to delete the oldest backup of the server. Its value must be true.
+	BackupKeepDataAnnotationName = "barmancloud.cnpg.io/keepBackupData"
+
+	// ProbeGenerationAnnotationName is the annotation applied to the
+	// probe Job, recording the generation of the ObjectStore spec
+	// being probed
+	ProbeGenerationAnnotationName = "barmancloud.cnpg.io/probeGeneration"
+
+	// ServerPurgeClusterUIDAnnotationName is the annotation applied to
+	// the server purge Job, recording the UID of the deleted Cluster
+	// whose data is being purged
+	ServerPurgeClusterUIDAnnotationName = "barmancloud.cnpg.io/purgeClusterUID"
+
+	// ServerPurgeFinalizerName is the finalizer applied to the Clusters
+	// having the Delete purge policy, allowing the plugin to record the
+	// purge of their data when they are deleted
+	ServerPurgeFinalizerName = "barmancloud.cnpg.io/server-purge"
+
+	// ServerTakeoverAnnotationName is the annotation allowing a Cluster
+	// to take over the ownership of its server in the object store.
+	// Its value must be the UID of the Cluster currently owning it,
+	// as reported by the error refusing the write.
+	ServerTakeoverAnnotationName = "barmancloud.cnpg.io/serverTakeover"
+
+	// ObjectStoreGenerationAnnotationName is the annotation applied to
+	// the instance pods when the spec of one of the object stores of
+	// their Cluster changes, recording its key and generation. Changing
+	// it makes the operator reconcile the Cluster owning the pods,
+	// rolling out the ones whose sidecar is outdated.
+	ObjectStoreGenerationAnnotationName = "barmancloud.cnpg.io/objectStoreGeneration"
+
+	// BackupObjectStoreAnnotationName is the annotation applied to the
+	// Backups taken by this plugin, holding the name of the object
+	// store where the backup has been stored
+	BackupObjectStoreAnnotationName = "barmancloud.cnpg.io/backupObjectStore"
+
+	// BackupLocationAnnotationName is the annotation applied to the
+	// Backups taken by this plugin, holding the URL of the directory
+	// of the backup inside the object store
+	BackupLocationAnnotationName = "barmancloud.cnpg.io/backupLocation"
+
+	// BackupDurationAnnotationName is the annotation applied to the
+	// Backups taken by this plugin, holding the time taken by the backup
+	BackupDurationAnnotationName = "barmancloud.cnpg.io/backupDuration"
+
 	// AppLabelValue is the value applied to app.kubernetes.io/name on
 	// every plugin-managed object. It identifies the application as
 	// the Barman Cloud plugin (see issue #545).
@@ -58,6 +128,23 @@ const (
 	// BarmanCertificatesFileName is the path where the Barman
 	// certificates will be used
 	BarmanCertificatesFileName = "barman-ca.crt"
+
+	// BarmanCredentialsPath is the path where the Secrets referenced
+	// by the credentials are mounted, when using the File
+	// credentials mode
+	BarmanCredentialsPath = "/barman-credentials"
+
+	// BarmanWorkloadIdentityPath is the path where the service account
+	// tokens of the workload identities are projected
+	BarmanWorkloadIdentityPath = "/barman-workload-identity"
+
+	// BarmanWorkloadIdentityTokenFileName is the name of the file
+	// hosting the service account token of a workload identity
+	BarmanWorkloadIdentityTokenFileName = "token"
+
+	// BarmanSpoolPath is the path where the dedicated volume hosting
+	// the WAL restore spool is mounted
+	BarmanSpoolPath = "/barman-spool"
 )
 
 // Data is the metadata of this plugin.
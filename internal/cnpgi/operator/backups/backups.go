@@ -0,0 +1,127 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backups
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/catalog"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+)
+
+// IsPluginBackup checks whether the passed Backup is taken by this plugin
+func IsPluginBackup(backup *cnpgv1.Backup) bool {
+	return backup.Spec.Method == cnpgv1.BackupMethodPlugin &&
+		backup.Spec.PluginConfiguration != nil &&
+		backup.Spec.PluginConfiguration.Name == metadata.PluginName
+}
+
+// Validate checks that the passed Backup, taken by this plugin, can
+// be executed for the passed Cluster.
+//
+// The plugin takes no parameter for backups: they are rejected
+// instead of being silently ignored by the sidecar.
+func Validate(backup *cnpgv1.Backup, cluster *cnpgv1.Cluster) error {
+	if parameters := backup.Spec.PluginConfiguration.Parameters; len(parameters) > 0 {
+		return fmt.Errorf("unknown backup parameters: %s",
+			strings.Join(slices.Sorted(maps.Keys(parameters)), ", "))
+	}
+
+	if len(config.NewFromCluster(cluster).BarmanObjectName) == 0 {
+		return fmt.Errorf("the Cluster %s does not set the barmanObjectName parameter of the plugin",
+			cluster.Name)
+	}
+
+	return nil
+}
+
+// BuildAnnotations builds the annotations describing the passed
+// Backup, using the data known when it is called. The backup
+// location is only known once the Backup has been completed.
+//
+// The catalog is not read: the size, the keep status and the
+// verification result of the backup are not provided.
+func BuildAnnotations(backup *cnpgv1.Backup) map[string]string {
+	result := make(map[string]string, 3)
+
+	backupMetadata := catalog.NewBackupResultMetadataFromMap(backup.Status.PluginMetadata)
+	if name := backupMetadata.GetObjectStoreName(); len(name) > 0 {
+		result[metadata.BackupObjectStoreAnnotationName] = name
+	}
+	if location := backupMetadata.GetBackupLocation(backup.Status.BackupID); len(location) > 0 {
+		result[metadata.BackupLocationAnnotationName] = location
+	}
+
+	if backup.Status.StartedAt != nil && backup.Status.StoppedAt != nil {
+		duration := backup.Status.StoppedAt.Sub(backup.Status.StartedAt.Time)
+		result[metadata.BackupDurationAnnotationName] = duration.String()
+	}
+
+	return result
+}
+
+// Annotate patches the annotations of the passed Backup to match
+// the ones built by BuildAnnotations, if needed
+func Annotate(ctx context.Context, c client.Client, backup *cnpgv1.Backup) error {
+	desired := BuildAnnotations(backup)
+
+	needsUpdate := false
+	for key, value := range desired {
+		if backup.Annotations[key] != value {
+			needsUpdate = true
+			break
+		}
+	}
+	if !needsUpdate {
+		return nil
+	}
+
+	log.FromContext(ctx).Debug("Annotating backup",
+		"backupName", backup.Name, "annotations", desired)
+
+	original := backup.DeepCopy()
+	if backup.Annotations == nil {
+		backup.Annotations = make(map[string]string, len(desired))
+	}
+	maps.Copy(backup.Annotations, desired)
+
+	return c.Patch(ctx, backup, client.MergeFrom(original))
+}
+
+// MarkAsFailed sets the phase of the passed Backup to failed,
+// reporting the passed error
+func MarkAsFailed(ctx context.Context, c client.Client, backup *cnpgv1.Backup, err error) error {
+	log.FromContext(ctx).Info("Marking backup as failed",
+		"backupName", backup.Name, "reason", err.Error())
+
+	original := backup.DeepCopy()
+	backup.Status.SetAsFailed(err)
+
+	return c.Status().Patch(ctx, backup, client.MergeFrom(original))
+}
@@ -0,0 +1,190 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backups_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/backups"
+)
+
+func newPluginBackup(parameters map[string]string) *cnpgv1.Backup {
+	return &cnpgv1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "backup",
+			Namespace: "default",
+		},
+		Spec: cnpgv1.BackupSpec{
+			Cluster: cnpgv1.LocalObjectReference{Name: "cluster"},
+			Method:  cnpgv1.BackupMethodPlugin,
+			PluginConfiguration: &cnpgv1.BackupPluginConfiguration{
+				Name:       metadata.PluginName,
+				Parameters: parameters,
+			},
+		},
+	}
+}
+
+func newCluster(parameters map[string]string) *cnpgv1.Cluster {
+	return &cnpgv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "cluster",
+			Namespace: "default",
+		},
+		Spec: cnpgv1.ClusterSpec{
+			Plugins: []cnpgv1.PluginConfiguration{
+				{
+					Name:       metadata.PluginName,
+					Parameters: parameters,
+				},
+			},
+		},
+	}
+}
+
+func newCompletedBackup() *cnpgv1.Backup {
+	backup := newPluginBackup(nil)
+	startedAt := metav1.NewTime(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC))
+	backup.Status = cnpgv1.BackupStatus{
+		Phase:     cnpgv1.BackupPhaseCompleted,
+		BackupID:  "20260101T100000",
+		StartedAt: &startedAt,
+		StoppedAt: ptr.To(metav1.NewTime(startedAt.Add(90 * time.Second))),
+		PluginMetadata: map[string]string{
+			"objectStoreName": "store",
+			"destinationPath": "s3://bucket/path/",
+			"serverName":      "cluster",
+		},
+	}
+	return backup
+}
+
+func newScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(cnpgv1.AddToScheme(s))
+	return s
+}
+
+var _ = Describe("IsPluginBackup", func() {
+	It("should accept the Backups taken by this plugin", func() {
+		Expect(backups.IsPluginBackup(newPluginBackup(nil))).To(BeTrue())
+	})
+
+	It("should reject the Backups taken by other plugins", func() {
+		backup := newPluginBackup(nil)
+		backup.Spec.PluginConfiguration.Name = "other"
+		Expect(backups.IsPluginBackup(backup)).To(BeFalse())
+	})
+
+	It("should reject the Backups not taken by a plugin", func() {
+		backup := newPluginBackup(nil)
+		backup.Spec.Method = cnpgv1.BackupMethodVolumeSnapshot
+		Expect(backups.IsPluginBackup(backup)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Validate", func() {
+	It("should accept a Backup of a Cluster archiving into an ObjectStore", func() {
+		cluster := newCluster(map[string]string{"barmanObjectName": "store"})
+		Expect(backups.Validate(newPluginBackup(nil), cluster)).To(Succeed())
+	})
+
+	It("should reject the unknown parameters", func() {
+		cluster := newCluster(map[string]string{"barmanObjectName": "store"})
+		backup := newPluginBackup(map[string]string{"serverName": "x", "barmanObjectName": "y"})
+		Expect(backups.Validate(backup, cluster)).To(MatchError(
+			"unknown backup parameters: barmanObjectName, serverName"))
+	})
+
+	It("should reject the Backups of a Cluster not archiving into an ObjectStore", func() {
+		cluster := newCluster(map[string]string{"serverName": "cluster"})
+		Expect(backups.Validate(newPluginBackup(nil), cluster)).To(MatchError(
+			ContainSubstring("does not set the barmanObjectName parameter")))
+	})
+})
+
+var _ = Describe("BuildAnnotations", func() {
+	It("should describe a completed Backup", func() {
+		Expect(backups.BuildAnnotations(newCompletedBackup())).To(Equal(map[string]string{
+			metadata.BackupObjectStoreAnnotationName: "store",
+			metadata.BackupLocationAnnotationName:    "s3://bucket/path/cluster/base/20260101T100000",
+			metadata.BackupDurationAnnotationName:    "1m30s",
+		}))
+	})
+
+	It("should be empty for a Backup not started yet", func() {
+		Expect(backups.BuildAnnotations(newPluginBackup(nil))).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Annotate and MarkAsFailed", func() {
+	var (
+		ctx        context.Context
+		fakeClient client.Client
+		backup     *cnpgv1.Backup
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		backup = newCompletedBackup()
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(newScheme()).
+			WithObjects(backup.DeepCopy()).
+			WithStatusSubresource(&cnpgv1.Backup{}).
+			Build()
+	})
+
+	It("should annotate the Backup, keeping the other annotations", func() {
+		var stored cnpgv1.Backup
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(backup), &stored)).To(Succeed())
+		stored.Annotations = map[string]string{"user": "value"}
+		Expect(fakeClient.Update(ctx, &stored)).To(Succeed())
+
+		Expect(backups.Annotate(ctx, fakeClient, &stored)).To(Succeed())
+
+		var updated cnpgv1.Backup
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(backup), &updated)).To(Succeed())
+		Expect(updated.Annotations).To(HaveKeyWithValue("user", "value"))
+		Expect(updated.Annotations).To(HaveKeyWithValue(metadata.BackupDurationAnnotationName, "1m30s"))
+	})
+
+	It("should mark the Backup as failed", func() {
+		backup.Status.Phase = cnpgv1.BackupPhasePending
+		Expect(backups.MarkAsFailed(ctx, fakeClient, backup, errors.New("invalid backup"))).To(Succeed())
+
+		var updated cnpgv1.Backup
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(backup), &updated)).To(Succeed())
+		Expect(updated.Status.Phase).To(BeEquivalentTo(cnpgv1.BackupPhaseFailed))
+		Expect(updated.Status.Error).To(Equal("invalid backup"))
+	})
+})
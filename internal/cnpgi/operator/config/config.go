@@ -20,13 +20,17 @@ SPDX-License-Identifier: Apache-2.0
 package config
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
-	"github.com/cloudnative-pg/machinery/pkg/stringset"
 	"k8s.io/apimachinery/pkg/types"
 
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
 )
 
@@ -64,64 +68,170 @@ func (e *ConfigurationError) IsEmpty() bool {
 	return len(e.messages) == 0
 }
 
+const (
+	// PurgePolicyRetain keeps the data archived by a Cluster in the
+	// object store once the Cluster is deleted
+	PurgePolicyRetain = "Retain"
+
+	// PurgePolicyDelete removes the data archived by a Cluster from
+	// the object store once the Cluster is deleted, after the purge
+	// grace period
+	PurgePolicyDelete = "Delete"
+)
+
 // PluginConfiguration is the configuration of the plugin
 type PluginConfiguration struct {
 	Cluster *cnpgv1.Cluster
 
 	BarmanObjectName string
+	// BarmanObjectKind is the kind of the barman object, either
+	// ObjectStore or ClusterObjectStore
+	BarmanObjectKind string
 	ServerName       string
 
+	// WALBarmanObjectName is the name of the barman object used for
+	// the WAL archive, when it differs from the one used for the
+	// base backups
+	WALBarmanObjectName string
+	WALBarmanObjectKind string
+
+	// ImportBackups enables the creation of the Backup objects
+	// for the backups found in the catalog and missing in Kubernetes
+	ImportBackups bool
+
+	// PurgePolicy defines what happens to the data archived by the
+	// Cluster once it is deleted, either PurgePolicyRetain or
+	// PurgePolicyDelete
+	PurgePolicy string
+
+	// PurgeGracePeriod is the time to wait after the deletion of the
+	// Cluster before purging its data, as a Go duration. Empty means
+	// that the data is purged at once.
+	PurgeGracePeriod string
+
 	RecoveryBarmanObjectName string
+	RecoveryBarmanObjectKind string
 	RecoveryServerName       string
 
+	RecoveryWALBarmanObjectName string
+	RecoveryWALBarmanObjectKind string
+
 	ReplicaSourceBarmanObjectName string
+	ReplicaSourceBarmanObjectKind string
 	ReplicaSourceServerName       string
+
+	ReplicaSourceWALBarmanObjectName string
+	ReplicaSourceWALBarmanObjectKind string
 }
 
-// GetBarmanObjectKey gets the namespaced name of the barman object
-func (config *PluginConfiguration) GetBarmanObjectKey() types.NamespacedName {
+// getBarmanObjectKey gets the key of a barman object having the passed
+// kind and name. ClusterObjectStores are cluster-scoped, and their key
+// has no namespace.
+func (config *PluginConfiguration) getBarmanObjectKey(kind, name string) types.NamespacedName {
+	if kind == barmancloudv1.ClusterObjectStoreKind {
+		return types.NamespacedName{
+			Name: name,
+		}
+	}
+
 	return types.NamespacedName{
 		Namespace: config.Cluster.Namespace,
-		Name:      config.BarmanObjectName,
+		Name:      name,
 	}
 }
 
+// GetBarmanObjectKey gets the namespaced name of the barman object
+func (config *PluginConfiguration) GetBarmanObjectKey() types.NamespacedName {
+	return config.getBarmanObjectKey(config.BarmanObjectKind, config.BarmanObjectName)
+}
+
 // GetRecoveryBarmanObjectKey gets the namespaced name of the recovery barman object
 func (config *PluginConfiguration) GetRecoveryBarmanObjectKey() types.NamespacedName {
-	return types.NamespacedName{
-		Namespace: config.Cluster.Namespace,
-		Name:      config.RecoveryBarmanObjectName,
-	}
+	return config.getBarmanObjectKey(config.RecoveryBarmanObjectKind, config.RecoveryBarmanObjectName)
 }
 
 // GetReplicaSourceBarmanObjectKey gets the namespaced name of the replica source barman object
 func (config *PluginConfiguration) GetReplicaSourceBarmanObjectKey() types.NamespacedName {
-	return types.NamespacedName{
-		Namespace: config.Cluster.Namespace,
-		Name:      config.ReplicaSourceBarmanObjectName,
+	return config.getBarmanObjectKey(config.ReplicaSourceBarmanObjectKind, config.ReplicaSourceBarmanObjectName)
+}
+
+// GetWALBarmanObjectKey gets the namespaced name of the barman object
+// containing the WAL archive. Unless a dedicated one is configured,
+// it is the same barman object containing the base backups.
+func (config *PluginConfiguration) GetWALBarmanObjectKey() types.NamespacedName {
+	if len(config.WALBarmanObjectName) == 0 {
+		return config.GetBarmanObjectKey()
 	}
+
+	return config.getBarmanObjectKey(config.WALBarmanObjectKind, config.WALBarmanObjectName)
+}
+
+// GetRecoveryWALBarmanObjectKey gets the namespaced name of the recovery
+// barman object containing the WAL archive
+func (config *PluginConfiguration) GetRecoveryWALBarmanObjectKey() types.NamespacedName {
+	if len(config.RecoveryWALBarmanObjectName) == 0 {
+		return config.GetRecoveryBarmanObjectKey()
+	}
+
+	return config.getBarmanObjectKey(config.RecoveryWALBarmanObjectKind, config.RecoveryWALBarmanObjectName)
+}
+
+// GetReplicaSourceWALBarmanObjectKey gets the namespaced name of the replica
+// source barman object containing the WAL archive
+func (config *PluginConfiguration) GetReplicaSourceWALBarmanObjectKey() types.NamespacedName {
+	if len(config.ReplicaSourceWALBarmanObjectName) == 0 {
+		return config.GetReplicaSourceBarmanObjectKey()
+	}
+
+	return config.getBarmanObjectKey(
+		config.ReplicaSourceWALBarmanObjectKind,
+		config.ReplicaSourceWALBarmanObjectName,
+	)
 }
 
 // GetReferredBarmanObjectsKey gets the list of barman objects referred by this
-// plugin configuration
+// plugin configuration. The keys of the ClusterObjectStores have no namespace.
 func (config *PluginConfiguration) GetReferredBarmanObjectsKey() []types.NamespacedName {
-	objectNames := stringset.New()
+	result := make([]types.NamespacedName, 0, 6)
 	if len(config.BarmanObjectName) > 0 {
-		objectNames.Put(config.BarmanObjectName)
+		result = append(result, config.GetBarmanObjectKey(), config.GetWALBarmanObjectKey())
 	}
 	if len(config.RecoveryBarmanObjectName) > 0 {
-		objectNames.Put(config.RecoveryBarmanObjectName)
+		result = append(result, config.GetRecoveryBarmanObjectKey(), config.GetRecoveryWALBarmanObjectKey())
 	}
 	if len(config.ReplicaSourceBarmanObjectName) > 0 {
-		objectNames.Put(config.ReplicaSourceBarmanObjectName)
+		result = append(result, config.GetReplicaSourceBarmanObjectKey(), config.GetReplicaSourceWALBarmanObjectKey())
+	}
+
+	slices.SortFunc(result, func(a, b types.NamespacedName) int {
+		return strings.Compare(a.String(), b.String())
+	})
+	return slices.Compact(result)
+}
+
+// GetReferredBarmanObjectsKeyByPrecedence gets the list of barman objects
+// referred by this plugin configuration, without duplicates, ordered by
+// precedence when configuring the sidecar: the archive object store, the
+// recovery one and the replica source one, each followed by the object
+// store of its WAL archive.
+func (config *PluginConfiguration) GetReferredBarmanObjectsKeyByPrecedence() []types.NamespacedName {
+	result := make([]types.NamespacedName, 0, 6)
+	appendKeys := func(keys ...types.NamespacedName) {
+		for _, key := range keys {
+			if !slices.Contains(result, key) {
+				result = append(result, key)
+			}
+		}
 	}
 
-	result := make([]types.NamespacedName, 0, 3)
-	for _, name := range objectNames.ToSortedList() {
-		result = append(result, types.NamespacedName{
-			Name:      name,
-			Namespace: config.Cluster.Namespace,
-		})
+	if len(config.BarmanObjectName) > 0 {
+		appendKeys(config.GetBarmanObjectKey(), config.GetWALBarmanObjectKey())
+	}
+	if len(config.RecoveryBarmanObjectName) > 0 {
+		appendKeys(config.GetRecoveryBarmanObjectKey(), config.GetRecoveryWALBarmanObjectKey())
+	}
+	if len(config.ReplicaSourceBarmanObjectName) > 0 {
+		appendKeys(config.GetReplicaSourceBarmanObjectKey(), config.GetReplicaSourceWALBarmanObjectKey())
 	}
 
 	return result
@@ -156,8 +266,14 @@ func NewFromCluster(cluster *cnpgv1.Cluster) *PluginConfiguration {
 
 	recoveryServerName := ""
 	recoveryBarmanObjectName := ""
+	recoveryBarmanObjectKind := ""
+	recoveryWALBarmanObjectName := ""
+	recoveryWALBarmanObjectKind := ""
 	if recoveryParameters := getRecoveryParameters(cluster); recoveryParameters != nil {
 		recoveryBarmanObjectName = recoveryParameters["barmanObjectName"]
+		recoveryBarmanObjectKind = getBarmanObjectKind(recoveryParameters, "barmanObjectKind")
+		recoveryWALBarmanObjectName = recoveryParameters["walBarmanObjectName"]
+		recoveryWALBarmanObjectKind = getBarmanObjectKind(recoveryParameters, "walBarmanObjectKind")
 		recoveryServerName = recoveryParameters["serverName"]
 		if len(recoveryServerName) == 0 {
 			recoveryServerName = cluster.Name
@@ -166,8 +282,14 @@ func NewFromCluster(cluster *cnpgv1.Cluster) *PluginConfiguration {
 
 	replicaSourceServerName := ""
 	replicaSourceBarmanObjectName := ""
+	replicaSourceBarmanObjectKind := ""
+	replicaSourceWALBarmanObjectName := ""
+	replicaSourceWALBarmanObjectKind := ""
 	if replicaSourceParameters := getReplicaSourceParameters(cluster); replicaSourceParameters != nil {
 		replicaSourceBarmanObjectName = replicaSourceParameters["barmanObjectName"]
+		replicaSourceBarmanObjectKind = getBarmanObjectKind(replicaSourceParameters, "barmanObjectKind")
+		replicaSourceWALBarmanObjectName = replicaSourceParameters["walBarmanObjectName"]
+		replicaSourceWALBarmanObjectKind = getBarmanObjectKind(replicaSourceParameters, "walBarmanObjectKind")
 		replicaSourceServerName = replicaSourceParameters["serverName"]
 		if len(replicaSourceServerName) == 0 {
 			replicaSourceServerName = cluster.Name
@@ -177,19 +299,90 @@ func NewFromCluster(cluster *cnpgv1.Cluster) *PluginConfiguration {
 	result := &PluginConfiguration{
 		Cluster: cluster,
 		// used for the backup/archive
-		BarmanObjectName: helper.Parameters["barmanObjectName"],
-		ServerName:       serverName,
+		BarmanObjectName:    helper.Parameters["barmanObjectName"],
+		BarmanObjectKind:    getBarmanObjectKind(helper.Parameters, "barmanObjectKind"),
+		ServerName:          serverName,
+		WALBarmanObjectName: helper.Parameters["walBarmanObjectName"],
+		WALBarmanObjectKind: getBarmanObjectKind(helper.Parameters, "walBarmanObjectKind"),
+		ImportBackups:       parseBoolParameter(helper.Parameters["importBackups"]),
+		PurgePolicy:         getPurgePolicy(helper.Parameters),
+		PurgeGracePeriod:    helper.Parameters["purgeGracePeriod"],
 		// used for restore and wal_restore during backup recovery
-		RecoveryServerName:       recoveryServerName,
-		RecoveryBarmanObjectName: recoveryBarmanObjectName,
+		RecoveryServerName:          recoveryServerName,
+		RecoveryBarmanObjectName:    recoveryBarmanObjectName,
+		RecoveryBarmanObjectKind:    recoveryBarmanObjectKind,
+		RecoveryWALBarmanObjectName: recoveryWALBarmanObjectName,
+		RecoveryWALBarmanObjectKind: recoveryWALBarmanObjectKind,
 		// used for wal_restore in the designed primary of a replica cluster
-		ReplicaSourceServerName:       replicaSourceServerName,
-		ReplicaSourceBarmanObjectName: replicaSourceBarmanObjectName,
+		ReplicaSourceServerName:          replicaSourceServerName,
+		ReplicaSourceBarmanObjectName:    replicaSourceBarmanObjectName,
+		ReplicaSourceBarmanObjectKind:    replicaSourceBarmanObjectKind,
+		ReplicaSourceWALBarmanObjectName: replicaSourceWALBarmanObjectName,
+		ReplicaSourceWALBarmanObjectKind: replicaSourceWALBarmanObjectKind,
+	}
+
+	return result
+}
+
+// getBarmanObjectKind gets the kind of a barman object from the passed
+// plugin parameter, defaulting to ObjectStore
+func getBarmanObjectKind(parameters map[string]string, parameterName string) string {
+	if kind := parameters[parameterName]; len(kind) > 0 {
+		return kind
+	}
+
+	return barmancloudv1.ObjectStoreKind
+}
+
+// getPurgePolicy gets the purge policy from the passed plugin
+// parameters, defaulting to PurgePolicyRetain
+func getPurgePolicy(parameters map[string]string) string {
+	if policy := parameters["purgePolicy"]; len(policy) > 0 {
+		return policy
+	}
+
+	return PurgePolicyRetain
+}
+
+// GetPurgeGracePeriod gets the time to wait after the deletion of the
+// Cluster before purging its data. Invalid values, which are rejected
+// by Validate, are considered zero.
+func (config *PluginConfiguration) GetPurgeGracePeriod() time.Duration {
+	if len(config.PurgeGracePeriod) == 0 {
+		return 0
+	}
+
+	result, err := time.ParseDuration(config.PurgeGracePeriod)
+	if err != nil || result < 0 {
+		return 0
+	}
+
+	return result
+}
+
+// GetArchiveBarmanObjectsKey gets the keys of the barman objects the
+// Cluster archives into: the one containing the base backups and,
+// when different, the one containing the WAL archive
+func (config *PluginConfiguration) GetArchiveBarmanObjectsKey() []types.NamespacedName {
+	if len(config.BarmanObjectName) == 0 {
+		return nil
+	}
+
+	result := []types.NamespacedName{config.GetBarmanObjectKey()}
+	if walKey := config.GetWALBarmanObjectKey(); walKey != result[0] {
+		result = append(result, walKey)
 	}
 
 	return result
 }
 
+// parseBoolParameter parses a boolean plugin parameter. Missing
+// or invalid values are considered false.
+func parseBoolParameter(value string) bool {
+	result, err := strconv.ParseBool(value)
+	return err == nil && result
+}
+
 func getRecoveryParameters(cluster *cnpgv1.Cluster) map[string]string {
 	recoveryPluginConfiguration := getRecoverySourcePlugin(cluster)
 	if recoveryPluginConfiguration == nil {
@@ -269,6 +462,52 @@ func (config *PluginConfiguration) Validate() error {
 		return err.WithMessage("no reference to barmanObjectName have been included")
 	}
 
+	if len(config.BarmanObjectName) == 0 && len(config.WALBarmanObjectName) > 0 {
+		err = err.WithMessage("walBarmanObjectName requires barmanObjectName to be set")
+	}
+	if len(config.RecoveryBarmanObjectName) == 0 && len(config.RecoveryWALBarmanObjectName) > 0 {
+		err = err.WithMessage("walBarmanObjectName of the recovery source requires barmanObjectName to be set")
+	}
+	if len(config.ReplicaSourceBarmanObjectName) == 0 && len(config.ReplicaSourceWALBarmanObjectName) > 0 {
+		err = err.WithMessage("walBarmanObjectName of the replica source requires barmanObjectName to be set")
+	}
+
+	for _, kind := range []string{
+		config.BarmanObjectKind,
+		config.WALBarmanObjectKind,
+		config.RecoveryBarmanObjectKind,
+		config.RecoveryWALBarmanObjectKind,
+		config.ReplicaSourceBarmanObjectKind,
+		config.ReplicaSourceWALBarmanObjectKind,
+	} {
+		if len(kind) > 0 &&
+			kind != barmancloudv1.ObjectStoreKind &&
+			kind != barmancloudv1.ClusterObjectStoreKind {
+			err = err.WithMessage(fmt.Sprintf(
+				"invalid barman object kind %q, must be %s or %s",
+				kind, barmancloudv1.ObjectStoreKind, barmancloudv1.ClusterObjectStoreKind))
+		}
+	}
+
+	if len(config.PurgePolicy) > 0 &&
+		config.PurgePolicy != PurgePolicyRetain &&
+		config.PurgePolicy != PurgePolicyDelete {
+		err = err.WithMessage(fmt.Sprintf(
+			"invalid purge policy %q, must be %s or %s",
+			config.PurgePolicy, PurgePolicyRetain, PurgePolicyDelete))
+	}
+	if len(config.PurgeGracePeriod) > 0 {
+		if gracePeriod, parseErr := time.ParseDuration(config.PurgeGracePeriod); parseErr != nil || gracePeriod < 0 {
+			err = err.WithMessage(fmt.Sprintf(
+				"invalid purge grace period %q, must be a non-negative duration such as 72h",
+				config.PurgeGracePeriod))
+		}
+	}
+
+	if !err.IsEmpty() {
+		return err
+	}
+
 	return nil
 }
 
@@ -20,8 +20,11 @@ SPDX-License-Identifier: Apache-2.0
 package config
 
 import (
+	"time"
+
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -123,4 +126,196 @@ var _ = Describe("NewFromCluster", func() {
 		Expect(cfg.ReplicaSourceBarmanObjectName).To(BeEmpty())
 		Expect(cfg.Validate()).NotTo(Succeed())
 	})
+	It("enables the catalog import only when requested", func() {
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-example", Namespace: "test-ns"},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName": "minio-store",
+						},
+					},
+				},
+			},
+		}
+
+		Expect(NewFromCluster(cluster).ImportBackups).To(BeFalse())
+
+		cluster.Spec.Plugins[0].Parameters["importBackups"] = "true"
+		Expect(NewFromCluster(cluster).ImportBackups).To(BeTrue())
+
+		cluster.Spec.Plugins[0].Parameters["importBackups"] = "maybe"
+		Expect(NewFromCluster(cluster).ImportBackups).To(BeFalse())
+	})
+
+	It("reads the purge policy and its grace period", func() {
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-example", Namespace: "test-ns"},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName": "minio-store",
+						},
+					},
+				},
+			},
+		}
+
+		cfg := NewFromCluster(cluster)
+		Expect(cfg.PurgePolicy).To(Equal(PurgePolicyRetain))
+		Expect(cfg.GetPurgeGracePeriod()).To(BeZero())
+
+		cluster.Spec.Plugins[0].Parameters["purgePolicy"] = PurgePolicyDelete
+		cluster.Spec.Plugins[0].Parameters["purgeGracePeriod"] = "72h"
+		cfg = NewFromCluster(cluster)
+		Expect(cfg.Validate()).To(Succeed())
+		Expect(cfg.PurgePolicy).To(Equal(PurgePolicyDelete))
+		Expect(cfg.GetPurgeGracePeriod()).To(Equal(72 * time.Hour))
+	})
+
+	It("rejects an invalid purge policy or grace period", func() {
+		cfg := &PluginConfiguration{BarmanObjectName: "my-store", PurgePolicy: "Purge"}
+		Expect(cfg.Validate()).To(MatchError(ContainSubstring("invalid purge policy")))
+
+		cfg = &PluginConfiguration{BarmanObjectName: "my-store", PurgeGracePeriod: "3 days"}
+		Expect(cfg.Validate()).To(MatchError(ContainSubstring("invalid purge grace period")))
+		Expect(cfg.GetPurgeGracePeriod()).To(BeZero())
+	})
+
+	It("lists the object stores the cluster archives into", func() {
+		cfg := &PluginConfiguration{
+			Cluster:                  &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}},
+			BarmanObjectName:         "archive",
+			RecoveryBarmanObjectName: "recovery",
+		}
+		Expect(cfg.GetArchiveBarmanObjectsKey()).To(Equal([]types.NamespacedName{
+			{Namespace: "test-ns", Name: "archive"},
+		}))
+
+		cfg.WALBarmanObjectName = "wal"
+		cfg.WALBarmanObjectKind = "ClusterObjectStore"
+		Expect(cfg.GetArchiveBarmanObjectsKey()).To(Equal([]types.NamespacedName{
+			{Namespace: "test-ns", Name: "archive"},
+			{Name: "wal"},
+		}))
+	})
+
+	It("resolves ClusterObjectStores as cluster-scoped keys", func() {
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-example", Namespace: "test-ns"},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName": "shared-store",
+							"barmanObjectKind": "ClusterObjectStore",
+						},
+					},
+				},
+			},
+		}
+
+		cfg := NewFromCluster(cluster)
+		Expect(cfg.Validate()).To(Succeed())
+		Expect(cfg.GetBarmanObjectKey()).To(Equal(types.NamespacedName{Name: "shared-store"}))
+		Expect(cfg.GetReferredBarmanObjectsKey()).To(ConsistOf(types.NamespacedName{Name: "shared-store"}))
+
+		delete(cluster.Spec.Plugins[0].Parameters, "barmanObjectKind")
+		cfg = NewFromCluster(cluster)
+		Expect(cfg.GetBarmanObjectKey()).To(Equal(types.NamespacedName{Namespace: "test-ns", Name: "shared-store"}))
+	})
+
+	It("orders the referred object stores by precedence", func() {
+		cfg := &PluginConfiguration{
+			Cluster:                       &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"}},
+			BarmanObjectName:              "z-archive",
+			WALBarmanObjectName:           "y-wal",
+			RecoveryBarmanObjectName:      "a-recovery",
+			ReplicaSourceBarmanObjectName: "z-archive",
+		}
+
+		Expect(cfg.GetReferredBarmanObjectsKeyByPrecedence()).To(Equal([]types.NamespacedName{
+			{Namespace: "test-ns", Name: "z-archive"},
+			{Namespace: "test-ns", Name: "y-wal"},
+			{Namespace: "test-ns", Name: "a-recovery"},
+		}))
+	})
+
+	It("rejects an unknown barmanObjectKind", func() {
+		cfg := &PluginConfiguration{BarmanObjectName: "my-store", BarmanObjectKind: "Bucket"}
+		Expect(cfg.Validate()).To(MatchError(ContainSubstring("invalid barman object kind")))
+	})
+
+	It("uses a dedicated destination for the WAL archive when requested", func() {
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-example", Namespace: "test-ns"},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName":    "base-store",
+							"walBarmanObjectName": "wal-store",
+						},
+					},
+				},
+			},
+		}
+
+		cfg := NewFromCluster(cluster)
+		Expect(cfg.Validate()).To(Succeed())
+		Expect(cfg.GetBarmanObjectKey()).To(Equal(types.NamespacedName{Namespace: "test-ns", Name: "base-store"}))
+		Expect(cfg.GetWALBarmanObjectKey()).To(Equal(types.NamespacedName{Namespace: "test-ns", Name: "wal-store"}))
+		Expect(cfg.GetReferredBarmanObjectsKey()).To(ConsistOf(
+			types.NamespacedName{Namespace: "test-ns", Name: "base-store"},
+			types.NamespacedName{Namespace: "test-ns", Name: "wal-store"},
+		))
+
+		delete(cluster.Spec.Plugins[0].Parameters, "walBarmanObjectName")
+		cfg = NewFromCluster(cluster)
+		Expect(cfg.GetWALBarmanObjectKey()).To(Equal(cfg.GetBarmanObjectKey()))
+		Expect(cfg.GetReferredBarmanObjectsKey()).To(HaveLen(1))
+	})
+
+	It("reads the WAL archive destination of the recovery source", func() {
+		cluster := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster-example", Namespace: "test-ns"},
+			Spec: cnpgv1.ClusterSpec{
+				Bootstrap: &cnpgv1.BootstrapConfiguration{
+					Recovery: &cnpgv1.BootstrapRecovery{Source: "origin"},
+				},
+				ExternalClusters: []cnpgv1.ExternalCluster{
+					{
+						Name: "origin",
+						PluginConfiguration: &cnpgv1.PluginConfiguration{
+							Name: metadata.PluginName,
+							Parameters: map[string]string{
+								"barmanObjectName":    "base-store",
+								"walBarmanObjectName": "wal-store",
+								"walBarmanObjectKind": "ClusterObjectStore",
+							},
+						},
+					},
+				},
+			},
+		}
+
+		cfg := NewFromCluster(cluster)
+		Expect(cfg.Validate()).To(Succeed())
+		Expect(cfg.GetRecoveryBarmanObjectKey()).To(Equal(types.NamespacedName{Namespace: "test-ns", Name: "base-store"}))
+		Expect(cfg.GetRecoveryWALBarmanObjectKey()).To(Equal(types.NamespacedName{Name: "wal-store"}))
+	})
+
+	It("rejects a WAL archive destination without a base backup one", func() {
+		cfg := &PluginConfiguration{
+			RecoveryBarmanObjectName: "recovery-store",
+			WALBarmanObjectName:      "wal-store",
+		}
+		Expect(cfg.Validate()).To(MatchError(ContainSubstring("walBarmanObjectName requires barmanObjectName")))
+	})
 })
@@ -0,0 +1,126 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// ResolutionError is returned when the credentials of an ObjectStore
+// are incomplete or reference missing Secret keys
+type ResolutionError struct {
+	// Reason is the reason of the CredentialsResolved condition
+	Reason string
+
+	// Message is a human readable description of the error
+	Message string
+}
+
+// Error implements the error interface
+func (e *ResolutionError) Error() string {
+	return e.Message
+}
+
+// Resolve checks that every Secret key referenced by the passed
+// configuration exists in the passed namespace. It returns whether
+// the credentials are provided by the environment rather than by
+// Secrets, and a *ResolutionError when they cannot be resolved.
+func Resolve(
+	ctx context.Context,
+	c client.Reader,
+	namespace string,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+) (bool, error) {
+	inherited := false
+	switch {
+	case configuration.AWS != nil:
+		inherited = configuration.AWS.InheritFromIAMRole
+		if !inherited && (configuration.AWS.AccessKeyIDReference == nil ||
+			configuration.AWS.SecretAccessKeyReference == nil) {
+			return false, &ResolutionError{
+				Reason:  barmancloudv1.ReasonMissingCredentials,
+				Message: "the access key ID and the secret access key are required",
+			}
+		}
+
+	case configuration.Azure != nil:
+		inherited = configuration.Azure.InheritFromAzureAD || configuration.Azure.UseDefaultAzureCredentials
+		if !inherited && configuration.Azure.ConnectionString == nil &&
+			configuration.Azure.StorageAccount == nil {
+			return false, &ResolutionError{
+				Reason:  barmancloudv1.ReasonMissingCredentials,
+				Message: "either the connection string or the storage account is required",
+			}
+		}
+
+	case configuration.Google != nil:
+		inherited = configuration.Google.GKEEnvironment && configuration.Google.ApplicationCredentials == nil
+		if !inherited && configuration.Google.ApplicationCredentials == nil {
+			return false, &ResolutionError{
+				Reason:  barmancloudv1.ReasonMissingCredentials,
+				Message: "the application credentials are required",
+			}
+		}
+
+	default:
+		return false, &ResolutionError{
+			Reason:  barmancloudv1.ReasonMissingCredentials,
+			Message: "no credentials defined",
+		}
+	}
+
+	selectors := specs.CollectSecretKeySelectorsFromCredentials(&configuration.BarmanCredentials)
+	if configuration.EndpointCA != nil {
+		selectors = append(selectors, configuration.EndpointCA)
+	}
+	for _, selector := range selectors {
+		var secret corev1.Secret
+		if err := c.Get(ctx, client.ObjectKey{
+			Namespace: namespace,
+			Name:      selector.Name,
+		}, &secret); err != nil {
+			if apierrs.IsNotFound(err) {
+				return false, &ResolutionError{
+					Reason:  barmancloudv1.ReasonSecretNotFound,
+					Message: fmt.Sprintf("secret %s not found", selector.Name),
+				}
+			}
+			return false, fmt.Errorf("while getting secret %s: %w", selector.Name, err)
+		}
+
+		if _, ok := secret.Data[selector.Key]; !ok {
+			return false, &ResolutionError{
+				Reason:  barmancloudv1.ReasonSecretKeyNotFound,
+				Message: fmt.Sprintf("missing key %s, inside secret %s", selector.Key, selector.Name),
+			}
+		}
+	}
+
+	return inherited, nil
+}
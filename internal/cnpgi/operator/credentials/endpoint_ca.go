@@ -0,0 +1,115 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// EnsureEndpointCASecrets copies the endpoint CA of the passed
+// ClusterObjectStores in the namespace of the Cluster, where it can
+// be projected in the sidecar containers. The copies are owned by
+// the Cluster and kept in sync with their source. The Secrets are
+// read through the passed reader, to avoid caching all of them.
+func EnsureEndpointCASecrets(
+	ctx context.Context,
+	c client.Client,
+	apiReader client.Reader,
+	cluster *cnpgv1.Cluster,
+	clusterObjectStores []barmancloudv1.ClusterObjectStore,
+) error {
+	for i := range clusterObjectStores {
+		clusterObjectStore := &clusterObjectStores[i]
+		endpointCA := clusterObjectStore.Spec.Configuration.EndpointCA
+		if endpointCA == nil {
+			continue
+		}
+
+		var source corev1.Secret
+		if err := apiReader.Get(ctx, client.ObjectKey{
+			Namespace: clusterObjectStore.Spec.CredentialsNamespace,
+			Name:      endpointCA.Name,
+		}, &source); err != nil {
+			return fmt.Errorf("while getting the endpoint CA of ClusterObjectStore %s: %w",
+				clusterObjectStore.Name, err)
+		}
+
+		if err := ensureEndpointCASecret(
+			ctx,
+			c,
+			apiReader,
+			cluster,
+			specs.BuildEndpointCASecret(cluster, clusterObjectStore, &source),
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func ensureEndpointCASecret(
+	ctx context.Context,
+	c client.Client,
+	apiReader client.Reader,
+	cluster *cnpgv1.Cluster,
+	newSecret *corev1.Secret,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	var secret corev1.Secret
+	err := apiReader.Get(ctx, client.ObjectKeyFromObject(newSecret), &secret)
+	if apierrs.IsNotFound(err) {
+		if err := specs.SetControllerReference(cluster, newSecret); err != nil {
+			return err
+		}
+
+		contextLogger.Info("Creating endpoint CA secret",
+			"name", newSecret.Name, "namespace", newSecret.Namespace)
+		if err := c.Create(ctx, newSecret); err != nil && !apierrs.IsAlreadyExists(err) {
+			return err
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(secret.Data, newSecret.Data) {
+		return nil
+	}
+
+	contextLogger.Info("Updating endpoint CA secret",
+		"name", newSecret.Name, "namespace", newSecret.Namespace)
+	oldSecret := secret.DeepCopy()
+	secret.Data = newSecret.Data
+	return c.Patch(ctx, &secret, client.MergeFrom(oldSecret))
+}
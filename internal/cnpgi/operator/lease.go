@@ -0,0 +1,61 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// ensureCatalogMaintenanceLease creates the Lease coordinating the
+// catalog maintenance between the primary instance and the operator,
+// if it doesn't exist. The Lease is owned by the Cluster.
+func ensureCatalogMaintenanceLease(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+) error {
+	newLease := specs.BuildCatalogMaintenanceLease(cluster)
+
+	var lease coordinationv1.Lease
+	err := c.Get(ctx, client.ObjectKeyFromObject(newLease), &lease)
+	if err == nil || !apierrs.IsNotFound(err) {
+		return err
+	}
+
+	if err := specs.SetControllerReference(cluster, newLease); err != nil {
+		return err
+	}
+
+	log.FromContext(ctx).Info("Creating catalog maintenance lease",
+		"name", newLease.Name, "namespace", newLease.Namespace)
+	if err := c.Create(ctx, newLease); err != nil && !apierrs.IsAlreadyExists(err) {
+		return err
+	}
+
+	return nil
+}
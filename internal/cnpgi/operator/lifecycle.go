@@ -23,6 +23,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
@@ -33,21 +34,39 @@ import (
 	"github.com/spf13/viper"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
 )
 
 // fullRecoveryJobName is the name of the restore job.
 const fullRecoveryJobName = "full-recovery"
 
+// sidecarContainerName is the name of the container injected by the plugin
+const sidecarContainerName = "plugin-barman-cloud"
+
+// defaultSpoolDirectory is the directory hosting the WAL restore spool
+// when no dedicated volume is requested
+const defaultSpoolDirectory = "/controller/wal-restore-spool"
+
 // LifecycleImplementation is the implementation of the lifecycle handler
 type LifecycleImplementation struct {
 	lifecycle.UnimplementedOperatorLifecycleServer
 	Client client.Client
+
+	// APIReader reads the objects that are not worth caching, such
+	// as the running instance pods
+	APIReader client.Reader
+
+	// Recorder reports the events about the Clusters, such as the
+	// conflicts between the configuration of their object stores
+	Recorder record.EventRecorder
 }
 
 // GetCapabilities exposes the lifecycle capabilities
@@ -135,7 +154,7 @@ func (impl LifecycleImplementation) reconcileJob(
 	request *lifecycle.OperatorLifecycleRequest,
 	pluginConfiguration *config.PluginConfiguration,
 ) (*lifecycle.OperatorLifecycleResponse, error) {
-	env, err := impl.collectAdditionalEnvs(ctx, cluster.Namespace, pluginConfiguration)
+	env, err := impl.collectAdditionalEnvs(ctx, pluginConfiguration)
 	if err != nil {
 		return nil, err
 	}
@@ -145,23 +164,71 @@ func (impl LifecycleImplementation) reconcileJob(
 		return nil, err
 	}
 
+	credentials, err := impl.collectAdditionalCredentials(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	workloadIdentity, workloadIdentityEnv, err := impl.collectWorkloadIdentity(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, workloadIdentityEnv...)
+
+	recoveryJobEnv, err := impl.collectRecoveryJobEnvs(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+	env = overrideEnvs(env, recoveryJobEnv)
+
 	resources, err := impl.collectSidecarResourcesForRecoveryJob(ctx, pluginConfiguration)
 	if err != nil {
 		return nil, err
 	}
 
+	additionalArgs, err := impl.collectAdditionalRecoveryJobArgs(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := impl.collectSidecarImageForRecoveryJob(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	containerTemplate, err := impl.collectSidecarContainerTemplateForRecoveryJob(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolVolume, err := impl.collectSpoolVolumeForRecoveryJob(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
 	return reconcileJob(ctx, cluster, request, sidecarConfiguration{
-		env:          env,
-		certificates: certificates,
-		resources:    resources,
+		env:               env,
+		certificates:      certificates,
+		credentials:       credentials,
+		workloadIdentity:  workloadIdentity,
+		image:             image,
+		resources:         resources,
+		additionalArgs:    additionalArgs,
+		containerTemplate: containerTemplate,
+		spoolVolume:       spoolVolume,
 	})
 }
 
 type sidecarConfiguration struct {
-	env            []corev1.EnvVar
-	certificates   []corev1.VolumeProjection
-	resources      corev1.ResourceRequirements
-	additionalArgs []string
+	image             string
+	env               []corev1.EnvVar
+	certificates      []corev1.VolumeProjection
+	credentials       []corev1.VolumeProjection
+	workloadIdentity  []corev1.VolumeProjection
+	resources         corev1.ResourceRequirements
+	additionalArgs    []string
+	containerTemplate *corev1.Container
+	spoolVolume       *corev1.VolumeSource
 }
 
 func reconcileJob(
@@ -228,7 +295,7 @@ func (impl LifecycleImplementation) reconcilePod(
 	request *lifecycle.OperatorLifecycleRequest,
 	pluginConfiguration *config.PluginConfiguration,
 ) (*lifecycle.OperatorLifecycleResponse, error) {
-	env, err := impl.collectAdditionalEnvs(ctx, cluster.Namespace, pluginConfiguration)
+	env, err := impl.collectAdditionalEnvs(ctx, pluginConfiguration)
 	if err != nil {
 		return nil, err
 	}
@@ -238,7 +305,63 @@ func (impl LifecycleImplementation) reconcilePod(
 		return nil, err
 	}
 
-	resources, err := impl.collectSidecarResourcesForPod(ctx, pluginConfiguration)
+	credentials, err := impl.collectAdditionalCredentials(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	workloadIdentity, workloadIdentityEnv, err := impl.collectWorkloadIdentity(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+	env = append(env, workloadIdentityEnv...)
+
+	pod, err := decoder.DecodePodJSON(request.GetObjectDefinition())
+	if err != nil {
+		return nil, err
+	}
+
+	// The evaluated pod spec is compared by the operator with the one
+	// of the running pod, which is rolled out when they differ
+	var runningPod *corev1.Pod
+	if request.GetOperationType().GetType() == lifecycle.OperatorOperationType_TYPE_EVALUATE {
+		runningPod = impl.getRunningPod(ctx, cluster, pod.Name)
+	}
+
+	// The sidecar resources follow the current role of the instance: after
+	// a switchover or a failover, the pods whose sidecar has the resources
+	// of their former role are rolled out
+	primary := isPrimaryInstance(cluster, pod.Name)
+	resources, err := impl.collectSidecarResourcesForPod(ctx, pluginConfiguration, primary)
+	if err != nil {
+		return nil, err
+	}
+
+	roleChange, err := impl.getSidecarRoleChange(ctx, pluginConfiguration, primary, runningPod)
+	if err != nil {
+		return nil, err
+	}
+	if len(roleChange) > 0 && primary &&
+		cluster.GetPrimaryUpdateMethod() == cnpgv1.PrimaryUpdateMethodSwitchover {
+		// Rolling out the promoted primary would switch over to the former
+		// one, whose sidecar would then have the resources of a replica,
+		// and so on: the resources are applied when the pod is restarted
+		resources = findSidecar(&runningPod.Spec).Resources
+		impl.reportDeferredRoleChange(ctx, cluster, pod.Name)
+		roleChange = ""
+	}
+
+	image, err := impl.collectSidecarImageForPod(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	containerTemplate, err := impl.collectSidecarContainerTemplateForPod(ctx, pluginConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolVolume, err := impl.collectSpoolVolumeForPod(ctx, pluginConfiguration)
 	if err != nil {
 		return nil, err
 	}
@@ -248,12 +371,24 @@ func (impl LifecycleImplementation) reconcilePod(
 		return nil, err
 	}
 
-	return reconcileInstancePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{
-		env:            env,
-		certificates:   certificates,
-		resources:      resources,
-		additionalArgs: additionalArgs,
+	response, evaluatedPod, err := reconcileInstancePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{
+		env:               env,
+		certificates:      certificates,
+		credentials:       credentials,
+		workloadIdentity:  workloadIdentity,
+		image:             image,
+		resources:         resources,
+		additionalArgs:    additionalArgs,
+		containerTemplate: containerTemplate,
+		spoolVolume:       spoolVolume,
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	impl.reportSidecarDrift(ctx, cluster, runningPod, evaluatedPod, roleChange)
+
+	return response, nil
 }
 
 func (impl LifecycleImplementation) collectAdditionalInstanceArgs(
@@ -278,43 +413,43 @@ func (impl LifecycleImplementation) collectAdditionalInstanceArgs(
 	// bootstrap) > ReplicaSourceBarmanObjectName (pg_basebackup replica).
 	// If none is configured, no additional args are provided.
 	if len(pluginConfiguration.BarmanObjectName) > 0 {
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, pluginConfiguration.GetBarmanObjectKey(), &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, pluginConfiguration.GetBarmanObjectKey())
+		if err != nil {
 			return nil, fmt.Errorf("while getting barman object store %s: %w",
 				pluginConfiguration.GetBarmanObjectKey().String(), err)
 		}
 		args := barmanObjectStore.Spec.InstanceSidecarConfiguration.AdditionalContainerArgs
 		args = append(
 			args,
-			collectTypedAdditionalArgs(&barmanObjectStore)...,
+			collectTypedAdditionalArgs(barmanObjectStore)...,
 		)
 		return args, nil
 	}
 
 	if len(pluginConfiguration.RecoveryBarmanObjectName) > 0 {
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, pluginConfiguration.GetRecoveryBarmanObjectKey(), &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, pluginConfiguration.GetRecoveryBarmanObjectKey())
+		if err != nil {
 			return nil, fmt.Errorf("while getting recovery barman object store %s: %w",
 				pluginConfiguration.GetRecoveryBarmanObjectKey().String(), err)
 		}
 		args := barmanObjectStore.Spec.InstanceSidecarConfiguration.AdditionalContainerArgs
 		args = append(
 			args,
-			collectTypedAdditionalArgs(&barmanObjectStore)...,
+			collectTypedAdditionalArgs(barmanObjectStore)...,
 		)
 		return args, nil
 	}
 
 	if len(pluginConfiguration.ReplicaSourceBarmanObjectName) > 0 {
 		key := pluginConfiguration.GetReplicaSourceBarmanObjectKey()
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, key, &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, key)
+		if err != nil {
 			return nil, fmt.Errorf("while getting replica source barman object store %s: %w", key.String(), err)
 		}
 		args := barmanObjectStore.Spec.InstanceSidecarConfiguration.AdditionalContainerArgs
 		args = append(
 			args,
-			collectTypedAdditionalArgs(&barmanObjectStore)...,
+			collectTypedAdditionalArgs(barmanObjectStore)...,
 		)
 		return args, nil
 	}
@@ -322,16 +457,18 @@ func (impl LifecycleImplementation) collectAdditionalInstanceArgs(
 	return nil, nil
 }
 
+// reconcileInstancePod injects the sidecar in the passed instance pod,
+// returning the patch to be applied and the resulting pod
 func reconcileInstancePod(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
 	request *lifecycle.OperatorLifecycleRequest,
 	pluginConfiguration *config.PluginConfiguration,
 	config sidecarConfiguration,
-) (*lifecycle.OperatorLifecycleResponse, error) {
+) (*lifecycle.OperatorLifecycleResponse, *corev1.Pod, error) {
 	pod, err := decoder.DecodePodJSON(request.GetObjectDefinition())
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	contextLogger := log.FromContext(ctx).WithName("plugin-barman-cloud-lifecycle").
@@ -350,7 +487,7 @@ func reconcileInstancePod(
 			},
 			config,
 		); err != nil {
-			return nil, fmt.Errorf("while reconciling pod spec for pod: %w", err)
+			return nil, nil, fmt.Errorf("while reconciling pod spec for pod: %w", err)
 		}
 	} else {
 		contextLogger.Debug("No need to mutate instance with no backup & archiving configuration")
@@ -358,13 +495,13 @@ func reconcileInstancePod(
 
 	patch, err := object.CreatePatch(mutatedPod, pod)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	contextLogger.Debug("generated patch", "content", string(patch))
 	return &lifecycle.OperatorLifecycleResponse{
 		JsonPatch: patch,
-	}, nil
+	}, mutatedPod, nil
 }
 
 func reconcilePodSpec(
@@ -374,6 +511,13 @@ func reconcilePodSpec(
 	sidecarTemplate corev1.Container,
 	config sidecarConfiguration,
 ) error {
+	// Unless a dedicated volume is requested, the spool lives in the
+	// volume shared with the instance manager
+	spoolDirectory := defaultSpoolDirectory
+	if config.spoolVolume != nil {
+		spoolDirectory = metadata.BarmanSpoolPath
+	}
+
 	envs := make([]corev1.EnvVar, 0, 5+len(config.env))
 	envs = append(envs,
 		corev1.EnvVar{
@@ -385,10 +529,8 @@ func reconcilePodSpec(
 			Value: cluster.Name,
 		},
 		corev1.EnvVar{
-			// TODO: should we really use this one?
-			// should we mount an emptyDir volume just for that?
 			Name:  "SPOOL_DIRECTORY",
-			Value: "/controller/wal-restore-spool",
+			Value: spoolDirectory,
 		},
 		corev1.EnvVar{
 			Name:  "CUSTOM_CNPG_GROUP",
@@ -414,22 +556,14 @@ func reconcilePodSpec(
 	}
 
 	// fixed values
-	sidecarTemplate.Name = "plugin-barman-cloud"
-	sidecarTemplate.Image = viper.GetString("sidecar-image")
+	sidecarTemplate.Name = sidecarContainerName
+	sidecarTemplate.Image = config.image
+	if len(sidecarTemplate.Image) == 0 {
+		sidecarTemplate.Image = viper.GetString("sidecar-image")
+	}
 	sidecarTemplate.ImagePullPolicy = cluster.Spec.ImagePullPolicy
 	sidecarTemplate.StartupProbe = baseProbe.DeepCopy()
-	sidecarTemplate.SecurityContext = &corev1.SecurityContext{
-		AllowPrivilegeEscalation: ptr.To(false),
-		RunAsNonRoot:             ptr.To(true),
-		Privileged:               ptr.To(false),
-		ReadOnlyRootFilesystem:   ptr.To(true),
-		SeccompProfile: &corev1.SeccompProfile{
-			Type: corev1.SeccompProfileTypeRuntimeDefault,
-		},
-		Capabilities: &corev1.Capabilities{
-			Drop: []corev1.Capability{"ALL"},
-		},
-	}
+	sidecarTemplate.SecurityContext = specs.BuildSidecarSecurityContext()
 	sidecarTemplate.RestartPolicy = ptr.To(corev1.ContainerRestartPolicyAlways)
 	sidecarTemplate.Resources = config.resources
 	sidecarTemplate.Args = append(sidecarTemplate.Args, config.additionalArgs...)
@@ -471,12 +605,12 @@ func reconcilePodSpec(
 		sidecarTemplate.VolumeMounts = ensureVolumeMount(
 			sidecarTemplate.VolumeMounts,
 			corev1.VolumeMount{
-				Name:      barmanCertificatesVolumeName,
+				Name:      specs.BarmanCertificatesVolumeName,
 				MountPath: metadata.BarmanCertificatesPath,
 			})
 
 		spec.Volumes = ensureVolume(spec.Volumes, corev1.Volume{
-			Name: barmanCertificatesVolumeName,
+			Name: specs.BarmanCertificatesVolumeName,
 			VolumeSource: corev1.VolumeSource{
 				Projected: &corev1.ProjectedVolumeSource{
 					Sources: config.certificates,
@@ -484,8 +618,86 @@ func reconcilePodSpec(
 			},
 		})
 	} else {
-		sidecarTemplate.VolumeMounts = removeVolumeMount(sidecarTemplate.VolumeMounts, barmanCertificatesVolumeName)
-		spec.Volumes = removeVolume(spec.Volumes, barmanCertificatesVolumeName)
+		sidecarTemplate.VolumeMounts = removeVolumeMount(sidecarTemplate.VolumeMounts, specs.BarmanCertificatesVolumeName)
+		spec.Volumes = removeVolume(spec.Volumes, specs.BarmanCertificatesVolumeName)
+	}
+
+	if len(config.credentials) > 0 {
+		sidecarTemplate.VolumeMounts = ensureVolumeMount(
+			sidecarTemplate.VolumeMounts,
+			corev1.VolumeMount{
+				Name:      specs.BarmanCredentialsVolumeName,
+				MountPath: metadata.BarmanCredentialsPath,
+				ReadOnly:  true,
+			})
+
+		spec.Volumes = ensureVolume(spec.Volumes, corev1.Volume{
+			Name: specs.BarmanCredentialsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: config.credentials,
+				},
+			},
+		})
+	} else {
+		sidecarTemplate.VolumeMounts = removeVolumeMount(sidecarTemplate.VolumeMounts, specs.BarmanCredentialsVolumeName)
+		spec.Volumes = removeVolume(spec.Volumes, specs.BarmanCredentialsVolumeName)
+	}
+
+	if len(config.workloadIdentity) > 0 {
+		sidecarTemplate.VolumeMounts = ensureVolumeMount(
+			sidecarTemplate.VolumeMounts,
+			corev1.VolumeMount{
+				Name:      specs.BarmanWorkloadIdentityVolumeName,
+				MountPath: metadata.BarmanWorkloadIdentityPath,
+				ReadOnly:  true,
+			})
+
+		spec.Volumes = ensureVolume(spec.Volumes, corev1.Volume{
+			Name: specs.BarmanWorkloadIdentityVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: config.workloadIdentity,
+				},
+			},
+		})
+	} else {
+		sidecarTemplate.VolumeMounts = removeVolumeMount(
+			sidecarTemplate.VolumeMounts,
+			specs.BarmanWorkloadIdentityVolumeName,
+		)
+		spec.Volumes = removeVolume(spec.Volumes, specs.BarmanWorkloadIdentityVolumeName)
+	}
+
+	if config.spoolVolume != nil {
+		sidecarTemplate.VolumeMounts = ensureVolumeMount(
+			sidecarTemplate.VolumeMounts,
+			corev1.VolumeMount{
+				Name:      specs.BarmanSpoolVolumeName,
+				MountPath: metadata.BarmanSpoolPath,
+			})
+
+		spec.Volumes = ensureVolume(spec.Volumes, corev1.Volume{
+			Name:         specs.BarmanSpoolVolumeName,
+			VolumeSource: *config.spoolVolume,
+		})
+	} else {
+		sidecarTemplate.VolumeMounts = removeVolumeMount(sidecarTemplate.VolumeMounts, specs.BarmanSpoolVolumeName)
+		spec.Volumes = removeVolume(spec.Volumes, specs.BarmanSpoolVolumeName)
+	}
+
+	if config.containerTemplate != nil {
+		for _, mount := range config.containerTemplate.VolumeMounts {
+			if !slices.ContainsFunc(spec.Volumes, func(volume corev1.Volume) bool {
+				return volume.Name == mount.Name
+			}) {
+				return fmt.Errorf("the sidecar container template mounts the unknown volume %q", mount.Name)
+			}
+		}
+
+		if err := specs.ApplySidecarContainerTemplate(&sidecarTemplate, config.containerTemplate); err != nil {
+			return err
+		}
 	}
 
 	if err := injectPluginSidecarPodSpec(spec, &sidecarTemplate, mainContainerName); err != nil {
@@ -0,0 +1,71 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+func (impl LifecycleImplementation) collectAdditionalCredentials(
+	ctx context.Context,
+	pluginConfiguration *config.PluginConfiguration,
+) ([]corev1.VolumeProjection, error) {
+	var result []corev1.VolumeProjection
+	projectionIndex := make(map[string]int)
+
+	for _, barmanObjectKey := range pluginConfiguration.GetReferredBarmanObjectsKey() {
+		// Secrets can't be projected from other namespaces, and the
+		// File credentials mode is rejected for ClusterObjectStores
+		if len(barmanObjectKey.Namespace) == 0 {
+			continue
+		}
+
+		objectStore, err := common.GetObjectStore(ctx, impl.Client, barmanObjectKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// The object stores of a Cluster may share the same Secrets,
+		// which are projected once with the keys used by any of them
+		for _, projection := range specs.BuildCredentialsProjection(objectStore) {
+			idx, found := projectionIndex[projection.Secret.Name]
+			if !found {
+				projectionIndex[projection.Secret.Name] = len(result)
+				result = append(result, projection)
+				continue
+			}
+
+			for _, item := range projection.Secret.Items {
+				if !slices.Contains(result[idx].Secret.Items, item) {
+					result[idx].Secret.Items = append(result[idx].Secret.Items, item)
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,170 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getRunningPod gets the running instance pod having the passed name,
+// returning nil when it does not exist or cannot be read
+func (impl LifecycleImplementation) getRunningPod(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	podName string,
+) *corev1.Pod {
+	if impl.APIReader == nil {
+		return nil
+	}
+
+	var runningPod corev1.Pod
+	if err := impl.APIReader.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      podName,
+	}, &runningPod); err != nil {
+		if !apierrs.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "while getting the running pod to detect the sidecar drift",
+				"podName", podName)
+		}
+		return nil
+	}
+
+	return &runningPod
+}
+
+// reportSidecarDrift compares the sidecar of the passed running
+// instance pod with the sidecar generated from the current
+// configuration, recording the differences in an event on the Cluster,
+// together with the role change causing them, if any. The rollout itself
+// is decided by the operator, which restarts the replicas first.
+func (impl LifecycleImplementation) reportSidecarDrift(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	runningPod *corev1.Pod,
+	evaluatedPod *corev1.Pod,
+	roleChange string,
+) {
+	if runningPod == nil || impl.Recorder == nil {
+		return
+	}
+
+	contextLogger := log.FromContext(ctx).WithValues("podName", evaluatedPod.Name)
+
+	differences := getSidecarDifferences(&runningPod.Spec, &evaluatedPod.Spec)
+	if len(differences) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("the sidecar of the instance %s differs in %s",
+		evaluatedPod.Name, strings.Join(differences, ", "))
+	if len(roleChange) > 0 {
+		message = fmt.Sprintf("%s, as %s", message, roleChange)
+	}
+
+	contextLogger.Info("The sidecar configuration changed",
+		"differences", differences, "roleChange", roleChange)
+	impl.Recorder.Event(cluster, corev1.EventTypeNormal, "SidecarRolloutRequired",
+		message+", requesting its rollout")
+}
+
+// reportDeferredRoleChange records in an event on the Cluster that the
+// sidecar of the passed promoted primary keeps the resources of a
+// replica, as rolling it out would cause another switchover
+func (impl LifecycleImplementation) reportDeferredRoleChange(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	podName string,
+) {
+	if impl.Recorder == nil {
+		return
+	}
+
+	log.FromContext(ctx).Info("Not rolling out the sidecar of the promoted primary", "podName", podName)
+	impl.Recorder.Event(cluster, corev1.EventTypeWarning, "SidecarRoleChangeDeferred", fmt.Sprintf(
+		"the sidecar of the primary %s has the resources of a replica, which are kept until the pod "+
+			"is restarted, as rolling it out with the switchover primary update method would "+
+			"cause another switchover", podName))
+}
+
+// findSidecar returns the plugin sidecar of the passed pod spec, or nil
+// if there is none
+func findSidecar(spec *corev1.PodSpec) *corev1.Container {
+	idx := slices.IndexFunc(spec.InitContainers, func(container corev1.Container) bool {
+		return container.Name == sidecarContainerName
+	})
+	if idx < 0 {
+		return nil
+	}
+	return &spec.InitContainers[idx]
+}
+
+// getSidecarDifferences returns the fields of the plugin sidecar that
+// differ between the passed pod specs
+func getSidecarDifferences(running, evaluated *corev1.PodSpec) []string {
+	runningSidecar := findSidecar(running)
+	evaluatedSidecar := findSidecar(evaluated)
+	if runningSidecar == nil || evaluatedSidecar == nil {
+		if runningSidecar != evaluatedSidecar {
+			return []string{"presence"}
+		}
+		return nil
+	}
+
+	var differences []string
+	compare := func(name string, runningValue, evaluatedValue any) {
+		if !equality.Semantic.DeepEqual(runningValue, evaluatedValue) {
+			differences = append(differences, name)
+		}
+	}
+
+	compare("image", runningSidecar.Image, evaluatedSidecar.Image)
+	compare("args", runningSidecar.Args, evaluatedSidecar.Args)
+	compare("env", normalizeEnv(runningSidecar.Env), normalizeEnv(evaluatedSidecar.Env))
+	compare("resources", runningSidecar.Resources, evaluatedSidecar.Resources)
+	compare("volumeMounts", runningSidecar.VolumeMounts, evaluatedSidecar.VolumeMounts)
+
+	return differences
+}
+
+// normalizeEnv applies to the passed environment variables the defaults
+// set by the API server, so that the running ones can be compared with
+// the generated ones
+func normalizeEnv(env []corev1.EnvVar) []corev1.EnvVar {
+	result := make([]corev1.EnvVar, len(env))
+	for i := range env {
+		env[i].DeepCopyInto(&result[i])
+		if fieldRef := result[i].ValueFrom; fieldRef != nil && fieldRef.FieldRef != nil &&
+			len(fieldRef.FieldRef.APIVersion) == 0 {
+			fieldRef.FieldRef.APIVersion = "v1"
+		}
+	}
+
+	return result
+}
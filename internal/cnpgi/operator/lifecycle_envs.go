@@ -21,75 +21,85 @@ package operator
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 
+	"github.com/cloudnative-pg/machinery/pkg/log"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/types"
 
-	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
 )
 
+// collectAdditionalEnvs merges the environment variables of the object
+// stores used by a Cluster, following their precedence order. When two
+// object stores define the same variable with different values, the
+// one with the highest precedence wins, and the conflict is reported
+// in an event on the Cluster.
 func (impl LifecycleImplementation) collectAdditionalEnvs(
 	ctx context.Context,
-	namespace string,
 	pluginConfiguration *config.PluginConfiguration,
 ) ([]corev1.EnvVar, error) {
 	var result []corev1.EnvVar
+	sources := make(map[string]types.NamespacedName)
+	var conflicts []string
 
-	// TODO: check if the environment variables are clashing and in
-	// that case raise an error
-
-	if len(pluginConfiguration.BarmanObjectName) > 0 {
-		envs, err := impl.collectObjectStoreEnvs(
-			ctx,
-			types.NamespacedName{
-				Name:      pluginConfiguration.BarmanObjectName,
-				Namespace: namespace,
-			},
-		)
+	for _, barmanObjectKey := range pluginConfiguration.GetReferredBarmanObjectsKeyByPrecedence() {
+		envs, err := impl.collectObjectStoreEnvs(ctx, barmanObjectKey)
 		if err != nil {
 			return nil, err
 		}
-		result = append(result, envs...)
-	}
 
-	if len(pluginConfiguration.RecoveryBarmanObjectName) > 0 {
-		envs, err := impl.collectObjectStoreEnvs(
-			ctx,
-			types.NamespacedName{
-				Name:      pluginConfiguration.RecoveryBarmanObjectName,
-				Namespace: namespace,
-			},
-		)
-		if err != nil {
-			return nil, err
+		for _, env := range envs {
+			idx := slices.IndexFunc(result, func(existing corev1.EnvVar) bool {
+				return existing.Name == env.Name
+			})
+			if idx < 0 {
+				result = append(result, env)
+				sources[env.Name] = barmanObjectKey
+				continue
+			}
+
+			if !equality.Semantic.DeepEqual(result[idx], env) {
+				conflicts = append(conflicts, fmt.Sprintf("%s (%s overrides %s)",
+					env.Name, sources[env.Name].Name, barmanObjectKey.Name))
+			}
 		}
-		result = append(result, envs...)
 	}
 
-	if len(pluginConfiguration.ReplicaSourceBarmanObjectName) > 0 {
-		envs, err := impl.collectObjectStoreEnvs(
-			ctx,
-			types.NamespacedName{
-				Name:      pluginConfiguration.ReplicaSourceBarmanObjectName,
-				Namespace: namespace,
-			},
-		)
-		if err != nil {
-			return nil, err
-		}
-		result = append(result, envs...)
+	if len(conflicts) > 0 {
+		impl.reportEnvConflicts(ctx, pluginConfiguration, conflicts)
 	}
 
 	return result, nil
 }
 
+// reportEnvConflicts records the conflicting environment variables of
+// the object stores used by a Cluster in an event
+func (impl LifecycleImplementation) reportEnvConflicts(
+	ctx context.Context,
+	pluginConfiguration *config.PluginConfiguration,
+	conflicts []string,
+) {
+	message := fmt.Sprintf(
+		"conflicting sidecar environment variables in the object stores: %s",
+		strings.Join(conflicts, ", "))
+	log.FromContext(ctx).Info("Conflicting sidecar environment variables", "conflicts", conflicts)
+
+	if impl.Recorder != nil && pluginConfiguration.Cluster != nil {
+		impl.Recorder.Event(pluginConfiguration.Cluster, corev1.EventTypeWarning, "SidecarEnvConflict", message)
+	}
+}
+
 func (impl LifecycleImplementation) collectObjectStoreEnvs(
 	ctx context.Context,
 	barmanObjectKey types.NamespacedName,
 ) ([]corev1.EnvVar, error) {
-	var objectStore barmancloudv1.ObjectStore
-	if err := impl.Client.Get(ctx, barmanObjectKey, &objectStore); err != nil {
+	objectStore, err := common.GetObjectStore(ctx, impl.Client, barmanObjectKey)
+	if err != nil {
 		return nil, err
 	}
 
@@ -22,9 +22,12 @@ package operator
 import (
 	"context"
 
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
 )
 
@@ -33,21 +36,84 @@ func (impl LifecycleImplementation) collectSidecarResourcesForRecoveryJob(
 	configuration *config.PluginConfiguration,
 ) (corev1.ResourceRequirements, error) {
 	if len(configuration.RecoveryBarmanObjectName) > 0 {
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, configuration.GetRecoveryBarmanObjectKey(), &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, configuration.GetRecoveryBarmanObjectKey())
+		if err != nil {
 			return corev1.ResourceRequirements{}, err
 		}
 
+		if jobConfiguration := barmanObjectStore.Spec.RecoveryJobSidecarConfiguration; jobConfiguration != nil &&
+			jobConfiguration.Resources != nil {
+			return *jobConfiguration.Resources, nil
+		}
+
 		return barmanObjectStore.Spec.InstanceSidecarConfiguration.Resources, nil
 	}
 
 	return corev1.ResourceRequirements{}, nil
 }
 
+// isPrimaryInstance tells whether the instance with the given name is the
+// primary of the cluster or is being promoted to primary
+func isPrimaryInstance(cluster *cnpgv1.Cluster, instanceName string) bool {
+	if len(cluster.Status.TargetPrimary) > 0 {
+		return cluster.Status.TargetPrimary == instanceName
+	}
+
+	return cluster.Status.CurrentPrimary == instanceName
+}
+
+// getSidecarRoleChange describes the role change of the passed running
+// instance pod, whose sidecar has the resources of the other role after
+// a switchover or a failover. It returns an empty string when the
+// resources of the running sidecar don't depend on a role change.
+func (impl LifecycleImplementation) getSidecarRoleChange(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+	primary bool,
+	runningPod *corev1.Pod,
+) (string, error) {
+	if runningPod == nil {
+		return "", nil
+	}
+
+	runningSidecar := findSidecar(&runningPod.Spec)
+	if runningSidecar == nil {
+		return "", nil
+	}
+
+	resources, err := impl.collectSidecarResourcesForPod(ctx, configuration, primary)
+	if err != nil {
+		return "", err
+	}
+	otherRoleResources, err := impl.collectSidecarResourcesForPod(ctx, configuration, !primary)
+	if err != nil {
+		return "", err
+	}
+
+	if equality.Semantic.DeepEqual(resources, otherRoleResources) ||
+		!equality.Semantic.DeepEqual(runningSidecar.Resources, otherRoleResources) {
+		return "", nil
+	}
+
+	if primary {
+		return "the instance has been promoted to primary", nil
+	}
+	return "the instance has been demoted to replica", nil
+}
+
 func (impl LifecycleImplementation) collectSidecarResourcesForPod(
 	ctx context.Context,
 	configuration *config.PluginConfiguration,
+	primary bool,
 ) (corev1.ResourceRequirements, error) {
+	resourcesForRole := func(store *barmancloudv1.ObjectStore) corev1.ResourceRequirements {
+		if primary {
+			return store.Spec.InstanceSidecarConfiguration.GetPrimaryResources()
+		}
+
+		return store.Spec.InstanceSidecarConfiguration.GetReplicaResources()
+	}
+
 	// Only one object store provides the sidecar resources, with precedence:
 	// BarmanObjectName (backup/archive) > RecoveryBarmanObjectName (recovery
 	// bootstrap) > ReplicaSourceBarmanObjectName (pg_basebackup replica).
@@ -58,12 +124,12 @@ func (impl LifecycleImplementation) collectSidecarResourcesForPod(
 		// In this case, we use the cluster object store for configuring
 		// the resources of the sidecar container.
 
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, configuration.GetBarmanObjectKey(), &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, configuration.GetBarmanObjectKey())
+		if err != nil {
 			return corev1.ResourceRequirements{}, err
 		}
 
-		return barmanObjectStore.Spec.InstanceSidecarConfiguration.Resources, nil
+		return resourcesForRole(barmanObjectStore), nil
 	}
 
 	if len(configuration.RecoveryBarmanObjectName) > 0 {
@@ -71,12 +137,12 @@ func (impl LifecycleImplementation) collectSidecarResourcesForPod(
 		// replica clusters, where the recovery and replica source object stores
 		// coincide), we use the recovery object store for configuring the
 		// resources of the sidecar container.
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, configuration.GetRecoveryBarmanObjectKey(), &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, configuration.GetRecoveryBarmanObjectKey())
+		if err != nil {
 			return corev1.ResourceRequirements{}, err
 		}
 
-		return barmanObjectStore.Spec.InstanceSidecarConfiguration.Resources, nil
+		return resourcesForRole(barmanObjectStore), nil
 	}
 
 	if len(configuration.ReplicaSourceBarmanObjectName) > 0 {
@@ -84,12 +150,12 @@ func (impl LifecycleImplementation) collectSidecarResourcesForPod(
 		// primary uses only the replica source object store.
 		// In this case, we use the replica source object store for configuring
 		// the resources of the sidecar container.
-		var barmanObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, configuration.GetReplicaSourceBarmanObjectKey(), &barmanObjectStore); err != nil {
+		barmanObjectStore, err := common.GetObjectStore(ctx, impl.Client, configuration.GetReplicaSourceBarmanObjectKey())
+		if err != nil {
 			return corev1.ResourceRequirements{}, err
 		}
 
-		return barmanObjectStore.Spec.InstanceSidecarConfiguration.Resources, nil
+		return resourcesForRole(barmanObjectStore), nil
 	}
 
 	return corev1.ResourceRequirements{}, nil
@@ -0,0 +1,239 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// getSidecarObjectStoreForRecoveryJob returns the object store
+// configuring the sidecar of the recovery Jobs, if any
+func (impl LifecycleImplementation) getSidecarObjectStoreForRecoveryJob(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (*barmancloudv1.ObjectStore, error) {
+	if len(configuration.RecoveryBarmanObjectName) == 0 {
+		return nil, nil
+	}
+
+	return common.GetObjectStore(ctx, impl.Client, configuration.GetRecoveryBarmanObjectKey())
+}
+
+// getSidecarObjectStoreForPod returns the object store configuring the
+// sidecar of the instance pods, if any, with the same precedence used
+// for the resources of the sidecar
+func (impl LifecycleImplementation) getSidecarObjectStoreForPod(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (*barmancloudv1.ObjectStore, error) {
+	switch {
+	case len(configuration.BarmanObjectName) > 0:
+		return common.GetObjectStore(ctx, impl.Client, configuration.GetBarmanObjectKey())
+
+	case len(configuration.RecoveryBarmanObjectName) > 0:
+		return common.GetObjectStore(ctx, impl.Client, configuration.GetRecoveryBarmanObjectKey())
+
+	case len(configuration.ReplicaSourceBarmanObjectName) > 0:
+		return common.GetObjectStore(ctx, impl.Client, configuration.GetReplicaSourceBarmanObjectKey())
+	}
+
+	return nil, nil
+}
+
+// getSidecarImage returns the image the passed object store requests for
+// the sidecar, or an empty string when the default image should be used,
+// as none is requested or the requested one is not in the allow-list
+func getSidecarImage(ctx context.Context, objectStore *barmancloudv1.ObjectStore) string {
+	requestedImage := objectStore.Spec.InstanceSidecarConfiguration.Image
+	image := specs.GetSidecarImage(
+		objectStore,
+		"",
+		specs.ParseSidecarImageAllowList(viper.GetString("sidecar-image-allow-list")),
+	)
+	if len(requestedImage) > 0 && len(image) == 0 {
+		log.FromContext(ctx).Info(
+			"The requested sidecar image is not allowed, using the default one",
+			"objectStoreName", objectStore.Name,
+			"image", requestedImage)
+	}
+
+	return image
+}
+
+func (impl LifecycleImplementation) collectSidecarImageForRecoveryJob(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (string, error) {
+	objectStore, err := impl.getSidecarObjectStoreForRecoveryJob(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return "", err
+	}
+
+	return getSidecarImage(ctx, objectStore), nil
+}
+
+func (impl LifecycleImplementation) collectSidecarImageForPod(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (string, error) {
+	objectStore, err := impl.getSidecarObjectStoreForPod(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return "", err
+	}
+
+	return getSidecarImage(ctx, objectStore), nil
+}
+
+// collectSidecarContainerTemplateForRecoveryJob returns the container
+// template of the recoveryJobSidecarConfiguration of the recovery object
+// store, falling back to the one of the instance sidecar
+func (impl LifecycleImplementation) collectSidecarContainerTemplateForRecoveryJob(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (*corev1.Container, error) {
+	objectStore, err := impl.getSidecarObjectStoreForRecoveryJob(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return nil, err
+	}
+
+	if jobConfiguration := objectStore.Spec.RecoveryJobSidecarConfiguration; jobConfiguration != nil &&
+		jobConfiguration.ContainerTemplate != nil {
+		return jobConfiguration.ContainerTemplate, nil
+	}
+
+	return objectStore.Spec.InstanceSidecarConfiguration.ContainerTemplate, nil
+}
+
+func (impl LifecycleImplementation) collectSidecarContainerTemplateForPod(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (*corev1.Container, error) {
+	objectStore, err := impl.getSidecarObjectStoreForPod(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return nil, err
+	}
+
+	return objectStore.Spec.InstanceSidecarConfiguration.ContainerTemplate, nil
+}
+
+// collectSpoolVolumeForRecoveryJob returns the spool volume of the
+// recoveryJobSidecarConfiguration of the recovery object store, falling
+// back to the one of the instance sidecar
+func (impl LifecycleImplementation) collectSpoolVolumeForRecoveryJob(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (*corev1.VolumeSource, error) {
+	objectStore, err := impl.getSidecarObjectStoreForRecoveryJob(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return nil, err
+	}
+
+	spoolVolume := objectStore.Spec.InstanceSidecarConfiguration.SpoolVolume
+	if jobConfiguration := objectStore.Spec.RecoveryJobSidecarConfiguration; jobConfiguration != nil &&
+		jobConfiguration.SpoolVolume != nil {
+		spoolVolume = jobConfiguration.SpoolVolume
+	}
+
+	return specs.BuildSpoolVolumeSource(spoolVolume), nil
+}
+
+func (impl LifecycleImplementation) collectSpoolVolumeForPod(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) (*corev1.VolumeSource, error) {
+	objectStore, err := impl.getSidecarObjectStoreForPod(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return nil, err
+	}
+
+	return specs.BuildSpoolVolumeSource(objectStore.Spec.InstanceSidecarConfiguration.SpoolVolume), nil
+}
+
+// collectRecoveryJobEnvs returns the environment variables that the
+// recoveryJobSidecarConfiguration of the recovery object store sets for
+// the sidecar of the recovery Jobs
+func (impl LifecycleImplementation) collectRecoveryJobEnvs(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) ([]corev1.EnvVar, error) {
+	objectStore, err := impl.getSidecarObjectStoreForRecoveryJob(ctx, configuration)
+	if err != nil || objectStore == nil || objectStore.Spec.RecoveryJobSidecarConfiguration == nil {
+		return nil, err
+	}
+
+	return objectStore.Spec.RecoveryJobSidecarConfiguration.Env, nil
+}
+
+// collectAdditionalRecoveryJobArgs returns the arguments of the sidecar
+// of the recovery Jobs. The additional arguments of the instance sidecar
+// are not used, as they may refer to flags only supported by the instance
+// command, while its log level is used unless the recovery Job sets one
+func (impl LifecycleImplementation) collectAdditionalRecoveryJobArgs(
+	ctx context.Context,
+	configuration *config.PluginConfiguration,
+) ([]string, error) {
+	objectStore, err := impl.getSidecarObjectStoreForRecoveryJob(ctx, configuration)
+	if err != nil || objectStore == nil {
+		return nil, err
+	}
+
+	logLevel := objectStore.Spec.InstanceSidecarConfiguration.LogLevel
+	var args []string
+	if jobConfiguration := objectStore.Spec.RecoveryJobSidecarConfiguration; jobConfiguration != nil {
+		args = slices.Clone(jobConfiguration.AdditionalContainerArgs)
+		if len(jobConfiguration.LogLevel) > 0 {
+			logLevel = jobConfiguration.LogLevel
+		}
+	}
+
+	if len(logLevel) > 0 {
+		args = append(args, fmt.Sprintf("--log-level=%s", logLevel))
+	}
+
+	return args, nil
+}
+
+// overrideEnvs replaces the variables of env having the same name of
+// one of the overrides, and appends the remaining overrides
+func overrideEnvs(env []corev1.EnvVar, overrides []corev1.EnvVar) []corev1.EnvVar {
+	for _, override := range overrides {
+		idx := slices.IndexFunc(env, func(existing corev1.EnvVar) bool {
+			return existing.Name == override.Name
+		})
+		if idx < 0 {
+			env = append(env, override)
+			continue
+		}
+		env[idx] = override
+	}
+
+	return env
+}
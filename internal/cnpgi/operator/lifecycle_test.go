@@ -22,18 +22,25 @@ package operator
 import (
 	"encoding/json"
 
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
 	"github.com/cloudnative-pg/cnpg-i/pkg/lifecycle"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/spf13/viper"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -229,7 +236,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: podJSON,
 			}
 
-			response, err := reconcileInstancePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
+			response, _, err := reconcileInstancePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
 			Expect(err).NotTo(HaveOccurred())
 			Expect(response).NotTo(BeNil())
 			Expect(response.JsonPatch).NotTo(BeEmpty())
@@ -247,7 +254,7 @@ var _ = Describe("LifecycleImplementation", func() {
 				ObjectDefinition: []byte("invalid-json"),
 			}
 
-			response, err := reconcileInstancePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
+			response, _, err := reconcileInstancePod(ctx, cluster, request, pluginConfiguration, sidecarConfiguration{})
 			Expect(err).To(HaveOccurred())
 			Expect(response).To(BeNil())
 		})
@@ -456,7 +463,7 @@ var _ = Describe("LifecycleImplementation", func() {
 			cli := buildClientFunc(makeStoreWithResourcesFunc(ns, pc.BarmanObjectName, res)).Build()
 
 			impl := LifecycleImplementation{Client: cli}
-			got, err := impl.collectSidecarResourcesForPod(ctx, pc)
+			got, err := impl.collectSidecarResourcesForPod(ctx, pc, true)
 			Expect(err).NotTo(HaveOccurred())
 			gotMem := got.Requests[corev1.ResourceMemory]
 			Expect(gotMem.String()).To(Equal("64Mi"))
@@ -472,7 +479,7 @@ var _ = Describe("LifecycleImplementation", func() {
 			cli := buildClientFunc(makeStoreWithResourcesFunc(ns, pc.RecoveryBarmanObjectName, res)).Build()
 
 			impl := LifecycleImplementation{Client: cli}
-			got, err := impl.collectSidecarResourcesForPod(ctx, pc)
+			got, err := impl.collectSidecarResourcesForPod(ctx, pc, true)
 			Expect(err).NotTo(HaveOccurred())
 			gotMem := got.Requests[corev1.ResourceMemory]
 			Expect(gotMem.String()).To(Equal("128Mi"))
@@ -488,7 +495,7 @@ var _ = Describe("LifecycleImplementation", func() {
 			cli := buildClientFunc(makeStoreWithResourcesFunc(ns, pc.ReplicaSourceBarmanObjectName, res)).Build()
 
 			impl := LifecycleImplementation{Client: cli}
-			got, err := impl.collectSidecarResourcesForPod(ctx, pc)
+			got, err := impl.collectSidecarResourcesForPod(ctx, pc, true)
 			Expect(err).NotTo(HaveOccurred())
 			gotMem := got.Requests[corev1.ResourceMemory]
 			Expect(gotMem.String()).To(Equal("256Mi"))
@@ -501,7 +508,7 @@ var _ = Describe("LifecycleImplementation", func() {
 			cli := buildClientFunc().Build()
 
 			impl := LifecycleImplementation{Client: cli}
-			got, err := impl.collectSidecarResourcesForPod(ctx, pc)
+			got, err := impl.collectSidecarResourcesForPod(ctx, pc, true)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(got).To(Equal(corev1.ResourceRequirements{}))
 		})
@@ -513,9 +520,698 @@ var _ = Describe("LifecycleImplementation", func() {
 			cli := buildClientFunc().Build()
 
 			impl := LifecycleImplementation{Client: cli}
-			_, err := impl.collectSidecarResourcesForPod(ctx, pc)
+			_, err := impl.collectSidecarResourcesForPod(ctx, pc, true)
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("uses the resources of the role of the instance", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "store"}
+			store := makeStoreWithResourcesFunc(ns, pc.BarmanObjectName, corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+			})
+			store.Spec.InstanceSidecarConfiguration.PrimaryResources = &corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("512Mi")},
+			}
+			cli := buildClientFunc(store).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			got, err := impl.collectSidecarResourcesForPod(ctx, pc, true)
+			Expect(err).NotTo(HaveOccurred())
+			gotMem := got.Requests[corev1.ResourceMemory]
+			Expect(gotMem.String()).To(Equal("512Mi"))
+
+			got, err = impl.collectSidecarResourcesForPod(ctx, pc, false)
+			Expect(err).NotTo(HaveOccurred())
+			gotMem = got.Requests[corev1.ResourceMemory]
+			Expect(gotMem.String()).To(Equal("64Mi"))
+		})
+	})
+
+	Describe("getSidecarRoleChange", func() {
+		const ns = "test-ns"
+		var (
+			cluster *cnpgv1.Cluster
+			pc      *config.PluginConfiguration
+			impl    LifecycleImplementation
+		)
+
+		memoryRequest := func(quantity string) corev1.ResourceRequirements {
+			return corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(quantity)},
+			}
+		}
+
+		runningPod := func(resources corev1.ResourceRequirements) *corev1.Pod {
+			return &corev1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: sidecarContainerName, Resources: resources}},
+				},
+			}
+		}
+
+		BeforeEach(func() {
+			cluster = &cnpgv1.Cluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns},
+				Status:     cnpgv1.ClusterStatus{CurrentPrimary: "c-2", TargetPrimary: "c-2"},
+			}
+			pc = &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "store"}
+			primaryResources := memoryRequest("512Mi")
+			store := &barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: pc.BarmanObjectName, Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						Resources:        memoryRequest("64Mi"),
+						PrimaryResources: &primaryResources,
+					},
+				},
+			}
+			impl = LifecycleImplementation{Client: buildClientFunc(store).Build()}
+		})
+
+		It("ignores the pods being created", func(ctx SpecContext) {
+			got, err := impl.getSidecarRoleChange(ctx, pc, true, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(BeEmpty())
+		})
+
+		It("reports the instances whose sidecar has the resources of their former role", func(ctx SpecContext) {
+			got, err := impl.getSidecarRoleChange(ctx, pc, true, runningPod(memoryRequest("64Mi")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal("the instance has been promoted to primary"))
+
+			got, err = impl.getSidecarRoleChange(ctx, pc, false, runningPod(memoryRequest("512Mi")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(Equal("the instance has been demoted to replica"))
+		})
+
+		It("ignores the sidecars having the resources of their role or different ones", func(ctx SpecContext) {
+			got, err := impl.getSidecarRoleChange(ctx, pc, true, runningPod(memoryRequest("512Mi")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(BeEmpty())
+
+			got, err = impl.getSidecarRoleChange(ctx, pc, true, runningPod(memoryRequest("128Mi")))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(BeEmpty())
+		})
+	})
+
+	Describe("isPrimaryInstance", func() {
+		It("follows the target primary during a switchover", func() {
+			cluster := &cnpgv1.Cluster{
+				Status: cnpgv1.ClusterStatus{CurrentPrimary: "c-1", TargetPrimary: "c-2"},
+			}
+			Expect(isPrimaryInstance(cluster, "c-1")).To(BeFalse())
+			Expect(isPrimaryInstance(cluster, "c-2")).To(BeTrue())
+		})
+
+		It("falls back to the current primary", func() {
+			cluster := &cnpgv1.Cluster{Status: cnpgv1.ClusterStatus{CurrentPrimary: "c-1"}}
+			Expect(isPrimaryInstance(cluster, "c-1")).To(BeTrue())
+			Expect(isPrimaryInstance(cluster, "c-2")).To(BeFalse())
+		})
+	})
+
+	Describe("collectAdditionalCredentials", func() {
+		makeFileStoreFunc := func(ns, name string, keys ...string) *barmancloudv1.ObjectStore {
+			store := &barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					Configuration: barmanapi.BarmanObjectStoreConfiguration{
+						BarmanCredentials: barmanapi.BarmanCredentials{
+							AWS: &barmanapi.S3Credentials{},
+						},
+					},
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						CredentialsMode: barmancloudv1.CredentialsModeFile,
+					},
+				},
+			}
+			store.Spec.Configuration.AWS.AccessKeyIDReference = &machineryapi.SecretKeySelector{
+				LocalObjectReference: machineryapi.LocalObjectReference{Name: "shared-secret"},
+				Key:                  keys[0],
+			}
+			if len(keys) > 1 {
+				store.Spec.Configuration.AWS.SecretAccessKeyReference = &machineryapi.SecretKeySelector{
+					LocalObjectReference: machineryapi.LocalObjectReference{Name: "shared-secret"},
+					Key:                  keys[1],
+				}
+			}
+			return store
+		}
+
+		It("projects a shared Secret once, with the keys used by every object store", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{
+				Cluster:             cluster,
+				BarmanObjectName:    "base-store",
+				WALBarmanObjectName: "wal-store",
+			}
+			cli := buildClientFunc(
+				makeFileStoreFunc(ns, "base-store", "ACCESS_KEY_ID"),
+				makeFileStoreFunc(ns, "wal-store", "ACCESS_KEY_ID", "SECRET_ACCESS_KEY"),
+			).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			got, err := impl.collectAdditionalCredentials(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got).To(HaveLen(1))
+			Expect(got[0].Secret.Name).To(Equal("shared-secret"))
+			Expect(got[0].Secret.Items).To(ConsistOf(
+				corev1.KeyToPath{Key: "ACCESS_KEY_ID", Path: "shared-secret/ACCESS_KEY_ID"},
+				corev1.KeyToPath{Key: "SECRET_ACCESS_KEY", Path: "shared-secret/SECRET_ACCESS_KEY"},
+			))
+		})
+
+		It("mounts the credentials volume in the sidecar", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "base-store"}
+			cli := buildClientFunc(makeFileStoreFunc(ns, "base-store", "ACCESS_KEY_ID")).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			credentials, err := impl.collectAdditionalCredentials(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+
+			spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}}
+			Expect(reconcilePodSpec(cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				credentials: credentials,
+			})).To(Succeed())
+
+			Expect(spec.Volumes).To(ContainElement(HaveField("Name", specs.BarmanCredentialsVolumeName)))
+			Expect(spec.InitContainers).To(HaveLen(1))
+			Expect(spec.InitContainers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      specs.BarmanCredentialsVolumeName,
+				MountPath: metadata.BarmanCredentialsPath,
+				ReadOnly:  true,
+			}))
+		})
+	})
+
+	Describe("collectWorkloadIdentity", func() {
+		makeIdentityStoreFunc := func(ns, name, roleARN string) *barmancloudv1.ObjectStore {
+			return &barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					Configuration: barmanapi.BarmanObjectStoreConfiguration{
+						BarmanCredentials: barmanapi.BarmanCredentials{
+							AWS: &barmanapi.S3Credentials{InheritFromIAMRole: true},
+						},
+					},
+					WorkloadIdentity: &barmancloudv1.WorkloadIdentityConfiguration{
+						Audience: "sts.amazonaws.com",
+						Annotations: map[string]string{
+							specs.AWSRoleARNAnnotationName: roleARN,
+						},
+					},
+				},
+			}
+		}
+
+		It("projects one token per object store, keeping the environment of the first one", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{
+				Cluster:             cluster,
+				BarmanObjectName:    "base-store",
+				WALBarmanObjectName: "wal-store",
+			}
+			cli := buildClientFunc(
+				makeIdentityStoreFunc(ns, "base-store", "base-role"),
+				makeIdentityStoreFunc(ns, "wal-store", "wal-role"),
+			).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			projections, env, err := impl.collectWorkloadIdentity(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(projections).To(HaveLen(2))
+			Expect(projections[0].ServiceAccountToken.Path).To(Equal("base-store/token"))
+			Expect(projections[1].ServiceAccountToken.Path).To(Equal("wal-store/token"))
+			Expect(env).To(ConsistOf(
+				corev1.EnvVar{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: "/barman-workload-identity/base-store/token"},
+				corev1.EnvVar{Name: "AWS_ROLE_ARN", Value: "base-role"},
+			))
+		})
+
+		It("mounts the workload identity volume in the sidecar", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "base-store"}
+			cli := buildClientFunc(makeIdentityStoreFunc(ns, "base-store", "base-role")).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			projections, env, err := impl.collectWorkloadIdentity(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+
+			spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}}
+			Expect(reconcilePodSpec(cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				env:              env,
+				workloadIdentity: projections,
+			})).To(Succeed())
+
+			Expect(spec.Volumes).To(ContainElement(HaveField("Name", specs.BarmanWorkloadIdentityVolumeName)))
+			Expect(spec.InitContainers).To(HaveLen(1))
+			Expect(spec.InitContainers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      specs.BarmanWorkloadIdentityVolumeName,
+				MountPath: metadata.BarmanWorkloadIdentityPath,
+				ReadOnly:  true,
+			}))
+			Expect(spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "AWS_WEB_IDENTITY_TOKEN_FILE",
+				Value: "/barman-workload-identity/base-store/token",
+			}))
+		})
+	})
+
+	Describe("collectSidecarContainerTemplateForPod", func() {
+		makeStoreWithTemplateFunc := func(ns, name string, template *corev1.Container) *barmancloudv1.ObjectStore {
+			return &barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						ContainerTemplate: template,
+					},
+				},
+			}
+		}
+
+		It("uses the template of the cluster object store", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{
+				Cluster:                  cluster,
+				BarmanObjectName:         "primary-store",
+				RecoveryBarmanObjectName: "recovery-store",
+			}
+			cli := buildClientFunc(
+				makeStoreWithTemplateFunc(ns, "primary-store", &corev1.Container{WorkingDir: "/primary"}),
+				makeStoreWithTemplateFunc(ns, "recovery-store", &corev1.Container{WorkingDir: "/recovery"}),
+			).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			got, err := impl.collectSidecarContainerTemplateForPod(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.WorkingDir).To(Equal("/primary"))
+
+			got, err = impl.collectSidecarContainerTemplateForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(got.WorkingDir).To(Equal("/recovery"))
+		})
+
+		It("applies the template to the sidecar", func() {
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "test-ns"}}
+			spec := corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "postgres"}},
+				Volumes:    []corev1.Volume{{Name: "projected"}},
+			}
+			Expect(reconcilePodSpec(cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				containerTemplate: &corev1.Container{
+					StartupProbe: &corev1.Probe{FailureThreshold: 120},
+					VolumeMounts: []corev1.VolumeMount{{Name: "projected", MountPath: "/etc/proxy"}},
+				},
+			})).To(Succeed())
+
+			Expect(spec.InitContainers).To(HaveLen(1))
+			Expect(spec.InitContainers[0].StartupProbe.FailureThreshold).To(BeEquivalentTo(120))
+			Expect(spec.InitContainers[0].StartupProbe.PeriodSeconds).To(BeEquivalentTo(1))
+			Expect(spec.InitContainers[0].VolumeMounts).To(ContainElement(
+				corev1.VolumeMount{Name: "projected", MountPath: "/etc/proxy"},
+			))
+		})
+
+		It("rejects a template mounting an unknown volume", func() {
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "test-ns"}}
+			spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}}
+			err := reconcilePodSpec(cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				containerTemplate: &corev1.Container{
+					VolumeMounts: []corev1.VolumeMount{{Name: "missing", MountPath: "/etc/proxy"}},
+				},
+			})
+			Expect(err).To(MatchError(ContainSubstring("unknown volume")))
+		})
+	})
+
+	Describe("collectAdditionalEnvs", func() {
+		makeStoreWithEnvFunc := func(ns, name string, env ...corev1.EnvVar) *barmancloudv1.ObjectStore {
+			return &barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						Env: env,
+					},
+				},
+			}
+		}
+
+		It("gives precedence to the archive object store and reports the conflicts", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{
+				TypeMeta:   metav1.TypeMeta{Kind: "Cluster", APIVersion: cnpgv1.SchemeGroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns},
+			}
+			pc := &config.PluginConfiguration{
+				Cluster:                  cluster,
+				BarmanObjectName:         "z-archive",
+				RecoveryBarmanObjectName: "a-recovery",
+			}
+			cli := buildClientFunc(
+				makeStoreWithEnvFunc(ns, "z-archive",
+					corev1.EnvVar{Name: "HTTPS_PROXY", Value: "archive-proxy:3128"},
+					corev1.EnvVar{Name: "NO_PROXY", Value: "localhost"},
+				),
+				makeStoreWithEnvFunc(ns, "a-recovery",
+					corev1.EnvVar{Name: "HTTPS_PROXY", Value: "recovery-proxy:3128"},
+					corev1.EnvVar{Name: "NO_PROXY", Value: "localhost"},
+					corev1.EnvVar{Name: "AWS_MAX_ATTEMPTS", Value: "5"},
+				),
+			).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			impl := LifecycleImplementation{Client: cli, Recorder: recorder}
+			env, err := impl.collectAdditionalEnvs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(Equal([]corev1.EnvVar{
+				{Name: "HTTPS_PROXY", Value: "archive-proxy:3128"},
+				{Name: "NO_PROXY", Value: "localhost"},
+				{Name: "AWS_MAX_ATTEMPTS", Value: "5"},
+			}))
+
+			var event string
+			Expect(recorder.Events).To(Receive(&event))
+			Expect(event).To(ContainSubstring("SidecarEnvConflict"))
+			Expect(event).To(ContainSubstring("HTTPS_PROXY (z-archive overrides a-recovery)"))
+			Expect(event).NotTo(ContainSubstring("NO_PROXY"))
+		})
+
+		It("does not report anything without conflicts", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "archive"}
+			cli := buildClientFunc(
+				makeStoreWithEnvFunc(ns, "archive", corev1.EnvVar{Name: "HTTPS_PROXY", Value: "proxy:3128"}),
+			).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			impl := LifecycleImplementation{Client: cli, Recorder: recorder}
+			env, err := impl.collectAdditionalEnvs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(HaveLen(1))
+			Expect(recorder.Events).To(BeEmpty())
+		})
+	})
+
+	Describe("collectSpoolVolumeForPod", func() {
+		It("mounts the dedicated spool volume and points the sidecar to it", func(ctx SpecContext) {
+			ns := "test-ns"
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc := &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "primary-store"}
+			sizeLimit := resource.MustParse("1Gi")
+			cli := buildClientFunc(&barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: "primary-store", Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						SpoolVolume: &barmancloudv1.SpoolVolumeConfiguration{
+							EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &sizeLimit},
+						},
+					},
+				},
+			}).Build()
+
+			impl := LifecycleImplementation{Client: cli}
+			spoolVolume, err := impl.collectSpoolVolumeForPod(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spoolVolume).NotTo(BeNil())
+
+			spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}}
+			Expect(reconcilePodSpec(cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				spoolVolume: spoolVolume,
+			})).To(Succeed())
+
+			Expect(spec.Volumes).To(ContainElement(corev1.Volume{
+				Name:         specs.BarmanSpoolVolumeName,
+				VolumeSource: *spoolVolume,
+			}))
+			Expect(spec.InitContainers).To(HaveLen(1))
+			Expect(spec.InitContainers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      specs.BarmanSpoolVolumeName,
+				MountPath: metadata.BarmanSpoolPath,
+			}))
+			Expect(spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "SPOOL_DIRECTORY",
+				Value: metadata.BarmanSpoolPath,
+			}))
+		})
+
+		It("keeps the spool in the shared volume by default", func() {
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: "test-ns"}}
+			spec := corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "postgres"}},
+				Volumes:    []corev1.Volume{{Name: specs.BarmanSpoolVolumeName}},
+			}
+			Expect(reconcilePodSpec(cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{})).
+				To(Succeed())
+
+			Expect(spec.Volumes).NotTo(ContainElement(HaveField("Name", specs.BarmanSpoolVolumeName)))
+			Expect(spec.InitContainers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name:  "SPOOL_DIRECTORY",
+				Value: "/controller/wal-restore-spool",
+			}))
+		})
+	})
+
+	Describe("collectSidecarImageForPod", func() {
+		const ns = "test-ns"
+		var (
+			pc  *config.PluginConfiguration
+			cli client.Client
+		)
+
+		BeforeEach(func() {
+			cluster := &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc = &config.PluginConfiguration{Cluster: cluster, BarmanObjectName: "store"}
+			cli = buildClientFunc(&barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						Image: "registry.example.com/barman:v2",
+					},
+				},
+			}).Build()
+
+			viper.Set("sidecar-image", "registry.example.com/barman:v1")
+			DeferCleanup(viper.Reset)
+		})
+
+		It("uses the requested image when allowed", func(ctx SpecContext) {
+			viper.Set("sidecar-image-allow-list", "registry.example.com/barman:*")
+			impl := LifecycleImplementation{Client: cli}
+			image, err := impl.collectSidecarImageForPod(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image).To(Equal("registry.example.com/barman:v2"))
+
+			spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}}
+			Expect(reconcilePodSpec(pc.Cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				image: image,
+			})).To(Succeed())
+			Expect(spec.InitContainers[0].Image).To(Equal("registry.example.com/barman:v2"))
+		})
+
+		It("falls back to the default image when not allowed", func(ctx SpecContext) {
+			impl := LifecycleImplementation{Client: cli}
+			image, err := impl.collectSidecarImageForPod(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(image).To(BeEmpty())
+
+			spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "postgres"}}}
+			Expect(reconcilePodSpec(pc.Cluster, &spec, "postgres", corev1.Container{}, sidecarConfiguration{
+				image: image,
+			})).To(Succeed())
+			Expect(spec.InitContainers[0].Image).To(Equal("registry.example.com/barman:v1"))
+		})
+	})
+
+	Describe("reportSidecarDrift", func() {
+		const ns = "test-ns"
+		var (
+			cluster  *cnpgv1.Cluster
+			recorder *record.FakeRecorder
+			sidecar  corev1.Container
+		)
+
+		BeforeEach(func() {
+			cluster = &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			recorder = record.NewFakeRecorder(10)
+			sidecar = corev1.Container{
+				Name:  sidecarContainerName,
+				Image: "registry.example.com/barman:v1",
+				Env: []corev1.EnvVar{{
+					Name: "POD_NAME",
+					ValueFrom: &corev1.EnvVarSource{
+						FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+					},
+				}},
+			}
+		})
+
+		buildPod := func(sidecar corev1.Container) *corev1.Pod {
+			return &corev1.Pod{
+				TypeMeta:   podTypeMeta,
+				ObjectMeta: metav1.ObjectMeta{Name: "c-1", Namespace: ns},
+				Spec: corev1.PodSpec{
+					Containers:     []corev1.Container{{Name: "postgres"}},
+					InitContainers: []corev1.Container{sidecar},
+				},
+			}
+		}
+
+		It("does not report anything when the sidecar is up to date", func(ctx SpecContext) {
+			runningSidecar := *sidecar.DeepCopy()
+			runningSidecar.Env[0].ValueFrom.FieldRef.APIVersion = "v1"
+			cli := fake.NewClientBuilder().WithObjects(buildPod(runningSidecar)).Build()
+
+			impl := LifecycleImplementation{APIReader: cli, Recorder: recorder}
+			impl.reportSidecarDrift(ctx, cluster, impl.getRunningPod(ctx, cluster, "c-1"), buildPod(sidecar), "")
+			Expect(recorder.Events).To(BeEmpty())
+		})
+
+		It("reports the fields of the sidecar that changed", func(ctx SpecContext) {
+			cli := fake.NewClientBuilder().WithObjects(buildPod(sidecar)).Build()
+
+			evaluatedSidecar := *sidecar.DeepCopy()
+			evaluatedSidecar.Image = "registry.example.com/barman:v2"
+			evaluatedSidecar.Resources.Requests = corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			}
+
+			impl := LifecycleImplementation{APIReader: cli, Recorder: recorder}
+			impl.reportSidecarDrift(ctx, cluster, impl.getRunningPod(ctx, cluster, "c-1"), buildPod(evaluatedSidecar), "")
+			Expect(recorder.Events).To(Receive(And(
+				ContainSubstring("SidecarRolloutRequired"),
+				ContainSubstring("c-1 differs in image, resources"),
+			)))
+		})
+
+		It("names the role change causing the drift", func(ctx SpecContext) {
+			cli := fake.NewClientBuilder().WithObjects(buildPod(sidecar)).Build()
+
+			evaluatedSidecar := *sidecar.DeepCopy()
+			evaluatedSidecar.Resources.Requests = corev1.ResourceList{
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			}
+
+			impl := LifecycleImplementation{APIReader: cli, Recorder: recorder}
+			impl.reportSidecarDrift(ctx, cluster, impl.getRunningPod(ctx, cluster, "c-1"), buildPod(evaluatedSidecar),
+				"the instance has been promoted to primary")
+			Expect(recorder.Events).To(Receive(ContainSubstring(
+				"c-1 differs in resources, as the instance has been promoted to primary, requesting its rollout")))
+		})
+	})
+
+	Describe("recoveryJobSidecarConfiguration", func() {
+		const ns = "test-ns"
+		var (
+			cluster *cnpgv1.Cluster
+			pc      *config.PluginConfiguration
+			store   *barmancloudv1.ObjectStore
+		)
+
+		BeforeEach(func() {
+			cluster = &cnpgv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "c", Namespace: ns}}
+			pc = &config.PluginConfiguration{Cluster: cluster, RecoveryBarmanObjectName: "recovery-store"}
+			store = &barmancloudv1.ObjectStore{
+				TypeMeta:   metav1.TypeMeta{Kind: "ObjectStore", APIVersion: barmancloudv1.GroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: "recovery-store", Namespace: ns},
+				Spec: barmancloudv1.ObjectStoreSpec{
+					InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+						Env:                     []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "instance"}},
+						LogLevel:                "info",
+						AdditionalContainerArgs: []string{"--pprof-server=0.0.0.0:6061"},
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")},
+						},
+						ContainerTemplate: &corev1.Container{WorkingDir: "/instance"},
+						SpoolVolume: &barmancloudv1.SpoolVolumeConfiguration{
+							EmptyDir: &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMediumMemory},
+						},
+					},
+				},
+			}
+		})
+
+		It("falls back to the instance sidecar configuration", func(ctx SpecContext) {
+			impl := LifecycleImplementation{Client: buildClientFunc(store).Build()}
+
+			resources, err := impl.collectSidecarResourcesForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			gotMem := resources.Requests[corev1.ResourceMemory]
+			Expect(gotMem.String()).To(Equal("64Mi"))
+
+			args, err := impl.collectAdditionalRecoveryJobArgs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(args).To(Equal([]string{"--log-level=info"}))
+
+			env, err := impl.collectRecoveryJobEnvs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(env).To(BeEmpty())
+
+			template, err := impl.collectSidecarContainerTemplateForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(template.WorkingDir).To(Equal("/instance"))
+
+			spoolVolume, err := impl.collectSpoolVolumeForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spoolVolume.EmptyDir).NotTo(BeNil())
+			Expect(spoolVolume.EmptyDir.Medium).To(Equal(corev1.StorageMediumMemory))
+		})
+
+		It("applies the recovery Job sidecar configuration", func(ctx SpecContext) {
+			store.Spec.RecoveryJobSidecarConfiguration = &barmancloudv1.RecoveryJobSidecarConfiguration{
+				Env:                     []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "job"}},
+				LogLevel:                "debug",
+				AdditionalContainerArgs: []string{"--custom"},
+				Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+				},
+				ContainerTemplate: &corev1.Container{WorkingDir: "/job"},
+				SpoolVolume: &barmancloudv1.SpoolVolumeConfiguration{
+					Ephemeral: &barmancloudv1.SpoolEphemeralVolumeConfiguration{Size: resource.MustParse("10Gi")},
+				},
+			}
+			impl := LifecycleImplementation{Client: buildClientFunc(store).Build()}
+
+			resources, err := impl.collectSidecarResourcesForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			gotMem := resources.Requests[corev1.ResourceMemory]
+			Expect(gotMem.String()).To(Equal("2Gi"))
+
+			args, err := impl.collectAdditionalRecoveryJobArgs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(args).To(Equal([]string{"--custom", "--log-level=debug"}))
+
+			env, err := impl.collectAdditionalEnvs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			jobEnv, err := impl.collectRecoveryJobEnvs(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(overrideEnvs(env, jobEnv)).To(Equal([]corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "job"}}))
+
+			template, err := impl.collectSidecarContainerTemplateForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(template.WorkingDir).To(Equal("/job"))
+
+			spoolVolume, err := impl.collectSpoolVolumeForRecoveryJob(ctx, pc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(spoolVolume.EmptyDir).To(BeNil())
+			Expect(spoolVolume.Ephemeral).NotTo(BeNil())
+		})
 	})
 })
 
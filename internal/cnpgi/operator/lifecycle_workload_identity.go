@@ -0,0 +1,73 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// collectWorkloadIdentity returns the projections of the service
+// account tokens of the workload identities used by the object stores
+// of a Cluster, together with the environment variables pointing the
+// cloud provider SDKs to them
+func (impl LifecycleImplementation) collectWorkloadIdentity(
+	ctx context.Context,
+	pluginConfiguration *config.PluginConfiguration,
+) ([]corev1.VolumeProjection, []corev1.EnvVar, error) {
+	var projections []corev1.VolumeProjection
+	var env []corev1.EnvVar
+
+	for _, barmanObjectKey := range pluginConfiguration.GetReferredBarmanObjectsKeyByPrecedence() {
+		objectStore, err := common.GetObjectStore(ctx, impl.Client, barmanObjectKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// An ObjectStore and a ClusterObjectStore may share the same
+		// name, and their tokens would be projected in the same file
+		for _, projection := range specs.BuildWorkloadIdentityProjection(objectStore) {
+			if slices.ContainsFunc(projections, func(existing corev1.VolumeProjection) bool {
+				return existing.ServiceAccountToken.Path == projection.ServiceAccountToken.Path
+			}) {
+				continue
+			}
+			projections = append(projections, projection)
+		}
+
+		// The cloud provider SDKs only support one workload identity,
+		// so the object store with the highest precedence wins
+		for _, envVar := range specs.BuildWorkloadIdentityEnv(objectStore) {
+			if !slices.ContainsFunc(env, func(existing corev1.EnvVar) bool {
+				return existing.Name == envVar.Name
+			}) {
+				env = append(env, envVar)
+			}
+		}
+	}
+
+	return projections, env, nil
+}
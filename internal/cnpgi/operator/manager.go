@@ -24,6 +24,7 @@ import (
 	"crypto/tls"
 
 	// +kubebuilder:scaffold:imports
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	"github.com/spf13/viper"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -36,7 +37,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/controller"
+	pluginscheme "github.com/cloudnative-pg/plugin-barman-cloud/internal/scheme"
+	webhookv1 "github.com/cloudnative-pg/plugin-barman-cloud/internal/webhook/v1"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -74,6 +79,7 @@ func Start(ctx context.Context) error {
 
 	webhookServer := webhook.NewServer(webhook.Options{
 		TLSOpts: tlsOpts,
+		CertDir: viper.GetString("webhook-cert-dir"),
 	})
 
 	// Metrics endpoint is enabled in 'config/default/kustomization.yaml'. The Metrics options configure the server.
@@ -100,6 +106,10 @@ func Start(ctx context.Context) error {
 		metricsServerOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
 	}
 
+	// The CNPG API group is only known at runtime, and the
+	// catalog maintenance needs to read Clusters
+	pluginscheme.AddCNPGToScheme(ctx, scheme)
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
@@ -124,13 +134,81 @@ func Start(ctx context.Context) error {
 		return err
 	}
 
+	sidecarImageAllowList := specs.ParseSidecarImageAllowList(viper.GetString("sidecar-image-allow-list"))
+	serverNameCollisionPolicy, err := ParseServerNameCollisionPolicy(
+		viper.GetString("server-name-collision-policy"))
+	if err != nil {
+		setupLog.Error(err, "invalid SERVER_NAME_COLLISION_POLICY")
+		return err
+	}
+	if err = mgr.GetFieldIndexer().IndexField(
+		ctx,
+		&cnpgv1.Cluster{},
+		common.ClusterArchiveObjectStoreField,
+		common.IndexClusterArchiveObjectStore,
+	); err != nil {
+		setupLog.Error(err, "unable to index the clusters by archive object store")
+		return err
+	}
 	if err = (&controller.ObjectStoreReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		APIReader:             mgr.GetAPIReader(),
+		SidecarImage:          viper.GetString("sidecar-image"),
+		SidecarImageAllowList: sidecarImageAllowList,
+		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
+		Recorder: mgr.GetEventRecorderFor("plugin-barman-cloud"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ObjectStore")
+		return err
+	}
+	if err = (&controller.ClusterObjectStoreReconciler{
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		APIReader:             mgr.GetAPIReader(),
+		SidecarImage:          viper.GetString("sidecar-image"),
+		SidecarImageAllowList: sidecarImageAllowList,
+		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
+		Recorder: mgr.GetEventRecorderFor("plugin-barman-cloud"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterObjectStore")
+		return err
+	}
+	if err = (&controller.BackupReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		APIReader:    mgr.GetAPIReader(),
+		SidecarImage: viper.GetString("sidecar-image"),
+		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
+		Recorder: mgr.GetEventRecorderFor("plugin-barman-cloud"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Backup")
+		return err
+	}
+	if err = (&controller.ClusterReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
-		setupLog.Error(err, "unable to create controller", "controller", "ObjectStore")
+		setupLog.Error(err, "unable to create controller", "controller", "Cluster")
 		return err
 	}
+	if err = (&controller.ServerPurgeReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		APIReader:    mgr.GetAPIReader(),
+		SidecarImage: viper.GetString("sidecar-image"),
+		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
+		Recorder: mgr.GetEventRecorderFor("plugin-barman-cloud"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerPurge")
+		return err
+	}
+	if viper.GetBool("enable-webhooks") {
+		if err = webhookv1.SetupObjectStoreWebhookWithManager(mgr, sidecarImageAllowList); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ObjectStore")
+			return err
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -143,12 +221,16 @@ func Start(ctx context.Context) error {
 	}
 
 	if err := mgr.Add(&CNPGI{
-		Client:         mgr.GetClient(),
-		PluginPath:     viper.GetString("plugin-path"),
-		ServerCertPath: viper.GetString("server-cert"),
-		ServerKeyPath:  viper.GetString("server-key"),
-		ClientCertPath: viper.GetString("client-cert"),
-		ServerAddress:  viper.GetString("server-address"),
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		//nolint:staticcheck // SA1019: old API required for RBAC compatibility
+		Recorder:                  mgr.GetEventRecorderFor("plugin-barman-cloud"),
+		ServerNameCollisionPolicy: serverNameCollisionPolicy,
+		PluginPath:                viper.GetString("plugin-path"),
+		ServerCertPath:            viper.GetString("server-cert"),
+		ServerKeyPath:             viper.GetString("server-key"),
+		ClientCertPath:            viper.GetString("client-cert"),
+		ServerAddress:             viper.GetString("server-address"),
 	}); err != nil {
 		setupLog.Error(err, "unable to create CNPGI runnable")
 		return err
@@ -0,0 +1,256 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rbac
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// EnsureClusterObjectStoreRBAC ensures the instances of the given
+// Cluster can read the given ClusterObjectStores, and the secrets
+// they reference in their credentials namespaces.
+//
+// This requires a ClusterRole and a ClusterRoleBinding, plus a Role
+// and a RoleBinding in every credentials namespace. None of them can
+// be owned by the Cluster, as they live outside its namespace: they
+// carry the Cluster name and namespace labels instead, and are
+// removed by DeleteClusterObjectStoreRBAC.
+//
+// The caller is responsible for checking that the namespace of the
+// Cluster is allowed to use the passed ClusterObjectStores. When
+// none is passed, the RBAC resources are removed.
+func EnsureClusterObjectStoreRBAC(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	clusterObjectStores []barmancloudv1.ClusterObjectStore,
+) error {
+	clusterKey := client.ObjectKeyFromObject(cluster)
+	if len(clusterObjectStores) == 0 {
+		return DeleteClusterObjectStoreRBAC(ctx, c, clusterKey)
+	}
+
+	if err := deleteStaleClusterRBAC(ctx, c, clusterKey); err != nil {
+		return err
+	}
+
+	desiredClusterRole := specs.BuildClusterRole(cluster, clusterObjectStores)
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: desiredClusterRole.Name}}
+	if err := createOrPatch(ctx, c, clusterRole, func() {
+		clusterRole.Labels = mergeLabels(clusterRole.Labels, desiredClusterRole.Labels)
+		clusterRole.Rules = desiredClusterRole.Rules
+	}); err != nil {
+		return err
+	}
+
+	desiredClusterRoleBinding := specs.BuildClusterRoleBinding(cluster)
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: desiredClusterRoleBinding.Name},
+	}
+	if err := createOrPatch(ctx, c, clusterRoleBinding, func() {
+		clusterRoleBinding.Labels = mergeLabels(clusterRoleBinding.Labels, desiredClusterRoleBinding.Labels)
+		clusterRoleBinding.Subjects = mergeSubjects(clusterRoleBinding.Subjects, desiredClusterRoleBinding.Subjects)
+		clusterRoleBinding.RoleRef = desiredClusterRoleBinding.RoleRef
+	}); err != nil {
+		return err
+	}
+
+	credentialsNamespaces := stringset.New()
+	for _, desiredRole := range specs.BuildCredentialsRoles(cluster, clusterObjectStores) {
+		credentialsNamespaces.Put(desiredRole.Namespace)
+
+		role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{
+			Namespace: desiredRole.Namespace,
+			Name:      desiredRole.Name,
+		}}
+		if err := createOrPatch(ctx, c, role, func() {
+			role.Labels = mergeLabels(role.Labels, desiredRole.Labels)
+			role.Rules = desiredRole.Rules
+		}); err != nil {
+			return err
+		}
+
+		desiredRoleBinding := specs.BuildCredentialsRoleBinding(cluster, desiredRole.Namespace)
+		roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{
+			Namespace: desiredRoleBinding.Namespace,
+			Name:      desiredRoleBinding.Name,
+		}}
+		if err := createOrPatch(ctx, c, roleBinding, func() {
+			roleBinding.Labels = mergeLabels(roleBinding.Labels, desiredRoleBinding.Labels)
+			roleBinding.Subjects = mergeSubjects(roleBinding.Subjects, desiredRoleBinding.Subjects)
+			roleBinding.RoleRef = desiredRoleBinding.RoleRef
+		}); err != nil {
+			return err
+		}
+	}
+
+	return deleteCredentialsRBAC(ctx, c, clusterKey, credentialsNamespaces)
+}
+
+// DeleteClusterObjectStoreRBAC removes the RBAC resources granting
+// the Cluster having the passed key access to ClusterObjectStores
+func DeleteClusterObjectStoreRBAC(ctx context.Context, c client.Client, clusterKey client.ObjectKey) error {
+	name := specs.GetClusterRBACName(clusterKey.Namespace, clusterKey.Name)
+	if err := deleteIfExists(ctx, c, &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name}}); err != nil {
+		return err
+	}
+	if err := deleteIfExists(ctx, c, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: name}}); err != nil {
+		return err
+	}
+	if err := deleteStaleClusterRBAC(ctx, c, clusterKey); err != nil {
+		return err
+	}
+
+	return deleteCredentialsRBAC(ctx, c, clusterKey, stringset.New())
+}
+
+// deleteStaleClusterRBAC removes the ClusterRoles and the
+// ClusterRoleBindings labelled for the Cluster having the passed key
+// but not having its current name, such as the ones named before the
+// name included a hash
+func deleteStaleClusterRBAC(ctx context.Context, c client.Client, clusterKey client.ObjectKey) error {
+	name := specs.GetClusterRBACName(clusterKey.Namespace, clusterKey.Name)
+	labels := getClusterRBACLabels(clusterKey)
+
+	var clusterRoleBindings rbacv1.ClusterRoleBindingList
+	if err := c.List(ctx, &clusterRoleBindings, labels); err != nil {
+		return err
+	}
+	for i := range clusterRoleBindings.Items {
+		if clusterRoleBindings.Items[i].Name == name {
+			continue
+		}
+		if err := deleteIfExists(ctx, c, &clusterRoleBindings.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	var clusterRoles rbacv1.ClusterRoleList
+	if err := c.List(ctx, &clusterRoles, labels); err != nil {
+		return err
+	}
+	for i := range clusterRoles.Items {
+		if clusterRoles.Items[i].Name == name {
+			continue
+		}
+		if err := deleteIfExists(ctx, c, &clusterRoles.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getClusterRBACLabels returns the selector of the RBAC resources
+// created outside its namespace for the Cluster having the passed key
+func getClusterRBACLabels(clusterKey client.ObjectKey) client.MatchingLabels {
+	return client.MatchingLabels{
+		metadata.ClusterLabelName:          clusterKey.Name,
+		metadata.ClusterNamespaceLabelName: clusterKey.Namespace,
+	}
+}
+
+// deleteCredentialsRBAC removes the Roles and RoleBindings granting the
+// Cluster having the passed key access to the secrets of the
+// credentials namespaces, except for the ones having the current name
+// in the passed namespaces
+func deleteCredentialsRBAC(
+	ctx context.Context,
+	c client.Client,
+	clusterKey client.ObjectKey,
+	keepNamespaces *stringset.Data,
+) error {
+	name := specs.GetClusterRBACName(clusterKey.Namespace, clusterKey.Name)
+	labels := getClusterRBACLabels(clusterKey)
+
+	var roleBindings rbacv1.RoleBindingList
+	if err := c.List(ctx, &roleBindings, labels); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		if keepNamespaces.Has(roleBindings.Items[i].Namespace) && roleBindings.Items[i].Name == name {
+			continue
+		}
+		if err := deleteIfExists(ctx, c, &roleBindings.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	var roles rbacv1.RoleList
+	if err := c.List(ctx, &roles, labels); err != nil {
+		return err
+	}
+	for i := range roles.Items {
+		if keepNamespaces.Has(roles.Items[i].Namespace) && roles.Items[i].Name == name {
+			continue
+		}
+		if err := deleteIfExists(ctx, c, &roles.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createOrPatch creates the passed object, or patches it when it
+// already exists, after applying the passed mutation
+func createOrPatch(ctx context.Context, c client.Client, obj client.Object, mutate func()) error {
+	result, err := controllerutil.CreateOrPatch(ctx, c, obj, func() error {
+		mutate()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("Reconciled ClusterObjectStore RBAC",
+			"name", obj.GetName(), "namespace", obj.GetNamespace(),
+			"operation", result)
+	}
+
+	return nil
+}
+
+func deleteIfExists(ctx context.Context, c client.Client, obj client.Object) error {
+	err := c.Delete(ctx, obj)
+	if err == nil {
+		log.FromContext(ctx).Info("Deleted plugin RBAC",
+			"name", obj.GetName(), "namespace", obj.GetNamespace())
+	}
+	if apierrs.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,185 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rbac_test
+
+import (
+	"context"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/rbac"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+func newClusterObjectStore(name, credentialsNamespace, secretName string) barmancloudv1.ClusterObjectStore {
+	objectStore := newObjectStore(name, "", secretName)
+	return barmancloudv1.ClusterObjectStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: barmancloudv1.ClusterObjectStoreSpec{
+			ObjectStoreSpec:      objectStore.Spec,
+			CredentialsNamespace: credentialsNamespace,
+			AllowedNamespaces:    []string{"default"},
+		},
+	}
+}
+
+var _ = Describe("EnsureClusterObjectStoreRBAC", func() {
+	rbacName := specs.GetClusterRBACName("default", "test-cluster")
+
+	var (
+		ctx        context.Context
+		cluster    *cnpgv1.Cluster
+		fakeClient client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cluster = newCluster("test-cluster", "default")
+		fakeClient = fake.NewClientBuilder().WithScheme(newScheme()).Build()
+	})
+
+	expectNotFound := func(obj client.Object) {
+		err := fakeClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		ExpectWithOffset(1, apierrs.IsNotFound(err)).To(BeTrue())
+	}
+
+	It("should create the ClusterRole, ClusterRoleBinding and credentials Roles", func() {
+		stores := []barmancloudv1.ClusterObjectStore{
+			newClusterObjectStore("store-a", "platform", "secret-a"),
+		}
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, stores)).To(Succeed())
+
+		var clusterRole rbacv1.ClusterRole
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: rbacName}, &clusterRole)).To(Succeed())
+		expectRequiredLabels(clusterRole.Labels, "test-cluster")
+		Expect(clusterRole.Labels).To(HaveKeyWithValue(metadata.ClusterNamespaceLabelName, "default"))
+		Expect(clusterRole.Rules[0].ResourceNames).To(Equal([]string{"store-a"}))
+
+		var clusterRoleBinding rbacv1.ClusterRoleBinding
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Name: rbacName}, &clusterRoleBinding)).To(Succeed())
+		Expect(clusterRoleBinding.RoleRef.Name).To(Equal(rbacName))
+
+		var role rbacv1.Role
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &role)).To(Succeed())
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{"secret-a"}))
+
+		var roleBinding rbacv1.RoleBinding
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &roleBinding)).To(Succeed())
+		Expect(roleBinding.Subjects[0].Namespace).To(Equal("default"))
+	})
+
+	It("should remove the credentials RBAC of namespaces no longer used", func() {
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, []barmancloudv1.ClusterObjectStore{
+			newClusterObjectStore("store-a", "platform-a", "secret-a"),
+		})).To(Succeed())
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, []barmancloudv1.ClusterObjectStore{
+			newClusterObjectStore("store-b", "platform-b", "secret-b"),
+		})).To(Succeed())
+
+		expectNotFound(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "platform-a", Name: rbacName}})
+		expectNotFound(&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "platform-a", Name: rbacName}})
+
+		var role rbacv1.Role
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform-b", Name: rbacName}, &role)).To(Succeed())
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{"secret-b"}))
+	})
+
+	It("should remove every RBAC resource when no ClusterObjectStore is referenced", func() {
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, []barmancloudv1.ClusterObjectStore{
+			newClusterObjectStore("store-a", "platform", "secret-a"),
+		})).To(Succeed())
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, nil)).To(Succeed())
+
+		expectNotFound(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: rbacName}})
+		expectNotFound(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: rbacName}})
+		expectNotFound(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: rbacName}})
+		expectNotFound(&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: rbacName}})
+	})
+
+	It("should not grant access to the secrets of a namespace without referenced secrets", func() {
+		store := newClusterObjectStore("store-a", "platform", "secret-a")
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, []barmancloudv1.ClusterObjectStore{
+			store,
+		})).To(Succeed())
+
+		store.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, []barmancloudv1.ClusterObjectStore{
+			store,
+		})).To(Succeed())
+
+		expectNotFound(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: rbacName}})
+		expectNotFound(&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: rbacName}})
+	})
+
+	It("should remove the RBAC resources named without the hash", func() {
+		const legacyName = "default-test-cluster-barman-cloud"
+		labels := map[string]string{
+			metadata.ClusterLabelName:          "test-cluster",
+			metadata.ClusterNamespaceLabelName: "default",
+		}
+		for _, obj := range []client.Object{
+			&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: legacyName, Labels: labels}},
+			&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: legacyName, Labels: labels}},
+			&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: legacyName, Labels: labels}},
+			&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: legacyName, Labels: labels}},
+		} {
+			Expect(fakeClient.Create(ctx, obj)).To(Succeed())
+		}
+
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, []barmancloudv1.ClusterObjectStore{
+			newClusterObjectStore("store-a", "platform", "secret-a"),
+		})).To(Succeed())
+
+		expectNotFound(&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: legacyName}})
+		expectNotFound(&rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: legacyName}})
+		expectNotFound(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: legacyName}})
+		expectNotFound(&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: legacyName}})
+
+		var role rbacv1.Role
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &role)).To(Succeed())
+	})
+
+	It("should not touch the RBAC of Clusters with the same name in other namespaces", func() {
+		otherCluster := newCluster("test-cluster", "other")
+		stores := []barmancloudv1.ClusterObjectStore{
+			newClusterObjectStore("store-a", "platform", "secret-a"),
+		}
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster, stores)).To(Succeed())
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, otherCluster, stores)).To(Succeed())
+
+		Expect(rbac.DeleteClusterObjectStoreRBAC(ctx, fakeClient, client.ObjectKeyFromObject(otherCluster))).To(Succeed())
+
+		var role rbacv1.Role
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &role)).To(Succeed())
+		expectNotFound(&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Namespace: "platform", Name: specs.GetClusterRBACName("other", "test-cluster")}})
+	})
+})
@@ -28,6 +28,7 @@ import (
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -71,14 +72,18 @@ func EnsureRole(
 // references — it only patches rules on Roles that already exist.
 // It is intended for the ObjectStore controller path where no
 // Cluster object is available. Returns nil if the Role does not
-// exist (the Pre hook has not created it yet).
+// exist (the Pre hook has not created it yet). The creation of the
+// Backups is granted according to the passed importBackups, as the
+// Cluster setting is not known on this path.
 func EnsureRoleRules(
 	ctx context.Context,
 	c client.Client,
 	roleKey client.ObjectKey,
+	clusterName string,
 	barmanObjects []barmancloudv1.ObjectStore,
+	importBackups bool,
 ) error {
-	err := patchRole(ctx, c, roleKey, specs.BuildRoleRules(barmanObjects), nil)
+	err := patchRole(ctx, c, roleKey, specs.BuildRoleRules(clusterName, barmanObjects, importBackups), nil)
 	if apierrs.IsNotFound(err) {
 		log.FromContext(ctx).Debug("Role not found, skipping rule update",
 			"name", roleKey.Name, "namespace", roleKey.Namespace)
@@ -121,6 +126,26 @@ func EnsureRoleBinding(ctx context.Context, c client.Client, cluster *cnpgv1.Clu
 	return reconcileRoleBinding(ctx, c, roleBinding, desiredRoleBinding)
 }
 
+// DeleteRole removes the Role and the RoleBinding granting the
+// instances of the Cluster having the passed key access to its
+// ObjectStores.
+//
+// Both are owned by the Cluster and garbage collected with it; this
+// function is meant for the Clusters that stopped using the plugin,
+// whose grants would otherwise outlive their purpose.
+func DeleteRole(ctx context.Context, c client.Client, clusterKey client.ObjectKey) error {
+	objectMeta := metav1.ObjectMeta{
+		Namespace: clusterKey.Namespace,
+		Name:      specs.GetRBACName(clusterKey.Name),
+	}
+
+	if err := deleteIfExists(ctx, c, &rbacv1.RoleBinding{ObjectMeta: objectMeta}); err != nil {
+		return err
+	}
+
+	return deleteIfExists(ctx, c, &rbacv1.Role{ObjectMeta: objectMeta})
+}
+
 // getOrCreateRoleBinding returns the existing RoleBinding when it
 // is already present on the API server, or nil after a successful
 // Create when the just-created object already carries the desired
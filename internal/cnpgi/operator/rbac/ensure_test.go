@@ -155,7 +155,7 @@ var _ = Describe("EnsureRole", func() {
 				Name:      "test-cluster-barman-cloud",
 			}, &role)
 			Expect(err).NotTo(HaveOccurred())
-			Expect(role.Rules).To(HaveLen(3))
+			Expect(role.Rules).To(HaveLen(4))
 
 			Expect(role.OwnerReferences).To(HaveLen(1))
 			Expect(role.OwnerReferences[0].Name).To(Equal("test-cluster"))
@@ -317,7 +317,7 @@ var _ = Describe("EnsureRole", func() {
 			}, &role)).To(Succeed())
 
 			expectRequiredLabels(role.Labels, "test-cluster")
-			Expect(role.Rules).To(HaveLen(3))
+			Expect(role.Rules).To(HaveLen(4))
 		})
 	})
 })
@@ -689,7 +689,7 @@ var _ = Describe("EnsureRoleRules", func() {
 				Namespace: "default",
 				Name:      "test-cluster-barman-cloud",
 			}
-			err := rbac.EnsureRoleRules(ctx, fakeClient, roleKey, objects)
+			err := rbac.EnsureRoleRules(ctx, fakeClient, roleKey, "test-cluster", objects, false)
 			Expect(err).NotTo(HaveOccurred())
 
 			var role rbacv1.Role
@@ -714,7 +714,7 @@ var _ = Describe("EnsureRoleRules", func() {
 				Namespace: "default",
 				Name:      "test-cluster-barman-cloud",
 			}
-			Expect(rbac.EnsureRoleRules(ctx, fakeClient, roleKey, objects)).To(Succeed())
+			Expect(rbac.EnsureRoleRules(ctx, fakeClient, roleKey, "test-cluster", objects, false)).To(Succeed())
 			Expect(*patchCount).To(BeZero())
 		})
 
@@ -727,7 +727,7 @@ var _ = Describe("EnsureRoleRules", func() {
 			var before rbacv1.Role
 			Expect(fakeClient.Get(ctx, roleKey, &before)).To(Succeed())
 
-			Expect(rbac.EnsureRoleRules(ctx, fakeClient, roleKey, objects)).To(Succeed())
+			Expect(rbac.EnsureRoleRules(ctx, fakeClient, roleKey, "test-cluster", objects, false)).To(Succeed())
 
 			var after rbacv1.Role
 			Expect(fakeClient.Get(ctx, roleKey, &after)).To(Succeed())
@@ -745,8 +745,38 @@ var _ = Describe("EnsureRoleRules", func() {
 				Namespace: "default",
 				Name:      "nonexistent-barman-cloud",
 			}
-			err := rbac.EnsureRoleRules(ctx, fakeClient, roleKey, objects)
+			err := rbac.EnsureRoleRules(ctx, fakeClient, roleKey, "test-cluster", objects, false)
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
 })
+
+var _ = Describe("DeleteRole", func() {
+	var (
+		ctx        context.Context
+		cluster    *cnpgv1.Cluster
+		fakeClient client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cluster = newCluster("test-cluster", "default")
+		fakeClient = fake.NewClientBuilder().WithScheme(newScheme()).Build()
+	})
+
+	It("should delete the Role and the RoleBinding of the Cluster", func() {
+		objects := []barmancloudv1.ObjectStore{newObjectStore("my-store", "default", "aws-creds")}
+		Expect(rbac.EnsureRole(ctx, fakeClient, cluster, objects)).To(Succeed())
+		Expect(rbac.EnsureRoleBinding(ctx, fakeClient, cluster)).To(Succeed())
+
+		Expect(rbac.DeleteRole(ctx, fakeClient, client.ObjectKeyFromObject(cluster))).To(Succeed())
+
+		key := client.ObjectKey{Namespace: "default", Name: "test-cluster-barman-cloud"}
+		Expect(apierrs.IsNotFound(fakeClient.Get(ctx, key, &rbacv1.Role{}))).To(BeTrue())
+		Expect(apierrs.IsNotFound(fakeClient.Get(ctx, key, &rbacv1.RoleBinding{}))).To(BeTrue())
+	})
+
+	It("should succeed when nothing has to be deleted", func() {
+		Expect(rbac.DeleteRole(ctx, fakeClient, client.ObjectKeyFromObject(cluster))).To(Succeed())
+	})
+})
@@ -21,6 +21,7 @@ package operator
 
 import (
 	"context"
+	"fmt"
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
@@ -28,16 +29,28 @@ import (
 	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
 	"github.com/cloudnative-pg/machinery/pkg/log"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/credentials"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/rbac"
 )
 
 // ReconcilerImplementation implements the Reconciler capability
 type ReconcilerImplementation struct {
 	Client client.Client
+	// APIReader reads the objects that are not worth caching,
+	// such as Secrets
+	APIReader client.Reader
+	// Recorder reports the events about the Clusters
+	Recorder record.EventRecorder
+	// ServerNameCollisionPolicy is what to do with a Cluster archiving
+	// with the same server name into the same destination as others
+	ServerNameCollisionPolicy ServerNameCollisionPolicy
+
+	collisionReports *serverNameCollisionReports
 	reconciler.UnimplementedReconcilerHooksServer
 }
 
@@ -69,6 +82,9 @@ func (r ReconcilerImplementation) Pre(
 	if err != nil {
 		return nil, err
 	}
+	if reconciledKind == "Backup" {
+		return r.preBackup(ctx, request)
+	}
 	if reconciledKind != "Cluster" {
 		return &reconciler.ReconcilerHooksResult{
 			Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
@@ -91,33 +107,77 @@ func (r ReconcilerImplementation) Pre(
 
 	contextLogger.Debug("parsing barman object configuration")
 
-	barmanObjects := make([]barmancloudv1.ObjectStore, 0, len(pluginConfiguration.GetReferredBarmanObjectsKey()))
+	var barmanObjects []barmancloudv1.ObjectStore
+	var clusterObjectStores []barmancloudv1.ClusterObjectStore
 	for _, barmanObjectKey := range pluginConfiguration.GetReferredBarmanObjectsKey() {
-		var barmanObject barmancloudv1.ObjectStore
-		if err := r.Client.Get(ctx, barmanObjectKey, &barmanObject); err != nil {
-			if apierrs.IsNotFound(err) {
-				contextLogger.Info(
-					"barman object configuration not found, requeuing",
-					"name", barmanObjectKey.Name,
-					"namespace", barmanObjectKey.Namespace)
-				return &reconciler.ReconcilerHooksResult{
-					Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
-				}, nil
+		var err error
+		if len(barmanObjectKey.Namespace) == 0 {
+			var clusterObjectStore barmancloudv1.ClusterObjectStore
+			if err = r.Client.Get(ctx, barmanObjectKey, &clusterObjectStore); err == nil {
+				if !clusterObjectStore.IsNamespaceAllowed(cluster.Namespace) {
+					return nil, fmt.Errorf("ClusterObjectStore %s does not allow the Clusters of namespace %s",
+						clusterObjectStore.Name, cluster.Namespace)
+				}
+				clusterObjectStores = append(clusterObjectStores, clusterObjectStore)
+			}
+		} else {
+			var barmanObject barmancloudv1.ObjectStore
+			if err = r.Client.Get(ctx, barmanObjectKey, &barmanObject); err == nil {
+				barmanObjects = append(barmanObjects, barmanObject)
 			}
+		}
 
+		if apierrs.IsNotFound(err) {
+			contextLogger.Info(
+				"barman object configuration not found, requeuing",
+				"name", barmanObjectKey.Name,
+				"namespace", barmanObjectKey.Namespace)
+			return &reconciler.ReconcilerHooksResult{
+				Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
+			}, nil
+		}
+		if err != nil {
 			return nil, err
 		}
+	}
 
-		barmanObjects = append(barmanObjects, barmanObject)
+	if len(barmanObjects) == 0 && len(clusterObjectStores) == 0 {
+		// No sidecar is injected when no object store is referred,
+		// and the grants left from a previous configuration are stale
+		if err := rbac.DeleteRole(ctx, r.Client, client.ObjectKeyFromObject(&cluster)); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := rbac.EnsureRole(ctx, r.Client, &cluster, barmanObjects); err != nil {
+			return nil, err
+		}
+
+		if err := rbac.EnsureRoleBinding(ctx, r.Client, &cluster); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rbac.EnsureClusterObjectStoreRBAC(ctx, r.Client, &cluster, clusterObjectStores); err != nil {
+		return nil, err
 	}
 
-	if err := rbac.EnsureRole(ctx, r.Client, &cluster, barmanObjects); err != nil {
+	if err := credentials.EnsureEndpointCASecrets(ctx, r.Client, r.APIReader, &cluster, clusterObjectStores); err != nil {
 		return nil, err
 	}
 
-	if err := rbac.EnsureRoleBinding(ctx, r.Client, &cluster); err != nil {
+	if len(pluginConfiguration.BarmanObjectName) > 0 {
+		if err := ensureCatalogMaintenanceLease(ctx, r.Client, &cluster); err != nil {
+			return nil, err
+		}
+	}
+
+	collisionResult, err := r.checkServerNameCollisions(ctx, &cluster, pluginConfiguration)
+	if err != nil {
 		return nil, err
 	}
+	if collisionResult != nil {
+		return collisionResult, nil
+	}
 
 	contextLogger.Info("Pre hook reconciliation completed")
 	return &reconciler.ReconcilerHooksResult{
@@ -127,9 +187,17 @@ func (r ReconcilerImplementation) Pre(
 
 // Post implements the reconciler interface
 func (r ReconcilerImplementation) Post(
-	_ context.Context,
-	_ *reconciler.ReconcilerHooksRequest,
+	ctx context.Context,
+	request *reconciler.ReconcilerHooksRequest,
 ) (*reconciler.ReconcilerHooksResult, error) {
+	reconciledKind, err := object.GetKind(request.GetResourceDefinition())
+	if err != nil {
+		return nil, err
+	}
+	if reconciledKind == "Backup" {
+		return r.postBackup(ctx, request)
+	}
+
 	return &reconciler.ReconcilerHooksResult{
 		Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
 	}, nil
@@ -0,0 +1,116 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cnpg-i-machinery/pkg/pluginhelper/decoder"
+	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/backups"
+)
+
+// preBackup validates the Backups taken by this plugin before the
+// operator starts them, failing the invalid ones, and annotates them
+func (r ReconcilerImplementation) preBackup(
+	ctx context.Context,
+	request *reconciler.ReconcilerHooksRequest,
+) (*reconciler.ReconcilerHooksResult, error) {
+	backup, err := decodePluginBackup(request)
+	if err != nil {
+		return nil, err
+	}
+	if backup == nil {
+		return continueResult(), nil
+	}
+
+	contextLogger := log.FromContext(ctx).WithValues("backupName", backup.Name, "namespace", backup.Namespace)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	// The backups already started have already been validated
+	if len(backup.Status.Phase) == 0 || backup.Status.Phase == cnpgv1.BackupPhasePending {
+		var cluster cnpgv1.Cluster
+		if err := decoder.DecodeObjectLenient(request.GetClusterDefinition(), &cluster); err != nil {
+			return nil, err
+		}
+
+		if validationErr := backups.Validate(backup, &cluster); validationErr != nil {
+			if err := backups.MarkAsFailed(ctx, r.Client, backup, validationErr); err != nil {
+				return nil, err
+			}
+			return &reconciler.ReconcilerHooksResult{
+				Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_TERMINATE,
+			}, nil
+		}
+	}
+
+	if err := backups.Annotate(ctx, r.Client, backup); err != nil {
+		return nil, err
+	}
+
+	return continueResult(), nil
+}
+
+// postBackup annotates the Backups taken by this plugin.
+//
+// The operator does not call the hooks anymore once a Backup is
+// completed: the Backup controller takes over from there.
+func (r ReconcilerImplementation) postBackup(
+	ctx context.Context,
+	request *reconciler.ReconcilerHooksRequest,
+) (*reconciler.ReconcilerHooksResult, error) {
+	backup, err := decodePluginBackup(request)
+	if err != nil {
+		return nil, err
+	}
+	if backup == nil {
+		return continueResult(), nil
+	}
+
+	if err := backups.Annotate(ctx, r.Client, backup); err != nil {
+		return nil, err
+	}
+
+	return continueResult(), nil
+}
+
+// decodePluginBackup decodes the Backup in the passed request,
+// returning nil when it is not taken by this plugin
+func decodePluginBackup(request *reconciler.ReconcilerHooksRequest) (*cnpgv1.Backup, error) {
+	var backup cnpgv1.Backup
+	if err := decoder.DecodeObjectLenient(request.GetResourceDefinition(), &backup); err != nil {
+		return nil, err
+	}
+
+	if !backups.IsPluginBackup(&backup) {
+		return nil, nil
+	}
+
+	return &backup, nil
+}
+
+func continueResult() *reconciler.ReconcilerHooksResult {
+	return &reconciler.ReconcilerHooksResult{
+		Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_CONTINUE,
+	}
+}
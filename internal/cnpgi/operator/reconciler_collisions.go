@@ -0,0 +1,259 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+)
+
+// ServerNameCollisionPolicy is what the Pre hook does with a Cluster
+// archiving with the same server name into the same destination as
+// other Clusters
+type ServerNameCollisionPolicy string
+
+const (
+	// ServerNameCollisionPolicyWarn reports the collision in a
+	// Warning event on the Cluster, and lets its reconciliation
+	// continue
+	ServerNameCollisionPolicyWarn ServerNameCollisionPolicy = "Warn"
+
+	// ServerNameCollisionPolicyRequeue reports the collision in a
+	// Warning event on the Cluster, and requeues its reconciliation
+	// until the collision is solved
+	ServerNameCollisionPolicyRequeue ServerNameCollisionPolicy = "Requeue"
+
+	// ServerNameCollisionPolicyBlock reports the collision in a
+	// Warning event on the Cluster, and stops its reconciliation
+	ServerNameCollisionPolicyBlock ServerNameCollisionPolicy = "Block"
+)
+
+const (
+	// serverNameCollisionRequeueSeconds is the time to wait before
+	// reconciling again a Cluster whose server name collides, with
+	// the Requeue policy
+	serverNameCollisionRequeueSeconds = 60
+
+	// reasonServerNameCollision is the reason of the event reporting
+	// a server name collision
+	reasonServerNameCollision = "ServerNameCollision"
+
+	// serverNameCollisionReportTTL is the time after which a report
+	// that has not been refreshed is forgotten, so that the reports of
+	// the deleted Clusters do not pile up
+	serverNameCollisionReportTTL = time.Hour
+)
+
+// ParseServerNameCollisionPolicy parses the passed server name
+// collision policy, defaulting to Warn when it is empty
+func ParseServerNameCollisionPolicy(value string) (ServerNameCollisionPolicy, error) {
+	if len(value) == 0 {
+		return ServerNameCollisionPolicyWarn, nil
+	}
+
+	policy := ServerNameCollisionPolicy(value)
+	switch policy {
+	case ServerNameCollisionPolicyWarn, ServerNameCollisionPolicyRequeue, ServerNameCollisionPolicyBlock:
+		return policy, nil
+	default:
+		return "", fmt.Errorf(
+			"invalid server name collision policy %q, must be %s, %s or %s",
+			value,
+			ServerNameCollisionPolicyWarn,
+			ServerNameCollisionPolicyRequeue,
+			ServerNameCollisionPolicyBlock,
+		)
+	}
+}
+
+// serverNameCollisionReports remembers the colliding Clusters last
+// reported for every Cluster, so that the Warning event is only emitted
+// when they change rather than on every reconciliation.
+//
+// The Clusters are forgotten when they are reconciled without a
+// collision, and the reports that are not refreshed within
+// serverNameCollisionReportTTL are dropped, as the Clusters deleted
+// while colliding may never be reconciled again. The report of a
+// colliding Cluster not reconciled within the TTL is emitted again.
+type serverNameCollisionReports struct {
+	mu       sync.Mutex
+	reported map[types.UID]serverNameCollisionReport
+}
+
+// serverNameCollisionReport is the report of the collision of a Cluster
+type serverNameCollisionReport struct {
+	message  string
+	lastSeen time.Time
+}
+
+// newServerNameCollisionReports creates an empty set of reports
+func newServerNameCollisionReports() *serverNameCollisionReports {
+	return &serverNameCollisionReports{
+		reported: make(map[types.UID]serverNameCollisionReport),
+	}
+}
+
+// update stores the passed report for the Cluster having the passed UID,
+// forgetting it when empty, and returns whether it changed. The expired
+// reports of the other Clusters are dropped.
+func (r *serverNameCollisionReports) update(uid types.UID, report string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for reportedUID, reported := range r.reported {
+		if now.Sub(reported.lastSeen) > serverNameCollisionReportTTL {
+			delete(r.reported, reportedUID)
+		}
+	}
+
+	if len(report) == 0 {
+		_, known := r.reported[uid]
+		delete(r.reported, uid)
+		return known
+	}
+
+	previous, known := r.reported[uid]
+	r.reported[uid] = serverNameCollisionReport{message: report, lastSeen: now}
+	return !known || previous.message != report
+}
+
+// isCreatedBefore checks whether the Cluster a was created before the
+// Cluster b, using the UIDs to order the ones created in the same second
+func isCreatedBefore(a, b *cnpgv1.Cluster) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.UID < b.UID
+}
+
+// checkServerNameCollisions looks for the other Clusters archiving with
+// the same server name into the destinations of the passed one. The
+// oldest of them is considered the established owner of the server and
+// is never hindered. The passed Cluster, when it is not the oldest one,
+// gets the outcome of the Pre hook required by the server name
+// collision policy. Otherwise, nil is returned.
+func (r ReconcilerImplementation) checkServerNameCollisions(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	pluginConfiguration *config.PluginConfiguration,
+) (*reconciler.ReconcilerHooksResult, error) {
+	contextLogger := log.FromContext(ctx)
+
+	enabledPlugins := cnpgv1.GetPluginConfigurationEnabledPluginNames(cluster.Spec.Plugins)
+	if !slices.Contains(enabledPlugins, metadata.PluginName) || !cluster.DeletionTimestamp.IsZero() {
+		r.collisionReports.update(cluster.UID, "")
+		return nil, nil
+	}
+
+	var olderClusters []string
+	var destinations []string
+	for _, key := range pluginConfiguration.GetArchiveBarmanObjectsKey() {
+		objectStore, err := common.GetObjectStore(ctx, r.Client, key)
+		if apierrs.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		clusters, err := common.ListClustersArchivingTo(
+			ctx,
+			r.Client,
+			objectStore,
+			pluginConfiguration.ServerName,
+			cluster.UID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for i := range clusters {
+			if !isCreatedBefore(&clusters[i], cluster) {
+				contextLogger.Debug("Newer cluster archiving with the same server name, ignoring",
+					"serverName", pluginConfiguration.ServerName,
+					"clusterNamespace", clusters[i].Namespace,
+					"clusterName", clusters[i].Name)
+				continue
+			}
+
+			found = true
+			name := fmt.Sprintf("%s/%s", clusters[i].Namespace, clusters[i].Name)
+			if !slices.Contains(olderClusters, name) {
+				olderClusters = append(olderClusters, name)
+			}
+		}
+		if found {
+			destinations = append(destinations, objectStore.Spec.Configuration.DestinationPath)
+		}
+	}
+
+	if len(olderClusters) == 0 {
+		r.collisionReports.update(cluster.UID, "")
+		return nil, nil
+	}
+
+	slices.Sort(olderClusters)
+	message := fmt.Sprintf(
+		"the server name %q is already used to archive into %s by the clusters %s: "+
+			"only one cluster may archive there, set a different serverName plugin parameter "+
+			"on this cluster",
+		pluginConfiguration.ServerName,
+		strings.Join(destinations, ", "),
+		strings.Join(olderClusters, ", "),
+	)
+	if r.collisionReports.update(cluster.UID, message) {
+		contextLogger.Info("Server name collision detected",
+			"serverName", pluginConfiguration.ServerName,
+			"olderClusters", olderClusters,
+			"policy", r.ServerNameCollisionPolicy)
+		r.Recorder.Event(cluster, corev1.EventTypeWarning, reasonServerNameCollision, message)
+	}
+
+	switch r.ServerNameCollisionPolicy {
+	case ServerNameCollisionPolicyRequeue:
+		return &reconciler.ReconcilerHooksResult{
+			Behavior:     reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE,
+			RequeueAfter: serverNameCollisionRequeueSeconds,
+		}, nil
+	case ServerNameCollisionPolicyBlock:
+		return &reconciler.ReconcilerHooksResult{
+			Behavior: reconciler.ReconcilerHooksResult_BEHAVIOR_TERMINATE,
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,276 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operator
+
+import (
+	"context"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseServerNameCollisionPolicy", func() {
+	It("defaults to Warn", func() {
+		policy, err := ParseServerNameCollisionPolicy("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy).To(Equal(ServerNameCollisionPolicyWarn))
+	})
+
+	It("accepts the known policies", func() {
+		policy, err := ParseServerNameCollisionPolicy("Block")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(policy).To(Equal(ServerNameCollisionPolicyBlock))
+	})
+
+	It("rejects the unknown policies", func() {
+		_, err := ParseServerNameCollisionPolicy("Strict")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Server name collisions", func() {
+	var (
+		ctx      context.Context
+		recorder *record.FakeRecorder
+		cluster  *cnpgv1.Cluster
+	)
+
+	newCluster := func(name, uid string, age time.Duration, parameters map[string]string) *cnpgv1.Cluster {
+		return &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				UID:               types.UID(uid),
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age).Truncate(time.Second)),
+			},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name:       metadata.PluginName,
+						Parameters: parameters,
+					},
+				},
+			},
+		}
+	}
+
+	newObjectStore := func(name, destinationPath string) *barmancloudv1.ObjectStore {
+		return &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: destinationPath,
+				},
+			},
+		}
+	}
+
+	newReconciler := func(policy ServerNameCollisionPolicy, objects ...client.Object) ReconcilerImplementation {
+		scheme := runtime.NewScheme()
+		barmancloudv1.AddKnownTypes(scheme)
+		utilruntime.Must(cnpgv1.AddToScheme(scheme))
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithIndex(&cnpgv1.Cluster{}, common.ClusterArchiveObjectStoreField, common.IndexClusterArchiveObjectStore).
+			Build()
+		return ReconcilerImplementation{
+			Client:                    fakeClient,
+			APIReader:                 fakeClient,
+			Recorder:                  recorder,
+			ServerNameCollisionPolicy: policy,
+			collisionReports:          newServerNameCollisionReports(),
+		}
+	}
+
+	checkCollisions := func(r ReconcilerImplementation) *reconciler.ReconcilerHooksResult {
+		result, err := r.checkServerNameCollisions(ctx, cluster, config.NewFromCluster(cluster))
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		cluster = newCluster("cluster-restored", "restored-uid", time.Minute, map[string]string{
+			"barmanObjectName": "store",
+			"serverName":       "cluster-example",
+		})
+	})
+
+	It("lets the Clusters with distinct server names continue", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "store",
+		})
+		cluster.Spec.Plugins[0].Parameters["serverName"] = "cluster-restored"
+		r := newReconciler(ServerNameCollisionPolicyBlock,
+			cluster, other, newObjectStore("store", "s3://bucket/path"))
+
+		Expect(checkCollisions(r)).To(BeNil())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("warns about a Cluster using the same server name through the same ObjectStore", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "store",
+		})
+		r := newReconciler(ServerNameCollisionPolicyWarn,
+			cluster, other, newObjectStore("store", "s3://bucket/path"))
+
+		Expect(checkCollisions(r)).To(BeNil())
+		Expect(recorder.Events).To(Receive(SatisfyAll(
+			ContainSubstring(reasonServerNameCollision),
+			ContainSubstring("default/cluster-example"),
+		)))
+	})
+
+	It("requeues a Cluster using the same server name in the same destination path", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "other-store",
+		})
+		r := newReconciler(ServerNameCollisionPolicyRequeue,
+			cluster, other,
+			newObjectStore("store", "s3://bucket/path"),
+			newObjectStore("other-store", "s3://bucket/path"))
+
+		result := checkCollisions(r)
+		Expect(result).NotTo(BeNil())
+		Expect(result.Behavior).To(Equal(reconciler.ReconcilerHooksResult_BEHAVIOR_REQUEUE))
+		Expect(result.RequeueAfter).To(BeEquivalentTo(serverNameCollisionRequeueSeconds))
+	})
+
+	It("matches the destination paths regardless of their trailing slash", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "other-store",
+		})
+		r := newReconciler(ServerNameCollisionPolicyBlock,
+			cluster, other,
+			newObjectStore("store", "s3://bucket/path"),
+			newObjectStore("other-store", "s3://bucket/path/"))
+
+		Expect(checkCollisions(r)).NotTo(BeNil())
+	})
+
+	It("reports the collision again only when the colliding Clusters change", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "store",
+		})
+		r := newReconciler(ServerNameCollisionPolicyRequeue,
+			cluster, other, newObjectStore("store", "s3://bucket/path"))
+
+		Expect(checkCollisions(r)).NotTo(BeNil())
+		Expect(recorder.Events).To(HaveLen(1))
+		Expect(checkCollisions(r)).NotTo(BeNil())
+		Expect(recorder.Events).To(HaveLen(1))
+
+		third := newCluster("cluster-third", "third-uid", 2*time.Hour, map[string]string{
+			"barmanObjectName": "store",
+			"serverName":       "cluster-example",
+		})
+		Expect(r.Client.Create(ctx, third)).To(Succeed())
+		Expect(checkCollisions(r)).NotTo(BeNil())
+		Expect(recorder.Events).To(HaveLen(2))
+	})
+
+	It("finds the Clusters archiving through a ClusterObjectStore sharing the destination", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "shared-store",
+			"barmanObjectKind": barmancloudv1.ClusterObjectStoreKind,
+		})
+		r := newReconciler(ServerNameCollisionPolicyBlock,
+			cluster, other,
+			newObjectStore("store", "s3://bucket/path"),
+			&barmancloudv1.ClusterObjectStore{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared-store"},
+				Spec: barmancloudv1.ClusterObjectStoreSpec{
+					ObjectStoreSpec: newObjectStore("shared-store", "s3://bucket/path").Spec,
+				},
+			})
+
+		Expect(checkCollisions(r)).NotTo(BeNil())
+	})
+
+	It("forgets the reports that are not refreshed", func() {
+		reports := newServerNameCollisionReports()
+		Expect(reports.update("deleted-uid", "collision")).To(BeTrue())
+		Expect(reports.update("deleted-uid", "collision")).To(BeFalse())
+
+		reports.reported["deleted-uid"] = serverNameCollisionReport{
+			message:  "collision",
+			lastSeen: time.Now().Add(-2 * serverNameCollisionReportTTL),
+		}
+		Expect(reports.update("other-uid", "")).To(BeFalse())
+		Expect(reports.reported).To(BeEmpty())
+	})
+
+	It("lets the oldest Cluster archive with the server name continue", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "store",
+		})
+		r := newReconciler(ServerNameCollisionPolicyBlock,
+			cluster, other, newObjectStore("store", "s3://bucket/path"))
+
+		result, err := r.checkServerNameCollisions(ctx, other, config.NewFromCluster(other))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeNil())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("blocks a colliding Cluster with the Block policy", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, map[string]string{
+			"barmanObjectName": "store",
+		})
+		r := newReconciler(ServerNameCollisionPolicyBlock,
+			cluster, other, newObjectStore("store", "s3://bucket/path"))
+
+		result := checkCollisions(r)
+		Expect(result).NotTo(BeNil())
+		Expect(result.Behavior).To(Equal(reconciler.ReconcilerHooksResult_BEHAVIOR_TERMINATE))
+	})
+
+	It("ignores the Clusters only recovering from the same server", func() {
+		other := newCluster("cluster-example", "example-uid", time.Hour, nil)
+		other.Spec.Plugins = nil
+		r := newReconciler(ServerNameCollisionPolicyBlock,
+			cluster, other, newObjectStore("store", "s3://bucket/path"))
+
+		Expect(checkCollisions(r)).To(BeNil())
+	})
+})
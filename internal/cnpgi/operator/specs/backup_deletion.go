@@ -0,0 +1,101 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+)
+
+const (
+	// BackupDeletionJobDeadline is the maximum duration of a backup
+	// deletion Job
+	BackupDeletionJobDeadline = 30 * time.Minute
+
+	// backupDeletionJobTTLSeconds is the time a finished backup
+	// deletion Job is kept. The plugin operator removes it as soon as
+	// it reads its outcome, but the Job must not be left behind when
+	// the Backup has been released in the meantime, for example by
+	// removing the finalizer.
+	backupDeletionJobTTLSeconds = 3600
+)
+
+// GetBackupDeletionJobName returns the name of the Job deleting the
+// data of the Backup having the passed UID. The UID is used in place
+// of the name of the Backup, since the Job may run in the credentials
+// namespace of a ClusterObjectStore.
+func GetBackupDeletionJobName(backupUID types.UID) string {
+	return fmt.Sprintf("barman-cloud-delete-%s", backupUID)
+}
+
+// BuildBackupDeletionJob builds the Job deleting the backup having the
+// passed ID and server name from the passed ObjectStore. Like the
+// probe Job, it gets the credentials from the kubelet and runs with
+// the same ServiceAccount, without any permission on the Kubernetes
+// API.
+func BuildBackupDeletionJob(
+	objectStore *barmancloudv1.ObjectStore,
+	backupUID types.UID,
+	serverName string,
+	backupID string,
+	image string,
+	imagePullSecrets []corev1.LocalObjectReference,
+) (*batchv1.Job, error) {
+	sidecarConfiguration := &objectStore.Spec.InstanceSidecarConfiguration
+
+	env, err := buildObjectStoreJobEnv(
+		objectStore,
+		corev1.EnvVar{
+			Name:  "SERVER_NAME",
+			Value: serverName,
+		},
+		corev1.EnvVar{
+			Name:  "BACKUP_ID",
+			Value: backupID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"backup-deletion"}
+	if len(sidecarConfiguration.LogLevel) > 0 {
+		args = append(args, fmt.Sprintf("--log-level=%s", sidecarConfiguration.LogLevel))
+	}
+
+	job := buildObjectStoreJob(
+		objectStore,
+		GetBackupDeletionJobName(backupUID),
+		image,
+		imagePullSecrets,
+		args,
+		env,
+		BackupDeletionJobDeadline,
+	)
+	job.Spec.TTLSecondsAfterFinished = ptr.To(int32(backupDeletionJobTTLSeconds))
+	return job, nil
+}
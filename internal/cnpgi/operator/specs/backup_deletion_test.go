@@ -0,0 +1,102 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"encoding/json"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("Backup deletion job", func() {
+	var objectStore *barmancloudv1.ObjectStore
+
+	BeforeEach(func() {
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+					BarmanCredentials: barmanapi.BarmanCredentials{
+						AWS: &barmanapi.S3Credentials{
+							AccessKeyIDReference: &machineryapi.SecretKeySelector{
+								LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+								Key:                  "ACCESS_KEY_ID",
+							},
+							SecretAccessKeyReference: &machineryapi.SecretKeySelector{
+								LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+								Key:                  "ACCESS_SECRET_KEY",
+							},
+						},
+					},
+				},
+				InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+					LogLevel: "debug",
+				},
+				Probe: barmancloudv1.ProbeConfiguration{
+					ServiceAccountName: "probe-sa",
+				},
+			},
+		}
+	})
+
+	It("should build the job", func() {
+		job, err := BuildBackupDeletionJob(objectStore, "1234", "cluster-example", "20250102T000000", "sidecar:latest", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(job.Name).To(Equal("barman-cloud-delete-1234"))
+		Expect(job.Namespace).To(Equal("default"))
+		Expect(job.Labels).To(HaveKeyWithValue(metadata.ObjectStoreLabelName, "my-store"))
+		Expect(*job.Spec.BackoffLimit).To(BeZero())
+		Expect(job.Spec.TTLSecondsAfterFinished).To(HaveValue(BeEquivalentTo(backupDeletionJobTTLSeconds)))
+
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.ServiceAccountName).To(Equal("probe-sa"))
+		Expect(podSpec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(podSpec.Containers).To(HaveLen(1))
+
+		container := podSpec.Containers[0]
+		Expect(container.Image).To(Equal("sidecar:latest"))
+		Expect(container.Args).To(Equal([]string{"backup-deletion", "--log-level=debug"}))
+
+		envByName := make(map[string]corev1.EnvVar, len(container.Env))
+		for _, env := range container.Env {
+			envByName[env.Name] = env
+		}
+		Expect(envByName["SERVER_NAME"].Value).To(Equal("cluster-example"))
+		Expect(envByName["BACKUP_ID"].Value).To(Equal("20250102T000000"))
+		Expect(envByName["AWS_ACCESS_KEY_ID"].ValueFrom.SecretKeyRef.Name).To(Equal("aws-creds"))
+
+		var configuration barmanapi.BarmanObjectStoreConfiguration
+		Expect(json.Unmarshal([]byte(envByName["OBJECT_STORE_CONFIGURATION"].Value), &configuration)).To(Succeed())
+		Expect(configuration.DestinationPath).To(Equal("s3://bucket/path"))
+	})
+})
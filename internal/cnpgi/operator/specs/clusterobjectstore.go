@@ -0,0 +1,281 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// BuildClusterObjectStoreLabels returns the labels applied to the
+// objects granting the given Cluster access to its ClusterObjectStores.
+// These objects live outside the namespace of the Cluster, which is
+// recorded in a dedicated label.
+func BuildClusterObjectStoreLabels(cluster *cnpgv1.Cluster) map[string]string {
+	labels := BuildLabels(cluster)
+	labels[metadata.ClusterNamespaceLabelName] = cluster.Namespace
+	return labels
+}
+
+// BuildClusterRole builds the ClusterRole allowing the instances of
+// the given Cluster to read the given ClusterObjectStores
+func BuildClusterRole(
+	cluster *cnpgv1.Cluster,
+	clusterObjectStores []barmancloudv1.ClusterObjectStore,
+) *rbacv1.ClusterRole {
+	return &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   GetClusterRBACName(cluster.Namespace, cluster.Name),
+			Labels: BuildClusterObjectStoreLabels(cluster),
+		},
+		Rules: BuildClusterRoleRules(clusterObjectStores),
+	}
+}
+
+// BuildClusterRoleRules builds the RBAC PolicyRules granting access to
+// the given ClusterObjectStores
+func BuildClusterRoleRules(clusterObjectStores []barmancloudv1.ClusterObjectStore) []rbacv1.PolicyRule {
+	names := stringset.New()
+	for _, clusterObjectStore := range clusterObjectStores {
+		names.Put(clusterObjectStore.Name)
+	}
+
+	return []rbacv1.PolicyRule{
+		{
+			APIGroups: []string{
+				barmancloudv1.GroupVersion.Group,
+			},
+			Verbs: []string{
+				"get",
+				"watch",
+				"list",
+			},
+			Resources: []string{
+				"clusterobjectstores",
+			},
+			ResourceNames: names.ToSortedList(),
+		},
+		{
+			APIGroups: []string{
+				barmancloudv1.GroupVersion.Group,
+			},
+			Verbs: []string{
+				"update",
+			},
+			Resources: []string{
+				"clusterobjectstores/status",
+			},
+			ResourceNames: names.ToSortedList(),
+		},
+	}
+}
+
+// ClusterObjectStoreNamesFromClusterRole extracts the ClusterObjectStore
+// names referenced by a plugin-managed ClusterRole. Returns nil if no
+// matching rule is found.
+func ClusterObjectStoreNamesFromClusterRole(clusterRole *rbacv1.ClusterRole) []string {
+	for _, rule := range clusterRole.Rules {
+		if len(rule.APIGroups) == 1 &&
+			rule.APIGroups[0] == barmancloudv1.GroupVersion.Group &&
+			len(rule.Resources) == 1 &&
+			rule.Resources[0] == "clusterobjectstores" {
+			return slices.Clone(rule.ResourceNames)
+		}
+	}
+
+	return nil
+}
+
+// BuildClusterRoleBinding builds the ClusterRoleBinding granting the
+// ClusterRole of the given Cluster to its instances
+func BuildClusterRoleBinding(cluster *cnpgv1.Cluster) *rbacv1.ClusterRoleBinding {
+	return &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   GetClusterRBACName(cluster.Namespace, cluster.Name),
+			Labels: BuildClusterObjectStoreLabels(cluster),
+		},
+		Subjects: buildClusterSubjects(cluster),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "ClusterRole",
+			Name:     GetClusterRBACName(cluster.Namespace, cluster.Name),
+		},
+	}
+}
+
+// BuildCredentialsRoles builds, for each namespace containing the
+// credentials of the given ClusterObjectStores, the Role allowing
+// the instances of the given Cluster to read them
+func BuildCredentialsRoles(
+	cluster *cnpgv1.Cluster,
+	clusterObjectStores []barmancloudv1.ClusterObjectStore,
+) []*rbacv1.Role {
+	secretsByNamespace := make(map[string]*stringset.Data)
+	for _, clusterObjectStore := range clusterObjectStores {
+		namespace := clusterObjectStore.Spec.CredentialsNamespace
+		secrets, ok := secretsByNamespace[namespace]
+		if !ok {
+			secrets = stringset.New()
+			secretsByNamespace[namespace] = secrets
+		}
+		for _, secret := range CollectSecretNamesFromCredentials(
+			&clusterObjectStore.Spec.Configuration.BarmanCredentials,
+		) {
+			secrets.Put(secret)
+		}
+	}
+
+	namespaces := stringset.FromKeys(secretsByNamespace).ToSortedList()
+	result := make([]*rbacv1.Role, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		// A rule without resource names would grant access to every
+		// Secret of the namespace, as happens when the credentials are
+		// inherited from the environment
+		secrets := secretsByNamespace[namespace]
+		if secrets.Len() == 0 {
+			continue
+		}
+
+		result = append(result, &rbacv1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      GetClusterRBACName(cluster.Namespace, cluster.Name),
+				Labels:    BuildClusterObjectStoreLabels(cluster),
+			},
+			Rules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{
+						"",
+					},
+					Resources: []string{
+						"secrets",
+					},
+					Verbs: []string{
+						"get",
+						"watch",
+						"list",
+					},
+					ResourceNames: secrets.ToSortedList(),
+				},
+			},
+		})
+	}
+
+	return result
+}
+
+// BuildCredentialsRoleBinding builds the RoleBinding granting the
+// Role of the given Cluster in the passed credentials namespace
+// to its instances
+func BuildCredentialsRoleBinding(cluster *cnpgv1.Cluster, namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      GetClusterRBACName(cluster.Namespace, cluster.Name),
+			Labels:    BuildClusterObjectStoreLabels(cluster),
+		},
+		Subjects: buildClusterSubjects(cluster),
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     GetClusterRBACName(cluster.Namespace, cluster.Name),
+		},
+	}
+}
+
+// BuildEndpointCASecret builds the copy, in the namespace of the given
+// Cluster, of the endpoint CA of a ClusterObjectStore. Only the key
+// containing the CA is copied.
+func BuildEndpointCASecret(
+	cluster *cnpgv1.Cluster,
+	clusterObjectStore *barmancloudv1.ClusterObjectStore,
+	source *corev1.Secret,
+) *corev1.Secret {
+	key := clusterObjectStore.Spec.Configuration.EndpointCA.Key
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      GetEndpointCASecretName(cluster.Name, clusterObjectStore.Name),
+			Labels:    BuildLabels(cluster),
+		},
+		Data: map[string][]byte{
+			key: source.Data[key],
+		},
+	}
+}
+
+func buildClusterSubjects(cluster *cnpgv1.Cluster) []rbacv1.Subject {
+	return []rbacv1.Subject{
+		{
+			Kind:      "ServiceAccount",
+			APIGroup:  "",
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+	}
+}
+
+// GetClusterRBACName returns the name of the RBAC entities granting
+// the given Cluster access to its ClusterObjectStores. These entities
+// are cluster-scoped or live in other namespaces, hence the name
+// includes the namespace of the Cluster, followed by a hash telling
+// apart the namespaces and names joined by the same dash, such as
+// "a-b"/"c" and "a"/"b-c".
+func GetClusterRBACName(clusterNamespace, clusterName string) string {
+	hash := sha256.Sum256([]byte(clusterNamespace + "/" + clusterName))
+	return fmt.Sprintf("%s-%s-barman-cloud-%s", clusterNamespace, clusterName, hex.EncodeToString(hash[:4]))
+}
+
+// GetEndpointCASecretName returns the name of the copy of the endpoint
+// CA of a ClusterObjectStore in the namespace of the Cluster
+func GetEndpointCASecretName(clusterName, clusterObjectStoreName string) string {
+	return fmt.Sprintf("%s-barman-cloud-%s-ca", clusterName, clusterObjectStoreName)
+}
+
+// UseEndpointCASecretCopy makes the passed view of a ClusterObjectStore
+// refer to the copy of its endpoint CA in the namespace of the Cluster
+// having the passed name. Secrets can't be projected from other
+// namespaces: the CA of a ClusterObjectStore is copied by the Pre hook
+// in the namespace of the Cluster.
+func UseEndpointCASecretCopy(objectStore *barmancloudv1.ObjectStore, clusterName string) {
+	endpointCA := objectStore.Spec.Configuration.EndpointCA
+	if endpointCA == nil {
+		return
+	}
+
+	objectStore.Spec.Configuration.EndpointCA = &machineryapi.SecretKeySelector{
+		LocalObjectReference: machineryapi.LocalObjectReference{
+			Name: GetEndpointCASecretName(clusterName, objectStore.Name),
+		},
+		Key: endpointCA.Key,
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+func newTestClusterObjectStore(name, credentialsNamespace, secretName string) barmancloudv1.ClusterObjectStore {
+	objectStore := newTestObjectStore(name, secretName)
+	return barmancloudv1.ClusterObjectStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+		},
+		Spec: barmancloudv1.ClusterObjectStoreSpec{
+			ObjectStoreSpec:      objectStore.Spec,
+			CredentialsNamespace: credentialsNamespace,
+			AllowedNamespaces:    []string{"default"},
+		},
+	}
+}
+
+var _ = Describe("ClusterObjectStore RBAC", func() {
+	var cluster *cnpgv1.Cluster
+
+	BeforeEach(func() {
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "default",
+			},
+		}
+	})
+
+	It("should name the RBAC resources after the Cluster namespace and name", func() {
+		clusterRole := BuildClusterRole(cluster, nil)
+		Expect(clusterRole.Name).To(HavePrefix("default-test-cluster-barman-cloud-"))
+		Expect(clusterRole.Labels).To(HaveKeyWithValue(metadata.ClusterLabelName, "test-cluster"))
+		Expect(clusterRole.Labels).To(HaveKeyWithValue(metadata.ClusterNamespaceLabelName, "default"))
+
+		clusterRoleBinding := BuildClusterRoleBinding(cluster)
+		Expect(clusterRoleBinding.RoleRef.Name).To(Equal(clusterRole.Name))
+		Expect(clusterRoleBinding.Subjects).To(HaveLen(1))
+		Expect(clusterRoleBinding.Subjects[0].Name).To(Equal("test-cluster"))
+		Expect(clusterRoleBinding.Subjects[0].Namespace).To(Equal("default"))
+	})
+
+	It("should restrict the ClusterRole to the passed ClusterObjectStores", func() {
+		stores := []barmancloudv1.ClusterObjectStore{
+			newTestClusterObjectStore("store-b", "platform", "secret-b"),
+			newTestClusterObjectStore("store-a", "platform", "secret-a"),
+		}
+
+		clusterRole := BuildClusterRole(cluster, stores)
+		Expect(clusterRole.Rules).To(HaveLen(2))
+		Expect(clusterRole.Rules[0].Resources).To(Equal([]string{"clusterobjectstores"}))
+		Expect(clusterRole.Rules[1].Resources).To(Equal([]string{"clusterobjectstores/status"}))
+		for _, rule := range clusterRole.Rules {
+			Expect(rule.ResourceNames).To(Equal([]string{"store-a", "store-b"}))
+		}
+
+		Expect(ClusterObjectStoreNamesFromClusterRole(clusterRole)).To(Equal([]string{"store-a", "store-b"}))
+	})
+
+	It("should tell apart the namespaces and names joined by the same dash", func() {
+		Expect(GetClusterRBACName("a-b", "c")).NotTo(Equal(GetClusterRBACName("a", "b-c")))
+		Expect(GetClusterRBACName("a-b", "c")).To(Equal(GetClusterRBACName("a-b", "c")))
+	})
+
+	It("should not grant access to the secrets of a namespace without referenced secrets", func() {
+		inherited := newTestClusterObjectStore("store-a", "platform", "secret-a")
+		inherited.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+
+		Expect(BuildCredentialsRoles(cluster, []barmancloudv1.ClusterObjectStore{inherited})).To(BeEmpty())
+	})
+
+	It("should build a Role for each credentials namespace", func() {
+		stores := []barmancloudv1.ClusterObjectStore{
+			newTestClusterObjectStore("store-a", "platform-a", "secret-a"),
+			newTestClusterObjectStore("store-b", "platform-b", "secret-b"),
+			newTestClusterObjectStore("store-c", "platform-a", "secret-c"),
+		}
+
+		roles := BuildCredentialsRoles(cluster, stores)
+		Expect(roles).To(HaveLen(2))
+
+		Expect(roles[0].Namespace).To(Equal("platform-a"))
+		Expect(roles[0].Name).To(Equal(GetClusterRBACName("default", "test-cluster")))
+		Expect(roles[0].Rules).To(HaveLen(1))
+		Expect(roles[0].Rules[0].Resources).To(Equal([]string{"secrets"}))
+		Expect(roles[0].Rules[0].ResourceNames).To(Equal([]string{"secret-a", "secret-c"}))
+
+		Expect(roles[1].Namespace).To(Equal("platform-b"))
+		Expect(roles[1].Rules[0].ResourceNames).To(Equal([]string{"secret-b"}))
+
+		roleBinding := BuildCredentialsRoleBinding(cluster, "platform-a")
+		Expect(roleBinding.Namespace).To(Equal("platform-a"))
+		Expect(roleBinding.RoleRef.Kind).To(Equal("Role"))
+		Expect(roleBinding.RoleRef.Name).To(Equal(roles[0].Name))
+	})
+})
@@ -23,6 +23,7 @@ import (
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
 
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
 	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
 )
 
@@ -39,3 +40,16 @@ func BuildLabels(cluster *cnpgv1.Cluster) map[string]string {
 		utils.KubernetesAppManagedByLabelName: metadata.ManagedByLabelValue,
 	}
 }
+
+// BuildObjectStoreLabels returns the Kubernetes recommended labels
+// applied to the objects managed by this plugin for the given
+// ObjectStore, which are not related to any Cluster.
+func BuildObjectStoreLabels(objectStore *barmancloudv1.ObjectStore) map[string]string {
+	return map[string]string{
+		metadata.ObjectStoreLabelName:         objectStore.Name,
+		utils.KubernetesAppLabelName:          metadata.AppLabelValue,
+		utils.KubernetesAppInstanceLabelName:  objectStore.Name,
+		utils.KubernetesAppVersionLabelName:   metadata.Data.Version,
+		utils.KubernetesAppManagedByLabelName: metadata.ManagedByLabelValue,
+	}
+}
@@ -0,0 +1,200 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"fmt"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+const (
+	// CatalogMaintenanceJobDeadline is the maximum duration of a
+	// catalog maintenance Job. It is also the duration of the Lease
+	// acquired by the Job.
+	CatalogMaintenanceJobDeadline = time.Hour
+
+	// catalogMaintenanceTTLSeconds is the time a finished catalog
+	// maintenance Job is kept around, to allow inspecting its logs
+	catalogMaintenanceTTLSeconds = 300
+)
+
+// GetCatalogMaintenanceName returns the name shared by the Lease
+// coordinating the catalog maintenance of a Cluster and by the Job
+// running it when the Cluster instances are down. It is also the
+// holder identity used by that Job.
+func GetCatalogMaintenanceName(clusterName string) string {
+	return fmt.Sprintf("%s-barman-cloud-maintenance", clusterName)
+}
+
+// BuildCatalogMaintenanceLease builds the Lease coordinating the
+// catalog maintenance of this cluster. The Lease is created empty:
+// its holder is set by whoever runs the maintenance.
+func BuildCatalogMaintenanceLease(cluster *cnpgv1.Cluster) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      GetCatalogMaintenanceName(cluster.Name),
+			Labels:    BuildLabels(cluster),
+		},
+	}
+}
+
+// BuildCatalogMaintenanceJob builds the Job running a single catalog
+// maintenance cycle for the passed Cluster, in place of its instances.
+// The Job uses the sidecar image and the Cluster ServiceAccount, which
+// is bound to the plugin Role.
+func BuildCatalogMaintenanceJob(
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+	image string,
+) *batchv1.Job {
+	const scratchDataVolumeName = "scratch-data"
+
+	sidecarConfiguration := &objectStore.Spec.InstanceSidecarConfiguration
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "NAMESPACE",
+			Value: cluster.Namespace,
+		},
+		{
+			Name:  "CLUSTER_NAME",
+			Value: cluster.Name,
+		},
+		{
+			Name:  "CUSTOM_CNPG_GROUP",
+			Value: cluster.GetObjectKind().GroupVersionKind().Group,
+		},
+		{
+			Name:  "CUSTOM_CNPG_VERSION",
+			Value: cluster.GetObjectKind().GroupVersionKind().Version,
+		},
+	}
+	env = append(env, sidecarConfiguration.Env...)
+	env = append(env, BuildWorkloadIdentityEnv(objectStore)...)
+
+	args := []string{"maintenance"}
+	if len(sidecarConfiguration.LogLevel) > 0 {
+		args = append(args, fmt.Sprintf("--log-level=%s", sidecarConfiguration.LogLevel))
+	}
+
+	volumes := []corev1.Volume{
+		{
+			Name: scratchDataVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      scratchDataVolumeName,
+			MountPath: "/controller",
+			SubPath:   "controller",
+		},
+		{
+			Name:      scratchDataVolumeName,
+			MountPath: "/tmp",
+			SubPath:   "tmp",
+		},
+	}
+	if certificates := BuildCertificatesProjection(objectStore); len(certificates) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: BarmanCertificatesVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: certificates,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      BarmanCertificatesVolumeName,
+			MountPath: metadata.BarmanCertificatesPath,
+		})
+	}
+	if workloadIdentity := BuildWorkloadIdentityProjection(objectStore); len(workloadIdentity) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: BarmanWorkloadIdentityVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: workloadIdentity,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      BarmanWorkloadIdentityVolumeName,
+			MountPath: metadata.BarmanWorkloadIdentityPath,
+			ReadOnly:  true,
+		})
+	}
+
+	imagePullSecrets := make([]corev1.LocalObjectReference, 0, len(cluster.Spec.ImagePullSecrets))
+	for _, secret := range cluster.Spec.ImagePullSecrets {
+		imagePullSecrets = append(imagePullSecrets, corev1.LocalObjectReference{Name: secret.Name})
+	}
+
+	labels := BuildLabels(cluster)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      GetCatalogMaintenanceName(cluster.Name),
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            ptr.To(int32(0)),
+			ActiveDeadlineSeconds:   ptr.To(int64(CatalogMaintenanceJobDeadline.Seconds())),
+			TTLSecondsAfterFinished: ptr.To(int32(catalogMaintenanceTTLSeconds)),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: cluster.Name,
+					ImagePullSecrets:   imagePullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:            "plugin-barman-cloud",
+							Image:           image,
+							ImagePullPolicy: cluster.Spec.ImagePullPolicy,
+							Args:            args,
+							Env:             env,
+							Resources:       sidecarConfiguration.Resources,
+							SecurityContext: BuildSidecarSecurityContext(),
+							VolumeMounts:    volumeMounts,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("Catalog maintenance", func() {
+	var cluster *cnpgv1.Cluster
+
+	BeforeEach(func() {
+		cluster = &cnpgv1.Cluster{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: cnpgv1.SchemeGroupVersion.String(),
+				Kind:       cnpgv1.ClusterKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-cluster",
+				Namespace: "default",
+			},
+			Spec: cnpgv1.ClusterSpec{
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				ImagePullSecrets: []cnpgv1.LocalObjectReference{
+					{Name: "registry-secret"},
+				},
+			},
+		}
+	})
+
+	It("should build the lease", func() {
+		lease := BuildCatalogMaintenanceLease(cluster)
+		Expect(lease.Name).To(Equal("my-cluster-barman-cloud-maintenance"))
+		Expect(lease.Namespace).To(Equal("default"))
+		Expect(lease.Labels).To(HaveKeyWithValue(metadata.ClusterLabelName, "my-cluster"))
+		Expect(lease.Spec.HolderIdentity).To(BeNil())
+	})
+
+	It("should build the job", func() {
+		objectStore := &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					EndpointCA: &machineryapi.SecretKeySelector{
+						LocalObjectReference: machineryapi.LocalObjectReference{Name: "ca-secret"},
+						Key:                  "ca.crt",
+					},
+				},
+				InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+					Env:      []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "proxy:3128"}},
+					LogLevel: "debug",
+				},
+			},
+		}
+
+		job := BuildCatalogMaintenanceJob(cluster, objectStore, "sidecar:latest")
+		Expect(job.Name).To(Equal("my-cluster-barman-cloud-maintenance"))
+		Expect(job.Namespace).To(Equal("default"))
+		Expect(job.Spec.BackoffLimit).To(HaveValue(BeEquivalentTo(0)))
+
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(podSpec.ServiceAccountName).To(Equal("my-cluster"))
+		Expect(podSpec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry-secret"}))
+		Expect(podSpec.Containers).To(HaveLen(1))
+
+		container := podSpec.Containers[0]
+		Expect(container.Image).To(Equal("sidecar:latest"))
+		Expect(container.ImagePullPolicy).To(Equal(corev1.PullIfNotPresent))
+		Expect(container.Args).To(Equal([]string{"maintenance", "--log-level=debug"}))
+		Expect(container.Env).To(ContainElements(
+			corev1.EnvVar{Name: "NAMESPACE", Value: "default"},
+			corev1.EnvVar{Name: "CLUSTER_NAME", Value: "my-cluster"},
+			corev1.EnvVar{Name: "CUSTOM_CNPG_GROUP", Value: cnpgv1.SchemeGroupVersion.Group},
+			corev1.EnvVar{Name: "CUSTOM_CNPG_VERSION", Value: cnpgv1.SchemeGroupVersion.Version},
+			corev1.EnvVar{Name: "HTTPS_PROXY", Value: "proxy:3128"},
+		))
+		Expect(container.SecurityContext).To(Equal(BuildSidecarSecurityContext()))
+		Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{
+			Name:      BarmanCertificatesVolumeName,
+			MountPath: metadata.BarmanCertificatesPath,
+		}))
+		Expect(podSpec.Volumes).To(ContainElement(HaveField("Name", BarmanCertificatesVolumeName)))
+	})
+})
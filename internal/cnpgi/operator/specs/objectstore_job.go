@@ -0,0 +1,269 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+const (
+	// jobScratchDataVolumeName is the name of the volume hosting the
+	// temporary files of the Jobs working on an object store
+	jobScratchDataVolumeName = "scratch-data"
+
+	// jobCredentialsVolumeName is the name of the volume hosting the
+	// Google application credentials of the Jobs working on an object
+	// store
+	jobCredentialsVolumeName = "google-credentials"
+
+	// jobCredentialsPath is the path where the Google application
+	// credentials are mounted in the Jobs working on an object store
+	jobCredentialsPath = "/google-credentials"
+
+	// jobCredentialsFileName is the name of the file hosting the
+	// Google application credentials in the Jobs working on an
+	// object store
+	jobCredentialsFileName = "application_credentials.json"
+)
+
+// buildObjectStoreJob builds a Job working directly on the passed
+// ObjectStore, running the sidecar image with the passed arguments
+// and environment
+func buildObjectStoreJob(
+	objectStore *barmancloudv1.ObjectStore,
+	name string,
+	image string,
+	imagePullSecrets []corev1.LocalObjectReference,
+	args []string,
+	env []corev1.EnvVar,
+	deadline time.Duration,
+) *batchv1.Job {
+	sidecarConfiguration := &objectStore.Spec.InstanceSidecarConfiguration
+	volumes, volumeMounts := buildObjectStoreJobVolumes(objectStore)
+	labels := BuildObjectStoreLabels(objectStore)
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: objectStore.Namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          ptr.To(int32(0)),
+			ActiveDeadlineSeconds: ptr.To(int64(deadline.Seconds())),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: getProbeServiceAccountName(objectStore),
+					ImagePullSecrets:   imagePullSecrets,
+					Containers: []corev1.Container{
+						{
+							Name:            "plugin-barman-cloud",
+							Image:           image,
+							Args:            args,
+							Env:             env,
+							Resources:       sidecarConfiguration.Resources,
+							SecurityContext: BuildSidecarSecurityContext(),
+							VolumeMounts:    volumeMounts,
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+}
+
+// buildObjectStoreJobEnv builds the environment of the Jobs working
+// directly on the passed ObjectStore, such as the probe Job. The
+// passed variables follow the object store configuration.
+func buildObjectStoreJobEnv(
+	objectStore *barmancloudv1.ObjectStore,
+	extraEnv ...corev1.EnvVar,
+) ([]corev1.EnvVar, error) {
+	rawConfiguration, err := json.Marshal(&objectStore.Spec.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("while encoding the object store configuration: %w", err)
+	}
+
+	env := []corev1.EnvVar{
+		{
+			Name:  "OBJECT_STORE_CONFIGURATION",
+			Value: string(rawConfiguration),
+		},
+	}
+	env = append(env, extraEnv...)
+	env = append(env, objectStore.Spec.InstanceSidecarConfiguration.Env...)
+	env = append(env, BuildWorkloadIdentityEnv(objectStore)...)
+	env = append(env, buildObjectStoreJobCredentialsEnv(objectStore)...)
+
+	return env, nil
+}
+
+// buildObjectStoreJobVolumes builds the volumes of the Jobs working
+// directly on the passed ObjectStore, together with their mounts
+func buildObjectStoreJobVolumes(
+	objectStore *barmancloudv1.ObjectStore,
+) ([]corev1.Volume, []corev1.VolumeMount) {
+	volumes := []corev1.Volume{
+		{
+			Name: jobScratchDataVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      jobScratchDataVolumeName,
+			MountPath: "/tmp",
+		},
+	}
+	if certificates := BuildCertificatesProjection(objectStore); len(certificates) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: BarmanCertificatesVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: certificates,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      BarmanCertificatesVolumeName,
+			MountPath: metadata.BarmanCertificatesPath,
+		})
+	}
+	if workloadIdentity := BuildWorkloadIdentityProjection(objectStore); len(workloadIdentity) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: BarmanWorkloadIdentityVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: workloadIdentity,
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      BarmanWorkloadIdentityVolumeName,
+			MountPath: metadata.BarmanWorkloadIdentityPath,
+			ReadOnly:  true,
+		})
+	}
+	if google := objectStore.Spec.Configuration.Google; google != nil && google.ApplicationCredentials != nil {
+		volumes = append(volumes, corev1.Volume{
+			Name: jobCredentialsVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: google.ApplicationCredentials.Name,
+					Items: []corev1.KeyToPath{
+						{
+							Key:  google.ApplicationCredentials.Key,
+							Path: jobCredentialsFileName,
+						},
+					},
+				},
+			},
+		})
+		volumeMounts = append(volumeMounts, corev1.VolumeMount{
+			Name:      jobCredentialsVolumeName,
+			MountPath: jobCredentialsPath,
+			ReadOnly:  true,
+		})
+	}
+
+	return volumes, volumeMounts
+}
+
+// buildObjectStoreJobCredentialsEnv builds the environment variables
+// passing the credentials of the ObjectStore to the Jobs working on
+// it, mirroring the ones set by the barman-cloud library in the
+// instance sidecar
+func buildObjectStoreJobCredentialsEnv(objectStore *barmancloudv1.ObjectStore) []corev1.EnvVar {
+	configuration := &objectStore.Spec.Configuration
+	certificateFile := path.Join(
+		metadata.BarmanCertificatesPath,
+		objectStore.Name,
+		metadata.BarmanCertificatesFileName,
+	)
+
+	var env []corev1.EnvVar
+	addSecretEnv := func(name string, selector *machineryapi.SecretKeySelector) {
+		if selector == nil {
+			return
+		}
+		env = append(env, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: selector.Name},
+					Key:                  selector.Key,
+				},
+			},
+		})
+	}
+
+	switch {
+	case configuration.AWS != nil:
+		if configuration.EndpointCA != nil {
+			env = append(env, corev1.EnvVar{Name: "AWS_CA_BUNDLE", Value: certificateFile})
+		}
+		if !configuration.AWS.InheritFromIAMRole {
+			addSecretEnv("AWS_ACCESS_KEY_ID", configuration.AWS.AccessKeyIDReference)
+			addSecretEnv("AWS_SECRET_ACCESS_KEY", configuration.AWS.SecretAccessKeyReference)
+			addSecretEnv("AWS_DEFAULT_REGION", configuration.AWS.RegionReference)
+			addSecretEnv("AWS_SESSION_TOKEN", configuration.AWS.SessionToken)
+		}
+
+	case configuration.Azure != nil:
+		if configuration.EndpointCA != nil {
+			env = append(env, corev1.EnvVar{Name: "REQUESTS_CA_BUNDLE", Value: certificateFile})
+		}
+		if !configuration.Azure.InheritFromAzureAD && !configuration.Azure.UseDefaultAzureCredentials {
+			addSecretEnv("AZURE_STORAGE_ACCOUNT", configuration.Azure.StorageAccount)
+			addSecretEnv("AZURE_STORAGE_KEY", configuration.Azure.StorageKey)
+			addSecretEnv("AZURE_STORAGE_SAS_TOKEN", configuration.Azure.StorageSasToken)
+			addSecretEnv("AZURE_STORAGE_CONNECTION_STRING", configuration.Azure.ConnectionString)
+		}
+
+	case configuration.Google != nil:
+		if configuration.Google.ApplicationCredentials != nil {
+			env = append(env, corev1.EnvVar{
+				Name:  "GOOGLE_APPLICATION_CREDENTIALS",
+				Value: path.Join(jobCredentialsPath, jobCredentialsFileName),
+			})
+		}
+	}
+
+	return env
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+const (
+	// DefaultProbeInterval is the time between two consecutive
+	// connectivity probes when not specified in the ObjectStore
+	DefaultProbeInterval = 300 * time.Second
+
+	// ProbeJobDeadline is the maximum duration of a probe Job
+	ProbeJobDeadline = 5 * time.Minute
+)
+
+// GetProbeJobName returns the name of the Job probing the connectivity
+// to the passed ObjectStore
+func GetProbeJobName(objectStoreName string) string {
+	return fmt.Sprintf("%s-barman-cloud-probe", objectStoreName)
+}
+
+// GetProbeInterval returns the time between two consecutive
+// connectivity probes of the passed ObjectStore
+func GetProbeInterval(objectStore *barmancloudv1.ObjectStore) time.Duration {
+	if objectStore.Spec.Probe.IntervalSeconds <= 0 {
+		return DefaultProbeInterval
+	}
+
+	return time.Duration(objectStore.Spec.Probe.IntervalSeconds) * time.Second
+}
+
+// BuildProbeJob builds the Job probing the connectivity to the passed
+// ObjectStore. The credentials are injected by the kubelet from the
+// referenced Secrets, so that the Job does not need any permission on
+// the Kubernetes API.
+func BuildProbeJob(
+	objectStore *barmancloudv1.ObjectStore,
+	image string,
+	imagePullSecrets []corev1.LocalObjectReference,
+) (*batchv1.Job, error) {
+	sidecarConfiguration := &objectStore.Spec.InstanceSidecarConfiguration
+
+	env, err := buildObjectStoreJobEnv(objectStore, corev1.EnvVar{
+		Name:  "WRITE_CHECK",
+		Value: strconv.FormatBool(objectStore.Spec.Probe.WriteCheck),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"probe"}
+	if len(sidecarConfiguration.LogLevel) > 0 {
+		args = append(args, fmt.Sprintf("--log-level=%s", sidecarConfiguration.LogLevel))
+	}
+
+	job := buildObjectStoreJob(
+		objectStore,
+		GetProbeJobName(objectStore.Name),
+		image,
+		imagePullSecrets,
+		args,
+		env,
+		ProbeJobDeadline,
+	)
+	job.Annotations = map[string]string{
+		metadata.ProbeGenerationAnnotationName: strconv.FormatInt(objectStore.Generation, 10),
+	}
+
+	return job, nil
+}
+
+// getProbeServiceAccountName returns the name of the ServiceAccount
+// used by the probe Job, falling back to the dedicated ServiceAccount
+// of the workload identity
+func getProbeServiceAccountName(objectStore *barmancloudv1.ObjectStore) string {
+	if len(objectStore.Spec.Probe.ServiceAccountName) > 0 {
+		return objectStore.Spec.Probe.ServiceAccountName
+	}
+
+	if workloadIdentity := objectStore.Spec.WorkloadIdentity; workloadIdentity != nil {
+		return workloadIdentity.ServiceAccountName
+	}
+
+	return ""
+}
@@ -0,0 +1,180 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"encoding/json"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("Probe job", func() {
+	var objectStore *barmancloudv1.ObjectStore
+
+	BeforeEach(func() {
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "my-store",
+				Namespace:  "default",
+				Generation: 4,
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+					EndpointCA: &machineryapi.SecretKeySelector{
+						LocalObjectReference: machineryapi.LocalObjectReference{Name: "ca-secret"},
+						Key:                  "ca.crt",
+					},
+					BarmanCredentials: barmanapi.BarmanCredentials{
+						AWS: &barmanapi.S3Credentials{
+							AccessKeyIDReference: &machineryapi.SecretKeySelector{
+								LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+								Key:                  "ACCESS_KEY_ID",
+							},
+							SecretAccessKeyReference: &machineryapi.SecretKeySelector{
+								LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+								Key:                  "ACCESS_SECRET_KEY",
+							},
+						},
+					},
+				},
+				InstanceSidecarConfiguration: barmancloudv1.InstanceSidecarConfiguration{
+					Env: []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "proxy:3128"}},
+				},
+				Probe: barmancloudv1.ProbeConfiguration{
+					WriteCheck:         true,
+					ServiceAccountName: "probe-sa",
+				},
+			},
+		}
+	})
+
+	It("uses the default interval when not specified", func() {
+		Expect(GetProbeInterval(objectStore)).To(Equal(DefaultProbeInterval))
+		objectStore.Spec.Probe.IntervalSeconds = 60
+		Expect(GetProbeInterval(objectStore)).To(Equal(time.Minute))
+	})
+
+	It("should build the job", func() {
+		job, err := BuildProbeJob(
+			objectStore,
+			"sidecar:latest",
+			[]corev1.LocalObjectReference{{Name: "registry"}},
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(job.Name).To(Equal("my-store-barman-cloud-probe"))
+		Expect(job.Namespace).To(Equal("default"))
+		Expect(job.Labels).To(HaveKeyWithValue(metadata.ObjectStoreLabelName, "my-store"))
+		Expect(job.Annotations).To(HaveKeyWithValue(metadata.ProbeGenerationAnnotationName, "4"))
+		Expect(*job.Spec.BackoffLimit).To(BeZero())
+
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.ServiceAccountName).To(Equal("probe-sa"))
+		Expect(podSpec.RestartPolicy).To(Equal(corev1.RestartPolicyNever))
+		Expect(podSpec.ImagePullSecrets).To(ConsistOf(corev1.LocalObjectReference{Name: "registry"}))
+		Expect(podSpec.Containers).To(HaveLen(1))
+
+		container := podSpec.Containers[0]
+		Expect(container.Image).To(Equal("sidecar:latest"))
+		Expect(container.Args).To(Equal([]string{"probe"}))
+		Expect(*container.SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+		Expect(container.VolumeMounts).To(ContainElement(HaveField("MountPath", metadata.BarmanCertificatesPath)))
+
+		envByName := make(map[string]corev1.EnvVar, len(container.Env))
+		for _, env := range container.Env {
+			envByName[env.Name] = env
+		}
+		Expect(envByName).To(HaveKey("HTTPS_PROXY"))
+		Expect(envByName["WRITE_CHECK"].Value).To(Equal("true"))
+		Expect(envByName["AWS_CA_BUNDLE"].Value).To(Equal("/barman-certificates/my-store/barman-ca.crt"))
+		Expect(envByName["AWS_ACCESS_KEY_ID"].ValueFrom.SecretKeyRef.Name).To(Equal("aws-creds"))
+		Expect(envByName["AWS_ACCESS_KEY_ID"].ValueFrom.SecretKeyRef.Key).To(Equal("ACCESS_KEY_ID"))
+		Expect(envByName["AWS_SECRET_ACCESS_KEY"].ValueFrom.SecretKeyRef.Key).To(Equal("ACCESS_SECRET_KEY"))
+		Expect(envByName).NotTo(HaveKey("AWS_SESSION_TOKEN"))
+
+		var configuration barmanapi.BarmanObjectStoreConfiguration
+		Expect(json.Unmarshal([]byte(envByName["OBJECT_STORE_CONFIGURATION"].Value), &configuration)).To(Succeed())
+		Expect(configuration.DestinationPath).To(Equal("s3://bucket/path"))
+	})
+
+	It("does not pass secrets for credentials inherited from the IAM role", func() {
+		objectStore.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+
+		job, err := BuildProbeJob(objectStore, "sidecar:latest", nil)
+		Expect(err).NotTo(HaveOccurred())
+		for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+			Expect(env.ValueFrom).To(BeNil())
+		}
+	})
+
+	It("mounts the Google application credentials", func() {
+		objectStore.Spec.Configuration.EndpointCA = nil
+		objectStore.Spec.Configuration.AWS = nil
+		objectStore.Spec.Configuration.Google = &barmanapi.GoogleCredentials{
+			ApplicationCredentials: &machineryapi.SecretKeySelector{
+				LocalObjectReference: machineryapi.LocalObjectReference{Name: "gcs-creds"},
+				Key:                  "credentials.json",
+			},
+		}
+
+		job, err := BuildProbeJob(objectStore, "sidecar:latest", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ContainElement(HaveField("Secret.SecretName", "gcs-creds")))
+		Expect(podSpec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+			Name:  "GOOGLE_APPLICATION_CREDENTIALS",
+			Value: "/google-credentials/application_credentials.json",
+		}))
+	})
+
+	It("uses the workload identity of the dedicated ServiceAccount", func() {
+		objectStore.Spec.Probe.ServiceAccountName = ""
+		objectStore.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+		objectStore.Spec.WorkloadIdentity = &barmancloudv1.WorkloadIdentityConfiguration{
+			Audience:           "sts.amazonaws.com",
+			ServiceAccountName: "backup-identity",
+			Annotations: map[string]string{
+				AWSRoleARNAnnotationName: "arn:aws:iam::123456789012:role/backup",
+			},
+		}
+
+		job, err := BuildProbeJob(objectStore, "sidecar:latest", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		podSpec := job.Spec.Template.Spec
+		Expect(podSpec.ServiceAccountName).To(Equal("backup-identity"))
+		Expect(podSpec.Volumes).To(ContainElement(HaveField("Name", BarmanWorkloadIdentityVolumeName)))
+		Expect(podSpec.Containers[0].Env).To(ContainElements(
+			corev1.EnvVar{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: "/barman-workload-identity/my-store/token"},
+			corev1.EnvVar{Name: "AWS_ROLE_ARN", Value: "arn:aws:iam::123456789012:role/backup"},
+		))
+	})
+})
@@ -25,10 +25,13 @@ import (
 
 	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/stringset"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	pluginscheme "github.com/cloudnative-pg/plugin-barman-cloud/internal/scheme"
 )
 
 // BuildRole builds the Role object for this cluster
@@ -42,52 +45,77 @@ func BuildRole(
 			Name:      GetRBACName(cluster.Name),
 			Labels:    BuildLabels(cluster),
 		},
-		Rules: BuildRoleRules(barmanObjects),
+		Rules: BuildRoleRules(cluster.Name, barmanObjects, config.NewFromCluster(cluster).ImportBackups),
 	}
 }
 
-// BuildRoleRules builds the RBAC PolicyRules for the given ObjectStores.
+// BuildRoleRules builds the RBAC PolicyRules for the given ObjectStores,
+// used by the instances of the Cluster having the passed name. The
+// creation of the Backups is only granted when the Cluster imports
+// them from the catalog.
+//
+// The rules restricted to a set of resource names are omitted when
+// the set is empty, as an empty set would grant access to every
+// object of that kind. This happens when the Cluster only uses
+// ClusterObjectStores, inherits the credentials from its
+// environment, or reads them from files.
 //
 //nolint:goconst
-func BuildRoleRules(barmanObjects []barmancloudv1.ObjectStore) []rbacv1.PolicyRule {
+func BuildRoleRules(
+	clusterName string,
+	barmanObjects []barmancloudv1.ObjectStore,
+	importBackups bool,
+) []rbacv1.PolicyRule {
 	secretsSet := stringset.New()
 	barmanObjectsSet := stringset.New()
 
 	for _, barmanObject := range barmanObjects {
 		barmanObjectsSet.Put(barmanObject.Name)
+
+		// The Secrets are mounted in the sidecar when using the File
+		// credentials mode, and don't need to be read from the API
+		if barmanObject.Spec.InstanceSidecarConfiguration.GetCredentialsMode() == barmancloudv1.CredentialsModeFile {
+			continue
+		}
 		for _, secret := range CollectSecretNamesFromCredentials(&barmanObject.Spec.Configuration.BarmanCredentials) {
 			secretsSet.Put(secret)
 		}
 	}
 
-	return []rbacv1.PolicyRule{
-		{
-			APIGroups: []string{
-				barmancloudv1.GroupVersion.Group,
-			},
-			Verbs: []string{
-				"get",
-				"watch",
-				"list",
-			},
-			Resources: []string{
-				"objectstores",
+	rules := make([]rbacv1.PolicyRule, 0, 5)
+	if barmanObjectsSet.Len() > 0 {
+		rules = append(rules,
+			rbacv1.PolicyRule{
+				APIGroups: []string{
+					barmancloudv1.GroupVersion.Group,
+				},
+				Verbs: []string{
+					"get",
+					"watch",
+					"list",
+				},
+				Resources: []string{
+					"objectstores",
+				},
+				ResourceNames: barmanObjectsSet.ToSortedList(),
 			},
-			ResourceNames: barmanObjectsSet.ToSortedList(),
-		},
-		{
-			APIGroups: []string{
-				barmancloudv1.GroupVersion.Group,
-			},
-			Verbs: []string{
-				"update",
-			},
-			Resources: []string{
-				"objectstores/status",
+			rbacv1.PolicyRule{
+				APIGroups: []string{
+					barmancloudv1.GroupVersion.Group,
+				},
+				Verbs: []string{
+					"update",
+				},
+				Resources: []string{
+					"objectstores/status",
+				},
+				ResourceNames: barmanObjectsSet.ToSortedList(),
 			},
-			ResourceNames: barmanObjectsSet.ToSortedList(),
-		},
-		{
+		)
+	}
+
+	if secretsSet.Len() > 0 {
+		rules = append(rules, rbacv1.PolicyRule{
 			APIGroups: []string{
 				"",
 			},
@@ -100,8 +128,50 @@ func BuildRoleRules(barmanObjects []barmancloudv1.ObjectStore) []rbacv1.PolicyRu
 				"list",
 			},
 			ResourceNames: secretsSet.ToSortedList(),
+		})
+	}
+
+	rules = append(rules, rbacv1.PolicyRule{
+		APIGroups: []string{
+			coordinationv1.GroupName,
 		},
+		Resources: []string{
+			"leases",
+		},
+		Verbs: []string{
+			"get",
+			"update",
+		},
+		ResourceNames: []string{
+			GetCatalogMaintenanceName(clusterName),
+		},
+	})
+
+	if importBackups {
+		rules = append(rules, rbacv1.PolicyRule{
+			APIGroups: []string{
+				pluginscheme.GetCNPGGroupVersion().Group,
+			},
+			Resources: []string{
+				"backups",
+			},
+			Verbs: []string{
+				"create",
+			},
+		})
 	}
+
+	return rules
+}
+
+// ImportBackupsFromRole tells whether the passed plugin-managed Role
+// grants the creation of the Backups imported from the catalog
+func ImportBackupsFromRole(role *rbacv1.Role) bool {
+	return slices.ContainsFunc(role.Rules, func(rule rbacv1.PolicyRule) bool {
+		return slices.Equal(rule.APIGroups, []string{pluginscheme.GetCNPGGroupVersion().Group}) &&
+			slices.Equal(rule.Resources, []string{"backups"}) &&
+			slices.Contains(rule.Verbs, "create")
+	})
 }
 
 // ObjectStoreNamesFromRole extracts the ObjectStore names referenced
@@ -57,13 +57,13 @@ func newTestObjectStore(name, secretName string) barmancloudv1.ObjectStore {
 }
 
 var _ = Describe("BuildRoleRules", func() {
-	It("should produce 3 rules with correct ResourceNames", func() {
+	It("should produce 5 rules with correct ResourceNames", func() {
 		objects := []barmancloudv1.ObjectStore{
 			newTestObjectStore("store-a", "secret-a"),
 			newTestObjectStore("store-b", "secret-b"),
 		}
-		rules := BuildRoleRules(objects)
-		Expect(rules).To(HaveLen(3))
+		rules := BuildRoleRules("test-cluster", objects, true)
+		Expect(rules).To(HaveLen(5))
 
 		Expect(rules[0].APIGroups).To(Equal([]string{barmancloudv1.GroupVersion.Group}))
 		Expect(rules[0].Resources).To(Equal([]string{"objectstores"}))
@@ -76,15 +76,58 @@ var _ = Describe("BuildRoleRules", func() {
 		Expect(rules[2].APIGroups).To(Equal([]string{""}))
 		Expect(rules[2].Resources).To(Equal([]string{"secrets"}))
 		Expect(rules[2].ResourceNames).To(ConsistOf("secret-a", "secret-b"))
+
+		Expect(rules[3].APIGroups).To(Equal([]string{"coordination.k8s.io"}))
+		Expect(rules[3].Resources).To(Equal([]string{"leases"}))
+		Expect(rules[3].Verbs).To(ConsistOf("get", "update"))
+		Expect(rules[3].ResourceNames).To(Equal([]string{"test-cluster-barman-cloud-maintenance"}))
+
+		Expect(rules[4].APIGroups).To(Equal([]string{"postgresql.cnpg.io"}))
+		Expect(rules[4].Resources).To(Equal([]string{"backups"}))
+		Expect(rules[4].Verbs).To(Equal([]string{"create"}))
+	})
+
+	It("should omit the rules restricted by name for empty input", func() {
+		rules := BuildRoleRules("test-cluster", nil, true)
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].Resources).To(Equal([]string{"leases"}))
+		Expect(rules[1].Resources).To(Equal([]string{"backups"}))
+	})
+
+	It("should only grant the creation of the Backups when importing them", func() {
+		objects := []barmancloudv1.ObjectStore{newTestObjectStore("store-a", "secret-a")}
+		rules := BuildRoleRules("test-cluster", objects, false)
+		Expect(rules).To(HaveLen(4))
+		for _, rule := range rules {
+			Expect(rule.Resources).NotTo(ContainElement("backups"))
+		}
+		Expect(ImportBackupsFromRole(&rbacv1.Role{Rules: rules})).To(BeFalse())
+
+		rules = BuildRoleRules("test-cluster", objects, true)
+		Expect(ImportBackupsFromRole(&rbacv1.Role{Rules: rules})).To(BeTrue())
 	})
 
-	It("should produce rules with empty ResourceNames for empty input", func() {
-		rules := BuildRoleRules(nil)
+	It("should omit the secrets rule when no secret is referenced", func() {
+		object := newTestObjectStore("store-a", "secret-a")
+		object.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+		rules := BuildRoleRules("test-cluster", []barmancloudv1.ObjectStore{object}, false)
 		Expect(rules).To(HaveLen(3))
-		Expect(rules[0].ResourceNames).To(BeEmpty())
-		Expect(rules[0].ResourceNames).NotTo(BeNil())
-		Expect(rules[1].ResourceNames).To(BeEmpty())
-		Expect(rules[2].ResourceNames).To(BeEmpty())
+		for _, rule := range rules {
+			Expect(rule.Resources).NotTo(ContainElement("secrets"))
+		}
+	})
+
+	It("should omit the secrets of the ObjectStores using the File credentials mode", func() {
+		fileStore := newTestObjectStore("store-a", "secret-a")
+		fileStore.Spec.InstanceSidecarConfiguration.CredentialsMode = barmancloudv1.CredentialsModeFile
+		objects := []barmancloudv1.ObjectStore{
+			fileStore,
+			newTestObjectStore("store-b", "secret-b"),
+		}
+		rules := BuildRoleRules("test-cluster", objects, false)
+		Expect(rules[0].ResourceNames).To(ConsistOf("store-a", "store-b"))
+		Expect(rules[2].Resources).To(Equal([]string{"secrets"}))
+		Expect(rules[2].ResourceNames).To(Equal([]string{"secret-b"}))
 	})
 
 	It("should deduplicate secret names across ObjectStores", func() {
@@ -92,7 +135,7 @@ var _ = Describe("BuildRoleRules", func() {
 			newTestObjectStore("store-a", "shared-secret"),
 			newTestObjectStore("store-b", "shared-secret"),
 		}
-		rules := BuildRoleRules(objects)
+		rules := BuildRoleRules("test-cluster", objects, false)
 		Expect(rules[2].ResourceNames).To(Equal([]string{"shared-secret"}))
 	})
 })
@@ -118,14 +161,14 @@ var _ = Describe("BuildRoleRules / ObjectStoreNamesFromRole round-trip", func()
 			newTestObjectStore("store-a", "secret-a"),
 			newTestObjectStore("store-b", "secret-b"),
 		}
-		rules := BuildRoleRules(objects)
+		rules := BuildRoleRules("test-cluster", objects, false)
 		role := &rbacv1.Role{Rules: rules}
 		names := ObjectStoreNamesFromRole(role)
 		Expect(names).To(ConsistOf("store-a", "store-b"))
 	})
 
 	It("should recover empty names from rules built with no ObjectStores", func() {
-		rules := BuildRoleRules(nil)
+		rules := BuildRoleRules("test-cluster", nil, false)
 		role := &rbacv1.Role{Rules: rules}
 		names := ObjectStoreNamesFromRole(role)
 		Expect(names).To(BeEmpty())
@@ -22,10 +22,40 @@ package specs
 import (
 	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
 	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	"github.com/cloudnative-pg/machinery/pkg/stringset"
 )
 
 // CollectSecretNamesFromCredentials collects the names of the secrets
 func CollectSecretNamesFromCredentials(barmanCredentials *barmanapi.BarmanCredentials) []string {
+	references := CollectSecretKeySelectorsFromCredentials(barmanCredentials)
+
+	result := make([]string, 0, len(references))
+	for _, reference := range references {
+		result = append(result, reference.Name)
+	}
+
+	// TODO: stringset belongs to machinery :(
+
+	return result
+}
+
+// CollectReferencedSecretNames collects the sorted names of the secrets
+// referenced by the credentials and the endpoint CA of the passed
+// configuration
+func CollectReferencedSecretNames(configuration *barmanapi.BarmanObjectStoreConfiguration) []string {
+	names := stringset.From(CollectSecretNamesFromCredentials(&configuration.BarmanCredentials))
+	if configuration.EndpointCA != nil && len(configuration.EndpointCA.Name) > 0 {
+		names.Put(configuration.EndpointCA.Name)
+	}
+
+	return names.ToSortedList()
+}
+
+// CollectSecretKeySelectorsFromCredentials collects the secret keys
+// referenced by the credentials
+func CollectSecretKeySelectorsFromCredentials(
+	barmanCredentials *barmanapi.BarmanCredentials,
+) []*machineryapi.SecretKeySelector {
 	var references []*machineryapi.SecretKeySelector
 	if barmanCredentials.AWS != nil {
 		references = append(
@@ -56,15 +86,13 @@ func CollectSecretNamesFromCredentials(barmanCredentials *barmanapi.BarmanCreden
 		)
 	}
 
-	result := make([]string, 0, len(references))
+	result := make([]*machineryapi.SecretKeySelector, 0, len(references))
 	for _, reference := range references {
 		if reference == nil {
 			continue
 		}
-		result = append(result, reference.Name)
+		result = append(result, reference)
 	}
 
-	// TODO: stringset belongs to machinery :(
-
 	return result
 }
@@ -225,3 +225,38 @@ var _ = Describe("CollectSecretNamesFromCredentials", func() {
 		})
 	})
 })
+
+var _ = Describe("CollectReferencedSecretNames", func() {
+	It("should return the sorted secrets of the credentials and of the endpoint CA", func() {
+		configuration := &barmanapi.BarmanObjectStoreConfiguration{
+			BarmanCredentials: barmanapi.BarmanCredentials{
+				AWS: &barmanapi.S3Credentials{
+					AccessKeyIDReference: &machineryapi.SecretKeySelector{
+						LocalObjectReference: machineryapi.LocalObjectReference{
+							Name: "aws-secret",
+						},
+						Key: "access-key-id",
+					},
+					SecretAccessKeyReference: &machineryapi.SecretKeySelector{
+						LocalObjectReference: machineryapi.LocalObjectReference{
+							Name: "aws-secret",
+						},
+						Key: "secret-access-key",
+					},
+				},
+			},
+			EndpointCA: &machineryapi.SecretKeySelector{
+				LocalObjectReference: machineryapi.LocalObjectReference{
+					Name: "ca-secret",
+				},
+				Key: "ca.crt",
+			},
+		}
+
+		Expect(CollectReferencedSecretNames(configuration)).To(Equal([]string{"aws-secret", "ca-secret"}))
+	})
+
+	It("should return an empty list when no secret is referenced", func() {
+		Expect(CollectReferencedSecretNames(&barmanapi.BarmanObjectStoreConfiguration{})).To(BeEmpty())
+	})
+})
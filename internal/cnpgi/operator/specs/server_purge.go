@@ -0,0 +1,83 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// ServerPurgeJobDeadline is the maximum duration of a server purge Job
+const ServerPurgeJobDeadline = time.Hour
+
+// GetServerPurgeJobName returns the name of the Job purging the data
+// of a deleted Cluster from the passed ObjectStore. The purges of an
+// ObjectStore are run one at a time.
+func GetServerPurgeJobName(objectStoreName string) string {
+	return fmt.Sprintf("%s-barman-cloud-purge", objectStoreName)
+}
+
+// BuildServerPurgeJob builds the Job running the passed purge of the
+// data of a deleted Cluster from the passed ObjectStore. Like the
+// probe Job, it gets the credentials from the kubelet and runs with
+// the same ServiceAccount, without any permission on the Kubernetes
+// API.
+func BuildServerPurgeJob(
+	objectStore *barmancloudv1.ObjectStore,
+	purge *barmancloudv1.ServerPurge,
+	image string,
+	imagePullSecrets []corev1.LocalObjectReference,
+) (*batchv1.Job, error) {
+	sidecarConfiguration := &objectStore.Spec.InstanceSidecarConfiguration
+
+	env, err := buildObjectStoreJobEnv(objectStore, corev1.EnvVar{
+		Name:  "SERVER_NAME",
+		Value: purge.ServerName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"server-purge"}
+	if len(sidecarConfiguration.LogLevel) > 0 {
+		args = append(args, fmt.Sprintf("--log-level=%s", sidecarConfiguration.LogLevel))
+	}
+
+	job := buildObjectStoreJob(
+		objectStore,
+		GetServerPurgeJobName(objectStore.Name),
+		image,
+		imagePullSecrets,
+		args,
+		env,
+		ServerPurgeJobDeadline,
+	)
+	job.Annotations = map[string]string{
+		metadata.ServerPurgeClusterUIDAnnotationName: purge.ClusterUID,
+	}
+
+	return job, nil
+}
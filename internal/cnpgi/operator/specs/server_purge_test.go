@@ -0,0 +1,65 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("Server purge job", func() {
+	It("should build the job", func() {
+		objectStore := &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-store",
+				Namespace: "backup-credentials",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+					BarmanCredentials: barmanapi.BarmanCredentials{
+						AWS: &barmanapi.S3Credentials{InheritFromIAMRole: true},
+					},
+				},
+			},
+		}
+		purge := &barmancloudv1.ServerPurge{
+			ServerName: "cluster-example",
+			ClusterUID: "1234",
+		}
+
+		job, err := BuildServerPurgeJob(objectStore, purge, "sidecar:latest", nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(job.Name).To(Equal("my-store-barman-cloud-purge"))
+		Expect(job.Namespace).To(Equal("backup-credentials"))
+		Expect(job.Annotations).To(HaveKeyWithValue(metadata.ServerPurgeClusterUIDAnnotationName, "1234"))
+
+		container := job.Spec.Template.Spec.Containers[0]
+		Expect(container.Args).To(Equal([]string{"server-purge"}))
+		Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "SERVER_NAME", Value: "cluster-example"}))
+	})
+})
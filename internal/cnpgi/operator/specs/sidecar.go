@@ -0,0 +1,248 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// BarmanCertificatesVolumeName is the name of the volume that hosts
+// the barman certificates to be used
+const BarmanCertificatesVolumeName = "barman-certificates"
+
+// BarmanCredentialsVolumeName is the name of the volume that hosts
+// the Secrets referenced by the credentials, when using the File
+// credentials mode
+const BarmanCredentialsVolumeName = "barman-credentials"
+
+// BarmanSpoolVolumeName is the name of the dedicated volume that hosts
+// the WAL restore spool
+const BarmanSpoolVolumeName = "barman-spool"
+
+// BuildSidecarSecurityContext returns the security context applied to
+// every container running the plugin image
+func BuildSidecarSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		AllowPrivilegeEscalation: ptr.To(false),
+		RunAsNonRoot:             ptr.To(true),
+		Privileged:               ptr.To(false),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+}
+
+// BuildCertificatesProjection returns the projection of the endpoint CA
+// of the passed ObjectStore inside the barman certificates volume, or
+// nil if the ObjectStore does not use a custom CA
+func BuildCertificatesProjection(objectStore *barmancloudv1.ObjectStore) []corev1.VolumeProjection {
+	endpointCA := objectStore.Spec.Configuration.EndpointCA
+	if endpointCA == nil {
+		return nil
+	}
+
+	return []corev1.VolumeProjection{
+		{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: endpointCA.Name,
+				},
+				Items: []corev1.KeyToPath{
+					{
+						Key: endpointCA.Key,
+						Path: path.Join(
+							objectStore.Name,
+							metadata.BarmanCertificatesFileName,
+						),
+					},
+				},
+			},
+		},
+	}
+}
+
+// BuildCredentialsProjection returns the projection of the Secrets
+// referenced by the credentials of the passed ObjectStore inside the
+// barman credentials volume, or nil if the ObjectStore does not use
+// the File credentials mode.
+//
+// Every key is projected in a file named after it, inside a directory
+// named after its Secret.
+func BuildCredentialsProjection(objectStore *barmancloudv1.ObjectStore) []corev1.VolumeProjection {
+	if objectStore.Spec.InstanceSidecarConfiguration.GetCredentialsMode() != barmancloudv1.CredentialsModeFile {
+		return nil
+	}
+
+	keysBySecret := make(map[string][]string)
+	for _, selector := range CollectSecretKeySelectorsFromCredentials(
+		&objectStore.Spec.Configuration.BarmanCredentials,
+	) {
+		if !slices.Contains(keysBySecret[selector.Name], selector.Key) {
+			keysBySecret[selector.Name] = append(keysBySecret[selector.Name], selector.Key)
+		}
+	}
+
+	secretNames := slices.Sorted(maps.Keys(keysBySecret))
+	result := make([]corev1.VolumeProjection, 0, len(secretNames))
+	for _, secretName := range secretNames {
+		keys := keysBySecret[secretName]
+		slices.Sort(keys)
+
+		items := make([]corev1.KeyToPath, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, corev1.KeyToPath{
+				Key:  key,
+				Path: path.Join(secretName, key),
+			})
+		}
+
+		result = append(result, corev1.VolumeProjection{
+			Secret: &corev1.SecretProjection{
+				LocalObjectReference: corev1.LocalObjectReference{
+					Name: secretName,
+				},
+				Items: items,
+			},
+		})
+	}
+
+	return result
+}
+
+// BuildSpoolVolumeSource returns the source of the dedicated volume
+// hosting the WAL restore spool, or nil if the spool uses the volume
+// shared with the instance manager
+func BuildSpoolVolumeSource(configuration *barmancloudv1.SpoolVolumeConfiguration) *corev1.VolumeSource {
+	switch {
+	case configuration == nil:
+		return nil
+
+	case configuration.EmptyDir != nil:
+		return &corev1.VolumeSource{
+			EmptyDir: configuration.EmptyDir.DeepCopy(),
+		}
+
+	case configuration.Ephemeral != nil:
+		return &corev1.VolumeSource{
+			Ephemeral: &corev1.EphemeralVolumeSource{
+				VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+						StorageClassName: configuration.Ephemeral.StorageClassName,
+						Resources: corev1.VolumeResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceStorage: configuration.Ephemeral.Size,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	return nil
+}
+
+// ApplySidecarContainerTemplate applies the passed template as a
+// strategic merge patch over the sidecar container generated by the
+// plugin. The fields the plugin relies on are restored after the
+// merge: the name, image, command, arguments and restart policy, the
+// handler of the startup probe, and the environment variables and
+// volume mounts of the generated container.
+func ApplySidecarContainerTemplate(sidecar *corev1.Container, template *corev1.Container) error {
+	if template == nil {
+		return nil
+	}
+
+	original, err := runtime.DefaultUnstructuredConverter.ToUnstructured(sidecar)
+	if err != nil {
+		return fmt.Errorf("while encoding the sidecar container: %w", err)
+	}
+
+	patch, err := runtime.DefaultUnstructuredConverter.ToUnstructured(template)
+	if err != nil {
+		return fmt.Errorf("while encoding the sidecar container template: %w", err)
+	}
+	// The name is required by the Container type, but it is not
+	// meant to be set in the template
+	delete(patch, "name")
+
+	merged, err := strategicpatch.StrategicMergeMapPatch(original, patch, corev1.Container{})
+	if err != nil {
+		return fmt.Errorf("while applying the sidecar container template: %w", err)
+	}
+
+	var result corev1.Container
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(merged, &result); err != nil {
+		return fmt.Errorf("while decoding the sidecar container: %w", err)
+	}
+
+	result.Name = sidecar.Name
+	result.Image = sidecar.Image
+	result.Command = sidecar.Command
+	result.Args = sidecar.Args
+	result.RestartPolicy = sidecar.RestartPolicy
+
+	if sidecar.StartupProbe != nil {
+		if result.StartupProbe == nil {
+			result.StartupProbe = sidecar.StartupProbe.DeepCopy()
+		}
+		result.StartupProbe.ProbeHandler = *sidecar.StartupProbe.ProbeHandler.DeepCopy()
+	}
+
+	for _, env := range sidecar.Env {
+		idx := slices.IndexFunc(result.Env, func(existing corev1.EnvVar) bool {
+			return existing.Name == env.Name
+		})
+		if idx < 0 {
+			result.Env = append(result.Env, env)
+			continue
+		}
+		result.Env[idx] = env
+	}
+
+	// The volume mounts are merged by name and path: the template mounts
+	// clashing with a generated one are dropped, and the generated ones
+	// are kept
+	result.VolumeMounts = slices.DeleteFunc(result.VolumeMounts, func(mount corev1.VolumeMount) bool {
+		return slices.ContainsFunc(sidecar.VolumeMounts, func(generated corev1.VolumeMount) bool {
+			return generated.Name == mount.Name || generated.MountPath == mount.MountPath
+		})
+	})
+	result.VolumeMounts = append(result.VolumeMounts, sidecar.VolumeMounts...)
+
+	*sidecar = result
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+)
+
+// ParseSidecarImageAllowList splits the comma-separated list of patterns
+// of the images that the object stores are allowed to use for the sidecar
+func ParseSidecarImageAllowList(value string) []string {
+	var result []string
+	for _, pattern := range strings.Split(value, ",") {
+		if pattern = strings.TrimSpace(pattern); len(pattern) > 0 {
+			result = append(result, pattern)
+		}
+	}
+
+	return result
+}
+
+// IsSidecarImageAllowed checks if the passed image matches one of the
+// patterns of the allow-list, using the syntax of path.Match
+func IsSidecarImageAllowed(image string, allowList []string) bool {
+	return slices.ContainsFunc(allowList, func(pattern string) bool {
+		matched, err := path.Match(pattern, image)
+		return err == nil && matched
+	})
+}
+
+// GetSidecarImage returns the image of the sidecar of the passed object
+// store, which is the one it requests when allowed, and the default one
+// otherwise
+func GetSidecarImage(
+	objectStore *barmancloudv1.ObjectStore,
+	defaultImage string,
+	allowList []string,
+) string {
+	image := objectStore.Spec.InstanceSidecarConfiguration.Image
+	if len(image) == 0 || !IsSidecarImageAllowed(image, allowList) {
+		return defaultImage
+	}
+
+	return image
+}
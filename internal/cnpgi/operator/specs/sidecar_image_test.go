@@ -0,0 +1,62 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sidecar image", func() {
+	const defaultImage = "ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:v1.0.0"
+
+	allowList := ParseSidecarImageAllowList(
+		" ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:*, ,registry.example.com/barman@sha256:* ")
+
+	It("parses the comma-separated allow-list", func() {
+		Expect(allowList).To(Equal([]string{
+			"ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:*",
+			"registry.example.com/barman@sha256:*",
+		}))
+		Expect(ParseSidecarImageAllowList("")).To(BeEmpty())
+	})
+
+	DescribeTable("matches the images against the allow-list",
+		func(image string, expected bool) {
+			Expect(IsSidecarImageAllowed(image, allowList)).To(Equal(expected))
+		},
+		Entry("tag", "ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:v1.1.0", true),
+		Entry("digest", "registry.example.com/barman@sha256:0123456789abcdef", true),
+		Entry("another repository", "ghcr.io/attacker/plugin-barman-cloud-sidecar:v1.1.0", false),
+		Entry("nested repository", "ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar/x:v1", false),
+	)
+
+	It("uses the requested image only when allowed", func() {
+		objectStore := &barmancloudv1.ObjectStore{}
+		Expect(GetSidecarImage(objectStore, defaultImage, allowList)).To(Equal(defaultImage))
+
+		objectStore.Spec.InstanceSidecarConfiguration.Image = "ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:v1.1.0"
+		Expect(GetSidecarImage(objectStore, defaultImage, allowList)).
+			To(Equal("ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:v1.1.0"))
+		Expect(GetSidecarImage(objectStore, defaultImage, nil)).To(Equal(defaultImage))
+	})
+})
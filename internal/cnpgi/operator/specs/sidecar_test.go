@@ -0,0 +1,212 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BuildCredentialsProjection", func() {
+	var objectStore barmancloudv1.ObjectStore
+
+	BeforeEach(func() {
+		objectStore = newTestObjectStore("store", "aws-secret")
+		objectStore.Spec.Configuration.AWS.SecretAccessKeyReference = &machineryapi.SecretKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{
+				Name: "aws-secret",
+			},
+			Key: "SECRET_ACCESS_KEY",
+		}
+		objectStore.Spec.Configuration.AWS.RegionReference = &machineryapi.SecretKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{
+				Name: "region-secret",
+			},
+			Key: "REGION",
+		}
+	})
+
+	It("should not project anything when using the API credentials mode", func() {
+		Expect(BuildCredentialsProjection(&objectStore)).To(BeNil())
+	})
+
+	It("should project every key in a directory named after its Secret", func() {
+		objectStore.Spec.InstanceSidecarConfiguration.CredentialsMode = barmancloudv1.CredentialsModeFile
+
+		Expect(BuildCredentialsProjection(&objectStore)).To(Equal([]corev1.VolumeProjection{
+			{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "aws-secret"},
+					Items: []corev1.KeyToPath{
+						{Key: "ACCESS_KEY_ID", Path: "aws-secret/ACCESS_KEY_ID"},
+						{Key: "SECRET_ACCESS_KEY", Path: "aws-secret/SECRET_ACCESS_KEY"},
+					},
+				},
+			},
+			{
+				Secret: &corev1.SecretProjection{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "region-secret"},
+					Items: []corev1.KeyToPath{
+						{Key: "REGION", Path: "region-secret/REGION"},
+					},
+				},
+			},
+		}))
+	})
+
+	It("should not project anything when the credentials are inherited", func() {
+		objectStore.Spec.InstanceSidecarConfiguration.CredentialsMode = barmancloudv1.CredentialsModeFile
+		objectStore.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+
+		Expect(BuildCredentialsProjection(&objectStore)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("ApplySidecarContainerTemplate", func() {
+	var sidecar corev1.Container
+
+	BeforeEach(func() {
+		sidecar = corev1.Container{
+			Name:          "plugin-barman-cloud",
+			Image:         "sidecar:latest",
+			Args:          []string{"instance"},
+			RestartPolicy: ptr.To(corev1.ContainerRestartPolicyAlways),
+			Env:           []corev1.EnvVar{{Name: "CLUSTER_NAME", Value: "cluster"}},
+			StartupProbe: &corev1.Probe{
+				PeriodSeconds:    1,
+				FailureThreshold: 30,
+				ProbeHandler: corev1.ProbeHandler{
+					Exec: &corev1.ExecAction{Command: []string{"/manager", "healthcheck", "unix"}},
+				},
+			},
+			SecurityContext: BuildSidecarSecurityContext(),
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: BarmanCertificatesVolumeName, MountPath: "/barman-certificates"},
+			},
+		}
+	})
+
+	It("should not change the container without a template", func() {
+		expected := sidecar.DeepCopy()
+		Expect(ApplySidecarContainerTemplate(&sidecar, nil)).To(Succeed())
+		Expect(sidecar).To(Equal(*expected))
+	})
+
+	It("should merge the template over the generated container", func() {
+		Expect(ApplySidecarContainerTemplate(&sidecar, &corev1.Container{
+			Env: []corev1.EnvVar{{Name: "HTTPS_PROXY", Value: "proxy:3128"}},
+			StartupProbe: &corev1.Probe{
+				PeriodSeconds:    5,
+				FailureThreshold: 60,
+			},
+			SecurityContext: &corev1.SecurityContext{RunAsUser: ptr.To(int64(1001))},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "projected", MountPath: "/etc/proxy"},
+			},
+		})).To(Succeed())
+
+		Expect(sidecar.Env).To(ConsistOf(
+			corev1.EnvVar{Name: "CLUSTER_NAME", Value: "cluster"},
+			corev1.EnvVar{Name: "HTTPS_PROXY", Value: "proxy:3128"},
+		))
+		Expect(sidecar.StartupProbe.PeriodSeconds).To(BeEquivalentTo(5))
+		Expect(sidecar.StartupProbe.FailureThreshold).To(BeEquivalentTo(60))
+		Expect(sidecar.StartupProbe.Exec.Command).To(Equal([]string{"/manager", "healthcheck", "unix"}))
+		Expect(*sidecar.SecurityContext.RunAsUser).To(BeEquivalentTo(1001))
+		Expect(*sidecar.SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+		Expect(sidecar.VolumeMounts).To(ConsistOf(
+			corev1.VolumeMount{Name: "projected", MountPath: "/etc/proxy"},
+			corev1.VolumeMount{Name: BarmanCertificatesVolumeName, MountPath: "/barman-certificates"},
+		))
+	})
+
+	It("should preserve the fields the plugin relies on", func() {
+		Expect(ApplySidecarContainerTemplate(&sidecar, &corev1.Container{
+			Name:          "other",
+			Image:         "other:latest",
+			Command:       []string{"/bin/sh"},
+			Args:          []string{"backup"},
+			RestartPolicy: ptr.To(corev1.ContainerRestartPolicy("Never")),
+			Env:           []corev1.EnvVar{{Name: "CLUSTER_NAME", Value: "other"}},
+			StartupProbe: &corev1.Probe{
+				ProbeHandler: corev1.ProbeHandler{
+					Exec: &corev1.ExecAction{Command: []string{"true"}},
+				},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				{Name: "other", MountPath: "/barman-certificates"},
+			},
+		})).To(Succeed())
+
+		Expect(sidecar.Name).To(Equal("plugin-barman-cloud"))
+		Expect(sidecar.Image).To(Equal("sidecar:latest"))
+		Expect(sidecar.Command).To(BeEmpty())
+		Expect(sidecar.Args).To(Equal([]string{"instance"}))
+		Expect(*sidecar.RestartPolicy).To(Equal(corev1.ContainerRestartPolicyAlways))
+		Expect(sidecar.Env).To(ConsistOf(corev1.EnvVar{Name: "CLUSTER_NAME", Value: "cluster"}))
+		Expect(sidecar.StartupProbe.Exec.Command).To(Equal([]string{"/manager", "healthcheck", "unix"}))
+		Expect(sidecar.VolumeMounts).To(ConsistOf(
+			corev1.VolumeMount{Name: BarmanCertificatesVolumeName, MountPath: "/barman-certificates"},
+		))
+	})
+})
+
+var _ = Describe("BuildSpoolVolumeSource", func() {
+	It("should not build anything without a dedicated volume", func() {
+		Expect(BuildSpoolVolumeSource(nil)).To(BeNil())
+	})
+
+	It("should build an emptyDir volume", func() {
+		sizeLimit := resource.MustParse("2Gi")
+		source := BuildSpoolVolumeSource(&barmancloudv1.SpoolVolumeConfiguration{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMediumMemory,
+				SizeLimit: &sizeLimit,
+			},
+		})
+		Expect(source).NotTo(BeNil())
+		Expect(source.EmptyDir.Medium).To(Equal(corev1.StorageMediumMemory))
+		Expect(source.EmptyDir.SizeLimit.String()).To(Equal("2Gi"))
+	})
+
+	It("should build an ephemeral volume", func() {
+		source := BuildSpoolVolumeSource(&barmancloudv1.SpoolVolumeConfiguration{
+			Ephemeral: &barmancloudv1.SpoolEphemeralVolumeConfiguration{
+				StorageClassName: ptr.To("fast"),
+				Size:             resource.MustParse("10Gi"),
+			},
+		})
+		Expect(source).NotTo(BeNil())
+
+		claimSpec := source.Ephemeral.VolumeClaimTemplate.Spec
+		Expect(claimSpec.AccessModes).To(ConsistOf(corev1.ReadWriteOnce))
+		Expect(*claimSpec.StorageClassName).To(Equal("fast"))
+		storage := claimSpec.Resources.Requests[corev1.ResourceStorage]
+		Expect(storage.String()).To(Equal("10Gi"))
+	})
+})
@@ -0,0 +1,147 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	"maps"
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// BarmanWorkloadIdentityVolumeName is the name of the volume that hosts
+// the service account tokens of the workload identities
+const BarmanWorkloadIdentityVolumeName = "barman-workload-identity"
+
+const (
+	// AWSRoleARNAnnotationName is the annotation of the workload
+	// identity holding the ARN of the AWS IAM role to be assumed
+	AWSRoleARNAnnotationName = "eks.amazonaws.com/role-arn"
+
+	// AzureClientIDAnnotationName is the annotation of the workload
+	// identity holding the client ID of the Azure managed identity
+	AzureClientIDAnnotationName = "azure.workload.identity/client-id"
+
+	// AzureTenantIDAnnotationName is the annotation of the workload
+	// identity holding the ID of the Azure tenant
+	AzureTenantIDAnnotationName = "azure.workload.identity/tenant-id"
+
+	// azureAuthorityHost is the Microsoft Entra ID endpoint the Azure
+	// SDKs exchange the projected token with
+	azureAuthorityHost = "https://login.microsoftonline.com/"
+)
+
+// GetWorkloadIdentityTokenFile returns the path of the service account
+// token projected for the workload identity of the passed ObjectStore
+func GetWorkloadIdentityTokenFile(objectStoreName string) string {
+	return path.Join(
+		metadata.BarmanWorkloadIdentityPath,
+		objectStoreName,
+		metadata.BarmanWorkloadIdentityTokenFileName,
+	)
+}
+
+// BuildWorkloadIdentityProjection returns the projection of the service
+// account token of the workload identity of the passed ObjectStore
+// inside the barman workload identity volume, or nil if the ObjectStore
+// does not use a workload identity
+func BuildWorkloadIdentityProjection(objectStore *barmancloudv1.ObjectStore) []corev1.VolumeProjection {
+	workloadIdentity := objectStore.Spec.WorkloadIdentity
+	if workloadIdentity == nil {
+		return nil
+	}
+
+	return []corev1.VolumeProjection{
+		{
+			ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+				Audience:          workloadIdentity.Audience,
+				ExpirationSeconds: ptr.To(workloadIdentity.GetExpirationSeconds()),
+				Path: path.Join(
+					objectStore.Name,
+					metadata.BarmanWorkloadIdentityTokenFileName,
+				),
+			},
+		},
+	}
+}
+
+// BuildWorkloadIdentityEnv returns the environment variables the cloud
+// provider SDKs need to exchange the projected service account token
+// of the passed ObjectStore for temporary credentials, or nil if the
+// ObjectStore does not use a workload identity.
+//
+// Google Cloud Storage is not covered, as GKE serves the credentials
+// of the workload identity through the metadata server.
+func BuildWorkloadIdentityEnv(objectStore *barmancloudv1.ObjectStore) []corev1.EnvVar {
+	workloadIdentity := objectStore.Spec.WorkloadIdentity
+	if workloadIdentity == nil {
+		return nil
+	}
+
+	tokenFile := GetWorkloadIdentityTokenFile(objectStore.Name)
+
+	var env []corev1.EnvVar
+	addAnnotationEnv := func(name string, annotationName string) {
+		if value := workloadIdentity.Annotations[annotationName]; len(value) > 0 {
+			env = append(env, corev1.EnvVar{Name: name, Value: value})
+		}
+	}
+
+	configuration := &objectStore.Spec.Configuration
+	switch {
+	case configuration.AWS != nil:
+		env = append(env, corev1.EnvVar{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: tokenFile})
+		addAnnotationEnv("AWS_ROLE_ARN", AWSRoleARNAnnotationName)
+
+	case configuration.Azure != nil:
+		env = append(env,
+			corev1.EnvVar{Name: "AZURE_FEDERATED_TOKEN_FILE", Value: tokenFile},
+			corev1.EnvVar{Name: "AZURE_AUTHORITY_HOST", Value: azureAuthorityHost},
+		)
+		addAnnotationEnv("AZURE_CLIENT_ID", AzureClientIDAnnotationName)
+		addAnnotationEnv("AZURE_TENANT_ID", AzureTenantIDAnnotationName)
+	}
+
+	return env
+}
+
+// BuildWorkloadIdentityServiceAccount builds the dedicated ServiceAccount
+// of the workload identity of the passed ObjectStore, or returns nil if
+// the ObjectStore does not require one
+func BuildWorkloadIdentityServiceAccount(objectStore *barmancloudv1.ObjectStore) *corev1.ServiceAccount {
+	workloadIdentity := objectStore.Spec.WorkloadIdentity
+	if workloadIdentity == nil || len(workloadIdentity.ServiceAccountName) == 0 {
+		return nil
+	}
+
+	return &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   objectStore.Namespace,
+			Name:        workloadIdentity.ServiceAccountName,
+			Labels:      BuildObjectStoreLabels(objectStore),
+			Annotations: maps.Clone(workloadIdentity.Annotations),
+		},
+	}
+}
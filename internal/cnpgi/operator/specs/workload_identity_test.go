@@ -0,0 +1,102 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package specs
+
+import (
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Workload identity", func() {
+	var objectStore barmancloudv1.ObjectStore
+
+	BeforeEach(func() {
+		objectStore = newTestObjectStore("store", "aws-secret")
+		objectStore.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+		objectStore.Spec.WorkloadIdentity = &barmancloudv1.WorkloadIdentityConfiguration{
+			Audience: "sts.amazonaws.com",
+			Annotations: map[string]string{
+				AWSRoleARNAnnotationName: "arn:aws:iam::123456789012:role/backup",
+			},
+		}
+	})
+
+	It("should not build anything without a workload identity", func() {
+		objectStore.Spec.WorkloadIdentity = nil
+
+		Expect(BuildWorkloadIdentityProjection(&objectStore)).To(BeNil())
+		Expect(BuildWorkloadIdentityEnv(&objectStore)).To(BeNil())
+		Expect(BuildWorkloadIdentityServiceAccount(&objectStore)).To(BeNil())
+	})
+
+	It("should project the token in a directory named after the object store", func() {
+		Expect(BuildWorkloadIdentityProjection(&objectStore)).To(Equal([]corev1.VolumeProjection{
+			{
+				ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+					Audience:          "sts.amazonaws.com",
+					ExpirationSeconds: ptr.To(int64(3600)),
+					Path:              "store/token",
+				},
+			},
+		}))
+	})
+
+	It("should build the AWS environment", func() {
+		Expect(BuildWorkloadIdentityEnv(&objectStore)).To(Equal([]corev1.EnvVar{
+			{Name: "AWS_WEB_IDENTITY_TOKEN_FILE", Value: "/barman-workload-identity/store/token"},
+			{Name: "AWS_ROLE_ARN", Value: "arn:aws:iam::123456789012:role/backup"},
+		}))
+	})
+
+	It("should build the Azure environment", func() {
+		objectStore.Spec.Configuration.AWS = nil
+		objectStore.Spec.Configuration.Azure = &barmanapi.AzureCredentials{InheritFromAzureAD: true}
+		objectStore.Spec.WorkloadIdentity.Audience = "api://AzureADTokenExchange"
+		objectStore.Spec.WorkloadIdentity.Annotations = map[string]string{
+			AzureClientIDAnnotationName: "client-id",
+			AzureTenantIDAnnotationName: "tenant-id",
+		}
+
+		Expect(BuildWorkloadIdentityEnv(&objectStore)).To(Equal([]corev1.EnvVar{
+			{Name: "AZURE_FEDERATED_TOKEN_FILE", Value: "/barman-workload-identity/store/token"},
+			{Name: "AZURE_AUTHORITY_HOST", Value: "https://login.microsoftonline.com/"},
+			{Name: "AZURE_CLIENT_ID", Value: "client-id"},
+			{Name: "AZURE_TENANT_ID", Value: "tenant-id"},
+		}))
+	})
+
+	It("should build the dedicated ServiceAccount with the annotations", func() {
+		objectStore.Spec.WorkloadIdentity.ServiceAccountName = "backup-identity"
+
+		serviceAccount := BuildWorkloadIdentityServiceAccount(&objectStore)
+		Expect(serviceAccount).NotTo(BeNil())
+		Expect(serviceAccount.Namespace).To(Equal("default"))
+		Expect(serviceAccount.Name).To(Equal("backup-identity"))
+		Expect(serviceAccount.Labels).To(HaveKeyWithValue(metadata.ObjectStoreLabelName, "store"))
+		Expect(serviceAccount.Annotations).To(Equal(objectStore.Spec.WorkloadIdentity.Annotations))
+	})
+})
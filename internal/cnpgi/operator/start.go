@@ -26,17 +26,26 @@ import (
 	"github.com/cloudnative-pg/cnpg-i/pkg/lifecycle"
 	"github.com/cloudnative-pg/cnpg-i/pkg/reconciler"
 	"google.golang.org/grpc"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // CNPGI is the implementation of the CNPG-i server
 type CNPGI struct {
-	Client         client.Client
-	PluginPath     string
-	ServerCertPath string
-	ServerKeyPath  string
-	ClientCertPath string
-	ServerAddress  string
+	Client client.Client
+	// APIReader reads the objects that are not worth caching,
+	// such as Secrets
+	APIReader client.Reader
+	// Recorder reports the events about the Clusters
+	Recorder record.EventRecorder
+	// ServerNameCollisionPolicy is what to do with a Cluster archiving
+	// with the same server name into the same destination as others
+	ServerNameCollisionPolicy ServerNameCollisionPolicy
+	PluginPath                string
+	ServerCertPath            string
+	ServerKeyPath             string
+	ClientCertPath            string
+	ServerAddress             string
 }
 
 // Start starts the GRPC server
@@ -44,10 +53,16 @@ type CNPGI struct {
 func (c *CNPGI) Start(ctx context.Context) error {
 	enrich := func(server *grpc.Server) error {
 		reconciler.RegisterReconcilerHooksServer(server, ReconcilerImplementation{
-			Client: c.Client,
+			Client:                    c.Client,
+			APIReader:                 c.APIReader,
+			Recorder:                  c.Recorder,
+			ServerNameCollisionPolicy: c.ServerNameCollisionPolicy,
+			collisionReports:          newServerNameCollisionReports(),
 		})
 		lifecycle.RegisterOperatorLifecycleServer(server, LifecycleImplementation{
-			Client: c.Client,
+			Client:    c.Client,
+			APIReader: c.APIReader,
+			Recorder:  c.Recorder,
 		})
 		return nil
 	}
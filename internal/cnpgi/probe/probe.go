@@ -0,0 +1,173 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package probe
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/spf13/viper"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/cloudscript"
+)
+
+// script is the Python script doing the actual probe through the
+// barman cloud interface
+//
+//go:embed probe.py
+var script string
+
+const (
+	// serverName is the server name passed to barman, which requires
+	// one even if the probe never accesses the server directory
+	serverName = "barman-cloud-probe"
+
+	// TerminationMessagePath is the path where the probe Job writes
+	// the Result of a failed probe
+	TerminationMessagePath = "/dev/termination-log"
+
+	// maxMessageLength is the maximum length of the message stored in
+	// the termination message, which is limited to 4096 bytes
+	maxMessageLength = 2048
+)
+
+// Result is the outcome of a failed probe. It is written by the probe
+// Job as its termination message, and read by the operator to set the
+// Reachable condition of the ObjectStore.
+type Result struct {
+	// Reason is the reason of the Reachable condition
+	Reason string `json:"reason"`
+
+	// Message is a human readable description of the failure
+	Message string `json:"message"`
+}
+
+// Start runs the probe of the object store configuration passed in
+// the environment, recording its failure in the termination message
+func Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx)
+
+	var configuration barmanapi.BarmanObjectStoreConfiguration
+	if err := json.Unmarshal(
+		[]byte(viper.GetString("object-store-configuration")),
+		&configuration,
+	); err != nil {
+		return fmt.Errorf("while decoding the object store configuration: %w", err)
+	}
+
+	result := Run(ctx, &configuration, viper.GetBool("write-check"))
+	if result == nil {
+		contextLogger.Info("Object store reachable",
+			"destinationPath", configuration.DestinationPath)
+		return nil
+	}
+
+	if err := WriteTerminationMessage(TerminationMessagePath, result); err != nil {
+		contextLogger.Error(err, "while writing the termination message")
+	}
+
+	return fmt.Errorf("%s: %s", result.Reason, result.Message)
+}
+
+// Run lists the destination path of the passed configuration and,
+// if writeCheck is set, uploads and removes a small object there.
+// It returns nil if the object store is reachable.
+func Run(
+	ctx context.Context,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	writeCheck bool,
+) *Result {
+	if err := cloudscript.Run(
+		ctx,
+		script,
+		configuration,
+		serverName,
+		os.Environ(),
+		nil,
+		strconv.FormatBool(writeCheck),
+	); err != nil {
+		return newResult(err)
+	}
+
+	return nil
+}
+
+// newResult builds the Result of a failed probe
+func newResult(err error) *Result {
+	return &Result{
+		Reason:  reasonFromExitCode(cloudscript.GetExitCode(err)),
+		Message: err.Error(),
+	}
+}
+
+// reasonFromExitCode maps the exit code of the probe script to the
+// reason of the Reachable condition
+func reasonFromExitCode(exitCode int) string {
+	switch exitCode {
+	case cloudscript.ExitConnectionFailed:
+		return barmancloudv1.ReasonConnectionFailed
+	case cloudscript.ExitBucketNotFound:
+		return barmancloudv1.ReasonBucketNotFound
+	case cloudscript.ExitListFailed:
+		return barmancloudv1.ReasonListFailed
+	case cloudscript.ExitWriteFailed:
+		return barmancloudv1.ReasonWriteFailed
+	default:
+		return barmancloudv1.ReasonProbeFailed
+	}
+}
+
+// WriteTerminationMessage writes the passed Result to the termination
+// message file, truncating its message when needed
+func WriteTerminationMessage(fileName string, result *Result) error {
+	truncated := *result
+	if len(truncated.Message) > maxMessageLength {
+		// Keep the tail, where the actual error is printed
+		truncated.Message = truncated.Message[len(truncated.Message)-maxMessageLength:]
+	}
+
+	data, err := json.Marshal(truncated)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(fileName, data, 0o600)
+}
+
+// ParseTerminationMessage decodes the Result written by a failed probe
+// Job in its termination message
+func ParseTerminationMessage(message string) (*Result, error) {
+	var result Result
+	if err := json.Unmarshal([]byte(message), &result); err != nil {
+		return nil, err
+	}
+	if len(result.Reason) == 0 {
+		return nil, fmt.Errorf("missing reason in the probe termination message")
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,55 @@
+# Copyright © contributors to CloudNativePG, established as
+# CloudNativePG a Series of LF Projects, LLC.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# SPDX-License-Identifier: Apache-2.0
+
+
+# Connectivity probe of an object store, run after the cloudscript prelude.
+#
+# Arguments: <write check>
+#
+# The destination path is listed and, when the write check is enabled, a
+# small object is uploaded and removed there.
+
+import io
+import uuid
+
+PROBE_DIRECTORY = ".barman-cloud-probe"
+
+
+def main(config, cloud_interface, write_check):
+    prefix = get_prefix(cloud_interface)
+    try:
+        next(iter(cloud_interface.list_bucket(prefix + "/" if prefix else "")), None)
+    except Exception as exc:
+        raise ScriptError(EXIT_LIST_FAILED, "cannot list %s: %s" % (config.source_url, exc))
+
+    if write_check != "true":
+        return None
+
+    key = get_prefix(cloud_interface, PROBE_DIRECTORY, uuid.uuid4().hex)
+    try:
+        cloud_interface.upload_fileobj(io.BytesIO(b"barman-cloud-probe"), key)
+    except Exception as exc:
+        raise ScriptError(EXIT_WRITE_FAILED, "cannot upload %s: %s" % (key, exc))
+    try:
+        cloud_interface.delete_objects([key])
+    except Exception as exc:
+        raise ScriptError(EXIT_WRITE_FAILED, "cannot delete %s: %s" % (key, exc))
+
+    return None
+
+
+run(main, 1)
@@ -0,0 +1,81 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package probe
+
+import (
+	"errors"
+	"os"
+	"path"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/cloudscript"
+)
+
+var _ = Describe("Probe", func() {
+	It("maps the exit codes of the script to the condition reasons", func() {
+		Expect(reasonFromExitCode(cloudscript.ExitConnectionFailed)).To(Equal(barmancloudv1.ReasonConnectionFailed))
+		Expect(reasonFromExitCode(cloudscript.ExitBucketNotFound)).To(Equal(barmancloudv1.ReasonBucketNotFound))
+		Expect(reasonFromExitCode(cloudscript.ExitListFailed)).To(Equal(barmancloudv1.ReasonListFailed))
+		Expect(reasonFromExitCode(cloudscript.ExitWriteFailed)).To(Equal(barmancloudv1.ReasonWriteFailed))
+		Expect(reasonFromExitCode(1)).To(Equal(barmancloudv1.ReasonProbeFailed))
+	})
+
+	It("reads the reason from the failure of the script", func() {
+		result := newResult(&cloudscript.Error{
+			ExitCode: cloudscript.ExitBucketNotFound,
+			Message:  "bucket my-bucket does not exist",
+		})
+		Expect(result.Reason).To(Equal(barmancloudv1.ReasonBucketNotFound))
+		Expect(result.Message).To(Equal("bucket my-bucket does not exist"))
+
+		result = newResult(errors.New("invalid credentials configuration"))
+		Expect(result.Reason).To(Equal(barmancloudv1.ReasonProbeFailed))
+		Expect(result.Message).To(Equal("invalid credentials configuration"))
+	})
+
+	It("writes and parses the termination message", func() {
+		fileName := path.Join(GinkgoT().TempDir(), "termination-log")
+		result := &Result{
+			Reason:  barmancloudv1.ReasonWriteFailed,
+			Message: strings.Repeat("x", maxMessageLength) + "access denied",
+		}
+		Expect(WriteTerminationMessage(fileName, result)).To(Succeed())
+
+		content, err := os.ReadFile(fileName) // #nosec G304
+		Expect(err).NotTo(HaveOccurred())
+
+		parsed, err := ParseTerminationMessage(string(content))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed.Reason).To(Equal(barmancloudv1.ReasonWriteFailed))
+		Expect(parsed.Message).To(HaveLen(maxMessageLength))
+		Expect(parsed.Message).To(HaveSuffix("access denied"))
+	})
+
+	It("rejects termination messages not written by the probe", func() {
+		_, err := ParseTerminationMessage("panic: runtime error")
+		Expect(err).To(HaveOccurred())
+		_, err = ParseTerminationMessage(`{"message":"no reason"}`)
+		Expect(err).To(HaveOccurred())
+	})
+})
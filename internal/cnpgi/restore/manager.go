@@ -44,6 +44,7 @@ func Start(ctx context.Context) error {
 				DisableFor: []client.Object{
 					&corev1.Secret{},
 					&barmancloudv1.ObjectStore{},
+					&barmancloudv1.ClusterObjectStore{},
 				},
 			},
 		},
@@ -56,7 +57,7 @@ func Start(ctx context.Context) error {
 	if err := mgr.Add(&CNPGI{
 		PluginPath:     viper.GetString("plugin-path"),
 		SpoolDirectory: viper.GetString("spool-directory"),
-		Client:         mgr.GetClient(),
+		Client:         common.NewFileCredentialsClient(mgr.GetClient(), viper.GetString("namespace")),
 		PGDataPath:     viper.GetString("pgdata"),
 		InstanceName:   viper.GetString("pod-name"),
 	}); err != nil {
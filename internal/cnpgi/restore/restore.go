@@ -28,7 +28,6 @@ import (
 	"path"
 	"time"
 
-	"github.com/cloudnative-pg/barman-cloud/pkg/api"
 	barmanArchiver "github.com/cloudnative-pg/barman-cloud/pkg/archiver"
 	barmanCatalog "github.com/cloudnative-pg/barman-cloud/pkg/catalog"
 	barmanCommand "github.com/cloudnative-pg/barman-cloud/pkg/command"
@@ -97,22 +96,31 @@ func (impl JobHookImpl) Restore(
 		return nil, err
 	}
 
-	var recoveryObjectStore barmancloudv1.ObjectStore
-	if err := impl.Client.Get(ctx, configuration.GetRecoveryBarmanObjectKey(), &recoveryObjectStore); err != nil {
+	recoveryObjectStore, err := common.GetObjectStore(ctx, impl.Client, configuration.GetRecoveryBarmanObjectKey())
+	if err != nil {
 		return nil, err
 	}
 
+	recoveryWALObjectStore := recoveryObjectStore
+	if configuration.GetRecoveryWALBarmanObjectKey() != configuration.GetRecoveryBarmanObjectKey() {
+		recoveryWALObjectStore, err = common.GetObjectStore(ctx, impl.Client, configuration.GetRecoveryWALBarmanObjectKey())
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if configuration.BarmanObjectName != "" {
-		var targetObjectStore barmancloudv1.ObjectStore
-		if err := impl.Client.Get(ctx, configuration.GetBarmanObjectKey(), &targetObjectStore); err != nil {
+		// The new cluster must not archive its WAL files over an
+		// existing WAL archive
+		targetObjectStore, err := common.GetObjectStore(ctx, impl.Client, configuration.GetWALBarmanObjectKey())
+		if err != nil {
 			return nil, err
 		}
 
 		if err := impl.checkBackupDestination(
 			ctx,
 			configuration.Cluster,
-			&targetObjectStore.Spec.Configuration,
-			targetObjectStore.Name,
+			targetObjectStore,
 			req.CheckEmptyWalArchive,
 		); err != nil {
 			return nil, err
@@ -124,19 +132,32 @@ func (impl JobHookImpl) Restore(
 		ctx,
 		impl.Client,
 		configuration.Cluster,
-		&recoveryObjectStore.Spec.Configuration,
-		recoveryObjectStore.Name,
+		recoveryObjectStore,
 		configuration.RecoveryServerName,
 	)
 	if err != nil {
 		return nil, err
 	}
 
+	walEnv := env
+	if recoveryWALObjectStore != recoveryObjectStore {
+		walEnv, err = barmanCredentials.EnvSetCloudCredentialsAndCertificates(
+			ctx,
+			impl.Client,
+			recoveryWALObjectStore.Namespace,
+			&recoveryWALObjectStore.Spec.Configuration,
+			os.Environ(),
+			common.BuildCertificateFilePath(recoveryWALObjectStore.Name))
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if err := impl.ensureArchiveContainsLastCheckpointRedoWAL(
 		ctx,
-		env,
+		walEnv,
 		backup,
-		&recoveryObjectStore.Spec.Configuration,
+		&recoveryWALObjectStore.Spec.Configuration,
 	); err != nil {
 		return nil, err
 	}
@@ -249,17 +270,18 @@ func (impl JobHookImpl) ensureArchiveContainsLastCheckpointRedoWAL(
 func (impl *JobHookImpl) checkBackupDestination(
 	ctx context.Context,
 	cluster *cnpgv1.Cluster,
-	barmanConfiguration *cnpgv1.BarmanObjectStoreConfiguration,
-	objectStoreName string,
+	objectStore *barmancloudv1.ObjectStore,
 	operatorCheckEmptyWalArchive *bool,
 ) error {
+	barmanConfiguration := &objectStore.Spec.Configuration
+
 	// Get environment from cache
 	env, err := barmanCredentials.EnvSetCloudCredentialsAndCertificates(ctx,
 		impl.Client,
-		cluster.Namespace,
+		objectStore.Namespace,
 		barmanConfiguration,
 		os.Environ(),
-		common.BuildCertificateFilePath(objectStoreName),
+		common.BuildCertificateFilePath(objectStore.Name),
 	)
 	if err != nil {
 		return fmt.Errorf("can't get credentials for cluster %v: %w", cluster.Name, err)
@@ -366,11 +388,11 @@ func loadBackupObjectFromExternalCluster(
 	ctx context.Context,
 	typedClient client.Client,
 	cluster *cnpgv1.Cluster,
-	recoveryObjectStore *api.BarmanObjectStoreConfiguration,
-	recoveryObjectStoreName string,
+	objectStore *barmancloudv1.ObjectStore,
 	serverName string,
 ) (*cnpgv1.Backup, []string, error) {
 	contextLogger := log.FromContext(ctx)
+	recoveryObjectStore := &objectStore.Spec.Configuration
 
 	contextLogger.Info("Recovering from external cluster",
 		"serverName", serverName,
@@ -379,10 +401,10 @@ func loadBackupObjectFromExternalCluster(
 	env, err := barmanCredentials.EnvSetCloudCredentialsAndCertificates(
 		ctx,
 		typedClient,
-		cluster.Namespace,
+		objectStore.Namespace,
 		recoveryObjectStore,
 		os.Environ(),
-		common.BuildCertificateFilePath(recoveryObjectStoreName))
+		common.BuildCertificateFilePath(objectStore.Name))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,45 @@
+# Copyright © contributors to CloudNativePG, established as
+# CloudNativePG a Series of LF Projects, LLC.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+#
+# SPDX-License-Identifier: Apache-2.0
+
+
+# Purge of the data archived by a server in an object store, run after the
+# cloudscript prelude.
+#
+# Every object under the directory of the server is deleted, and their
+# number is returned as deletedObjects.
+
+
+def main(config, cloud_interface):
+    prefix = get_prefix(cloud_interface, config.server_name)
+    try:
+        objects = list(cloud_interface.list_bucket(prefix + "/", delimiter=""))
+    except Exception as exc:
+        raise ScriptError(EXIT_LIST_FAILED, "cannot list %s: %s" % (prefix, exc))
+
+    if objects:
+        try:
+            cloud_interface.delete_objects(objects)
+        except Exception as exc:
+            raise ScriptError(
+                EXIT_DELETE_FAILED,
+                "cannot delete the objects under %s: %s" % (prefix, exc),
+            )
+
+    return {"deletedObjects": len(objects)}
+
+
+run(main, 0)
@@ -0,0 +1,115 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package serverpurge
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"github.com/spf13/viper"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/cloudscript"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/probe"
+)
+
+// script is the Python script doing the actual purge through the
+// barman cloud interface
+//
+//go:embed purge.py
+var script string
+
+const (
+	// ReasonPurged is the reason of a completed purge
+	ReasonPurged = "Purged"
+
+	// ReasonPurgeFailed is the reason of a failed purge
+	ReasonPurgeFailed = "PurgeFailed"
+)
+
+// Start purges the data of the server passed in the environment,
+// recording the outcome in the termination message, in the same
+// format used by the probe Job
+func Start(ctx context.Context) error {
+	contextLogger := log.FromContext(ctx)
+
+	var configuration barmanapi.BarmanObjectStoreConfiguration
+	if err := json.Unmarshal(
+		[]byte(viper.GetString("object-store-configuration")),
+		&configuration,
+	); err != nil {
+		return fmt.Errorf("while decoding the object store configuration: %w", err)
+	}
+
+	serverName := viper.GetString("server-name")
+	result, err := Run(ctx, &configuration, serverName)
+	if writeErr := probe.WriteTerminationMessage(probe.TerminationMessagePath, result); writeErr != nil {
+		contextLogger.Error(writeErr, "while writing the termination message")
+	}
+	if err != nil {
+		return err
+	}
+
+	contextLogger.Info("Server data purged",
+		"destinationPath", configuration.DestinationPath,
+		"serverName", serverName,
+		"message", result.Message)
+	return nil
+}
+
+// Run deletes every object stored under the directory of the passed
+// server, returning a Result describing the deleted data, or the
+// reason of the failure together with an error
+func Run(
+	ctx context.Context,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+	serverName string,
+) (*probe.Result, error) {
+	location := fmt.Sprintf("%s/%s", strings.TrimSuffix(configuration.DestinationPath, "/"), serverName)
+
+	var result cloudscript.DeletionResult
+	if err := cloudscript.Run(ctx, script, configuration, serverName, os.Environ(), &result); err != nil {
+		return newFailedResult(err.Error())
+	}
+
+	return newPurgedResult(location, result), nil
+}
+
+// newPurgedResult builds the Result of a completed purge from the
+// result of the purge script
+func newPurgedResult(location string, result cloudscript.DeletionResult) *probe.Result {
+	return &probe.Result{
+		Reason:  ReasonPurged,
+		Message: fmt.Sprintf("deleted %d objects under %s", result.DeletedObjects, location),
+	}
+}
+
+// newFailedResult builds the Result of a failed purge
+func newFailedResult(message string) (*probe.Result, error) {
+	return &probe.Result{
+		Reason:  ReasonPurgeFailed,
+		Message: message,
+	}, fmt.Errorf("%s: %s", ReasonPurgeFailed, message)
+}
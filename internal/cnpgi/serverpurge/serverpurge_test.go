@@ -0,0 +1,42 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package serverpurge
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/cloudscript"
+)
+
+var _ = Describe("Server purge", func() {
+	It("describes the deleted objects", func() {
+		result := newPurgedResult("s3://bucket/path/cluster-example", cloudscript.DeletionResult{DeletedObjects: 42})
+		Expect(result.Reason).To(Equal(ReasonPurged))
+		Expect(result.Message).To(Equal("deleted 42 objects under s3://bucket/path/cluster-example"))
+	})
+
+	It("reports the failure of the script", func() {
+		result, err := newFailedResult("cannot list cluster-example: access denied")
+		Expect(err).To(HaveOccurred())
+		Expect(result.Reason).To(Equal(ReasonPurgeFailed))
+		Expect(result.Message).To(Equal("cannot list cluster-example: access denied"))
+	})
+})
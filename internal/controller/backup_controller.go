@@ -0,0 +1,122 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/backups"
+)
+
+// BackupReconciler annotates the completed Backups taken by the
+// plugin. The reconciler hooks annotate them while they are running,
+// but the operator does not call them anymore once they are completed.
+// It also deletes the data of the Backups stored in an object store
+// having the Delete backup deletion policy, when they are deleted.
+type BackupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIReader reads the Pods of the backup deletion Jobs, which
+	// are not worth caching
+	APIReader client.Reader
+
+	// SidecarImage is the image used to run the backup deletion Jobs
+	SidecarImage string
+
+	// Recorder reports the events about the Backups
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups/status,verbs=patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile annotates the Backup with the data describing it, and
+// manages the deletion of its data from the object store
+func (r *BackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues(
+		"backupName", req.Name,
+		"namespace", req.Namespace,
+	)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var backup cnpgv1.Backup
+	if err := r.Get(ctx, req.NamespacedName, &backup); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !isCompletedPluginBackup(&backup) {
+		return ctrl.Result{}, nil
+	}
+
+	if !backup.DeletionTimestamp.IsZero() {
+		requeueAfter, err := r.reconcileBackupDeletion(ctx, &backup)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("while deleting the backup data: %w", err)
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
+	}
+
+	if err := backups.Annotate(ctx, r.Client, &backup); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while annotating backup: %w", err)
+	}
+
+	if err := r.reconcileDeletionFinalizer(ctx, &backup); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while reconciling the backup data deletion finalizer: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// isCompletedPluginBackup checks whether the passed object is a
+// completed Backup taken by this plugin
+func isCompletedPluginBackup(obj client.Object) bool {
+	backup, ok := obj.(*cnpgv1.Backup)
+	if !ok {
+		return false
+	}
+
+	return backups.IsPluginBackup(backup) && backup.Status.Phase == cnpgv1.BackupPhaseCompleted
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&cnpgv1.Backup{}, builder.WithPredicates(predicate.NewPredicateFuncs(isCompletedPluginBackup))).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
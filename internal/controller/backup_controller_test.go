@@ -0,0 +1,106 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("BackupReconciler", func() {
+	var (
+		ctx    context.Context
+		backup *cnpgv1.Backup
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		startedAt := metav1.NewTime(time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC))
+		backup = &cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backup",
+				Namespace: "default",
+			},
+			Spec: cnpgv1.BackupSpec{
+				Cluster: cnpgv1.LocalObjectReference{Name: "cluster"},
+				Method:  cnpgv1.BackupMethodPlugin,
+				PluginConfiguration: &cnpgv1.BackupPluginConfiguration{
+					Name: metadata.PluginName,
+				},
+			},
+			Status: cnpgv1.BackupStatus{
+				Phase:     cnpgv1.BackupPhaseCompleted,
+				BackupID:  "20260101T100000",
+				StartedAt: &startedAt,
+				StoppedAt: ptr.To(metav1.NewTime(startedAt.Add(time.Minute))),
+				PluginMetadata: map[string]string{
+					"objectStoreName": "store",
+					"destinationPath": "s3://bucket/path",
+					"serverName":      "cluster",
+				},
+			},
+		}
+	})
+
+	reconcileBackup := func() *cnpgv1.Backup {
+		scheme := newCatalogMaintenanceScheme()
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(backup).
+			Build()
+		r := &BackupReconciler{Client: fakeClient, Scheme: scheme}
+
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(backup)})
+		Expect(err).NotTo(HaveOccurred())
+
+		var updated cnpgv1.Backup
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(backup), &updated)).To(Succeed())
+		return &updated
+	}
+
+	It("should annotate the completed Backups taken by the plugin", func() {
+		updated := reconcileBackup()
+		Expect(updated.Annotations).To(HaveKeyWithValue(metadata.BackupObjectStoreAnnotationName, "store"))
+		Expect(updated.Annotations).To(HaveKeyWithValue(metadata.BackupLocationAnnotationName,
+			"s3://bucket/path/cluster/base/20260101T100000"))
+		Expect(updated.Annotations).To(HaveKeyWithValue(metadata.BackupDurationAnnotationName, "1m0s"))
+	})
+
+	It("should ignore the Backups taken by other plugins", func() {
+		backup.Spec.PluginConfiguration.Name = "other"
+		Expect(reconcileBackup().Annotations).To(BeEmpty())
+	})
+
+	It("should ignore the Backups not completed yet", func() {
+		backup.Status.Phase = cnpgv1.BackupPhaseStarted
+		Expect(reconcileBackup().Annotations).To(BeEmpty())
+	})
+})
@@ -0,0 +1,272 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/backupdeletion"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/catalog"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+const (
+	// backupDeletionCheckInterval is the time between two checks of a
+	// running backup deletion Job
+	backupDeletionCheckInterval = 30 * time.Second
+
+	// backupDeletionRetryInterval is the time to wait before retrying
+	// a failed backup deletion
+	backupDeletionRetryInterval = 5 * time.Minute
+)
+
+// reconcileDeletionFinalizer adds the data deletion finalizer to the
+// passed completed Backup when its object store has the Delete backup
+// deletion policy, and removes it when the policy changes
+func (r *BackupReconciler) reconcileDeletionFinalizer(
+	ctx context.Context,
+	backup *cnpgv1.Backup,
+) error {
+	objectStore, err := r.getBackupObjectStore(ctx, backup)
+	if err != nil {
+		return err
+	}
+
+	deleteData := objectStore != nil &&
+		objectStore.Spec.BackupDeletionPolicy == barmancloudv1.BackupDeletionPolicyDelete &&
+		len(backup.Status.BackupID) > 0 &&
+		len(r.SidecarImage) > 0
+	if deleteData == controllerutil.ContainsFinalizer(backup, metadata.BackupDataDeletionFinalizerName) {
+		return nil
+	}
+
+	original := backup.DeepCopy()
+	if deleteData {
+		controllerutil.AddFinalizer(backup, metadata.BackupDataDeletionFinalizerName)
+	} else {
+		controllerutil.RemoveFinalizer(backup, metadata.BackupDataDeletionFinalizerName)
+	}
+	return r.Patch(ctx, backup, client.MergeFrom(original))
+}
+
+// reconcileBackupDeletion removes the data of the passed Backup from
+// its object store through a dedicated Job, then releases the Backup
+// by removing the data deletion finalizer. A failed deletion, as well
+// as a deletion refused because the backup is the oldest one, is
+// reported in an event on the Backup and retried later. The Backup is
+// released keeping its data only when it is annotated to do so, or
+// when the namespace of the Job is being deleted.
+func (r *BackupReconciler) reconcileBackupDeletion(
+	ctx context.Context,
+	backup *cnpgv1.Backup,
+) (time.Duration, error) {
+	contextLogger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(backup, metadata.BackupDataDeletionFinalizerName) {
+		return 0, nil
+	}
+
+	objectStore, err := r.getBackupObjectStore(ctx, backup)
+	if err != nil {
+		return 0, err
+	}
+
+	if objectStore == nil ||
+		objectStore.Spec.BackupDeletionPolicy != barmancloudv1.BackupDeletionPolicyDelete ||
+		len(r.SidecarImage) == 0 {
+		contextLogger.Info("Backup data not deleted by the plugin anymore, removing the finalizer")
+		return 0, r.removeDeletionFinalizer(ctx, backup)
+	}
+
+	if backup.Annotations[metadata.BackupKeepDataAnnotationName] == "true" {
+		contextLogger.Info("Backup annotated to keep its data, removing the finalizer",
+			"annotation", metadata.BackupKeepDataAnnotationName)
+		if err := r.deleteBackupDeletionJob(ctx, &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: objectStore.Namespace,
+				Name:      specs.GetBackupDeletionJobName(backup.UID),
+			},
+		}); err != nil {
+			return 0, err
+		}
+		return 0, r.removeDeletionFinalizer(ctx, backup)
+	}
+
+	imagePullSecrets, err := common.GetImagePullSecrets(ctx, r.Client, objectStore)
+	if err != nil {
+		return 0, err
+	}
+
+	backupMetadata := catalog.NewBackupResultMetadataFromMap(backup.Status.PluginMetadata)
+	job, err := specs.BuildBackupDeletionJob(
+		objectStore,
+		backup.UID,
+		backupMetadata.GetServerName(),
+		backup.Status.BackupID,
+		r.SidecarImage,
+		imagePullSecrets,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var existingJob batchv1.Job
+	err = r.Get(ctx, client.ObjectKeyFromObject(job), &existingJob)
+	if apierrs.IsNotFound(err) {
+		contextLogger.Info("Starting the backup deletion job",
+			"jobName", job.Name,
+			"jobNamespace", job.Namespace)
+		err := r.Create(ctx, job)
+		switch {
+		case err == nil, apierrs.IsAlreadyExists(err):
+			return backupDeletionCheckInterval, nil
+
+		case apierrs.HasStatusCause(err, corev1.NamespaceTerminatingCause):
+			// The Job cannot run anymore, and waiting for it would
+			// block the deletion of the namespace
+			contextLogger.Info("Namespace of the backup deletion job terminating, "+
+				"keeping the data and removing the finalizer",
+				"jobNamespace", job.Namespace)
+			r.Recorder.Event(backup, corev1.EventTypeWarning, "BackupDataKept",
+				fmt.Sprintf("The namespace %s is being deleted, the data has been kept in the object store",
+					job.Namespace))
+			return 0, r.removeDeletionFinalizer(ctx, backup)
+
+		case apierrs.IsForbidden(err):
+			contextLogger.Info("Backup deletion job refused, retrying later", "error", err.Error())
+			r.Recorder.Event(backup, corev1.EventTypeWarning, backupdeletion.ReasonBackupDeletionFailed,
+				fmt.Sprintf("The backup deletion job has been refused: %v", err))
+			return backupDeletionRetryInterval, nil
+
+		default:
+			return 0, fmt.Errorf("while creating the backup deletion job: %w", err)
+		}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("while getting the backup deletion job: %w", err)
+	}
+
+	finishedCondition := getJobFinishedCondition(&existingJob)
+	if finishedCondition == nil {
+		return backupDeletionCheckInterval, nil
+	}
+
+	if finishedCondition.Type == batchv1.JobComplete {
+		contextLogger.Info("Backup data deleted from the object store, removing the finalizer")
+		if err := r.deleteBackupDeletionJob(ctx, &existingJob); err != nil {
+			return 0, err
+		}
+		return 0, r.removeDeletionFinalizer(ctx, backup)
+	}
+
+	reason, message := backupdeletion.ReasonBackupDeletionFailed, finishedCondition.Message
+	result, err := getJobTerminationResult(ctx, r.APIReader, &existingJob)
+	if err != nil {
+		return 0, fmt.Errorf("while reading the backup deletion job outcome: %w", err)
+	}
+	if result != nil {
+		reason, message = result.Reason, result.Message
+	}
+
+	if reason == backupdeletion.ReasonOldestBackup {
+		// Releasing the Backup would leave its data in the object store
+		// without any Backup object tracking it: this requires an
+		// explicit choice of the user. The deletion is retried anyway,
+		// since the retention policy may remove the backup meanwhile.
+		message = fmt.Sprintf("%s. To delete the Backup keeping its data, annotate it with %s=true",
+			message, metadata.BackupKeepDataAnnotationName)
+	}
+
+	contextLogger.Info("Backup data deletion failed, retrying later",
+		"reason", reason,
+		"message", message)
+	r.Recorder.Event(backup, corev1.EventTypeWarning, reason, message)
+
+	return backupDeletionRetryInterval, r.deleteBackupDeletionJob(ctx, &existingJob)
+}
+
+// getBackupObjectStore gets the object store where the passed Backup
+// has been stored, looking for an ObjectStore in the namespace of the
+// Backup first, and for a ClusterObjectStore then. It returns nil when
+// the object store does not exist anymore, or its destination changed.
+func (r *BackupReconciler) getBackupObjectStore(
+	ctx context.Context,
+	backup *cnpgv1.Backup,
+) (*barmancloudv1.ObjectStore, error) {
+	backupMetadata := catalog.NewBackupResultMetadataFromMap(backup.Status.PluginMetadata)
+	objectStoreName := backupMetadata.GetObjectStoreName()
+	if len(objectStoreName) == 0 || len(backupMetadata.GetServerName()) == 0 {
+		return nil, nil
+	}
+
+	for _, key := range []client.ObjectKey{
+		{Namespace: backup.Namespace, Name: objectStoreName},
+		{Name: objectStoreName},
+	} {
+		objectStore, err := common.GetObjectStore(ctx, r.Client, key)
+		if apierrs.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("while getting the object store of the backup: %w", err)
+		}
+
+		if backupMetadata.IsLocatedIn(objectStore) {
+			return objectStore, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// removeDeletionFinalizer removes the data deletion finalizer from the
+// passed Backup
+func (r *BackupReconciler) removeDeletionFinalizer(ctx context.Context, backup *cnpgv1.Backup) error {
+	original := backup.DeepCopy()
+	controllerutil.RemoveFinalizer(backup, metadata.BackupDataDeletionFinalizerName)
+	return r.Patch(ctx, backup, client.MergeFrom(original))
+}
+
+// deleteBackupDeletionJob removes the passed finished backup deletion Job
+func (r *BackupReconciler) deleteBackupDeletionJob(ctx context.Context, job *batchv1.Job) error {
+	if err := r.Delete(
+		ctx,
+		job,
+		client.PropagationPolicy(metav1.DeletePropagationBackground),
+	); err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("while deleting the backup deletion job: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,340 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/backupdeletion"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+var _ = Describe("Backup data deletion", func() {
+	var (
+		ctx         context.Context
+		backup      *cnpgv1.Backup
+		objectStore *barmancloudv1.ObjectStore
+		recorder    *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		backup = &cnpgv1.Backup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "backup",
+				Namespace: "default",
+				UID:       "backup-uid",
+			},
+			Spec: cnpgv1.BackupSpec{
+				Cluster: cnpgv1.LocalObjectReference{Name: "cluster"},
+				Method:  cnpgv1.BackupMethodPlugin,
+				PluginConfiguration: &cnpgv1.BackupPluginConfiguration{
+					Name: metadata.PluginName,
+				},
+			},
+			Status: cnpgv1.BackupStatus{
+				Phase:    cnpgv1.BackupPhaseCompleted,
+				BackupID: "20260101T100000",
+				PluginMetadata: map[string]string{
+					"objectStoreName": "store",
+					"destinationPath": "s3://bucket/path",
+					"serverName":      "cluster",
+				},
+			},
+		}
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+				},
+				BackupDeletionPolicy: barmancloudv1.BackupDeletionPolicyDelete,
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *BackupReconciler {
+		scheme := newCatalogMaintenanceScheme()
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			Build()
+		return &BackupReconciler{
+			Client:       fakeClient,
+			Scheme:       scheme,
+			APIReader:    fakeClient,
+			SidecarImage: "sidecar:latest",
+			Recorder:     recorder,
+		}
+	}
+
+	reconcileBackup := func(r *BackupReconciler) time.Duration {
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(backup)})
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	getBackup := func(r *BackupReconciler) (*cnpgv1.Backup, error) {
+		var updated cnpgv1.Backup
+		err := r.Get(ctx, client.ObjectKeyFromObject(backup), &updated)
+		return &updated, err
+	}
+
+	deletingBackup := func() *cnpgv1.Backup {
+		result := backup.DeepCopy()
+		result.Finalizers = []string{metadata.BackupDataDeletionFinalizerName}
+		result.DeletionTimestamp = ptr.To(metav1.Now())
+		return result
+	}
+
+	newFinishedJob := func(namespace string, conditionType batchv1.JobConditionType) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      specs.GetBackupDeletionJobName(backup.UID),
+				Namespace: namespace,
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{
+						Type:   conditionType,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		}
+	}
+
+	It("protects the Backups stored with the Delete policy", func() {
+		r := newReconciler(backup, objectStore)
+		reconcileBackup(r)
+
+		updated, err := getBackup(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(ContainElement(metadata.BackupDataDeletionFinalizerName))
+	})
+
+	It("releases the Backups when the policy is not Delete anymore", func() {
+		backup.Finalizers = []string{metadata.BackupDataDeletionFinalizerName}
+		objectStore.Spec.BackupDeletionPolicy = barmancloudv1.BackupDeletionPolicyRetain
+		r := newReconciler(backup, objectStore)
+		reconcileBackup(r)
+
+		updated, err := getBackup(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(BeEmpty())
+	})
+
+	It("does not protect the Backups stored in a different destination", func() {
+		objectStore.Spec.Configuration.DestinationPath = "s3://other-bucket/path"
+		r := newReconciler(backup, objectStore)
+		reconcileBackup(r)
+
+		updated, err := getBackup(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(BeEmpty())
+	})
+
+	It("starts the deletion job when the Backup is deleted", func() {
+		r := newReconciler(deletingBackup(), objectStore)
+		Expect(reconcileBackup(r)).To(Equal(backupDeletionCheckInterval))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKey{
+			Namespace: "default",
+			Name:      specs.GetBackupDeletionJobName(backup.UID),
+		}, &job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElements(
+			corev1.EnvVar{Name: "SERVER_NAME", Value: "cluster"},
+			corev1.EnvVar{Name: "BACKUP_ID", Value: "20260101T100000"},
+		))
+
+		updated, err := getBackup(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(ContainElement(metadata.BackupDataDeletionFinalizerName))
+	})
+
+	It("runs the deletion job in the credentials namespace of a ClusterObjectStore", func() {
+		clusterObjectStore := &barmancloudv1.ClusterObjectStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "store"},
+			Spec: barmancloudv1.ClusterObjectStoreSpec{
+				ObjectStoreSpec:      objectStore.Spec,
+				CredentialsNamespace: "backup-credentials",
+				AllowedNamespaces:    []string{"default"},
+			},
+		}
+		r := newReconciler(deletingBackup(), clusterObjectStore)
+		reconcileBackup(r)
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, client.ObjectKey{
+			Namespace: "backup-credentials",
+			Name:      specs.GetBackupDeletionJobName(backup.UID),
+		}, &job)).To(Succeed())
+	})
+
+	It("releases the Backup once its data has been deleted", func() {
+		job := newFinishedJob("default", batchv1.JobComplete)
+		r := newReconciler(deletingBackup(), objectStore, job)
+		Expect(reconcileBackup(r)).To(BeZero())
+
+		_, err := getBackup(r)
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		err = r.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reports a failed deletion and retries it later", func() {
+		job := newFinishedJob("default", batchv1.JobFailed)
+		r := newReconciler(deletingBackup(), objectStore, job)
+		Expect(reconcileBackup(r)).To(Equal(backupDeletionRetryInterval))
+
+		Expect(recorder.Events).To(Receive(ContainSubstring(backupdeletion.ReasonBackupDeletionFailed)))
+		updated, err := getBackup(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(ContainElement(metadata.BackupDataDeletionFinalizerName))
+		err = r.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps the oldest Backup, explaining how to release it", func() {
+		job := newFinishedJob("default", batchv1.JobFailed)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job.Name + "-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "plugin-barman-cloud",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: 1,
+								Message:  `{"reason":"OldestBackup","message":"backup 20260101T100000 is the oldest one"}`,
+							},
+						},
+					},
+				},
+			},
+		}
+		r := newReconciler(deletingBackup(), objectStore, job, pod)
+		Expect(reconcileBackup(r)).To(Equal(backupDeletionRetryInterval))
+
+		Expect(recorder.Events).To(Receive(And(
+			ContainSubstring(backupdeletion.ReasonOldestBackup),
+			ContainSubstring(metadata.BackupKeepDataAnnotationName+"=true"),
+		)))
+		updated, err := getBackup(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(ContainElement(metadata.BackupDataDeletionFinalizerName))
+		err = r.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("releases the Backup annotated to keep its data", func() {
+		deleting := deletingBackup()
+		deleting.Annotations = map[string]string{metadata.BackupKeepDataAnnotationName: "true"}
+		job := newFinishedJob("default", batchv1.JobFailed)
+		r := newReconciler(deleting, objectStore, job)
+		Expect(reconcileBackup(r)).To(BeZero())
+
+		_, err := getBackup(r)
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		err = r.Get(ctx, client.ObjectKeyFromObject(job), &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	Context("when the deletion job cannot be created", func() {
+		newRefusingReconciler := func(createErr error) *BackupReconciler {
+			r := newReconciler(deletingBackup(), objectStore)
+			r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+				Create: func(
+					ctx context.Context,
+					c client.WithWatch,
+					obj client.Object,
+					opts ...client.CreateOption,
+				) error {
+					if _, ok := obj.(*batchv1.Job); ok {
+						return createErr
+					}
+					return c.Create(ctx, obj, opts...)
+				},
+			})
+			return r
+		}
+
+		It("releases the Backup keeping its data when the namespace is terminating", func() {
+			createErr := apierrs.NewForbidden(batchv1.Resource("jobs"), "job", errors.New("namespace terminating"))
+			createErr.ErrStatus.Details.Causes = []metav1.StatusCause{
+				{Type: corev1.NamespaceTerminatingCause},
+			}
+			r := newRefusingReconciler(createErr)
+			Expect(reconcileBackup(r)).To(BeZero())
+
+			Expect(recorder.Events).To(Receive(ContainSubstring("BackupDataKept")))
+			_, err := getBackup(r)
+			Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("reports a forbidden job and retries it later", func() {
+			r := newRefusingReconciler(
+				apierrs.NewForbidden(batchv1.Resource("jobs"), "job", errors.New("exceeded quota")),
+			)
+			Expect(reconcileBackup(r)).To(Equal(backupDeletionRetryInterval))
+
+			Expect(recorder.Events).To(Receive(ContainSubstring("exceeded quota")))
+			updated, err := getBackup(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updated.Finalizers).To(ContainElement(metadata.BackupDataDeletionFinalizerName))
+		})
+	})
+
+	It("releases the Backup when its object store does not exist anymore", func() {
+		r := newReconciler(deletingBackup())
+		Expect(reconcileBackup(r)).To(BeZero())
+
+		_, err := getBackup(r)
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+})
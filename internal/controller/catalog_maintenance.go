@@ -0,0 +1,293 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/catalog"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// finishedJobRequeueInterval is the time to wait before checking
+// again after a finished catalog maintenance Job has been deleted
+const finishedJobRequeueInterval = 10 * time.Second
+
+// reconcileCatalogMaintenance starts a catalog maintenance Job for
+// the passed Cluster when it archives into the passed ObjectStore or
+// ClusterObjectStore, its primary instance is not running and the
+// maintenance is due. It returns the time after which the maintenance
+// should be checked again, or zero if this object store is not in
+// charge of it.
+func reconcileCatalogMaintenance(
+	ctx context.Context,
+	c client.Client,
+	apiReader client.Reader,
+	sidecarImage string,
+	objectStoreKey client.ObjectKey,
+	clusterKey client.ObjectKey,
+) (time.Duration, error) {
+	contextLogger := log.FromContext(ctx).WithValues("clusterName", clusterKey.Name)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	if len(sidecarImage) == 0 || len(clusterKey.Name) == 0 {
+		return 0, nil
+	}
+
+	var cluster cnpgv1.Cluster
+	if err := c.Get(ctx, clusterKey, &cluster); err != nil {
+		if apierrs.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("while getting cluster: %w", err)
+	}
+
+	enabledPlugins := cnpgv1.GetPluginConfigurationEnabledPluginNames(cluster.Spec.Plugins)
+	if !slices.Contains(enabledPlugins, metadata.PluginName) {
+		return 0, nil
+	}
+
+	configuration := config.NewFromCluster(&cluster)
+	if len(configuration.BarmanObjectName) == 0 || configuration.GetBarmanObjectKey() != objectStoreKey {
+		return 0, nil
+	}
+
+	objectStore, err := common.GetObjectStore(ctx, c, objectStoreKey)
+	if err != nil {
+		if apierrs.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("while getting the object store: %w", err)
+	}
+	if len(objectStoreKey.Namespace) == 0 {
+		// The Job runs in the namespace of the Cluster
+		specs.UseEndpointCASecretCopy(objectStore, cluster.Name)
+	}
+
+	interval := time.Second * time.Duration(
+		objectStore.Spec.InstanceSidecarConfiguration.RetentionPolicyIntervalSeconds)
+
+	primaryUnavailable, err := isPrimaryUnavailable(ctx, apiReader, &cluster)
+	if err != nil {
+		return 0, err
+	}
+	if !primaryUnavailable {
+		return interval, nil
+	}
+
+	var lease coordinationv1.Lease
+	if err := c.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      specs.GetCatalogMaintenanceName(cluster.Name),
+	}, &lease); err != nil {
+		if apierrs.IsNotFound(err) {
+			// The lease is created by the Pre hook
+			return interval, nil
+		}
+		return 0, fmt.Errorf("while getting the catalog maintenance lease: %w", err)
+	}
+
+	now := time.Now()
+	if catalog.IsLeaseHeld(&lease, now) {
+		contextLogger.Debug("Skipping catalog maintenance, lease is held",
+			"holder", *lease.Spec.HolderIdentity)
+		return interval, nil
+	}
+	if lease.Spec.RenewTime != nil {
+		if nextRun := lease.Spec.RenewTime.Add(interval); now.Before(nextRun) {
+			return nextRun.Sub(now), nil
+		}
+	}
+
+	gvk, err := apiutil.GVKForObject(&cluster, c.Scheme())
+	if err != nil {
+		return 0, err
+	}
+	cluster.SetGroupVersionKind(gvk)
+
+	return ensureCatalogMaintenanceJob(ctx, c, &cluster, objectStore, sidecarImage, interval)
+}
+
+// ensureCatalogMaintenanceJob creates the catalog maintenance Job,
+// removing the one left from the previous run if needed
+func ensureCatalogMaintenanceJob(
+	ctx context.Context,
+	c client.Client,
+	cluster *cnpgv1.Cluster,
+	objectStore *barmancloudv1.ObjectStore,
+	sidecarImage string,
+	interval time.Duration,
+) (time.Duration, error) {
+	contextLogger := log.FromContext(ctx)
+
+	job := specs.BuildCatalogMaintenanceJob(cluster, objectStore, sidecarImage)
+
+	var existingJob batchv1.Job
+	err := c.Get(ctx, client.ObjectKeyFromObject(job), &existingJob)
+	switch {
+	case err == nil && !isJobFinished(&existingJob):
+		return interval, nil
+
+	case err == nil:
+		contextLogger.Info("Deleting finished catalog maintenance job",
+			"jobName", existingJob.Name)
+		if err := c.Delete(
+			ctx,
+			&existingJob,
+			client.PropagationPolicy(metav1.DeletePropagationBackground),
+		); err != nil && !apierrs.IsNotFound(err) {
+			return 0, fmt.Errorf("while deleting finished catalog maintenance job: %w", err)
+		}
+		return finishedJobRequeueInterval, nil
+
+	case !apierrs.IsNotFound(err):
+		return 0, fmt.Errorf("while getting catalog maintenance job: %w", err)
+	}
+
+	if err := specs.SetControllerReference(cluster, job); err != nil {
+		return 0, err
+	}
+
+	contextLogger.Info("Starting catalog maintenance job", "jobName", job.Name)
+	if err := c.Create(ctx, job); err != nil && !apierrs.IsAlreadyExists(err) {
+		return 0, fmt.Errorf("while creating catalog maintenance job: %w", err)
+	}
+
+	return interval, nil
+}
+
+// mapCatalogMaintenanceJobToObjectStores enqueues the ObjectStores
+// used by the Cluster of a catalog maintenance Job. The Job is owned
+// by the Cluster, so that it is not noticed by the ObjectStore
+// controller through its owner reference.
+func (r *ObjectStoreReconciler) mapCatalogMaintenanceJobToObjectStores(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return nil
+	}
+
+	clusterName := job.Labels[metadata.ClusterLabelName]
+	if len(clusterName) == 0 || job.Name != specs.GetCatalogMaintenanceName(clusterName) {
+		return nil
+	}
+
+	var role rbacv1.Role
+	if err := r.Get(ctx, client.ObjectKey{
+		Namespace: job.Namespace,
+		Name:      specs.GetRBACName(clusterName),
+	}, &role); err != nil {
+		if !apierrs.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to get the role of the cluster",
+				"clusterName", clusterName, "namespace", job.Namespace)
+		}
+		return nil
+	}
+
+	return mapRoleToObjectStores(ctx, &role)
+}
+
+// jobFinishedPredicate accepts the updates of the Jobs that just
+// completed or failed
+var jobFinishedPredicate = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldJob, oldOk := e.ObjectOld.(*batchv1.Job)
+		newJob, newOk := e.ObjectNew.(*batchv1.Job)
+		if !oldOk || !newOk {
+			return false
+		}
+		return !isJobFinished(oldJob) && isJobFinished(newJob)
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// isPrimaryUnavailable checks whether the primary instance of the
+// passed Cluster, which runs the catalog maintenance, is not running,
+// either because the Cluster is hibernated or because its Pod is
+// missing or not ready. The Pod is read through the passed reader, to
+// avoid caching all of them.
+func isPrimaryUnavailable(ctx context.Context, apiReader client.Reader, cluster *cnpgv1.Cluster) (bool, error) {
+	if cluster.Annotations[utils.HibernationAnnotationName] == string(utils.HibernationAnnotationValueOn) {
+		return true, nil
+	}
+
+	// A cluster that never had a primary has nothing to maintain yet
+	if len(cluster.Status.CurrentPrimary) == 0 {
+		return false, nil
+	}
+
+	var primary corev1.Pod
+	if err := apiReader.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      cluster.Status.CurrentPrimary,
+	}, &primary); err != nil {
+		if apierrs.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("while getting the primary pod: %w", err)
+	}
+
+	return !utils.IsPodReady(primary), nil
+}
+
+// isJobFinished checks whether the passed Job completed or failed
+func isJobFinished(job *batchv1.Job) bool {
+	return getJobFinishedCondition(job) != nil
+}
+
+// getJobFinishedCondition returns the condition reporting that the
+// passed Job completed or failed, or nil if it is still running
+func getJobFinishedCondition(job *batchv1.Job) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		condition := &job.Status.Conditions[i]
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) &&
+			condition.Status == corev1.ConditionTrue {
+			return condition
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,397 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+func newCatalogMaintenanceScheme() *runtime.Scheme {
+	s := newFakeScheme()
+	utilruntime.Must(cnpgv1.AddToScheme(s))
+	utilruntime.Must(coordinationv1.AddToScheme(s))
+	utilruntime.Must(batchv1.AddToScheme(s))
+	return s
+}
+
+func newInstancePod(name, namespace string, ready bool) *corev1.Pod {
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Status: corev1.PodStatus{
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: readyStatus},
+			},
+		},
+	}
+}
+
+func newStoppedCluster(name, namespace, objectStoreName string) *cnpgv1.Cluster {
+	return &cnpgv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			UID:       "cluster-uid",
+			Annotations: map[string]string{
+				utils.HibernationAnnotationName: string(utils.HibernationAnnotationValueOn),
+			},
+		},
+		Spec: cnpgv1.ClusterSpec{
+			Plugins: []cnpgv1.PluginConfiguration{
+				{
+					Name:          metadata.PluginName,
+					IsWALArchiver: ptr.To(true),
+					Parameters: map[string]string{
+						"barmanObjectName": objectStoreName,
+					},
+				},
+			},
+		},
+		Status: cnpgv1.ClusterStatus{
+			CurrentPrimary: name + "-1",
+		},
+	}
+}
+
+func newCatalogMaintenanceLease(name, namespace string) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name + "-barman-cloud-maintenance",
+			Namespace: namespace,
+		},
+	}
+}
+
+var _ = Describe("Catalog maintenance", func() {
+	var (
+		ctx            context.Context
+		scheme         *runtime.Scheme
+		objectStore    *barmancloudv1.ObjectStore
+		objectStoreKey client.ObjectKey
+		clusterKey     client.ObjectKey
+		jobKey         client.ObjectKey
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme = newCatalogMaintenanceScheme()
+		objectStore = newTestObjectStore("my-store", "default", "aws-creds")
+		objectStore.Spec.InstanceSidecarConfiguration.RetentionPolicyIntervalSeconds = 1800
+		objectStoreKey = client.ObjectKeyFromObject(objectStore)
+		clusterKey = client.ObjectKey{Namespace: "default", Name: "my-cluster"}
+		jobKey = client.ObjectKey{Namespace: "default", Name: "my-cluster-barman-cloud-maintenance"}
+	})
+
+	newReconciler := func(objs ...client.Object) *ObjectStoreReconciler {
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			Build()
+		return &ObjectStoreReconciler{
+			Client:       fakeClient,
+			APIReader:    fakeClient,
+			Scheme:       scheme,
+			SidecarImage: "sidecar:latest",
+		}
+	}
+
+	reconcileMaintenance := func(r *ObjectStoreReconciler, objectStoreKey client.ObjectKey) (time.Duration, error) {
+		return reconcileCatalogMaintenance(ctx, r.Client, r.APIReader, r.SidecarImage, objectStoreKey, clusterKey)
+	}
+
+	It("starts a job for a hibernated cluster", func() {
+		r := newReconciler(
+			objectStore,
+			newStoppedCluster("my-cluster", "default", "my-store"),
+			newCatalogMaintenanceLease("my-cluster", "default"),
+		)
+
+		requeueAfter, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(30 * time.Minute))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, jobKey, &job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("sidecar:latest"))
+		Expect(job.OwnerReferences).To(ConsistOf(HaveField("Name", "my-cluster")))
+	})
+
+	It("does not start a job when the primary is running", func() {
+		cluster := newStoppedCluster("my-cluster", "default", "my-store")
+		cluster.Annotations = nil
+		r := newReconciler(
+			objectStore,
+			cluster,
+			newCatalogMaintenanceLease("my-cluster", "default"),
+			newInstancePod("my-cluster-1", "default", true),
+		)
+
+		requeueAfter, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(30 * time.Minute))
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{}))).To(BeTrue())
+	})
+
+	It("starts a job when the primary is down, even if the replicas are ready", func() {
+		cluster := newStoppedCluster("my-cluster", "default", "my-store")
+		cluster.Annotations = nil
+		cluster.Status.ReadyInstances = 2
+		r := newReconciler(
+			objectStore,
+			cluster,
+			newCatalogMaintenanceLease("my-cluster", "default"),
+			newInstancePod("my-cluster-1", "default", false),
+			newInstancePod("my-cluster-2", "default", true),
+		)
+
+		_, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Get(ctx, jobKey, &batchv1.Job{})).To(Succeed())
+	})
+
+	It("starts a job for a cluster archiving into a ClusterObjectStore", func() {
+		clusterObjectStore := &barmancloudv1.ClusterObjectStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-store"},
+			Spec: barmancloudv1.ClusterObjectStoreSpec{
+				ObjectStoreSpec:      objectStore.Spec,
+				CredentialsNamespace: "backup-credentials",
+			},
+		}
+		clusterObjectStore.Spec.Configuration.EndpointCA = &machineryapi.SecretKeySelector{
+			LocalObjectReference: machineryapi.LocalObjectReference{Name: "shared-ca"},
+			Key:                  "ca.crt",
+		}
+		cluster := newStoppedCluster("my-cluster", "default", "shared-store")
+		cluster.Spec.Plugins[0].Parameters["barmanObjectKind"] = "ClusterObjectStore"
+		r := newReconciler(clusterObjectStore, cluster, newCatalogMaintenanceLease("my-cluster", "default"))
+
+		requeueAfter, err := reconcileMaintenance(r, client.ObjectKey{Name: "shared-store"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(30 * time.Minute))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, jobKey, &job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Volumes).To(ContainElement(HaveField(
+			"VolumeSource.Projected.Sources",
+			ContainElement(HaveField("Secret.LocalObjectReference.Name", "my-cluster-barman-cloud-shared-store-ca")),
+		)))
+
+		_, err = reconcileMaintenance(r, client.ObjectKey{Namespace: "default", Name: "shared-store"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("does not start a job when the lease is held", func() {
+		lease := newCatalogMaintenanceLease("my-cluster", "default")
+		lease.Spec.HolderIdentity = ptr.To("my-cluster-1")
+		lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now()}
+		lease.Spec.LeaseDurationSeconds = ptr.To(int32(3600))
+		r := newReconciler(objectStore, newStoppedCluster("my-cluster", "default", "my-store"), lease)
+
+		_, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{}))).To(BeTrue())
+	})
+
+	It("waits for the maintenance interval since the last run", func() {
+		lease := newCatalogMaintenanceLease("my-cluster", "default")
+		lease.Spec.RenewTime = &metav1.MicroTime{Time: time.Now().Add(-10 * time.Minute)}
+		r := newReconciler(objectStore, newStoppedCluster("my-cluster", "default", "my-store"), lease)
+
+		requeueAfter, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeNumerically("~", 20*time.Minute, time.Minute))
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{}))).To(BeTrue())
+	})
+
+	It("ignores clusters not archiving into the ObjectStore", func() {
+		r := newReconciler(
+			objectStore,
+			newStoppedCluster("my-cluster", "default", "other-store"),
+			newCatalogMaintenanceLease("my-cluster", "default"),
+		)
+
+		requeueAfter, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeZero())
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{}))).To(BeTrue())
+	})
+
+	It("deletes a finished job before starting a new one", func() {
+		finishedJob := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: jobKey.Name, Namespace: jobKey.Namespace},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+				},
+			},
+		}
+		r := newReconciler(
+			objectStore,
+			newStoppedCluster("my-cluster", "default", "my-store"),
+			newCatalogMaintenanceLease("my-cluster", "default"),
+			finishedJob,
+		)
+
+		requeueAfter, err := reconcileMaintenance(r, objectStoreKey)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(finishedJobRequeueInterval))
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &batchv1.Job{}))).To(BeTrue())
+	})
+})
+
+var _ = Describe("mapCatalogMaintenanceJobToObjectStores", func() {
+	var (
+		ctx context.Context
+		r   *ObjectStoreReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme := newCatalogMaintenanceScheme()
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{
+			*newTestObjectStore("my-store", "default", "aws-creds"),
+		})
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(role).Build()
+		r = &ObjectStoreReconciler{Client: fakeClient, Scheme: scheme}
+	})
+
+	newJob := func(name, clusterName string) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    map[string]string{metadata.ClusterLabelName: clusterName},
+			},
+		}
+	}
+
+	It("enqueues the ObjectStores used by the cluster of the job", func() {
+		requests := r.mapCatalogMaintenanceJobToObjectStores(ctx,
+			newJob("my-cluster-barman-cloud-maintenance", "my-cluster"))
+		Expect(requests).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKey{Namespace: "default", Name: "my-store"},
+		}))
+	})
+
+	It("enqueues the ClusterObjectStores used by the cluster of the job", func() {
+		cluster := newStoppedCluster("my-cluster", "default", "shared-store")
+		cluster.Spec.Plugins[0].Parameters["barmanObjectKind"] = "ClusterObjectStore"
+		scheme := newCatalogMaintenanceScheme()
+		clusterObjectStoreReconciler := &ClusterObjectStoreReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster).Build(),
+			Scheme: scheme,
+		}
+
+		requests := clusterObjectStoreReconciler.mapCatalogMaintenanceJobToClusterObjectStores(ctx,
+			newJob("my-cluster-barman-cloud-maintenance", "my-cluster"))
+		Expect(requests).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKey{Name: "shared-store"},
+		}))
+	})
+
+	It("ignores the other jobs of the cluster", func() {
+		Expect(r.mapCatalogMaintenanceJobToObjectStores(ctx,
+			newJob("my-cluster-other", "my-cluster"))).To(BeEmpty())
+	})
+
+	It("only accepts the jobs that just finished", func() {
+		oldJob := newJob("my-cluster-barman-cloud-maintenance", "my-cluster")
+		finishedJob := oldJob.DeepCopy()
+		Expect(jobFinishedPredicate.Update(event.UpdateEvent{ObjectOld: oldJob, ObjectNew: finishedJob})).To(BeFalse())
+
+		finishedJob.Status.Conditions = []batchv1.JobCondition{
+			{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+		}
+		Expect(jobFinishedPredicate.Update(event.UpdateEvent{ObjectOld: oldJob, ObjectNew: finishedJob})).To(BeTrue())
+		Expect(jobFinishedPredicate.Update(event.UpdateEvent{
+			ObjectOld: finishedJob,
+			ObjectNew: finishedJob,
+		})).To(BeFalse())
+	})
+})
+
+var _ = Describe("isPrimaryUnavailable", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	newReader := func(objs ...client.Object) client.Reader {
+		return fake.NewClientBuilder().
+			WithScheme(newCatalogMaintenanceScheme()).
+			WithObjects(objs...).
+			Build()
+	}
+
+	isUnavailable := func(cluster *cnpgv1.Cluster, objs ...client.Object) bool {
+		unavailable, err := isPrimaryUnavailable(ctx, newReader(objs...), cluster)
+		Expect(err).NotTo(HaveOccurred())
+		return unavailable
+	}
+
+	It("detects hibernated clusters", func() {
+		cluster := newStoppedCluster("my-cluster", "default", "my-store")
+		Expect(isUnavailable(cluster, newInstancePod("my-cluster-1", "default", true))).To(BeTrue())
+	})
+
+	It("detects a missing or not ready primary", func() {
+		cluster := newStoppedCluster("my-cluster", "default", "my-store")
+		cluster.Annotations = nil
+		Expect(isUnavailable(cluster)).To(BeTrue())
+		Expect(isUnavailable(cluster, newInstancePod("my-cluster-1", "default", false))).To(BeTrue())
+		Expect(isUnavailable(cluster, newInstancePod("my-cluster-1", "default", true))).To(BeFalse())
+	})
+
+	It("ignores clusters that were never initialized", func() {
+		cluster := newStoppedCluster("my-cluster", "default", "my-store")
+		cluster.Annotations = nil
+		cluster.Status.CurrentPrimary = ""
+		Expect(isUnavailable(cluster)).To(BeFalse())
+	})
+})
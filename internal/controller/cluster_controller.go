@@ -0,0 +1,186 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+)
+
+// ClusterReconciler protects the Clusters having the Delete purge
+// policy with a finalizer. When one of them is deleted, it records
+// the purge of its data in the status of the object stores it
+// archives into, then releases it. The purge itself is run by the
+// ServerPurgeReconciler.
+type ClusterReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get;list;watch;patch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters/finalizers,verbs=update
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=objectstores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=clusterobjectstores/status,verbs=get;update;patch
+
+// Reconcile manages the server purge finalizer of the Cluster
+func (r *ClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues(
+		"clusterName", req.Name,
+		"namespace", req.Namespace,
+	)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Get(ctx, req.NamespacedName, &cluster); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	configuration := config.NewFromCluster(&cluster)
+	purgeData := slices.Contains(
+		cnpgv1.GetPluginConfigurationEnabledPluginNames(cluster.Spec.Plugins),
+		metadata.PluginName,
+	) && configuration.PurgePolicy == config.PurgePolicyDelete &&
+		len(configuration.BarmanObjectName) > 0
+
+	hasFinalizer := controllerutil.ContainsFinalizer(&cluster, metadata.ServerPurgeFinalizerName)
+
+	if cluster.DeletionTimestamp.IsZero() {
+		if purgeData == hasFinalizer {
+			return ctrl.Result{}, nil
+		}
+
+		original := cluster.DeepCopy()
+		if purgeData {
+			controllerutil.AddFinalizer(&cluster, metadata.ServerPurgeFinalizerName)
+		} else {
+			controllerutil.RemoveFinalizer(&cluster, metadata.ServerPurgeFinalizerName)
+		}
+		return ctrl.Result{}, r.Patch(ctx, &cluster, client.MergeFrom(original))
+	}
+
+	if !hasFinalizer {
+		return ctrl.Result{}, nil
+	}
+
+	if purgeData {
+		if err := r.recordServerPurges(ctx, &cluster, configuration); err != nil {
+			return ctrl.Result{}, fmt.Errorf("while recording the server purge: %w", err)
+		}
+	}
+
+	contextLogger.Info("Releasing the deleted cluster")
+	original := cluster.DeepCopy()
+	controllerutil.RemoveFinalizer(&cluster, metadata.ServerPurgeFinalizerName)
+	return ctrl.Result{}, r.Patch(ctx, &cluster, client.MergeFrom(original))
+}
+
+// recordServerPurges adds a pending purge of the data of the passed
+// deleted Cluster to the status of every object store it archives
+// into, to be run after the purge grace period
+func (r *ClusterReconciler) recordServerPurges(
+	ctx context.Context,
+	cluster *cnpgv1.Cluster,
+	configuration *config.PluginConfiguration,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	purge := barmancloudv1.ServerPurge{
+		ServerName:       configuration.ServerName,
+		ClusterNamespace: cluster.Namespace,
+		ClusterName:      cluster.Name,
+		ClusterUID:       string(cluster.UID),
+		ScheduledTime:    metav1.NewTime(cluster.DeletionTimestamp.Add(configuration.GetPurgeGracePeriod())),
+		Phase:            barmancloudv1.ServerPurgePhasePending,
+	}
+
+	for _, key := range configuration.GetArchiveBarmanObjectsKey() {
+		objectStore, err := common.GetObjectStore(ctx, r.Client, key)
+		if err == nil {
+			// The purge is bound to the location the Cluster archived
+			// into, which is not the one of the object store anymore
+			// if it changes during the grace period
+			purge.DestinationPath = objectStore.Spec.Configuration.DestinationPath
+			purge.EndpointURL = objectStore.Spec.Configuration.EndpointURL
+			err = common.UpdateObjectStoreStatus(ctx, r.Client, key, func(status *barmancloudv1.ObjectStoreStatus) {
+				if findServerPurge(status, purge.ClusterUID, purge.ServerName) == nil {
+					status.ServerPurges = append(status.ServerPurges, purge)
+				}
+			})
+		}
+		if apierrs.IsNotFound(err) {
+			contextLogger.Info("Object store not found, its data will not be purged",
+				"objectStoreName", key.Name,
+				"objectStoreNamespace", key.Namespace)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		contextLogger.Info("Server purge scheduled",
+			"objectStoreName", key.Name,
+			"objectStoreNamespace", key.Namespace,
+			"serverName", purge.ServerName,
+			"scheduledTime", purge.ScheduledTime)
+	}
+
+	return nil
+}
+
+// purgePolicyChangedPredicate accepts the events of the Clusters that
+// may change their purge policy, or that are being deleted
+var purgePolicyChangedPredicate = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() ||
+			e.ObjectOld.GetDeletionTimestamp().IsZero() != e.ObjectNew.GetDeletionTimestamp().IsZero() ||
+			!slices.Equal(e.ObjectOld.GetFinalizers(), e.ObjectNew.GetFinalizers())
+	},
+	DeleteFunc: func(event.DeleteEvent) bool { return false },
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&cnpgv1.Cluster{}, builder.WithPredicates(purgePolicyChangedPredicate)).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
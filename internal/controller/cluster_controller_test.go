@@ -0,0 +1,159 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("Cluster server purge finalizer", func() {
+	var (
+		ctx         context.Context
+		cluster     *cnpgv1.Cluster
+		objectStore *barmancloudv1.ObjectStore
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cluster",
+				Namespace: "default",
+				UID:       "cluster-uid",
+			},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName": "store",
+							"purgePolicy":      "Delete",
+							"purgeGracePeriod": "1h",
+						},
+					},
+				},
+			},
+		}
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+				},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *ClusterReconciler {
+		scheme := newCatalogMaintenanceScheme()
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			Build()
+		return &ClusterReconciler{
+			Client: fakeClient,
+			Scheme: scheme,
+		}
+	}
+
+	reconcileCluster := func(r *ClusterReconciler) {
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(cluster)})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	getCluster := func(r *ClusterReconciler) (*cnpgv1.Cluster, error) {
+		var updated cnpgv1.Cluster
+		err := r.Get(ctx, client.ObjectKeyFromObject(cluster), &updated)
+		return &updated, err
+	}
+
+	deletingCluster := func() *cnpgv1.Cluster {
+		result := cluster.DeepCopy()
+		result.Finalizers = []string{metadata.ServerPurgeFinalizerName}
+		result.DeletionTimestamp = ptr.To(metav1.Now())
+		return result
+	}
+
+	It("protects the Clusters having the Delete purge policy", func() {
+		r := newReconciler(cluster, objectStore)
+		reconcileCluster(r)
+
+		updated, err := getCluster(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(ContainElement(metadata.ServerPurgeFinalizerName))
+	})
+
+	It("releases the Clusters when the purge policy is not Delete anymore", func() {
+		cluster.Finalizers = []string{metadata.ServerPurgeFinalizerName}
+		cluster.Spec.Plugins[0].Parameters["purgePolicy"] = "Retain"
+		r := newReconciler(cluster, objectStore)
+		reconcileCluster(r)
+
+		updated, err := getCluster(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(updated.Finalizers).To(BeEmpty())
+	})
+
+	It("schedules the purge of a deleted Cluster after the grace period", func() {
+		deleting := deletingCluster()
+		r := newReconciler(deleting, objectStore)
+		reconcileCluster(r)
+
+		_, err := getCluster(r)
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+
+		var updated barmancloudv1.ObjectStore
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		Expect(updated.Status.ServerPurges).To(HaveLen(1))
+		purge := updated.Status.ServerPurges[0]
+		Expect(purge.ServerName).To(Equal("cluster"))
+		Expect(purge.ClusterUID).To(Equal("cluster-uid"))
+		Expect(purge.DestinationPath).To(Equal("s3://bucket/path"))
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhasePending))
+		Expect(purge.ScheduledTime.Time).To(BeTemporally("~", deleting.DeletionTimestamp.Add(time.Hour), time.Second))
+	})
+
+	It("releases a deleted Cluster whose object store does not exist", func() {
+		r := newReconciler(deletingCluster())
+		reconcileCluster(r)
+
+		_, err := getCluster(r)
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,352 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/credentials"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/rbac"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// ClusterObjectStoreReconciler reconciles a ClusterObjectStore object.
+type ClusterObjectStoreReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIReader reads the objects that are not worth caching, such
+	// as the Secrets referenced by the ClusterObjectStores
+	APIReader client.Reader
+
+	// SidecarImage is the default image of the sidecars
+	SidecarImage string
+
+	// SidecarImageAllowList is the list of patterns of the images
+	// the ClusterObjectStores are allowed to use for the sidecar
+	SidecarImageAllowList []string
+
+	// Recorder reports the events about the ClusterObjectStores
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles;clusterrolebindings,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=clusterobjectstores,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=list;patch
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=clusterobjectstores/status,verbs=get;update;patch
+
+// Reconcile ensures that the RBAC resources granting the Clusters
+// access to this ClusterObjectStore match its current spec. It
+// discovers them by listing the plugin-managed ClusterRoles, and
+// revokes the access of the Clusters that have been deleted or whose
+// namespace is no longer allowed, and refreshes the copies of its
+// endpoint CA.
+//
+// It also records the resource version of the referenced Secrets,
+// which are watched, so that the sidecars learn about their rotation,
+// and the image used by the sidecars. Like the ObjectStore controller,
+// it runs the catalog maintenance of the Clusters whose primary
+// instance is not running.
+//
+// When the spec changed, it annotates the Clusters using the
+// ClusterObjectStore, so that the operator evaluates their instance
+// pods again and rolls out the ones whose sidecar is outdated.
+func (r *ClusterObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues("clusterObjectStoreName", req.Name)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	contextLogger.Info("ClusterObjectStore reconciliation start")
+
+	var errs []error
+	var requeueAfter time.Duration
+
+	// The generation of the spec, when it changed since the last
+	// reconciliation, requiring the Clusters to be evaluated again
+	var changedGeneration int64
+
+	var clusterObjectStore barmancloudv1.ClusterObjectStore
+	err := r.Get(ctx, req.NamespacedName, &clusterObjectStore)
+	switch {
+	case err == nil:
+		if clusterObjectStore.Status.ObservedGeneration > 0 &&
+			clusterObjectStore.Status.ObservedGeneration != clusterObjectStore.Generation {
+			changedGeneration = clusterObjectStore.Generation
+		}
+
+		if err := recordSecretsResourceVersion(
+			ctx,
+			r.Client,
+			r.APIReader,
+			r.Recorder,
+			req.NamespacedName,
+			&clusterObjectStore,
+		); err != nil {
+			contextLogger.Error(err, "Failed to record the secrets resource version")
+			errs = append(errs, fmt.Errorf("while recording the secrets resource version: %w", err))
+		}
+
+		if err := recordSidecarImage(
+			ctx,
+			r.Client,
+			r.Recorder,
+			req.NamespacedName,
+			&clusterObjectStore,
+			r.SidecarImage,
+			r.SidecarImageAllowList,
+		); err != nil {
+			contextLogger.Error(err, "Failed to record the sidecar image")
+			errs = append(errs, fmt.Errorf("while recording the sidecar image: %w", err))
+		}
+
+	case !apierrs.IsNotFound(err):
+		errs = append(errs, fmt.Errorf("while getting ClusterObjectStore: %w", err))
+	}
+
+	var clusterRoleList rbacv1.ClusterRoleList
+	if err := r.List(ctx, &clusterRoleList,
+		client.HasLabels{metadata.ClusterLabelName, metadata.ClusterNamespaceLabelName},
+	); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while listing cluster roles: %w", err)
+	}
+
+	for i := range clusterRoleList.Items {
+		clusterRole := &clusterRoleList.Items[i]
+		if !slices.Contains(specs.ClusterObjectStoreNamesFromClusterRole(clusterRole), req.Name) {
+			continue
+		}
+
+		clusterKey := client.ObjectKey{
+			Namespace: clusterRole.Labels[metadata.ClusterNamespaceLabelName],
+			Name:      clusterRole.Labels[metadata.ClusterLabelName],
+		}
+		if err := r.reconcileClusterRBAC(ctx, clusterKey); err != nil {
+			contextLogger.Error(err, "Failed to reconcile RBAC for cluster",
+				"clusterName", clusterKey.Name, "clusterNamespace", clusterKey.Namespace)
+			errs = append(errs, fmt.Errorf("while reconciling RBAC for cluster %s: %w", clusterKey, err))
+		}
+
+		if changedGeneration > 0 {
+			if err := requestClusterEvaluation(
+				ctx,
+				r.Client,
+				r.APIReader,
+				clusterKey,
+				req.NamespacedName,
+				changedGeneration,
+			); err != nil {
+				contextLogger.Error(err, "Failed to request the cluster evaluation",
+					"clusterName", clusterKey.Name, "clusterNamespace", clusterKey.Namespace)
+				errs = append(errs, err)
+			}
+		}
+
+		result, err := reconcileCatalogMaintenance(
+			ctx,
+			r.Client,
+			r.APIReader,
+			r.SidecarImage,
+			req.NamespacedName,
+			clusterKey,
+		)
+		if err != nil {
+			contextLogger.Error(err, "Failed to reconcile catalog maintenance",
+				"clusterName", clusterKey.Name, "clusterNamespace", clusterKey.Namespace)
+			errs = append(errs, fmt.Errorf("while reconciling catalog maintenance for cluster %s: %w",
+				clusterKey, err))
+		}
+		if result > 0 && (requeueAfter == 0 || result < requeueAfter) {
+			requeueAfter = result
+		}
+	}
+
+	// The generation is recorded once every Cluster has been notified,
+	// so that a failure is retried
+	if err == nil && len(errs) == 0 &&
+		clusterObjectStore.Status.ObservedGeneration != clusterObjectStore.Generation {
+		if err := common.UpdateObjectStoreStatus(
+			ctx,
+			r.Client,
+			req.NamespacedName,
+			func(status *barmancloudv1.ObjectStoreStatus) {
+				status.ObservedGeneration = clusterObjectStore.Generation
+			},
+		); err != nil {
+			errs = append(errs, fmt.Errorf("while recording the observed generation: %w", err))
+		}
+	}
+
+	contextLogger.Info("ClusterObjectStore reconciliation completed")
+	return ctrl.Result{RequeueAfter: requeueAfter}, errors.Join(errs...)
+}
+
+// reconcileClusterRBAC updates the RBAC resources granting the Cluster
+// having the passed key access to the ClusterObjectStores it uses
+func (r *ClusterObjectStoreReconciler) reconcileClusterRBAC(ctx context.Context, clusterKey client.ObjectKey) error {
+	contextLogger := log.FromContext(ctx)
+
+	var cluster cnpgv1.Cluster
+	if err := r.Get(ctx, clusterKey, &cluster); err != nil {
+		if apierrs.IsNotFound(err) {
+			return rbac.DeleteClusterObjectStoreRBAC(ctx, r.Client, clusterKey)
+		}
+		return err
+	}
+
+	pluginConfiguration := config.NewFromCluster(&cluster)
+	var clusterObjectStores []barmancloudv1.ClusterObjectStore
+	for _, barmanObjectKey := range pluginConfiguration.GetReferredBarmanObjectsKey() {
+		if len(barmanObjectKey.Namespace) > 0 {
+			continue
+		}
+
+		var clusterObjectStore barmancloudv1.ClusterObjectStore
+		if err := r.Get(ctx, barmanObjectKey, &clusterObjectStore); err != nil {
+			if apierrs.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		if !clusterObjectStore.IsNamespaceAllowed(cluster.Namespace) {
+			contextLogger.Info("Namespace not allowed anymore, revoking the access to the ClusterObjectStore",
+				"clusterName", cluster.Name, "clusterNamespace", cluster.Namespace)
+			continue
+		}
+
+		clusterObjectStores = append(clusterObjectStores, clusterObjectStore)
+	}
+
+	if err := rbac.EnsureClusterObjectStoreRBAC(ctx, r.Client, &cluster, clusterObjectStores); err != nil {
+		return err
+	}
+
+	return credentials.EnsureEndpointCASecrets(ctx, r.Client, r.APIReader, &cluster, clusterObjectStores)
+}
+
+// mapClusterToClusterObjectStores enqueues the ClusterObjectStores
+// used by a Cluster
+func mapClusterToClusterObjectStores(_ context.Context, obj client.Object) []reconcile.Request {
+	cluster, ok := obj.(*cnpgv1.Cluster)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, barmanObjectKey := range config.NewFromCluster(cluster).GetReferredBarmanObjectsKey() {
+		if len(barmanObjectKey.Namespace) == 0 {
+			requests = append(requests, reconcile.Request{NamespacedName: barmanObjectKey})
+		}
+	}
+
+	return requests
+}
+
+// mapCatalogMaintenanceJobToClusterObjectStores enqueues the
+// ClusterObjectStores used by the Cluster of a catalog maintenance Job
+func (r *ClusterObjectStoreReconciler) mapCatalogMaintenanceJobToClusterObjectStores(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		return nil
+	}
+
+	clusterName := job.Labels[metadata.ClusterLabelName]
+	if len(clusterName) == 0 || job.Name != specs.GetCatalogMaintenanceName(clusterName) {
+		return nil
+	}
+
+	var cluster cnpgv1.Cluster
+	if err := r.Get(ctx, client.ObjectKey{Namespace: job.Namespace, Name: clusterName}, &cluster); err != nil {
+		if !apierrs.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to get the cluster of the catalog maintenance job",
+				"clusterName", clusterName, "namespace", job.Namespace)
+		}
+		return nil
+	}
+
+	return mapClusterToClusterObjectStores(ctx, &cluster)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterObjectStoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The Pre hook keeps the RBAC resources of existing Clusters up
+	// to date, while the deleted Clusters are only seen here
+	onlyDeletions := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return true },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+
+	err := ctrl.NewControllerManagedBy(mgr).
+		For(&barmancloudv1.ClusterObjectStore{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(
+			&cnpgv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(mapClusterToClusterObjectStores),
+			builder.WithPredicates(onlyDeletions),
+		).
+		Watches(
+			&batchv1.Job{},
+			handler.EnqueueRequestsFromMapFunc(r.mapCatalogMaintenanceJobToClusterObjectStores),
+			builder.WithPredicates(jobFinishedPredicate),
+		).
+		// Only the metadata of the Secrets is cached, as their
+		// content is read on demand
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.mapSecretToClusterObjectStores),
+			builder.OnlyMetadata,
+		).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
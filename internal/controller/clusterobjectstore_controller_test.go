@@ -0,0 +1,150 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/rbac"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+var _ = Describe("ClusterObjectStoreReconciler", func() {
+	rbacName := specs.GetClusterRBACName("default", "test-cluster")
+
+	var (
+		ctx                context.Context
+		cluster            *cnpgv1.Cluster
+		clusterObjectStore *barmancloudv1.ClusterObjectStore
+		fakeClient         client.Client
+		reconciler         *ClusterObjectStoreReconciler
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme := newFakeScheme()
+		utilruntime.Must(cnpgv1.AddToScheme(scheme))
+
+		cluster = &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-cluster",
+				Namespace: "default",
+			},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName": "shared-store",
+							"barmanObjectKind": barmancloudv1.ClusterObjectStoreKind,
+						},
+					},
+				},
+			},
+		}
+
+		objectStore := newTestObjectStore("shared-store", "", "shared-secret")
+		clusterObjectStore = &barmancloudv1.ClusterObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "shared-store",
+			},
+			Spec: barmancloudv1.ClusterObjectStoreSpec{
+				ObjectStoreSpec:      objectStore.Spec,
+				CredentialsNamespace: "platform",
+				AllowedNamespaces:    []string{"default"},
+			},
+		}
+
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(cluster, clusterObjectStore).
+			Build()
+		reconciler = &ClusterObjectStoreReconciler{
+			Client:    fakeClient,
+			APIReader: fakeClient,
+			Scheme:    scheme,
+			Recorder:  record.NewFakeRecorder(10),
+		}
+
+		Expect(rbac.EnsureClusterObjectStoreRBAC(ctx, fakeClient, cluster,
+			[]barmancloudv1.ClusterObjectStore{*clusterObjectStore})).To(Succeed())
+	})
+
+	reconcileStore := func() {
+		_, err := reconciler.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKey{Name: "shared-store"},
+		})
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	}
+
+	expectRBACRemoved := func() {
+		err := fakeClient.Get(ctx, client.ObjectKey{Name: rbacName}, &rbacv1.ClusterRole{})
+		ExpectWithOffset(1, apierrs.IsNotFound(err)).To(BeTrue())
+		err = fakeClient.Get(ctx, client.ObjectKey{Name: rbacName}, &rbacv1.ClusterRoleBinding{})
+		ExpectWithOffset(1, apierrs.IsNotFound(err)).To(BeTrue())
+		err = fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &rbacv1.Role{})
+		ExpectWithOffset(1, apierrs.IsNotFound(err)).To(BeTrue())
+		err = fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &rbacv1.RoleBinding{})
+		ExpectWithOffset(1, apierrs.IsNotFound(err)).To(BeTrue())
+	}
+
+	It("should keep the RBAC of Clusters allowed to use the ClusterObjectStore", func() {
+		reconcileStore()
+
+		var role rbacv1.Role
+		Expect(fakeClient.Get(ctx, client.ObjectKey{Namespace: "platform", Name: rbacName}, &role)).To(Succeed())
+		Expect(role.Rules[0].ResourceNames).To(Equal([]string{"shared-secret"}))
+	})
+
+	It("should remove the RBAC of deleted Clusters", func() {
+		Expect(fakeClient.Delete(ctx, cluster)).To(Succeed())
+		reconcileStore()
+		expectRBACRemoved()
+	})
+
+	It("should revoke the access when the namespace is no longer allowed", func() {
+		clusterObjectStore.Spec.AllowedNamespaces = []string{"other"}
+		Expect(fakeClient.Update(ctx, clusterObjectStore)).To(Succeed())
+		reconcileStore()
+		expectRBACRemoved()
+	})
+
+	It("should map a Cluster to the ClusterObjectStores it uses", func() {
+		requests := mapClusterToClusterObjectStores(ctx, cluster)
+		Expect(requests).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKey{Name: "shared-store"},
+		}))
+	})
+})
@@ -24,14 +24,20 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"time"
 
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	"github.com/cloudnative-pg/machinery/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
@@ -44,12 +50,34 @@ import (
 type ObjectStoreReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// APIReader reads the objects that are not worth caching, such
+	// as the Secrets referenced by the ObjectStores and the Pods of
+	// the probe Jobs
+	APIReader client.Reader
+
+	// SidecarImage is the image used to run the catalog
+	// maintenance and the probe Jobs
+	SidecarImage string
+
+	// SidecarImageAllowList is the list of patterns of the images
+	// the ObjectStores are allowed to use for the sidecar
+	SidecarImageAllowList []string
+
+	// Recorder reports the events about the ObjectStores
+	Recorder record.EventRecorder
 }
 
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create;patch;update;get;list;watch
-// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=create;patch;update;get;list;watch
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=create;patch;update;get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=create;patch;update;get;list;watch;delete
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=create;list;get;watch;delete
-// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;patch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=create;patch;get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=backups,verbs=create;get;list;watch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=coordination.k8s.io,resources=leases,verbs=create;get;list;watch;update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=create;delete;get;list;watch
 // +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters/finalizers,verbs=update
 // +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=objectstores,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=objectstores/status,verbs=get;update;patch
@@ -58,7 +86,32 @@ type ObjectStoreReconciler struct {
 // Reconcile ensures that the RBAC Role for each Cluster referencing
 // this ObjectStore is up to date with the current ObjectStore spec.
 // It discovers affected Roles by listing plugin-managed Roles and
-// inspecting their rules, without needing access to Cluster objects.
+// inspecting their rules.
+//
+// The rules only grant access to the ObjectStores, and their Secrets,
+// the Cluster still refers to. When the Cluster does not use the
+// plugin anymore, its RBAC resources are removed.
+//
+// It also resolves the ObjectStore credentials and periodically probes
+// its connectivity, publishing the outcome in the status conditions.
+//
+// For every Cluster archiving into this ObjectStore whose instances
+// are not running, it also takes over the catalog maintenance that
+// would otherwise be done by the primary instance.
+//
+// It records the resource version of the referenced Secrets, which
+// are watched, so that the sidecars learn about their rotation, and
+// the image used by the sidecars.
+//
+// When the spec changed, it annotates the Clusters using the
+// ObjectStore, so that the operator evaluates their instance pods
+// again and rolls out the ones whose sidecar is outdated.
+//
+// It manages the dedicated ServiceAccount of the workload identity,
+// if any.
+//
+// Finally, it protects the ObjectStore with a finalizer, which is
+// removed only once no Cluster is using it anymore.
 func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	contextLogger := log.FromContext(ctx).WithValues(
 		"objectStoreName", req.Name,
@@ -68,6 +121,77 @@ func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	contextLogger.Info("ObjectStore reconciliation start")
 
+	var errs []error
+	var requeueAfter time.Duration
+
+	// The generation of the spec, when it changed since the last
+	// reconciliation, requiring the Clusters to be evaluated again
+	var changedGeneration int64
+
+	var objectStore barmancloudv1.ObjectStore
+	err := r.Get(ctx, req.NamespacedName, &objectStore)
+	switch {
+	case err == nil && !objectStore.DeletionTimestamp.IsZero():
+		result, err := r.reconcileDeletion(ctx, &objectStore)
+		if err != nil {
+			contextLogger.Error(err, "Failed to reconcile the ObjectStore deletion")
+			errs = append(errs, fmt.Errorf("while reconciling deletion: %w", err))
+		}
+		requeueAfter = result
+
+	case err == nil:
+		if objectStore.Status.ObservedGeneration > 0 &&
+			objectStore.Status.ObservedGeneration != objectStore.Generation {
+			changedGeneration = objectStore.Generation
+		}
+
+		if err := r.ensureFinalizer(ctx, &objectStore); err != nil {
+			contextLogger.Error(err, "Failed to add the ObjectStore finalizer")
+			errs = append(errs, fmt.Errorf("while adding the finalizer: %w", err))
+		}
+
+		if err := recordSecretsResourceVersion(
+			ctx,
+			r.Client,
+			r.APIReader,
+			r.Recorder,
+			req.NamespacedName,
+			&objectStore,
+		); err != nil {
+			contextLogger.Error(err, "Failed to record the secrets resource version")
+			errs = append(errs, fmt.Errorf("while recording the secrets resource version: %w", err))
+		}
+
+		if err := recordSidecarImage(
+			ctx,
+			r.Client,
+			r.Recorder,
+			req.NamespacedName,
+			&objectStore,
+			r.SidecarImage,
+			r.SidecarImageAllowList,
+		); err != nil {
+			contextLogger.Error(err, "Failed to record the sidecar image")
+			errs = append(errs, fmt.Errorf("while recording the sidecar image: %w", err))
+		}
+
+		if err := r.reconcileWorkloadIdentity(ctx, &objectStore); err != nil {
+			contextLogger.Error(err, "Failed to reconcile the workload identity")
+			errs = append(errs, fmt.Errorf("while reconciling the workload identity: %w", err))
+		}
+
+		result, err := r.reconcileStatus(ctx, &objectStore)
+		if err != nil {
+			contextLogger.Error(err, "Failed to reconcile the ObjectStore status")
+			errs = append(errs, fmt.Errorf("while reconciling status: %w", err))
+		}
+		requeueAfter = result
+
+	case !apierrs.IsNotFound(err):
+		// The Roles referencing this ObjectStore are still reconciled
+		errs = append(errs, fmt.Errorf("while getting ObjectStore: %w", err))
+	}
+
 	// NOTE: Roles created before the introduction of ClusterLabelName
 	// are not discovered here. The Pre hook patches the label on every
 	// Cluster reconciliation, so unlabeled Roles are picked up after
@@ -80,27 +204,79 @@ func (r *ObjectStoreReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, fmt.Errorf("while listing roles: %w", err)
 	}
 
-	var errs []error
 	for i := range roleList.Items {
 		role := &roleList.Items[i]
 
-		objectStoreNames := specs.ObjectStoreNamesFromRole(role)
-		if !slices.Contains(objectStoreNames, req.Name) {
+		if !slices.Contains(specs.ObjectStoreNamesFromRole(role), req.Name) {
 			continue
 		}
 
 		contextLogger.Info("Reconciling RBAC for role",
 			"roleName", role.Name)
 
+		clusterKey := client.ObjectKey{
+			Namespace: role.Namespace,
+			Name:      role.Labels[metadata.ClusterLabelName],
+		}
+
+		objectStoreNames, inUse, err := r.getObjectStoreNamesInUse(ctx, role)
+		if err != nil {
+			contextLogger.Error(err, "Failed to get the ObjectStores used by the cluster",
+				"clusterName", clusterKey.Name)
+			errs = append(errs, fmt.Errorf("while reconciling role %s: %w", role.Name, err))
+			continue
+		}
+
+		if !inUse {
+			if err := r.deleteClusterRBAC(ctx, clusterKey); err != nil {
+				contextLogger.Error(err, "Failed to remove the RBAC resources of the cluster",
+					"clusterName", clusterKey.Name)
+				errs = append(errs, fmt.Errorf("while removing the RBAC of cluster %s: %w", clusterKey.Name, err))
+			}
+			continue
+		}
+
 		if err := r.reconcileRoleRules(ctx, role, objectStoreNames); err != nil {
 			contextLogger.Error(err, "Failed to reconcile RBAC for role",
 				"roleName", role.Name)
 			errs = append(errs, fmt.Errorf("while reconciling role %s: %w", role.Name, err))
 		}
+		if changedGeneration > 0 {
+			if err := requestClusterEvaluation(
+				ctx,
+				r.Client,
+				r.APIReader,
+				clusterKey,
+				req.NamespacedName,
+				changedGeneration,
+			); err != nil {
+				contextLogger.Error(err, "Failed to request the cluster evaluation",
+					"clusterName", clusterKey.Name)
+				errs = append(errs, err)
+			}
+		}
+
+		result, err := reconcileCatalogMaintenance(
+			ctx,
+			r.Client,
+			r.APIReader,
+			r.SidecarImage,
+			req.NamespacedName,
+			clusterKey,
+		)
+		if err != nil {
+			contextLogger.Error(err, "Failed to reconcile catalog maintenance",
+				"clusterName", clusterKey.Name)
+			errs = append(errs, fmt.Errorf("while reconciling catalog maintenance for cluster %s: %w",
+				clusterKey.Name, err))
+		}
+		if result > 0 && (requeueAfter == 0 || result < requeueAfter) {
+			requeueAfter = result
+		}
 	}
 
 	contextLogger.Info("ObjectStore reconciliation completed")
-	return ctrl.Result{}, errors.Join(errs...)
+	return ctrl.Result{RequeueAfter: requeueAfter}, errors.Join(errs...)
 }
 
 // reconcileRoleRules fetches the ObjectStores referenced by the
@@ -129,13 +305,45 @@ func (r *ObjectStoreReconciler) reconcileRoleRules(
 		barmanObjects = append(barmanObjects, barmanObject)
 	}
 
-	return rbac.EnsureRoleRules(ctx, r.Client, client.ObjectKeyFromObject(role), barmanObjects)
+	return rbac.EnsureRoleRules(
+		ctx,
+		r.Client,
+		client.ObjectKeyFromObject(role),
+		role.Labels[metadata.ClusterLabelName],
+		barmanObjects,
+		specs.ImportBackupsFromRole(role),
+	)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ObjectStoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	err := ctrl.NewControllerManagedBy(mgr).
 		For(&barmancloudv1.ObjectStore{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&batchv1.Job{}).
+		Owns(&corev1.ServiceAccount{}).
+		// The catalog maintenance Jobs are owned by their Cluster
+		Watches(
+			&batchv1.Job{},
+			handler.EnqueueRequestsFromMapFunc(r.mapCatalogMaintenanceJobToObjectStores),
+			builder.WithPredicates(jobFinishedPredicate),
+		).
+		Watches(
+			&cnpgv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(r.mapClusterToObjectStores),
+			builder.WithPredicates(pluginUsageChangedPredicate),
+		).
+		Watches(
+			&rbacv1.Role{},
+			handler.EnqueueRequestsFromMapFunc(mapRoleToObjectStores),
+			builder.WithPredicates(roleUsageChangedPredicate),
+		).
+		// Only the metadata of the Secrets is cached, as their
+		// content is read on demand
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(r.mapSecretToObjectStores),
+			builder.OnlyMetadata,
+		).
 		Complete(r)
 	if err != nil {
 		return fmt.Errorf("unable to create controller: %w", err)
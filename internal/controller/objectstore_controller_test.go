@@ -24,15 +24,19 @@ import (
 	"fmt"
 
 	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
 	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
@@ -46,6 +50,9 @@ import (
 func newFakeScheme() *runtime.Scheme {
 	s := runtime.NewScheme()
 	utilruntime.Must(rbacv1.AddToScheme(s))
+	utilruntime.Must(corev1.AddToScheme(s))
+	utilruntime.Must(batchv1.AddToScheme(s))
+	utilruntime.Must(cnpgv1.AddToScheme(s))
 	barmancloudv1.AddKnownTypes(s)
 	return s
 }
@@ -83,7 +90,7 @@ func newLabeledRole(clusterName, namespace string, objectStores []barmancloudv1.
 				metadata.ClusterLabelName: clusterName,
 			},
 		},
-		Rules: specs.BuildRoleRules(objectStores),
+		Rules: specs.BuildRoleRules(clusterName, objectStores, false),
 	}
 }
 
@@ -109,11 +116,14 @@ var _ = Describe("ObjectStoreReconciler", func() {
 			fakeClient := fake.NewClientBuilder().
 				WithScheme(scheme).
 				WithObjects(role, newStore).
+				WithStatusSubresource(&barmancloudv1.ObjectStore{}).
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			result, err := reconciler.Reconcile(ctx, reconcile.Request{
@@ -123,7 +133,8 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal(reconcile.Result{}))
+			// The credentials are incomplete, so they are checked again later
+			Expect(result).To(Equal(reconcile.Result{RequeueAfter: specs.DefaultProbeInterval}))
 
 			var updatedRole rbacv1.Role
 			Expect(fakeClient.Get(ctx, client.ObjectKey{
@@ -146,8 +157,10 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			var before rbacv1.Role
@@ -180,8 +193,10 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			result, err := reconciler.Reconcile(ctx, reconcile.Request{
@@ -206,8 +221,10 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			result, err := reconciler.Reconcile(ctx, reconcile.Request{
@@ -247,8 +264,10 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			result, err := reconciler.Reconcile(ctx, reconcile.Request{
@@ -261,7 +280,7 @@ var _ = Describe("ObjectStoreReconciler", func() {
 			Expect(result).To(Equal(reconcile.Result{}))
 		})
 
-		It("should produce empty ResourceNames when all ObjectStores are deleted", func() {
+		It("should revoke the access to ObjectStores and Secrets when all ObjectStores are deleted", func() {
 			store := newTestObjectStore("my-store", "default", "aws-creds")
 			role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*store})
 
@@ -272,8 +291,10 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			result, err := reconciler.Reconcile(ctx, reconcile.Request{
@@ -291,10 +312,11 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				Name:      "my-cluster-barman-cloud",
 			}, &updatedRole)).To(Succeed())
 
-			// All rules should have empty ResourceNames
-			Expect(updatedRole.Rules[0].ResourceNames).To(BeEmpty())
-			Expect(updatedRole.Rules[1].ResourceNames).To(BeEmpty())
-			Expect(updatedRole.Rules[2].ResourceNames).To(BeEmpty())
+			// An empty list of ResourceNames would grant access to every
+			// object, so the rules restricted by name are dropped
+			for _, rule := range updatedRole.Rules {
+				Expect(rule.Resources).NotTo(ContainElement(BeElementOf("objectstores", "secrets")))
+			}
 		})
 
 		It("should return an error when listing Roles fails", func() {
@@ -311,7 +333,12 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				}).
 				Build()
 
-			reconciler := &ObjectStoreReconciler{Client: fakeClient, Scheme: scheme}
+			reconciler := &ObjectStoreReconciler{
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
+			}
 			_, err := reconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: types.NamespacedName{Name: "my-store", Namespace: "default"},
 			})
@@ -337,7 +364,12 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				}).
 				Build()
 
-			reconciler := &ObjectStoreReconciler{Client: fakeClient, Scheme: scheme}
+			reconciler := &ObjectStoreReconciler{
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
+			}
 			_, err := reconciler.Reconcile(ctx, reconcile.Request{
 				NamespacedName: types.NamespacedName{Name: "my-store", Namespace: "default"},
 			})
@@ -370,11 +402,14 @@ var _ = Describe("ObjectStoreReconciler", func() {
 			fakeClient := fake.NewClientBuilder().
 				WithScheme(scheme).
 				WithObjects(role1, role2, store).
+				WithStatusSubresource(&barmancloudv1.ObjectStore{}).
 				Build()
 
 			reconciler := &ObjectStoreReconciler{
-				Client: fakeClient,
-				Scheme: scheme,
+				Client:    fakeClient,
+				APIReader: fakeClient,
+				Scheme:    scheme,
+				Recorder:  record.NewFakeRecorder(10),
 			}
 
 			result, err := reconciler.Reconcile(ctx, reconcile.Request{
@@ -384,7 +419,8 @@ var _ = Describe("ObjectStoreReconciler", func() {
 				},
 			})
 			Expect(err).NotTo(HaveOccurred())
-			Expect(result).To(Equal(reconcile.Result{}))
+			// The credentials are incomplete, so they are checked again later
+			Expect(result).To(Equal(reconcile.Result{RequeueAfter: specs.DefaultProbeInterval}))
 
 			for _, clusterName := range []string{"cluster-1", "cluster-2"} {
 				var updatedRole rbacv1.Role
@@ -0,0 +1,176 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// deletionBlockedRequeueInterval is the interval after which the
+// deletion of an ObjectStore still in use is checked again. The
+// changes to the plugin-managed Roles trigger the check too.
+const deletionBlockedRequeueInterval = time.Minute
+
+// ensureFinalizer adds the protection finalizer to the passed
+// ObjectStore, if missing
+func (r *ObjectStoreReconciler) ensureFinalizer(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) error {
+	if controllerutil.ContainsFinalizer(objectStore, metadata.ObjectStoreFinalizerName) {
+		return nil
+	}
+
+	original := objectStore.DeepCopy()
+	controllerutil.AddFinalizer(objectStore, metadata.ObjectStoreFinalizerName)
+	return r.Patch(ctx, objectStore, client.MergeFrom(original))
+}
+
+// reconcileDeletion removes the protection finalizer from the passed
+// ObjectStore once no Cluster is using it. Until then, the Clusters
+// blocking the deletion are reported in an event and in the
+// DeletionBlocked condition.
+func (r *ObjectStoreReconciler) reconcileDeletion(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) (time.Duration, error) {
+	contextLogger := log.FromContext(ctx)
+
+	if !controllerutil.ContainsFinalizer(objectStore, metadata.ObjectStoreFinalizerName) {
+		return 0, nil
+	}
+
+	clusterNames, err := r.getClustersUsingObjectStore(ctx, client.ObjectKeyFromObject(objectStore))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(clusterNames) == 0 {
+		contextLogger.Info("ObjectStore not in use anymore, removing the finalizer")
+		original := objectStore.DeepCopy()
+		controllerutil.RemoveFinalizer(objectStore, metadata.ObjectStoreFinalizerName)
+		return 0, r.Patch(ctx, objectStore, client.MergeFrom(original))
+	}
+
+	message := fmt.Sprintf("the ObjectStore is used by the Clusters: %s", strings.Join(clusterNames, ", "))
+	contextLogger.Info("ObjectStore deletion blocked", "clusterNames", clusterNames)
+	r.Recorder.Event(objectStore, corev1.EventTypeWarning, "DeletionBlocked", message)
+
+	if err := r.patchStatusConditions(ctx, objectStore, metav1.Condition{
+		Type:               barmancloudv1.ConditionDeletionBlocked,
+		Status:             metav1.ConditionTrue,
+		Reason:             barmancloudv1.ReasonInUse,
+		Message:            message,
+		ObservedGeneration: objectStore.Generation,
+	}); err != nil {
+		return 0, fmt.Errorf("while patching the ObjectStore status: %w", err)
+	}
+
+	return deletionBlockedRequeueInterval, nil
+}
+
+// getClustersUsingObjectStore gets the sorted names of the Clusters
+// using the ObjectStore having the passed key. They are discovered
+// through the plugin-managed Roles granting them access to it.
+func (r *ObjectStoreReconciler) getClustersUsingObjectStore(
+	ctx context.Context,
+	objectStoreKey client.ObjectKey,
+) ([]string, error) {
+	var roleList rbacv1.RoleList
+	if err := r.List(ctx, &roleList,
+		client.InNamespace(objectStoreKey.Namespace),
+		client.HasLabels{metadata.ClusterLabelName},
+	); err != nil {
+		return nil, fmt.Errorf("while listing roles: %w", err)
+	}
+
+	var result []string
+	for i := range roleList.Items {
+		role := &roleList.Items[i]
+		if role.DeletionTimestamp != nil {
+			continue
+		}
+		if slices.Contains(specs.ObjectStoreNamesFromRole(role), objectStoreKey.Name) {
+			result = append(result, role.Labels[metadata.ClusterLabelName])
+		}
+	}
+
+	slices.Sort(result)
+	return slices.Compact(result), nil
+}
+
+// mapRoleToObjectStores enqueues the ObjectStores referenced by a
+// plugin-managed Role
+func mapRoleToObjectStores(_ context.Context, obj client.Object) []reconcile.Request {
+	role, ok := obj.(*rbacv1.Role)
+	if !ok {
+		return nil
+	}
+
+	objectStoreNames := specs.ObjectStoreNamesFromRole(role)
+	requests := make([]reconcile.Request, 0, len(objectStoreNames))
+	for _, name := range objectStoreNames {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: client.ObjectKey{Namespace: role.Namespace, Name: name},
+		})
+	}
+
+	return requests
+}
+
+// roleUsageChangedPredicate accepts the events of the plugin-managed
+// Roles that may stop a Cluster from using an ObjectStore
+var roleUsageChangedPredicate = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldRole, oldOk := e.ObjectOld.(*rbacv1.Role)
+		newRole, newOk := e.ObjectNew.(*rbacv1.Role)
+		if !oldOk || !newOk {
+			return false
+		}
+		return !slices.Equal(
+			specs.ObjectStoreNamesFromRole(oldRole),
+			specs.ObjectStoreNamesFromRole(newRole),
+		)
+	},
+	DeleteFunc: func(e event.DeleteEvent) bool {
+		_, ok := e.Object.GetLabels()[metadata.ClusterLabelName]
+		return ok
+	},
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
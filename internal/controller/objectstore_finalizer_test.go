@@ -0,0 +1,144 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("ObjectStore finalizer", func() {
+	var (
+		ctx         context.Context
+		objectStore *barmancloudv1.ObjectStore
+		recorder    *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objectStore = newTestObjectStore("my-store", "default", "my-secret")
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	newReconciler := func(objs ...client.Object) *ObjectStoreReconciler {
+		scheme := newFakeScheme()
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			Build()
+		return &ObjectStoreReconciler{
+			Client:    fakeClient,
+			APIReader: fakeClient,
+			Scheme:    scheme,
+			Recorder:  recorder,
+		}
+	}
+
+	reconcileStore := func(r *ObjectStoreReconciler) reconcile.Result {
+		result, err := r.Reconcile(ctx, reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(objectStore),
+		})
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		return result
+	}
+
+	It("adds the finalizer to the ObjectStores", func() {
+		r := newReconciler(objectStore)
+		reconcileStore(r)
+
+		var updated barmancloudv1.ObjectStore
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		Expect(updated.Finalizers).To(ContainElement(metadata.ObjectStoreFinalizerName))
+	})
+
+	It("blocks the deletion of an ObjectStore used by a Cluster", func() {
+		objectStore.Finalizers = []string{metadata.ObjectStoreFinalizerName}
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*objectStore})
+		r := newReconciler(objectStore, role)
+		Expect(r.Delete(ctx, objectStore)).To(Succeed())
+
+		result := reconcileStore(r)
+		Expect(result.RequeueAfter).To(Equal(deletionBlockedRequeueInterval))
+
+		var updated barmancloudv1.ObjectStore
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		Expect(updated.Finalizers).To(ContainElement(metadata.ObjectStoreFinalizerName))
+
+		condition := meta.FindStatusCondition(updated.Status.Conditions, barmancloudv1.ConditionDeletionBlocked)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(barmancloudv1.ReasonInUse))
+		Expect(condition.Message).To(ContainSubstring("my-cluster"))
+
+		Expect(recorder.Events).To(Receive(ContainSubstring("DeletionBlocked")))
+	})
+
+	It("removes the finalizer once no Cluster uses the ObjectStore", func() {
+		objectStore.Finalizers = []string{metadata.ObjectStoreFinalizerName}
+		otherStore := newTestObjectStore("other-store", "default", "other-secret")
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*otherStore})
+		r := newReconciler(objectStore, role)
+		Expect(r.Delete(ctx, objectStore)).To(Succeed())
+
+		result := reconcileStore(r)
+		Expect(result.RequeueAfter).To(BeZero())
+
+		err := r.Get(ctx, client.ObjectKeyFromObject(objectStore), &barmancloudv1.ObjectStore{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("enqueues the ObjectStores no longer referenced by a Role", func() {
+		otherStore := newTestObjectStore("other-store", "default", "other-secret")
+		oldRole := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*objectStore, *otherStore})
+		newRole := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*otherStore})
+
+		Expect(roleUsageChangedPredicate.Update(event.UpdateEvent{
+			ObjectOld: oldRole,
+			ObjectNew: newRole,
+		})).To(BeTrue())
+		Expect(roleUsageChangedPredicate.Update(event.UpdateEvent{
+			ObjectOld: newRole,
+			ObjectNew: newRole.DeepCopy(),
+		})).To(BeFalse())
+		Expect(roleUsageChangedPredicate.Delete(event.DeleteEvent{Object: oldRole})).To(BeTrue())
+		Expect(roleUsageChangedPredicate.Delete(event.DeleteEvent{Object: &rbacv1.Role{}})).To(BeFalse())
+
+		Expect(mapRoleToObjectStores(ctx, oldRole)).To(ConsistOf(
+			reconcile.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "my-store"}},
+			reconcile.Request{NamespacedName: client.ObjectKey{Namespace: "default", Name: "other-store"}},
+		))
+	})
+})
@@ -0,0 +1,150 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/config"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/rbac"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// getObjectStoreNamesInUse gets the names of the ObjectStores the
+// Cluster owning the passed plugin-managed Role refers to, and
+// whether the Cluster still uses the plugin at all.
+//
+// When the Cluster is not found, the names in the Role are kept:
+// the Role is owned by the Cluster and garbage collected with it.
+func (r *ObjectStoreReconciler) getObjectStoreNamesInUse(
+	ctx context.Context,
+	role *rbacv1.Role,
+) ([]string, bool, error) {
+	clusterKey := client.ObjectKey{
+		Namespace: role.Namespace,
+		Name:      role.Labels[metadata.ClusterLabelName],
+	}
+
+	var cluster cnpgv1.Cluster
+	if err := r.Get(ctx, clusterKey, &cluster); err != nil {
+		if apierrs.IsNotFound(err) {
+			return specs.ObjectStoreNamesFromRole(role), true, nil
+		}
+		return nil, false, fmt.Errorf("while getting cluster: %w", err)
+	}
+
+	if !isPluginEnabled(&cluster) {
+		return nil, false, nil
+	}
+
+	barmanObjectKeys := config.NewFromCluster(&cluster).GetReferredBarmanObjectsKey()
+	result := make([]string, 0, len(barmanObjectKeys))
+	for _, barmanObjectKey := range barmanObjectKeys {
+		// The ClusterObjectStores have their own RBAC resources
+		if len(barmanObjectKey.Namespace) > 0 {
+			result = append(result, barmanObjectKey.Name)
+		}
+	}
+
+	return result, len(barmanObjectKeys) > 0, nil
+}
+
+// deleteClusterRBAC revokes every grant given by the plugin to the
+// Cluster having the passed key, as it does not use the plugin anymore
+func (r *ObjectStoreReconciler) deleteClusterRBAC(ctx context.Context, clusterKey client.ObjectKey) error {
+	log.FromContext(ctx).Info("Cluster not using the plugin anymore, removing its RBAC resources",
+		"clusterName", clusterKey.Name)
+
+	if err := rbac.DeleteRole(ctx, r.Client, clusterKey); err != nil {
+		return err
+	}
+
+	return rbac.DeleteClusterObjectStoreRBAC(ctx, r.Client, clusterKey)
+}
+
+// isPluginEnabled checks whether the operator loads the plugin when
+// reconciling the passed Cluster, which is when the plugin hooks run
+func isPluginEnabled(cluster *cnpgv1.Cluster) bool {
+	return slices.Contains(
+		cnpgv1.GetPluginConfigurationEnabledPluginNames(cluster.Spec.Plugins),
+		metadata.PluginName,
+	) || slices.Contains(
+		cnpgv1.GetExternalClustersEnabledPluginNames(cluster.Spec.ExternalClusters),
+		metadata.PluginName,
+	)
+}
+
+// mapClusterToObjectStores enqueues the ObjectStores listed in the
+// plugin-managed Role of a Cluster, which are the ones it was using
+// before its last change
+func (r *ObjectStoreReconciler) mapClusterToObjectStores(ctx context.Context, obj client.Object) []reconcile.Request {
+	cluster, ok := obj.(*cnpgv1.Cluster)
+	if !ok {
+		return nil
+	}
+
+	var role rbacv1.Role
+	if err := r.Get(ctx, client.ObjectKey{
+		Namespace: cluster.Namespace,
+		Name:      specs.GetRBACName(cluster.Name),
+	}, &role); err != nil {
+		if !apierrs.IsNotFound(err) {
+			log.FromContext(ctx).Error(err, "Failed to get the role of the cluster",
+				"clusterName", cluster.Name, "namespace", cluster.Namespace)
+		}
+		return nil
+	}
+
+	return mapRoleToObjectStores(ctx, &role)
+}
+
+// pluginUsageChangedPredicate accepts the updates of the Clusters
+// changing whether they use the plugin, or the object stores they
+// refer to. The Pre hook is not called anymore once a Cluster stops
+// using the plugin, so its grants are revoked by this controller.
+var pluginUsageChangedPredicate = predicate.Funcs{
+	CreateFunc: func(event.CreateEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldCluster, oldOk := e.ObjectOld.(*cnpgv1.Cluster)
+		newCluster, newOk := e.ObjectNew.(*cnpgv1.Cluster)
+		if !oldOk || !newOk {
+			return false
+		}
+		return isPluginEnabled(oldCluster) != isPluginEnabled(newCluster) ||
+			!slices.Equal(
+				config.NewFromCluster(oldCluster).GetReferredBarmanObjectsKey(),
+				config.NewFromCluster(newCluster).GetReferredBarmanObjectsKey(),
+			)
+	},
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
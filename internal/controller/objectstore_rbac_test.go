@@ -0,0 +1,203 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+func newPluginCluster(name, namespace string, parameters map[string]string) *cnpgv1.Cluster {
+	return &cnpgv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: cnpgv1.ClusterSpec{
+			Plugins: []cnpgv1.PluginConfiguration{
+				{
+					Name:          metadata.PluginName,
+					IsWALArchiver: ptr.To(true),
+					Parameters:    parameters,
+				},
+			},
+		},
+	}
+}
+
+func newLabeledRoleBinding(clusterName, namespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      specs.GetRBACName(clusterName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				metadata.ClusterLabelName: clusterName,
+			},
+		},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: "rbac.authorization.k8s.io",
+			Kind:     "Role",
+			Name:     specs.GetRBACName(clusterName),
+		},
+	}
+}
+
+var _ = Describe("ObjectStore RBAC garbage collection", func() {
+	var (
+		ctx     context.Context
+		scheme  *runtime.Scheme
+		storeA  *barmancloudv1.ObjectStore
+		storeB  *barmancloudv1.ObjectStore
+		roleKey client.ObjectKey
+		request reconcile.Request
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme = newFakeScheme()
+		storeA = newTestObjectStore("store-a", "default", "secret-a")
+		storeB = newTestObjectStore("store-b", "default", "secret-b")
+		roleKey = client.ObjectKey{Namespace: "default", Name: specs.GetRBACName("my-cluster")}
+		request = reconcile.Request{NamespacedName: client.ObjectKeyFromObject(storeA)}
+	})
+
+	newReconciler := func(objs ...client.Object) *ObjectStoreReconciler {
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			Build()
+		return &ObjectStoreReconciler{
+			Client:    fakeClient,
+			APIReader: fakeClient,
+			Scheme:    scheme,
+			Recorder:  record.NewFakeRecorder(10),
+		}
+	}
+
+	It("should prune the ObjectStores the Cluster does not refer to anymore", func() {
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*storeA, *storeB})
+		cluster := newPluginCluster("my-cluster", "default", map[string]string{
+			"barmanObjectName": "store-b",
+		})
+		r := newReconciler(role, cluster, storeA, storeB)
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		var updatedRole rbacv1.Role
+		Expect(r.Get(ctx, roleKey, &updatedRole)).To(Succeed())
+		Expect(specs.ObjectStoreNamesFromRole(&updatedRole)).To(ConsistOf("store-b"))
+		Expect(updatedRole.Rules).To(Equal(specs.BuildRoleRules("my-cluster", []barmancloudv1.ObjectStore{*storeB}, false)))
+	})
+
+	It("should delete the Role and the RoleBinding when the plugin is disabled", func() {
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*storeA})
+		cluster := newPluginCluster("my-cluster", "default", map[string]string{
+			"barmanObjectName": "store-a",
+		})
+		cluster.Spec.Plugins[0].Enabled = ptr.To(false)
+		r := newReconciler(role, newLabeledRoleBinding("my-cluster", "default"), cluster, storeA)
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(apierrs.IsNotFound(r.Get(ctx, roleKey, &rbacv1.Role{}))).To(BeTrue())
+		Expect(apierrs.IsNotFound(r.Get(ctx, roleKey, &rbacv1.RoleBinding{}))).To(BeTrue())
+	})
+
+	It("should delete the Role when the Cluster refers to no object store", func() {
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*storeA})
+		cluster := newPluginCluster("my-cluster", "default", nil)
+		r := newReconciler(role, cluster, storeA)
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(apierrs.IsNotFound(r.Get(ctx, roleKey, &rbacv1.Role{}))).To(BeTrue())
+	})
+
+	It("should keep the Role of a Cluster that is not found", func() {
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*storeA})
+		r := newReconciler(role, storeA)
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+
+		var updatedRole rbacv1.Role
+		Expect(r.Get(ctx, roleKey, &updatedRole)).To(Succeed())
+		Expect(specs.ObjectStoreNamesFromRole(&updatedRole)).To(ConsistOf("store-a"))
+	})
+
+	It("should map a Cluster to the ObjectStores listed in its Role", func() {
+		role := newLabeledRole("my-cluster", "default", []barmancloudv1.ObjectStore{*storeA, *storeB})
+		cluster := newPluginCluster("my-cluster", "default", nil)
+		r := newReconciler(role)
+
+		Expect(r.mapClusterToObjectStores(ctx, cluster)).To(ConsistOf(
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(storeA)},
+			reconcile.Request{NamespacedName: client.ObjectKeyFromObject(storeB)},
+		))
+	})
+
+	DescribeTable("pluginUsageChangedPredicate",
+		func(mutate func(cluster *cnpgv1.Cluster), expected bool) {
+			oldCluster := newPluginCluster("my-cluster", "default", map[string]string{
+				"barmanObjectName": "store-a",
+			})
+			newCluster := oldCluster.DeepCopy()
+			mutate(newCluster)
+
+			Expect(pluginUsageChangedPredicate.Update(event.UpdateEvent{
+				ObjectOld: oldCluster,
+				ObjectNew: newCluster,
+			})).To(Equal(expected))
+		},
+		Entry("unrelated change", func(cluster *cnpgv1.Cluster) {
+			cluster.Spec.Instances = 3
+		}, false),
+		Entry("plugin disabled", func(cluster *cnpgv1.Cluster) {
+			cluster.Spec.Plugins[0].Enabled = ptr.To(false)
+		}, true),
+		Entry("plugin removed", func(cluster *cnpgv1.Cluster) {
+			cluster.Spec.Plugins = nil
+		}, true),
+		Entry("object store changed", func(cluster *cnpgv1.Cluster) {
+			cluster.Spec.Plugins[0].Parameters["barmanObjectName"] = "store-b"
+		}, true),
+	)
+})
@@ -0,0 +1,410 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/credentials"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/probe"
+)
+
+// reconcileStatus resolves the credentials of the passed ObjectStore,
+// probes its connectivity and publishes the outcome in the status
+// conditions. It returns the time after which the status should be
+// checked again.
+func (r *ObjectStoreReconciler) reconcileStatus(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) (time.Duration, error) {
+	credentialsCondition, err := r.resolveCredentials(ctx, objectStore)
+	if err != nil {
+		return 0, err
+	}
+
+	reachableCondition, requeueAfter, err := r.reconcileProbe(
+		ctx,
+		objectStore,
+		credentialsCondition.Status == metav1.ConditionTrue,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	readyCondition := buildReadyCondition(objectStore, credentialsCondition, reachableCondition)
+
+	if err := r.patchStatusConditions(
+		ctx,
+		objectStore,
+		credentialsCondition,
+		reachableCondition,
+		readyCondition,
+	); err != nil {
+		return 0, fmt.Errorf("while patching the ObjectStore status: %w", err)
+	}
+
+	return requeueAfter, nil
+}
+
+// resolveCredentials checks that every Secret key referenced by the
+// credentials of the passed ObjectStore exists, building the
+// CredentialsResolved condition
+func (r *ObjectStoreReconciler) resolveCredentials(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) (metav1.Condition, error) {
+	condition := metav1.Condition{
+		Type:               barmancloudv1.ConditionCredentialsResolved,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: objectStore.Generation,
+	}
+
+	inherited, err := credentials.Resolve(
+		ctx,
+		r.APIReader,
+		objectStore.Namespace,
+		&objectStore.Spec.Configuration,
+	)
+	var resolutionError *credentials.ResolutionError
+	if errors.As(err, &resolutionError) {
+		condition.Reason = resolutionError.Reason
+		condition.Message = resolutionError.Message
+		return condition, nil
+	}
+	if err != nil {
+		return condition, err
+	}
+
+	condition.Status = metav1.ConditionTrue
+	condition.Reason = barmancloudv1.ReasonCredentialsResolved
+	condition.Message = "every referenced secret key has been found"
+	if inherited {
+		condition.Reason = barmancloudv1.ReasonCredentialsInherited
+		condition.Message = "the credentials are provided by the environment"
+	}
+
+	return condition, nil
+}
+
+// reconcileProbe manages the Job probing the connectivity to the
+// passed ObjectStore, building the Reachable condition from its
+// outcome. A new probe is started when the previous one is older
+// than the probe interval, or refers to a previous generation.
+func (r *ObjectStoreReconciler) reconcileProbe(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+	credentialsResolved bool,
+) (metav1.Condition, time.Duration, error) {
+	contextLogger := log.FromContext(ctx)
+	interval := specs.GetProbeInterval(objectStore)
+
+	condition := metav1.Condition{
+		Type:               barmancloudv1.ConditionReachable,
+		Status:             metav1.ConditionUnknown,
+		ObservedGeneration: objectStore.Generation,
+	}
+
+	switch {
+	case !objectStore.Spec.Probe.Enabled:
+		condition.Reason = barmancloudv1.ReasonProbeDisabled
+		condition.Message = "the connectivity probe is not enabled"
+		// The Secrets are not watched, and the credentials that are
+		// not resolved yet are checked again at the probe interval
+		var requeueAfter time.Duration
+		if !credentialsResolved {
+			requeueAfter = interval
+		}
+		return condition, requeueAfter, r.deleteProbeJob(ctx, objectStore)
+
+	case !credentialsResolved:
+		condition.Reason = barmancloudv1.ReasonCredentialsNotResolved
+		condition.Message = "the connectivity probe requires the credentials to be resolved"
+		return condition, interval, r.deleteProbeJob(ctx, objectStore)
+
+	case len(r.SidecarImage) == 0:
+		condition.Reason = barmancloudv1.ReasonProbeUnavailable
+		condition.Message = "the plugin operator has no sidecar image to run the connectivity probe"
+		return condition, 0, nil
+	}
+
+	// Until the probe of the current generation completes, keep the
+	// outcome of the last one only if it refers to the same generation
+	condition.Reason = barmancloudv1.ReasonProbePending
+	condition.Message = "waiting for the connectivity probe to complete"
+	if previous := meta.FindStatusCondition(
+		objectStore.Status.Conditions,
+		barmancloudv1.ConditionReachable,
+	); previous != nil && previous.Status != metav1.ConditionUnknown &&
+		previous.ObservedGeneration == objectStore.Generation {
+		condition = *previous
+	}
+
+	var job batchv1.Job
+	err := r.Get(ctx, client.ObjectKey{
+		Namespace: objectStore.Namespace,
+		Name:      specs.GetProbeJobName(objectStore.Name),
+	}, &job)
+	if apierrs.IsNotFound(err) {
+		return condition, interval, r.createProbeJob(ctx, objectStore)
+	}
+	if err != nil {
+		return condition, 0, fmt.Errorf("while getting the probe job: %w", err)
+	}
+
+	if getProbeJobGeneration(&job) != objectStore.Generation {
+		contextLogger.Info("Deleting the probe job of a previous generation", "jobName", job.Name)
+		return condition, finishedJobRequeueInterval, r.deleteProbeJob(ctx, objectStore)
+	}
+
+	finishedCondition := getJobFinishedCondition(&job)
+	if finishedCondition == nil {
+		return condition, interval, nil
+	}
+
+	condition, err = r.getProbeJobOutcome(ctx, objectStore, &job, finishedCondition)
+	if err != nil {
+		return condition, 0, err
+	}
+
+	if nextRun := finishedCondition.LastTransitionTime.Add(interval); time.Now().Before(nextRun) {
+		return condition, time.Until(nextRun), nil
+	}
+
+	return condition, finishedJobRequeueInterval, r.deleteProbeJob(ctx, objectStore)
+}
+
+// getProbeJobOutcome builds the Reachable condition from the outcome
+// of a finished probe Job, reading the reason of a failure from the
+// termination message of its Pod
+func (r *ObjectStoreReconciler) getProbeJobOutcome(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+	job *batchv1.Job,
+	finishedCondition *batchv1.JobCondition,
+) (metav1.Condition, error) {
+	condition := metav1.Condition{
+		Type:               barmancloudv1.ConditionReachable,
+		Status:             metav1.ConditionTrue,
+		Reason:             barmancloudv1.ReasonProbeSucceeded,
+		Message:            "the destination path has been listed",
+		ObservedGeneration: objectStore.Generation,
+	}
+	if objectStore.Spec.Probe.WriteCheck {
+		condition.Message = "the destination path has been listed and written"
+	}
+
+	if finishedCondition.Type == batchv1.JobComplete {
+		return condition, nil
+	}
+
+	condition.Status = metav1.ConditionFalse
+	condition.Reason = barmancloudv1.ReasonProbeFailed
+	condition.Message = finishedCondition.Message
+
+	result, err := getJobTerminationResult(ctx, r.APIReader, job)
+	if err != nil {
+		return condition, fmt.Errorf("while reading the probe job outcome: %w", err)
+	}
+	if result != nil {
+		condition.Reason = result.Reason
+		condition.Message = result.Message
+	}
+
+	return condition, nil
+}
+
+// getJobTerminationResult reads the Result written by a failed Job in
+// the termination message of its Pod, returning nil if there is none
+func getJobTerminationResult(
+	ctx context.Context,
+	reader client.Reader,
+	job *batchv1.Job,
+) (*probe.Result, error) {
+	var podList corev1.PodList
+	if err := reader.List(
+		ctx,
+		&podList,
+		client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name},
+	); err != nil {
+		return nil, fmt.Errorf("while listing the job pods: %w", err)
+	}
+
+	for i := range podList.Items {
+		for _, containerStatus := range podList.Items[i].Status.ContainerStatuses {
+			if containerStatus.State.Terminated == nil {
+				continue
+			}
+
+			result, err := probe.ParseTerminationMessage(containerStatus.State.Terminated.Message)
+			if err != nil {
+				continue
+			}
+
+			return result, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// createProbeJob starts the Job probing the connectivity to the
+// passed ObjectStore
+func (r *ObjectStoreReconciler) createProbeJob(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	imagePullSecrets, err := common.GetImagePullSecrets(ctx, r.Client, objectStore)
+	if err != nil {
+		return err
+	}
+
+	job, err := specs.BuildProbeJob(objectStore, r.SidecarImage, imagePullSecrets)
+	if err != nil {
+		return err
+	}
+
+	owner := objectStore.DeepCopy()
+	gvk, err := apiutil.GVKForObject(owner, r.Scheme)
+	if err != nil {
+		return err
+	}
+	owner.SetGroupVersionKind(gvk)
+	if err := specs.SetControllerReference(owner, job); err != nil {
+		return err
+	}
+
+	contextLogger.Info("Starting the probe job", "jobName", job.Name)
+	if err := r.Create(ctx, job); err != nil && !apierrs.IsAlreadyExists(err) {
+		return fmt.Errorf("while creating the probe job: %w", err)
+	}
+
+	return nil
+}
+
+// deleteProbeJob removes the Job probing the connectivity to the
+// passed ObjectStore, if any
+func (r *ObjectStoreReconciler) deleteProbeJob(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) error {
+	job := batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: objectStore.Namespace,
+			Name:      specs.GetProbeJobName(objectStore.Name),
+		},
+	}
+	if err := r.Delete(
+		ctx,
+		&job,
+		client.PropagationPolicy(metav1.DeletePropagationBackground),
+	); err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("while deleting the probe job: %w", err)
+	}
+
+	return nil
+}
+
+// patchStatusConditions sets the passed conditions in the ObjectStore
+// status, patching it only when something changed
+func (r *ObjectStoreReconciler) patchStatusConditions(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+	conditions ...metav1.Condition,
+) error {
+	original := objectStore.DeepCopy()
+	for _, condition := range conditions {
+		meta.SetStatusCondition(&objectStore.Status.Conditions, condition)
+	}
+	objectStore.Status.ObservedGeneration = objectStore.Generation
+
+	if equality.Semantic.DeepEqual(original.Status, objectStore.Status) {
+		return nil
+	}
+
+	return r.Status().Patch(ctx, objectStore, client.MergeFrom(original))
+}
+
+// buildReadyCondition builds the Ready condition of an ObjectStore,
+// which summarizes the CredentialsResolved and Reachable ones
+func buildReadyCondition(
+	objectStore *barmancloudv1.ObjectStore,
+	credentialsCondition metav1.Condition,
+	reachableCondition metav1.Condition,
+) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               barmancloudv1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             barmancloudv1.ReasonReady,
+		Message:            "the object store is ready",
+		ObservedGeneration: objectStore.Generation,
+	}
+
+	switch {
+	case credentialsCondition.Status != metav1.ConditionTrue:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = barmancloudv1.ReasonCredentialsNotResolved
+		condition.Message = credentialsCondition.Message
+
+	case reachableCondition.Status == metav1.ConditionFalse:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = barmancloudv1.ReasonNotReachable
+		condition.Message = reachableCondition.Message
+
+	case reachableCondition.Status == metav1.ConditionUnknown &&
+		reachableCondition.Reason != barmancloudv1.ReasonProbeDisabled:
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = reachableCondition.Reason
+		condition.Message = reachableCondition.Message
+	}
+
+	return condition
+}
+
+// getProbeJobGeneration returns the generation of the ObjectStore
+// probed by the passed Job
+func getProbeJobGeneration(job *batchv1.Job) int64 {
+	generation, err := strconv.ParseInt(job.Annotations[metadata.ProbeGenerationAnnotationName], 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return generation
+}
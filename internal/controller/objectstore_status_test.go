@@ -0,0 +1,354 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+func newProbedObjectStore() *barmancloudv1.ObjectStore {
+	return &barmancloudv1.ObjectStore{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "my-store",
+			Namespace:  "default",
+			Generation: 2,
+		},
+		Spec: barmancloudv1.ObjectStoreSpec{
+			Configuration: barmanapi.BarmanObjectStoreConfiguration{
+				DestinationPath: "s3://bucket/path",
+				BarmanCredentials: barmanapi.BarmanCredentials{
+					AWS: &barmanapi.S3Credentials{
+						AccessKeyIDReference: &machineryapi.SecretKeySelector{
+							LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+							Key:                  "ACCESS_KEY_ID",
+						},
+						SecretAccessKeyReference: &machineryapi.SecretKeySelector{
+							LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+							Key:                  "ACCESS_SECRET_KEY",
+						},
+					},
+				},
+			},
+			Probe: barmancloudv1.ProbeConfiguration{
+				Enabled: true,
+			},
+		},
+	}
+}
+
+func newCredentialsSecret(keys ...string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "aws-creds",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{},
+	}
+	for _, key := range keys {
+		secret.Data[key] = []byte("value")
+	}
+	return secret
+}
+
+func newFinishedProbeJob(
+	objectStore *barmancloudv1.ObjectStore,
+	conditionType batchv1.JobConditionType,
+	finishedAt time.Time,
+) *batchv1.Job {
+	job, err := specs.BuildProbeJob(objectStore, "sidecar:latest", nil)
+	Expect(err).NotTo(HaveOccurred())
+	job.Status.Conditions = []batchv1.JobCondition{
+		{
+			Type:               conditionType,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(finishedAt),
+			Message:            "Job has reached the specified backoff limit",
+		},
+	}
+	return job
+}
+
+var _ = Describe("ObjectStore status", func() {
+	var (
+		ctx         context.Context
+		scheme      *runtime.Scheme
+		objectStore *barmancloudv1.ObjectStore
+		jobKey      client.ObjectKey
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		scheme = newFakeScheme()
+		objectStore = newProbedObjectStore()
+		jobKey = client.ObjectKey{Namespace: "default", Name: "my-store-barman-cloud-probe"}
+	})
+
+	newReconciler := func(objs ...client.Object) *ObjectStoreReconciler {
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			Build()
+		return &ObjectStoreReconciler{
+			Client:       fakeClient,
+			APIReader:    fakeClient,
+			Scheme:       scheme,
+			SidecarImage: "sidecar:latest",
+		}
+	}
+
+	getStatus := func(r *ObjectStoreReconciler) barmancloudv1.ObjectStoreStatus {
+		var updated barmancloudv1.ObjectStore
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		return updated.Status
+	}
+
+	expectCondition := func(
+		status barmancloudv1.ObjectStoreStatus,
+		conditionType string,
+		conditionStatus metav1.ConditionStatus,
+		reason string,
+	) {
+		condition := meta.FindStatusCondition(status.Conditions, conditionType)
+		Expect(condition).NotTo(BeNil())
+		Expect(condition.Status).To(Equal(conditionStatus))
+		Expect(condition.Reason).To(Equal(reason))
+		Expect(condition.ObservedGeneration).To(Equal(objectStore.Generation))
+	}
+
+	It("starts the probe once the credentials are resolved", func() {
+		r := newReconciler(objectStore, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		requeueAfter, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(specs.DefaultProbeInterval))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, jobKey, &job)).To(Succeed())
+		Expect(job.Annotations).To(HaveKeyWithValue(metadata.ProbeGenerationAnnotationName, "2"))
+		Expect(job.OwnerReferences).To(HaveLen(1))
+		Expect(job.OwnerReferences[0].Kind).To(Equal("ObjectStore"))
+
+		status := getStatus(r)
+		Expect(status.ObservedGeneration).To(Equal(int64(2)))
+		expectCondition(status, barmancloudv1.ConditionCredentialsResolved,
+			metav1.ConditionTrue, barmancloudv1.ReasonCredentialsResolved)
+		expectCondition(status, barmancloudv1.ConditionReachable,
+			metav1.ConditionUnknown, barmancloudv1.ReasonProbePending)
+		expectCondition(status, barmancloudv1.ConditionReady,
+			metav1.ConditionUnknown, barmancloudv1.ReasonProbePending)
+	})
+
+	It("passes the image pull secrets of the clusters using the object store to the probe", func() {
+		usingCluster := newPluginCluster("cluster-a", "default", map[string]string{
+			"barmanObjectName": "my-store",
+		})
+		usingCluster.Spec.ImagePullSecrets = []cnpgv1.LocalObjectReference{{Name: "registry-a"}}
+		otherCluster := newPluginCluster("cluster-b", "default", map[string]string{
+			"barmanObjectName": "other-store",
+		})
+		otherCluster.Spec.ImagePullSecrets = []cnpgv1.LocalObjectReference{{Name: "registry-b"}}
+		r := newReconciler(
+			objectStore,
+			newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"),
+			usingCluster,
+			otherCluster,
+		)
+
+		_, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, jobKey, &job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.ImagePullSecrets).To(ConsistOf(
+			corev1.LocalObjectReference{Name: "registry-a"},
+		))
+	})
+
+	It("reports a missing secret", func() {
+		r := newReconciler(objectStore)
+
+		_, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		var job batchv1.Job
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &job))).To(BeTrue())
+
+		status := getStatus(r)
+		expectCondition(status, barmancloudv1.ConditionCredentialsResolved,
+			metav1.ConditionFalse, barmancloudv1.ReasonSecretNotFound)
+		expectCondition(status, barmancloudv1.ConditionReachable,
+			metav1.ConditionUnknown, barmancloudv1.ReasonCredentialsNotResolved)
+		expectCondition(status, barmancloudv1.ConditionReady,
+			metav1.ConditionFalse, barmancloudv1.ReasonCredentialsNotResolved)
+	})
+
+	It("reports a missing secret key", func() {
+		r := newReconciler(objectStore, newCredentialsSecret("ACCESS_KEY_ID"))
+
+		_, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		status := getStatus(r)
+		expectCondition(status, barmancloudv1.ConditionCredentialsResolved,
+			metav1.ConditionFalse, barmancloudv1.ReasonSecretKeyNotFound)
+		Expect(meta.FindStatusCondition(status.Conditions, barmancloudv1.ConditionCredentialsResolved).Message).
+			To(ContainSubstring("ACCESS_SECRET_KEY"))
+	})
+
+	It("does not require secrets for credentials inherited from the IAM role", func() {
+		objectStore.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+		r := newReconciler(objectStore)
+
+		_, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		expectCondition(getStatus(r), barmancloudv1.ConditionCredentialsResolved,
+			metav1.ConditionTrue, barmancloudv1.ReasonCredentialsInherited)
+	})
+
+	It("reports a successful probe", func() {
+		job := newFinishedProbeJob(objectStore, batchv1.JobComplete, time.Now())
+		r := newReconciler(objectStore, job, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		requeueAfter, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeNumerically("~", specs.DefaultProbeInterval, time.Minute))
+
+		status := getStatus(r)
+		expectCondition(status, barmancloudv1.ConditionReachable,
+			metav1.ConditionTrue, barmancloudv1.ReasonProbeSucceeded)
+		expectCondition(status, barmancloudv1.ConditionReady,
+			metav1.ConditionTrue, barmancloudv1.ReasonReady)
+	})
+
+	It("reads the reason of a failed probe from the termination message", func() {
+		job := newFinishedProbeJob(objectStore, batchv1.JobFailed, time.Now())
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-store-barman-cloud-probe-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "plugin-barman-cloud",
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: 1,
+								Message:  `{"reason":"BucketNotFound","message":"bucket bucket does not exist"}`,
+							},
+						},
+					},
+				},
+			},
+		}
+		r := newReconciler(objectStore, job, pod, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		_, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		status := getStatus(r)
+		expectCondition(status, barmancloudv1.ConditionReachable,
+			metav1.ConditionFalse, barmancloudv1.ReasonBucketNotFound)
+		expectCondition(status, barmancloudv1.ConditionReady,
+			metav1.ConditionFalse, barmancloudv1.ReasonNotReachable)
+		Expect(meta.FindStatusCondition(status.Conditions, barmancloudv1.ConditionReady).Message).
+			To(Equal("bucket bucket does not exist"))
+	})
+
+	It("falls back to the job condition when the termination message is missing", func() {
+		job := newFinishedProbeJob(objectStore, batchv1.JobFailed, time.Now())
+		r := newReconciler(objectStore, job, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		_, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+
+		expectCondition(getStatus(r), barmancloudv1.ConditionReachable,
+			metav1.ConditionFalse, barmancloudv1.ReasonProbeFailed)
+	})
+
+	It("deletes a finished probe job once the interval elapsed", func() {
+		job := newFinishedProbeJob(objectStore, batchv1.JobComplete, time.Now().Add(-time.Hour))
+		r := newReconciler(objectStore, job, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		requeueAfter, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(finishedJobRequeueInterval))
+
+		var existing batchv1.Job
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &existing))).To(BeTrue())
+		expectCondition(getStatus(r), barmancloudv1.ConditionReachable,
+			metav1.ConditionTrue, barmancloudv1.ReasonProbeSucceeded)
+	})
+
+	It("deletes the probe job of a previous generation", func() {
+		job := newFinishedProbeJob(objectStore, batchv1.JobComplete, time.Now())
+		objectStore.Generation = 3
+		r := newReconciler(objectStore, job, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		requeueAfter, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(Equal(finishedJobRequeueInterval))
+
+		var existing batchv1.Job
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &existing))).To(BeTrue())
+		expectCondition(getStatus(r), barmancloudv1.ConditionReachable,
+			metav1.ConditionUnknown, barmancloudv1.ReasonProbePending)
+	})
+
+	It("only depends on the credentials when the probe is not enabled", func() {
+		objectStore.Spec.Probe.Enabled = false
+		r := newReconciler(objectStore, newCredentialsSecret("ACCESS_KEY_ID", "ACCESS_SECRET_KEY"))
+
+		requeueAfter, err := r.reconcileStatus(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requeueAfter).To(BeZero())
+
+		var job batchv1.Job
+		Expect(apierrs.IsNotFound(r.Get(ctx, jobKey, &job))).To(BeTrue())
+
+		status := getStatus(r)
+		expectCondition(status, barmancloudv1.ConditionReachable,
+			metav1.ConditionUnknown, barmancloudv1.ReasonProbeDisabled)
+		expectCondition(status, barmancloudv1.ConditionReady,
+			metav1.ConditionTrue, barmancloudv1.ReasonReady)
+	})
+})
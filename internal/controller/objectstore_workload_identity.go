@@ -0,0 +1,105 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// reconcileWorkloadIdentity ensures that the dedicated ServiceAccount
+// of the workload identity of the passed ObjectStore exists and carries
+// the requested annotations. A ServiceAccount with the same name not
+// controlled by the ObjectStore is left untouched, and reported in an
+// event.
+func (r *ObjectStoreReconciler) reconcileWorkloadIdentity(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	serviceAccount := specs.BuildWorkloadIdentityServiceAccount(objectStore)
+	if serviceAccount == nil {
+		return nil
+	}
+
+	var current corev1.ServiceAccount
+	err := r.Get(ctx, client.ObjectKeyFromObject(serviceAccount), &current)
+	switch {
+	case apierrs.IsNotFound(err):
+		owner := objectStore.DeepCopy()
+		gvk, err := apiutil.GVKForObject(owner, r.Scheme)
+		if err != nil {
+			return err
+		}
+		owner.SetGroupVersionKind(gvk)
+		if err := specs.SetControllerReference(owner, serviceAccount); err != nil {
+			return err
+		}
+
+		contextLogger.Info("Creating the workload identity service account",
+			"serviceAccountName", serviceAccount.Name)
+		if err := r.Create(ctx, serviceAccount); err != nil && !apierrs.IsAlreadyExists(err) {
+			return fmt.Errorf("while creating the workload identity service account: %w", err)
+		}
+		return nil
+
+	case err != nil:
+		return fmt.Errorf("while getting the workload identity service account: %w", err)
+	}
+
+	if !metav1.IsControlledBy(&current, objectStore) {
+		message := fmt.Sprintf(
+			"the ServiceAccount %s is not managed by this ObjectStore, its annotations are not updated",
+			current.Name)
+		contextLogger.Info("Workload identity service account not managed by the plugin",
+			"serviceAccountName", current.Name)
+		r.Recorder.Event(objectStore, corev1.EventTypeWarning, "ServiceAccountNotManaged", message)
+		return nil
+	}
+
+	if maps.Equal(current.Annotations, serviceAccount.Annotations) &&
+		maps.Equal(current.Labels, serviceAccount.Labels) {
+		return nil
+	}
+
+	original := current.DeepCopy()
+	current.Annotations = serviceAccount.Annotations
+	current.Labels = serviceAccount.Labels
+
+	contextLogger.Info("Updating the workload identity service account",
+		"serviceAccountName", current.Name)
+	if err := r.Patch(ctx, &current, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf("while patching the workload identity service account: %w", err)
+	}
+
+	return nil
+}
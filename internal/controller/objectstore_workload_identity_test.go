@@ -0,0 +1,121 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("ObjectStore workload identity", func() {
+	var (
+		ctx         context.Context
+		objectStore *barmancloudv1.ObjectStore
+		recorder    *record.FakeRecorder
+	)
+
+	serviceAccountKey := client.ObjectKey{Namespace: "default", Name: "backup-identity"}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objectStore = newTestObjectStore("my-store", "default", "my-secret")
+		objectStore.UID = "store-uid"
+		objectStore.Spec.WorkloadIdentity = &barmancloudv1.WorkloadIdentityConfiguration{
+			Audience:           "sts.amazonaws.com",
+			ServiceAccountName: "backup-identity",
+			Annotations: map[string]string{
+				"eks.amazonaws.com/role-arn": "arn:aws:iam::123456789012:role/backup",
+			},
+		}
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	newReconciler := func(objs ...client.Object) *ObjectStoreReconciler {
+		scheme := newFakeScheme()
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objs...).
+			Build()
+		return &ObjectStoreReconciler{
+			Client:    fakeClient,
+			APIReader: fakeClient,
+			Scheme:    scheme,
+			Recorder:  recorder,
+		}
+	}
+
+	It("creates the dedicated ServiceAccount with the annotations", func() {
+		r := newReconciler(objectStore)
+		Expect(r.reconcileWorkloadIdentity(ctx, objectStore)).To(Succeed())
+
+		var serviceAccount corev1.ServiceAccount
+		Expect(r.Get(ctx, serviceAccountKey, &serviceAccount)).To(Succeed())
+		Expect(serviceAccount.Annotations).To(HaveKeyWithValue(
+			"eks.amazonaws.com/role-arn", "arn:aws:iam::123456789012:role/backup"))
+		Expect(serviceAccount.Labels).To(HaveKeyWithValue(metadata.ObjectStoreLabelName, "my-store"))
+		Expect(metav1.IsControlledBy(&serviceAccount, objectStore)).To(BeTrue())
+	})
+
+	It("updates the annotations of the dedicated ServiceAccount", func() {
+		r := newReconciler(objectStore)
+		Expect(r.reconcileWorkloadIdentity(ctx, objectStore)).To(Succeed())
+
+		objectStore.Spec.WorkloadIdentity.Annotations["eks.amazonaws.com/role-arn"] = "arn:aws:iam::123456789012:role/other"
+		Expect(r.reconcileWorkloadIdentity(ctx, objectStore)).To(Succeed())
+
+		var serviceAccount corev1.ServiceAccount
+		Expect(r.Get(ctx, serviceAccountKey, &serviceAccount)).To(Succeed())
+		Expect(serviceAccount.Annotations).To(HaveKeyWithValue(
+			"eks.amazonaws.com/role-arn", "arn:aws:iam::123456789012:role/other"))
+	})
+
+	It("does not touch a ServiceAccount not managed by the ObjectStore", func() {
+		existing := &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-identity"},
+		}
+		r := newReconciler(objectStore, existing)
+		Expect(r.reconcileWorkloadIdentity(ctx, objectStore)).To(Succeed())
+
+		var serviceAccount corev1.ServiceAccount
+		Expect(r.Get(ctx, serviceAccountKey, &serviceAccount)).To(Succeed())
+		Expect(serviceAccount.Annotations).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring("ServiceAccountNotManaged")))
+	})
+
+	It("does nothing without a dedicated ServiceAccount", func() {
+		objectStore.Spec.WorkloadIdentity.ServiceAccountName = ""
+		r := newReconciler(objectStore)
+		Expect(r.reconcileWorkloadIdentity(ctx, objectStore)).To(Succeed())
+
+		var serviceAccounts corev1.ServiceAccountList
+		Expect(r.List(ctx, &serviceAccounts)).To(Succeed())
+		Expect(serviceAccounts.Items).To(BeEmpty())
+	})
+})
@@ -0,0 +1,172 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// recordSecretsResourceVersion records in the status of the object
+// store having the passed key the resource versions of the Secrets
+// referenced by its credentials and endpoint CA. When any of them
+// changed, the rotation time is recorded too, and an event is
+// emitted on the passed object.
+//
+// The instance sidecars do not depend on this, as they watch the
+// Secrets they cache, which are refreshed as soon as they change.
+func recordSecretsResourceVersion(
+	ctx context.Context,
+	c client.Client,
+	apiReader client.Reader,
+	recorder record.EventRecorder,
+	objectStoreKey client.ObjectKey,
+	eventObject runtime.Object,
+) error {
+	contextLogger := log.FromContext(ctx)
+
+	// For ClusterObjectStores, the namespace of the returned object
+	// is the one containing the Secrets
+	objectStore, err := common.GetObjectStore(ctx, c, objectStoreKey)
+	if err != nil {
+		return err
+	}
+
+	versions := make(map[string]string)
+	var rotatedSecrets []string
+	for _, name := range specs.CollectReferencedSecretNames(&objectStore.Spec.Configuration) {
+		var secret metav1.PartialObjectMetadata
+		secret.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Secret"))
+		if err := apiReader.Get(ctx, client.ObjectKey{
+			Namespace: objectStore.Namespace,
+			Name:      name,
+		}, &secret); err != nil {
+			if apierrs.IsNotFound(err) {
+				// Reported by the CredentialsResolved condition
+				continue
+			}
+			return fmt.Errorf("while getting secret %s: %w", name, err)
+		}
+
+		versions[name] = secret.ResourceVersion
+		if previous, ok := objectStore.Status.SecretsResourceVersion[name]; ok && previous != secret.ResourceVersion {
+			rotatedSecrets = append(rotatedSecrets, name)
+		}
+	}
+
+	if maps.Equal(versions, objectStore.Status.SecretsResourceVersion) {
+		return nil
+	}
+
+	if err := common.UpdateObjectStoreStatus(
+		ctx,
+		c,
+		objectStoreKey,
+		func(status *barmancloudv1.ObjectStoreStatus) {
+			status.SecretsResourceVersion = versions
+			if len(rotatedSecrets) > 0 {
+				status.LastSecretsRotationTime = ptr.To(metav1.Now())
+			}
+		},
+	); err != nil {
+		return fmt.Errorf("while recording the secrets resource version: %w", err)
+	}
+
+	if len(rotatedSecrets) > 0 {
+		contextLogger.Info("Secrets rotation detected", "secretNames", rotatedSecrets)
+		recorder.Event(eventObject, corev1.EventTypeNormal, "SecretsRotated",
+			fmt.Sprintf("the Secrets have been rotated: %s", strings.Join(rotatedSecrets, ", ")))
+	}
+
+	return nil
+}
+
+// referencesSecret checks if the passed object store specification
+// references the Secret having the passed name
+func referencesSecret(spec *barmancloudv1.ObjectStoreSpec, secretName string) bool {
+	return slices.Contains(specs.CollectReferencedSecretNames(&spec.Configuration), secretName)
+}
+
+// mapSecretToObjectStores enqueues the ObjectStores referencing a
+// Secret from their credentials or endpoint CA
+func (r *ObjectStoreReconciler) mapSecretToObjectStores(ctx context.Context, obj client.Object) []reconcile.Request {
+	var objectStoreList barmancloudv1.ObjectStoreList
+	if err := r.List(ctx, &objectStoreList, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "while listing the ObjectStores referencing a secret",
+			"secretName", obj.GetName(), "namespace", obj.GetNamespace())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range objectStoreList.Items {
+		objectStore := &objectStoreList.Items[i]
+		if referencesSecret(&objectStore.Spec, obj.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(objectStore),
+			})
+		}
+	}
+
+	return requests
+}
+
+// mapSecretToClusterObjectStores enqueues the ClusterObjectStores
+// referencing a Secret of their credentials namespace
+func (r *ClusterObjectStoreReconciler) mapSecretToClusterObjectStores(
+	ctx context.Context,
+	obj client.Object,
+) []reconcile.Request {
+	var clusterObjectStoreList barmancloudv1.ClusterObjectStoreList
+	if err := r.List(ctx, &clusterObjectStoreList); err != nil {
+		log.FromContext(ctx).Error(err, "while listing the ClusterObjectStores referencing a secret",
+			"secretName", obj.GetName(), "namespace", obj.GetNamespace())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range clusterObjectStoreList.Items {
+		clusterObjectStore := &clusterObjectStoreList.Items[i]
+		if clusterObjectStore.Spec.CredentialsNamespace == obj.GetNamespace() &&
+			referencesSecret(&clusterObjectStore.Spec.ObjectStoreSpec, obj.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: client.ObjectKeyFromObject(clusterObjectStore),
+			})
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,138 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+)
+
+var _ = Describe("Secrets rotation", func() {
+	var (
+		ctx         context.Context
+		objectStore *barmancloudv1.ObjectStore
+		secret      *corev1.Secret
+		recorder    *record.FakeRecorder
+		fakeClient  client.Client
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objectStore = newTestObjectStore("my-store", "default", "my-secret")
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-secret",
+				Namespace: "default",
+			},
+			StringData: map[string]string{"ACCESS_KEY_ID": "first"},
+		}
+		recorder = record.NewFakeRecorder(10)
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(newFakeScheme()).
+			WithObjects(objectStore, secret).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			Build()
+	})
+
+	recordVersions := func() *barmancloudv1.ObjectStore {
+		ExpectWithOffset(1, recordSecretsResourceVersion(
+			ctx,
+			fakeClient,
+			fakeClient,
+			recorder,
+			client.ObjectKeyFromObject(objectStore),
+			objectStore,
+		)).To(Succeed())
+
+		var updated barmancloudv1.ObjectStore
+		ExpectWithOffset(1, fakeClient.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		return &updated
+	}
+
+	It("records the resource version of the referenced secrets", func() {
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+
+		updated := recordVersions()
+		Expect(updated.Status.SecretsResourceVersion).To(Equal(map[string]string{
+			"my-secret": secret.ResourceVersion,
+		}))
+		Expect(updated.Status.LastSecretsRotationTime).To(BeNil())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("records the rotation time and emits an event when a secret changes", func() {
+		recordVersions()
+
+		Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+		secret.StringData = map[string]string{"ACCESS_KEY_ID": "second"}
+		Expect(fakeClient.Update(ctx, secret)).To(Succeed())
+
+		updated := recordVersions()
+		Expect(updated.Status.SecretsResourceVersion).To(HaveKeyWithValue("my-secret", secret.ResourceVersion))
+		Expect(updated.Status.LastSecretsRotationTime).NotTo(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring("SecretsRotated")))
+	})
+
+	It("ignores the secrets that do not exist yet", func() {
+		Expect(fakeClient.Delete(ctx, secret)).To(Succeed())
+
+		updated := recordVersions()
+		Expect(updated.Status.SecretsResourceVersion).To(BeEmpty())
+	})
+
+	It("maps a secret to the ObjectStores referencing it", func() {
+		otherStore := newTestObjectStore("other-store", "default", "other-secret")
+		Expect(fakeClient.Create(ctx, otherStore)).To(Succeed())
+
+		r := &ObjectStoreReconciler{Client: fakeClient}
+		Expect(r.mapSecretToObjectStores(ctx, secret)).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(objectStore),
+		}))
+	})
+
+	It("maps a secret to the ClusterObjectStores reading it from their credentials namespace", func() {
+		clusterObjectStore := &barmancloudv1.ClusterObjectStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared-store"},
+			Spec: barmancloudv1.ClusterObjectStoreSpec{
+				CredentialsNamespace: "default",
+				ObjectStoreSpec:      objectStore.Spec,
+			},
+		}
+		Expect(fakeClient.Create(ctx, clusterObjectStore)).To(Succeed())
+
+		r := &ClusterObjectStoreReconciler{Client: fakeClient}
+		Expect(r.mapSecretToClusterObjectStores(ctx, secret)).To(ConsistOf(reconcile.Request{
+			NamespacedName: client.ObjectKeyFromObject(clusterObjectStore),
+		}))
+
+		secret.Namespace = "other"
+		Expect(r.mapSecretToClusterObjectStores(ctx, secret)).To(BeEmpty())
+	})
+})
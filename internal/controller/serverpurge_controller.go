@@ -0,0 +1,470 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/serverpurge"
+)
+
+const (
+	// serverPurgeCheckInterval is the time between two checks of a
+	// running server purge Job
+	serverPurgeCheckInterval = 30 * time.Second
+
+	// serverPurgeRetryInterval is the time to wait before retrying a
+	// failed server purge
+	serverPurgeRetryInterval = 5 * time.Minute
+
+	// maxServerPurgeAttempts is the number of failed runs after which
+	// a server purge is not retried anymore
+	maxServerPurgeAttempts = 5
+
+	// maxFinishedServerPurges is the number of completed, failed or
+	// refused purges kept in the status of an object store
+	maxFinishedServerPurges = 10
+
+	// reasonServerPurged is the reason of the event reporting a
+	// completed server purge
+	reasonServerPurged = "ServerPurged"
+
+	// reasonServerPurgeRefused is the reason of the event reporting a
+	// server purge refused because another Cluster writes there
+	reasonServerPurgeRefused = "ServerPurgeRefused"
+
+	// reasonServerPurgeFailed is the reason of the event reporting a
+	// server purge that is not retried anymore
+	reasonServerPurgeFailed = "ServerPurgeFailed"
+)
+
+// ServerPurgeReconciler runs the purges of the data of the deleted
+// Clusters recorded in the status of the ObjectStores and of the
+// ClusterObjectStores, one at a time for each object store.
+type ServerPurgeReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// APIReader reads the objects that are not worth caching, such
+	// as the Pods of the purge Jobs
+	APIReader client.Reader
+
+	// SidecarImage is the image used to run the purge Jobs
+	SidecarImage string
+
+	// Recorder reports the events about the purges
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=objectstores;clusterobjectstores,verbs=get;list;watch
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=objectstores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=barmancloud.cnpg.io,resources=clusterobjectstores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=postgresql.cnpg.io,resources=clusters,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile runs the earliest pending server purge of the object
+// store. Requests without a namespace refer to a ClusterObjectStore.
+func (r *ServerPurgeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx).WithValues(
+		"objectStoreName", req.Name,
+		"namespace", req.Namespace,
+	)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	if len(r.SidecarImage) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	objectStore, err := common.GetObjectStore(ctx, r.Client, req.NamespacedName)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	purge := getNextServerPurge(&objectStore.Status)
+	if purge == nil {
+		return ctrl.Result{}, nil
+	}
+	contextLogger = contextLogger.WithValues(
+		"serverName", purge.ServerName,
+		"clusterName", purge.ClusterName,
+		"clusterNamespace", purge.ClusterNamespace,
+	)
+	ctx = log.IntoContext(ctx, contextLogger)
+
+	if wait := time.Until(purge.ScheduledTime.Time); wait > 0 {
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+
+	var job batchv1.Job
+	err = r.Get(ctx, client.ObjectKey{
+		Namespace: objectStore.Namespace,
+		Name:      specs.GetServerPurgeJobName(objectStore.Name),
+	}, &job)
+	if apierrs.IsNotFound(err) {
+		return r.startServerPurge(ctx, req.NamespacedName, objectStore, purge)
+	}
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("while getting the server purge job: %w", err)
+	}
+
+	if job.Annotations[metadata.ServerPurgeClusterUIDAnnotationName] != purge.ClusterUID {
+		contextLogger.Info("Removing a server purge job not matching the next purge", "jobName", job.Name)
+		return ctrl.Result{RequeueAfter: finishedJobRequeueInterval}, r.deleteServerPurgeJob(ctx, &job)
+	}
+
+	finishedCondition := getJobFinishedCondition(&job)
+	if finishedCondition == nil {
+		return ctrl.Result{RequeueAfter: serverPurgeCheckInterval}, nil
+	}
+
+	reason, message := serverpurge.ReasonPurgeFailed, finishedCondition.Message
+	result, err := getJobTerminationResult(ctx, r.APIReader, &job)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("while reading the server purge job outcome: %w", err)
+	}
+	if result != nil {
+		reason, message = result.Reason, result.Message
+	}
+
+	if finishedCondition.Type == batchv1.JobComplete {
+		contextLogger.Info("Server data purged from the object store", "message", message)
+		if err := r.finishServerPurge(
+			ctx,
+			req.NamespacedName,
+			purge,
+			barmancloudv1.ServerPurgePhaseCompleted,
+			message,
+		); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.recordEvent(ctx, req.NamespacedName, corev1.EventTypeNormal, reasonServerPurged,
+			fmt.Sprintf("Purged the data of the deleted cluster %s/%s: %s",
+				purge.ClusterNamespace, purge.ClusterName, message))
+		return ctrl.Result{RequeueAfter: finishedJobRequeueInterval}, r.deleteServerPurgeJob(ctx, &job)
+	}
+
+	attempts := purge.Attempts + 1
+	if attempts >= maxServerPurgeAttempts {
+		contextLogger.Info("Server data purge failed too many times, giving up",
+			"reason", reason,
+			"message", message,
+			"attempts", attempts)
+		purge.Attempts = attempts
+		if err := r.finishServerPurge(
+			ctx,
+			req.NamespacedName,
+			purge,
+			barmancloudv1.ServerPurgePhaseFailed,
+			message,
+		); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.recordEvent(ctx, req.NamespacedName, corev1.EventTypeWarning, reasonServerPurgeFailed,
+			fmt.Sprintf("Gave up purging the data of the deleted cluster %s/%s after %d attempts, "+
+				"it must be removed manually: %s",
+				purge.ClusterNamespace, purge.ClusterName, attempts, message))
+		return ctrl.Result{RequeueAfter: finishedJobRequeueInterval}, r.deleteServerPurgeJob(ctx, &job)
+	}
+
+	contextLogger.Info("Server data purge failed, retrying later",
+		"reason", reason,
+		"message", message,
+		"attempts", attempts)
+	if err := common.UpdateObjectStoreStatus(ctx, r.Client, req.NamespacedName,
+		func(status *barmancloudv1.ObjectStoreStatus) {
+			if entry := findServerPurge(status, purge.ClusterUID, purge.ServerName); entry != nil {
+				entry.Message = message
+				entry.Attempts = attempts
+				entry.ScheduledTime = metav1.NewTime(time.Now().Add(serverPurgeRetryInterval))
+			}
+		}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("while updating the server purge: %w", err)
+	}
+	r.recordEvent(ctx, req.NamespacedName, corev1.EventTypeWarning, reason, message)
+
+	return ctrl.Result{RequeueAfter: serverPurgeRetryInterval}, r.deleteServerPurgeJob(ctx, &job)
+}
+
+// startServerPurge starts the Job running the passed purge, unless the
+// object store no longer points to the location the deleted Cluster
+// archived into, or another Cluster is still writing to it or
+// replicating from it, in which case the purge is refused
+func (r *ServerPurgeReconciler) startServerPurge(
+	ctx context.Context,
+	key client.ObjectKey,
+	objectStore *barmancloudv1.ObjectStore,
+	purge *barmancloudv1.ServerPurge,
+) (ctrl.Result, error) {
+	contextLogger := log.FromContext(ctx)
+
+	message, err := r.getServerPurgeRefusal(ctx, objectStore, purge)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(message) > 0 {
+		contextLogger.Info("Server data purge refused", "message", message)
+		if err := r.finishServerPurge(
+			ctx,
+			key,
+			purge,
+			barmancloudv1.ServerPurgePhaseRefused,
+			message,
+		); err != nil {
+			return ctrl.Result{}, err
+		}
+		r.recordEvent(ctx, key, corev1.EventTypeWarning, reasonServerPurgeRefused, message)
+		return ctrl.Result{RequeueAfter: finishedJobRequeueInterval}, nil
+	}
+
+	imagePullSecrets, err := common.GetImagePullSecrets(ctx, r.Client, objectStore)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	job, err := specs.BuildServerPurgeJob(objectStore, purge, r.SidecarImage, imagePullSecrets)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	contextLogger.Info("Starting the server purge job",
+		"jobName", job.Name,
+		"jobNamespace", job.Namespace)
+	if err := r.Create(ctx, job); err != nil && !apierrs.IsAlreadyExists(err) {
+		return ctrl.Result{}, fmt.Errorf("while creating the server purge job: %w", err)
+	}
+
+	return ctrl.Result{RequeueAfter: serverPurgeCheckInterval}, nil
+}
+
+// getServerPurgeRefusal returns the reason why the passed purge must
+// not run, or an empty string if it can run
+func (r *ServerPurgeReconciler) getServerPurgeRefusal(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+	purge *barmancloudv1.ServerPurge,
+) (string, error) {
+	if !common.HasDestination(objectStore, purge.DestinationPath, purge.EndpointURL) {
+		return fmt.Sprintf(
+			"the object store no longer points to %q, where the cluster %s/%s archived, "+
+				"its data has been preserved",
+			purge.DestinationPath, purge.ClusterNamespace, purge.ClusterName), nil
+	}
+
+	writers, err := common.ListClustersArchivingTo(
+		ctx,
+		r.Client,
+		objectStore,
+		purge.ServerName,
+		types.UID(purge.ClusterUID),
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(writers) > 0 {
+		return fmt.Sprintf(
+			"the cluster %s/%s is archiving to the server name %q, its data has been preserved",
+			writers[0].Namespace, writers[0].Name, purge.ServerName), nil
+	}
+
+	readers, err := common.ListClustersReadingFrom(
+		ctx,
+		r.Client,
+		objectStore,
+		purge.ServerName,
+		types.UID(purge.ClusterUID),
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(readers) > 0 {
+		return fmt.Sprintf(
+			"the cluster %s/%s is replicating from the server name %q, its data has been preserved",
+			readers[0].Namespace, readers[0].Name, purge.ServerName), nil
+	}
+
+	return "", nil
+}
+
+// finishServerPurge records the outcome of the passed purge, and the
+// number of its failed attempts, in the status of the object store,
+// dropping the oldest finished purges
+func (r *ServerPurgeReconciler) finishServerPurge(
+	ctx context.Context,
+	key client.ObjectKey,
+	purge *barmancloudv1.ServerPurge,
+	phase barmancloudv1.ServerPurgePhase,
+	message string,
+) error {
+	now := metav1.Now()
+	err := common.UpdateObjectStoreStatus(ctx, r.Client, key, func(status *barmancloudv1.ObjectStoreStatus) {
+		entry := findServerPurge(status, purge.ClusterUID, purge.ServerName)
+		if entry == nil {
+			return
+		}
+		entry.Phase = phase
+		entry.Message = message
+		entry.Attempts = purge.Attempts
+		entry.CompletionTime = &now
+		pruneFinishedServerPurges(status)
+	})
+	if err != nil {
+		return fmt.Errorf("while updating the server purge: %w", err)
+	}
+
+	return nil
+}
+
+// recordEvent reports an event about the purges on the object store
+// having the passed key
+func (r *ServerPurgeReconciler) recordEvent(
+	ctx context.Context,
+	key client.ObjectKey,
+	eventType, reason, message string,
+) {
+	var object client.Object = &barmancloudv1.ObjectStore{}
+	if len(key.Namespace) == 0 {
+		object = &barmancloudv1.ClusterObjectStore{}
+	}
+
+	if err := r.Get(ctx, key, object); err != nil {
+		log.FromContext(ctx).Error(err, "while getting the object store to report an event")
+		return
+	}
+
+	r.Recorder.Event(object, eventType, reason, message)
+}
+
+// deleteServerPurgeJob removes the passed server purge Job
+func (r *ServerPurgeReconciler) deleteServerPurgeJob(ctx context.Context, job *batchv1.Job) error {
+	if err := r.Delete(
+		ctx,
+		job,
+		client.PropagationPolicy(metav1.DeletePropagationBackground),
+	); err != nil && !apierrs.IsNotFound(err) {
+		return fmt.Errorf("while deleting the server purge job: %w", err)
+	}
+
+	return nil
+}
+
+// findServerPurge returns the purge of the passed server of the passed
+// Cluster from the object store status, or nil if there is none
+func findServerPurge(
+	status *barmancloudv1.ObjectStoreStatus,
+	clusterUID, serverName string,
+) *barmancloudv1.ServerPurge {
+	for i := range status.ServerPurges {
+		if status.ServerPurges[i].ClusterUID == clusterUID &&
+			status.ServerPurges[i].ServerName == serverName {
+			return &status.ServerPurges[i]
+		}
+	}
+
+	return nil
+}
+
+// getNextServerPurge returns the pending purge scheduled first in the
+// object store status, or nil if there is none
+func getNextServerPurge(status *barmancloudv1.ObjectStoreStatus) *barmancloudv1.ServerPurge {
+	var next *barmancloudv1.ServerPurge
+	for i := range status.ServerPurges {
+		purge := &status.ServerPurges[i]
+		if purge.Phase != barmancloudv1.ServerPurgePhasePending {
+			continue
+		}
+		if next == nil || purge.ScheduledTime.Before(&next.ScheduledTime) {
+			next = purge
+		}
+	}
+
+	return next
+}
+
+// pruneFinishedServerPurges drops the oldest finished purges from the
+// object store status, keeping the last maxFinishedServerPurges
+func pruneFinishedServerPurges(status *barmancloudv1.ObjectStoreStatus) {
+	finished := 0
+	for i := len(status.ServerPurges) - 1; i >= 0; i-- {
+		if status.ServerPurges[i].Phase == barmancloudv1.ServerPurgePhasePending {
+			continue
+		}
+		finished++
+		if finished > maxFinishedServerPurges {
+			status.ServerPurges = slices.Delete(status.ServerPurges, i, i+1)
+		}
+	}
+}
+
+// hasPendingServerPurges accepts the object stores having a pending
+// server purge in their status
+var hasPendingServerPurges = predicate.NewPredicateFuncs(func(object client.Object) bool {
+	var status *barmancloudv1.ObjectStoreStatus
+	switch objectStore := object.(type) {
+	case *barmancloudv1.ObjectStore:
+		status = &objectStore.Status
+	case *barmancloudv1.ClusterObjectStore:
+		status = &objectStore.Status
+	default:
+		return false
+	}
+
+	return getNextServerPurge(status) != nil
+})
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ServerPurgeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewControllerManagedBy(mgr).
+		Named("serverpurge").
+		For(&barmancloudv1.ObjectStore{}, builder.WithPredicates(hasPendingServerPurges)).
+		Watches(
+			&barmancloudv1.ClusterObjectStore{},
+			&handler.EnqueueRequestForObject{},
+			builder.WithPredicates(hasPendingServerPurges),
+		).
+		Complete(r)
+	if err != nil {
+		return fmt.Errorf("unable to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+var _ = Describe("Server purge", func() {
+	var (
+		ctx         context.Context
+		objectStore *barmancloudv1.ObjectStore
+		recorder    *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = record.NewFakeRecorder(10)
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+				},
+			},
+			Status: barmancloudv1.ObjectStoreStatus{
+				ServerPurges: []barmancloudv1.ServerPurge{
+					{
+						ServerName:       "cluster",
+						ClusterNamespace: "default",
+						ClusterName:      "cluster",
+						ClusterUID:       "cluster-uid",
+						DestinationPath:  "s3://bucket/path/",
+						ScheduledTime:    metav1.NewTime(time.Now().Add(-time.Minute)),
+						Phase:            barmancloudv1.ServerPurgePhasePending,
+					},
+				},
+			},
+		}
+	})
+
+	newReconciler := func(objects ...client.Object) *ServerPurgeReconciler {
+		scheme := newCatalogMaintenanceScheme()
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(objects...).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			WithIndex(&cnpgv1.Cluster{}, common.ClusterArchiveObjectStoreField, common.IndexClusterArchiveObjectStore).
+			Build()
+		return &ServerPurgeReconciler{
+			Client:       fakeClient,
+			Scheme:       scheme,
+			APIReader:    fakeClient,
+			SidecarImage: "sidecar:latest",
+			Recorder:     recorder,
+		}
+	}
+
+	reconcileObjectStore := func(r *ServerPurgeReconciler) time.Duration {
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(objectStore)})
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	getServerPurge := func(r *ServerPurgeReconciler) barmancloudv1.ServerPurge {
+		var updated barmancloudv1.ObjectStore
+		Expect(r.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		Expect(updated.Status.ServerPurges).To(HaveLen(1))
+		return updated.Status.ServerPurges[0]
+	}
+
+	jobKey := client.ObjectKey{
+		Namespace: "default",
+		Name:      specs.GetServerPurgeJobName("store"),
+	}
+
+	newFinishedJob := func(conditionType batchv1.JobConditionType) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      jobKey.Name,
+				Namespace: jobKey.Namespace,
+				Annotations: map[string]string{
+					metadata.ServerPurgeClusterUIDAnnotationName: "cluster-uid",
+				},
+			},
+			Status: batchv1.JobStatus{
+				Conditions: []batchv1.JobCondition{
+					{
+						Type:    conditionType,
+						Status:  corev1.ConditionTrue,
+						Message: "job finished",
+					},
+				},
+			},
+		}
+	}
+
+	It("waits for the end of the grace period", func() {
+		objectStore.Status.ServerPurges[0].ScheduledTime = metav1.NewTime(time.Now().Add(time.Hour))
+		r := newReconciler(objectStore)
+		Expect(reconcileObjectStore(r)).To(BeNumerically(">", 59*time.Minute))
+
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("starts the purge job once the grace period is over", func() {
+		r := newReconciler(objectStore)
+		Expect(reconcileObjectStore(r)).To(Equal(serverPurgeCheckInterval))
+
+		var job batchv1.Job
+		Expect(r.Get(ctx, jobKey, &job)).To(Succeed())
+		Expect(job.Annotations).To(HaveKeyWithValue(metadata.ServerPurgeClusterUIDAnnotationName, "cluster-uid"))
+		Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+			corev1.EnvVar{Name: "SERVER_NAME", Value: "cluster"},
+		))
+	})
+
+	It("refuses the purge when another Cluster archives to the same server name", func() {
+		writer := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "new-cluster",
+				Namespace: "default",
+				UID:       "new-cluster-uid",
+			},
+			Spec: cnpgv1.ClusterSpec{
+				Plugins: []cnpgv1.PluginConfiguration{
+					{
+						Name: metadata.PluginName,
+						Parameters: map[string]string{
+							"barmanObjectName": "store",
+							"serverName":       "cluster",
+						},
+					},
+				},
+			},
+		}
+		r := newReconciler(objectStore, writer)
+		reconcileObjectStore(r)
+
+		purge := getServerPurge(r)
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhaseRefused))
+		Expect(purge.Message).To(ContainSubstring("default/new-cluster"))
+		Expect(purge.CompletionTime).NotTo(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring(reasonServerPurgeRefused)))
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("refuses the purge when the destination of the object store changed", func() {
+		objectStore.Spec.Configuration.DestinationPath = "s3://bucket/other-path"
+		r := newReconciler(objectStore)
+		reconcileObjectStore(r)
+
+		purge := getServerPurge(r)
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhaseRefused))
+		Expect(purge.Message).To(ContainSubstring("s3://bucket/path/"))
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("refuses the purge when a replica Cluster replicates from the server", func() {
+		reader := &cnpgv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "replica-cluster",
+				Namespace: "default",
+				UID:       "replica-cluster-uid",
+			},
+			Spec: cnpgv1.ClusterSpec{
+				ReplicaCluster: &cnpgv1.ReplicaClusterConfiguration{
+					Enabled: ptr.To(true),
+					Source:  "origin",
+				},
+				ExternalClusters: []cnpgv1.ExternalCluster{
+					{
+						Name: "origin",
+						PluginConfiguration: &cnpgv1.PluginConfiguration{
+							Name: metadata.PluginName,
+							Parameters: map[string]string{
+								"barmanObjectName": "store",
+								"serverName":       "cluster",
+							},
+						},
+					},
+				},
+			},
+		}
+		r := newReconciler(objectStore, reader)
+		reconcileObjectStore(r)
+
+		purge := getServerPurge(r)
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhaseRefused))
+		Expect(purge.Message).To(ContainSubstring("default/replica-cluster"))
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("records the completion of the purge", func() {
+		job := newFinishedJob(batchv1.JobComplete)
+		r := newReconciler(objectStore, job)
+		reconcileObjectStore(r)
+
+		purge := getServerPurge(r)
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhaseCompleted))
+		Expect(purge.CompletionTime).NotTo(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring(reasonServerPurged)))
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("retries a failed purge later", func() {
+		job := newFinishedJob(batchv1.JobFailed)
+		r := newReconciler(objectStore, job)
+		Expect(reconcileObjectStore(r)).To(Equal(serverPurgeRetryInterval))
+
+		purge := getServerPurge(r)
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhasePending))
+		Expect(purge.Message).To(Equal("job finished"))
+		Expect(purge.Attempts).To(BeEquivalentTo(1))
+		Expect(purge.ScheduledTime.Time).To(BeTemporally(">", time.Now()))
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("stops retrying a purge that failed too many times", func() {
+		objectStore.Status.ServerPurges[0].Attempts = maxServerPurgeAttempts - 1
+		job := newFinishedJob(batchv1.JobFailed)
+		r := newReconciler(objectStore, job)
+		Expect(reconcileObjectStore(r)).To(Equal(finishedJobRequeueInterval))
+
+		purge := getServerPurge(r)
+		Expect(purge.Phase).To(Equal(barmancloudv1.ServerPurgePhaseFailed))
+		Expect(purge.Attempts).To(BeEquivalentTo(maxServerPurgeAttempts))
+		Expect(purge.CompletionTime).NotTo(BeNil())
+		Expect(recorder.Events).To(Receive(ContainSubstring(reasonServerPurgeFailed)))
+		err := r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+
+		Expect(reconcileObjectStore(r)).To(BeZero())
+		err = r.Get(ctx, jobKey, &batchv1.Job{})
+		Expect(apierrs.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps a bounded number of finished purges", func() {
+		status := &barmancloudv1.ObjectStoreStatus{}
+		for range maxFinishedServerPurges + 2 {
+			status.ServerPurges = append(status.ServerPurges, barmancloudv1.ServerPurge{
+				Phase: barmancloudv1.ServerPurgePhaseCompleted,
+			})
+		}
+		status.ServerPurges = append(status.ServerPurges, barmancloudv1.ServerPurge{
+			Phase: barmancloudv1.ServerPurgePhasePending,
+		})
+
+		pruneFinishedServerPurges(status)
+		Expect(status.ServerPurges).To(HaveLen(maxFinishedServerPurges + 1))
+		Expect(getNextServerPurge(status)).NotTo(BeNil())
+	})
+})
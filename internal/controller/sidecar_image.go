@@ -0,0 +1,79 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/common"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// recordSidecarImage records in the status of the object store having
+// the passed key the image used by its sidecars. When the requested
+// image is not in the allow-list, the default image is recorded, and
+// an event is emitted on the passed object.
+func recordSidecarImage(
+	ctx context.Context,
+	c client.Client,
+	recorder record.EventRecorder,
+	objectStoreKey client.ObjectKey,
+	eventObject runtime.Object,
+	defaultImage string,
+	allowList []string,
+) error {
+	objectStore, err := common.GetObjectStore(ctx, c, objectStoreKey)
+	if err != nil {
+		return err
+	}
+
+	image := specs.GetSidecarImage(objectStore, defaultImage, allowList)
+	if image == objectStore.Status.SidecarImage {
+		return nil
+	}
+
+	if err := common.UpdateObjectStoreStatus(
+		ctx,
+		c,
+		objectStoreKey,
+		func(status *barmancloudv1.ObjectStoreStatus) {
+			status.SidecarImage = image
+		},
+	); err != nil {
+		return fmt.Errorf("while recording the sidecar image: %w", err)
+	}
+
+	if requestedImage := objectStore.Spec.InstanceSidecarConfiguration.Image; len(requestedImage) > 0 &&
+		requestedImage != image {
+		log.FromContext(ctx).Info("The requested sidecar image is not allowed", "image", requestedImage)
+		recorder.Event(eventObject, corev1.EventTypeWarning, "SidecarImageNotAllowed",
+			fmt.Sprintf("the sidecar image %s is not in the allow-list, using %s", requestedImage, image))
+	}
+
+	return nil
+}
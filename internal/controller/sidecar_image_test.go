@@ -0,0 +1,90 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+)
+
+var _ = Describe("Sidecar image", func() {
+	const defaultImage = "ghcr.io/cloudnative-pg/plugin-barman-cloud-sidecar:v1.0.0"
+
+	var (
+		ctx         context.Context
+		objectStore *barmancloudv1.ObjectStore
+		recorder    *record.FakeRecorder
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objectStore = newTestObjectStore("my-store", "default", "my-secret")
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	recordImage := func(allowList ...string) *barmancloudv1.ObjectStore {
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(newFakeScheme()).
+			WithObjects(objectStore).
+			WithStatusSubresource(&barmancloudv1.ObjectStore{}).
+			Build()
+
+		ExpectWithOffset(1, recordSidecarImage(
+			ctx,
+			fakeClient,
+			recorder,
+			client.ObjectKeyFromObject(objectStore),
+			objectStore,
+			defaultImage,
+			allowList,
+		)).To(Succeed())
+
+		var updated barmancloudv1.ObjectStore
+		ExpectWithOffset(1, fakeClient.Get(ctx, client.ObjectKeyFromObject(objectStore), &updated)).To(Succeed())
+		return &updated
+	}
+
+	It("records the default image", func() {
+		Expect(recordImage().Status.SidecarImage).To(Equal(defaultImage))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("records the requested image when allowed", func() {
+		objectStore.Spec.InstanceSidecarConfiguration.Image = "registry.example.com/barman:v2"
+
+		updated := recordImage("registry.example.com/barman:*")
+		Expect(updated.Status.SidecarImage).To(Equal("registry.example.com/barman:v2"))
+		Expect(recorder.Events).To(BeEmpty())
+	})
+
+	It("records the default image and emits an event when the requested one is not allowed", func() {
+		objectStore.Spec.InstanceSidecarConfiguration.Image = "registry.example.com/barman:v2"
+
+		Expect(recordImage().Status.SidecarImage).To(Equal(defaultImage))
+		Expect(recorder.Events).To(Receive(ContainSubstring("SidecarImageNotAllowed")))
+	})
+})
@@ -0,0 +1,86 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+// requestClusterEvaluation annotates the instance pods of the Cluster
+// having the passed key with the key and the generation of the object
+// store whose spec changed. The operator reconciles the Cluster owning
+// the updated pods, evaluating them with the plugin, and rolls out the
+// ones whose sidecar no longer matches the configuration of the object
+// store. The Cluster itself is never written, as it is usually owned
+// by the user or by a GitOps tool.
+func requestClusterEvaluation(
+	ctx context.Context,
+	c client.Client,
+	apiReader client.Reader,
+	clusterKey client.ObjectKey,
+	objectStoreKey client.ObjectKey,
+	generation int64,
+) error {
+	var pods corev1.PodList
+	if err := apiReader.List(
+		ctx,
+		&pods,
+		client.InNamespace(clusterKey.Namespace),
+		client.MatchingLabels{
+			utils.ClusterLabelName: clusterKey.Name,
+			utils.PodRoleLabelName: string(utils.PodRoleInstance),
+		},
+	); err != nil {
+		return fmt.Errorf("while listing the pods of cluster %s: %w", clusterKey, err)
+	}
+
+	value := fmt.Sprintf("%s:%d", objectStoreKey, generation)
+	var errs []error
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Annotations[metadata.ObjectStoreGenerationAnnotationName] == value {
+			continue
+		}
+
+		original := pod.DeepCopy()
+		if pod.Annotations == nil {
+			pod.Annotations = make(map[string]string)
+		}
+		pod.Annotations[metadata.ObjectStoreGenerationAnnotationName] = value
+
+		log.FromContext(ctx).Info("Requesting the evaluation of the pod after an object store change",
+			"clusterName", clusterKey.Name, "podName", pod.Name, "generation", generation)
+		if err := c.Patch(ctx, pod, client.MergeFrom(original)); err != nil && !apierrs.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("while annotating pod %s: %w", pod.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package controller
+
+import (
+	"context"
+
+	cnpgv1 "github.com/cloudnative-pg/cloudnative-pg/api/v1"
+	"github.com/cloudnative-pg/cloudnative-pg/pkg/utils"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/metadata"
+)
+
+var _ = Describe("requestClusterEvaluation", func() {
+	var (
+		ctx        context.Context
+		fakeClient client.Client
+		clusterKey client.ObjectKey
+		storeKey   client.ObjectKey
+	)
+
+	newPod := func(name, clusterName string, role utils.PodRole) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					utils.ClusterLabelName: clusterName,
+					utils.PodRoleLabelName: string(role),
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		clusterKey = client.ObjectKey{Namespace: "default", Name: "cluster"}
+		storeKey = client.ObjectKey{Namespace: "default", Name: "my-store"}
+		fakeClient = fake.NewClientBuilder().
+			WithScheme(newCatalogMaintenanceScheme()).
+			WithObjects(
+				&cnpgv1.Cluster{
+					ObjectMeta: metav1.ObjectMeta{Name: clusterKey.Name, Namespace: clusterKey.Namespace},
+				},
+				newPod("cluster-1", "cluster", utils.PodRoleInstance),
+				newPod("cluster-2", "cluster", utils.PodRoleInstance),
+				newPod("cluster-pooler", "cluster", utils.PodRolePooler),
+				newPod("other-1", "other", utils.PodRoleInstance),
+			).
+			Build()
+	})
+
+	getAnnotation := func(podName string) string {
+		var pod corev1.Pod
+		ExpectWithOffset(1, fakeClient.Get(ctx, client.ObjectKey{Namespace: "default", Name: podName}, &pod)).
+			To(Succeed())
+		return pod.Annotations[metadata.ObjectStoreGenerationAnnotationName]
+	}
+
+	It("annotates the instance pods with the generation of the object store", func() {
+		Expect(requestClusterEvaluation(ctx, fakeClient, fakeClient, clusterKey, storeKey, 2)).To(Succeed())
+		Expect(getAnnotation("cluster-1")).To(Equal("default/my-store:2"))
+		Expect(getAnnotation("cluster-2")).To(Equal("default/my-store:2"))
+		Expect(getAnnotation("cluster-pooler")).To(BeEmpty())
+		Expect(getAnnotation("other-1")).To(BeEmpty())
+
+		Expect(requestClusterEvaluation(ctx, fakeClient, fakeClient, clusterKey, storeKey, 3)).To(Succeed())
+		Expect(getAnnotation("cluster-1")).To(Equal("default/my-store:3"))
+	})
+
+	It("does not write the cluster", func() {
+		var before cnpgv1.Cluster
+		Expect(fakeClient.Get(ctx, clusterKey, &before)).To(Succeed())
+
+		Expect(requestClusterEvaluation(ctx, fakeClient, fakeClient, clusterKey, storeKey, 2)).To(Succeed())
+
+		var after cnpgv1.Cluster
+		Expect(fakeClient.Get(ctx, clusterKey, &after)).To(Succeed())
+		Expect(after.ResourceVersion).To(Equal(before.ResourceVersion))
+		Expect(after.Annotations).NotTo(HaveKey(metadata.ObjectStoreGenerationAnnotationName))
+	})
+
+	It("ignores the clusters that do not have pods", func() {
+		missingKey := client.ObjectKey{Namespace: "default", Name: "missing"}
+		Expect(requestClusterEvaluation(ctx, fakeClient, fakeClient, missingKey, storeKey, 2)).To(Succeed())
+	})
+})
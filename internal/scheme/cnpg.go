@@ -30,11 +30,10 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
-// AddCNPGToScheme registers CNPG types into the given scheme using
-// the API group configured via CUSTOM_CNPG_GROUP/CUSTOM_CNPG_VERSION
-// environment variables, defaulting to postgresql.cnpg.io/v1.
-// This allows the plugin to work with any CNPG-based operator.
-func AddCNPGToScheme(ctx context.Context, s *runtime.Scheme) {
+// GetCNPGGroupVersion returns the CNPG API group and version
+// configured via CUSTOM_CNPG_GROUP/CUSTOM_CNPG_VERSION environment
+// variables, defaulting to postgresql.cnpg.io/v1.
+func GetCNPGGroupVersion() schema.GroupVersion {
 	cnpgGroup := viper.GetString("custom-cnpg-group")
 	cnpgVersion := viper.GetString("custom-cnpg-version")
 	if len(cnpgGroup) == 0 {
@@ -44,7 +43,14 @@ func AddCNPGToScheme(ctx context.Context, s *runtime.Scheme) {
 		cnpgVersion = cnpgv1.SchemeGroupVersion.Version
 	}
 
-	schemeGroupVersion := schema.GroupVersion{Group: cnpgGroup, Version: cnpgVersion}
+	return schema.GroupVersion{Group: cnpgGroup, Version: cnpgVersion}
+}
+
+// AddCNPGToScheme registers CNPG types into the given scheme using
+// the API group returned by GetCNPGGroupVersion.
+// This allows the plugin to work with any CNPG-based operator.
+func AddCNPGToScheme(ctx context.Context, s *runtime.Scheme) {
+	schemeGroupVersion := GetCNPGGroupVersion()
 	s.AddKnownTypes(schemeGroupVersion,
 		&cnpgv1.Cluster{}, &cnpgv1.ClusterList{},
 		&cnpgv1.Backup{}, &cnpgv1.BackupList{},
@@ -0,0 +1,349 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	"github.com/cloudnative-pg/machinery/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/credentials"
+	"github.com/cloudnative-pg/plugin-barman-cloud/internal/cnpgi/operator/specs"
+)
+
+// MaxWALParallel is the maximum accepted value of wal.maxParallel.
+// Every parallel worker is a barman-cloud-wal-archive process running
+// in the instance Pod, and PostgreSQL waits for all of them before
+// archiving the next batch of WAL files.
+const MaxWALParallel = 64
+
+// sidecarContainerName is the name of the container injected by the
+// plugin in the instance pods
+const sidecarContainerName = "plugin-barman-cloud"
+
+// SetupObjectStoreWebhookWithManager registers the webhook for ObjectStore in the manager.
+func SetupObjectStoreWebhookWithManager(mgr ctrl.Manager, sidecarImageAllowList []string) error {
+	return ctrl.NewWebhookManagedBy(mgr, &barmancloudv1.ObjectStore{}).
+		WithValidator(&ObjectStoreCustomValidator{
+			Client:                mgr.GetAPIReader(),
+			SidecarImageAllowList: sidecarImageAllowList,
+		}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-barmancloud-cnpg-io-v1-objectstore,mutating=false,failurePolicy=fail,sideEffects=None,groups=barmancloud.cnpg.io,resources=objectstores,verbs=create;update,versions=v1,name=vobjectstore-v1.barmancloud.cnpg.io,admissionReviewVersions=v1
+
+// ObjectStoreCustomValidator validates the ObjectStore resources
+// when they are created or updated.
+type ObjectStoreCustomValidator struct {
+	// Client is used to check that the referenced Secrets exist. It
+	// is not expected to be cached, to avoid watching every Secret.
+	Client client.Reader
+
+	// SidecarImageAllowList is the list of patterns of the images
+	// the ObjectStores are allowed to use for the sidecar
+	SidecarImageAllowList []string
+}
+
+var _ admission.Validator[*barmancloudv1.ObjectStore] = &ObjectStoreCustomValidator{}
+
+// ValidateCreate implements admission.Validator
+func (v *ObjectStoreCustomValidator) ValidateCreate(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) (admission.Warnings, error) {
+	log.FromContext(ctx).Debug("Validation for ObjectStore upon creation", "name", objectStore.GetName())
+
+	return v.validate(ctx, objectStore)
+}
+
+// ValidateUpdate implements admission.Validator
+func (v *ObjectStoreCustomValidator) ValidateUpdate(
+	ctx context.Context,
+	oldObjectStore, objectStore *barmancloudv1.ObjectStore,
+) (admission.Warnings, error) {
+	log.FromContext(ctx).Debug("Validation for ObjectStore upon update", "name", objectStore.GetName())
+
+	// The finalizers and the other metadata must be updatable even when
+	// the spec is not valid anymore, for example because a referenced
+	// Secret was deleted first, otherwise the ObjectStore could never
+	// be released
+	if !objectStore.DeletionTimestamp.IsZero() ||
+		equality.Semantic.DeepEqual(oldObjectStore.Spec, objectStore.Spec) {
+		return nil, nil
+	}
+
+	warnings, err := v.validate(ctx, objectStore)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(warnings, getOrphaningWarnings(oldObjectStore, objectStore)...), nil
+}
+
+// ValidateDelete implements admission.Validator
+func (v *ObjectStoreCustomValidator) ValidateDelete(
+	_ context.Context,
+	_ *barmancloudv1.ObjectStore,
+) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate checks the passed ObjectStore, returning an Invalid error
+// listing every problem found, and a warning for each referenced Secret
+// that is not available yet
+func (v *ObjectStoreCustomValidator) validate(
+	ctx context.Context,
+	objectStore *barmancloudv1.ObjectStore,
+) (admission.Warnings, error) {
+	configurationPath := field.NewPath("spec", "configuration")
+	configuration := &objectStore.Spec.Configuration
+
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validateProvider(configurationPath, configuration)...)
+	allErrs = append(allErrs, validateWAL(configurationPath.Child("wal"), configuration.Wal)...)
+	allErrs = append(allErrs, validateContainerTemplate(
+		field.NewPath("spec", "instanceSidecarConfiguration", "containerTemplate"),
+		objectStore.Spec.InstanceSidecarConfiguration.ContainerTemplate,
+	)...)
+	allErrs = append(allErrs, validateSidecarImage(
+		field.NewPath("spec", "instanceSidecarConfiguration", "image"),
+		objectStore.Spec.InstanceSidecarConfiguration.Image,
+		v.SidecarImageAllowList,
+	)...)
+
+	// The Secrets are checked only when the configuration is sound
+	var warnings admission.Warnings
+	if len(allErrs) == 0 {
+		var errs field.ErrorList
+		var err error
+		warnings, errs, err = v.validateSecrets(ctx, configurationPath, objectStore)
+		if err != nil {
+			return nil, apierrs.NewInternalError(err)
+		}
+		allErrs = append(allErrs, errs...)
+	}
+
+	if len(allErrs) == 0 {
+		return warnings, nil
+	}
+
+	return nil, apierrs.NewInvalid(
+		barmancloudv1.GroupVersion.WithKind("ObjectStore").GroupKind(),
+		objectStore.Name,
+		allErrs,
+	)
+}
+
+// validateProvider checks that exactly one provider is configured, and
+// that the scheme of the destination path matches it
+func validateProvider(
+	configurationPath *field.Path,
+	configuration *barmanapi.BarmanObjectStoreConfiguration,
+) field.ErrorList {
+	var allErrs field.ErrorList
+
+	var providers []string
+	if configuration.AWS != nil {
+		providers = append(providers, "s3Credentials")
+	}
+	if configuration.Azure != nil {
+		providers = append(providers, "azureCredentials")
+	}
+	if configuration.Google != nil {
+		providers = append(providers, "googleCredentials")
+	}
+	if len(providers) != 1 {
+		allErrs = append(allErrs, field.Invalid(
+			configurationPath,
+			providers,
+			"exactly one of s3Credentials, azureCredentials and googleCredentials must be set",
+		))
+		return allErrs
+	}
+
+	destinationPathField := configurationPath.Child("destinationPath")
+	destinationURL, err := url.Parse(configuration.DestinationPath)
+	if err != nil {
+		return append(allErrs, field.Invalid(
+			destinationPathField,
+			configuration.DestinationPath,
+			fmt.Sprintf("not a valid URL: %v", err),
+		))
+	}
+
+	var allowedSchemes []string
+	switch {
+	case configuration.AWS != nil:
+		allowedSchemes = []string{"s3"}
+	case configuration.Azure != nil:
+		allowedSchemes = []string{"https", "http"}
+	case configuration.Google != nil:
+		allowedSchemes = []string{"gs"}
+	}
+	for _, scheme := range allowedSchemes {
+		if destinationURL.Scheme == scheme {
+			return allErrs
+		}
+	}
+
+	return append(allErrs, field.Invalid(
+		destinationPathField,
+		configuration.DestinationPath,
+		fmt.Sprintf("the URL scheme must be one of %v when using %s", allowedSchemes, providers[0]),
+	))
+}
+
+// validateWAL checks the WAL archiving configuration
+func validateWAL(walPath *field.Path, wal *barmanapi.WalBackupConfiguration) field.ErrorList {
+	if wal == nil || wal.MaxParallel <= MaxWALParallel {
+		return nil
+	}
+
+	return field.ErrorList{
+		field.Invalid(
+			walPath.Child("maxParallel"),
+			wal.MaxParallel,
+			fmt.Sprintf("must be less than or equal to %d", MaxWALParallel),
+		),
+	}
+}
+
+// validateSidecarImage checks that the requested sidecar image is in
+// the allow-list of the plugin operator
+func validateSidecarImage(imagePath *field.Path, image string, allowList []string) field.ErrorList {
+	if len(image) == 0 || specs.IsSidecarImageAllowed(image, allowList) {
+		return nil
+	}
+
+	return field.ErrorList{
+		field.Forbidden(imagePath, fmt.Sprintf(
+			"the image %s is not in the sidecar image allow-list of the plugin operator", image)),
+	}
+}
+
+// validateContainerTemplate checks that the sidecar container template
+// does not set the fields managed by the plugin, which would otherwise
+// be silently ignored
+func validateContainerTemplate(templatePath *field.Path, template *corev1.Container) field.ErrorList {
+	if template == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	forbid := func(name string, isSet bool) {
+		if isSet {
+			allErrs = append(allErrs, field.Forbidden(
+				templatePath.Child(name),
+				"the field is managed by the plugin",
+			))
+		}
+	}
+
+	forbid("name", len(template.Name) > 0 && template.Name != sidecarContainerName)
+	forbid("image", len(template.Image) > 0)
+	forbid("command", len(template.Command) > 0)
+	forbid("args", len(template.Args) > 0)
+	forbid("restartPolicy", template.RestartPolicy != nil)
+	if template.StartupProbe != nil {
+		handler := template.StartupProbe.ProbeHandler
+		if handler.Exec != nil || handler.HTTPGet != nil || handler.TCPSocket != nil || handler.GRPC != nil {
+			allErrs = append(allErrs, field.Forbidden(
+				templatePath.Child("startupProbe"),
+				"only the timings of the startup probe can be changed",
+			))
+		}
+	}
+
+	return allErrs
+}
+
+// validateSecrets checks that every Secret key referenced by the
+// credentials exists. A missing Secret or key only produces a warning,
+// as the Secret is often created after the ObjectStore, for example by
+// a GitOps tool or an external secrets operator, while incomplete
+// credentials are rejected.
+func (v *ObjectStoreCustomValidator) validateSecrets(
+	ctx context.Context,
+	configurationPath *field.Path,
+	objectStore *barmancloudv1.ObjectStore,
+) (admission.Warnings, field.ErrorList, error) {
+	_, err := credentials.Resolve(ctx, v.Client, objectStore.Namespace, &objectStore.Spec.Configuration)
+
+	var resolutionError *credentials.ResolutionError
+	if !errors.As(err, &resolutionError) {
+		return nil, nil, err
+	}
+
+	switch resolutionError.Reason {
+	case barmancloudv1.ReasonSecretNotFound, barmancloudv1.ReasonSecretKeyNotFound:
+		return admission.Warnings{fmt.Sprintf(
+			"%s: %s: the ObjectStore cannot be used until it is available",
+			configurationPath,
+			resolutionError.Message,
+		)}, nil, nil
+
+	default:
+		return nil, field.ErrorList{
+			field.Invalid(configurationPath, resolutionError.Reason, resolutionError.Message),
+		}, nil
+	}
+}
+
+// getOrphaningWarnings warns when an update to the ObjectStore would
+// make the backups taken so far unreachable through it
+func getOrphaningWarnings(oldObjectStore, objectStore *barmancloudv1.ObjectStore) admission.Warnings {
+	oldConfiguration := &oldObjectStore.Spec.Configuration
+	configuration := &objectStore.Spec.Configuration
+
+	var warnings admission.Warnings
+	if oldConfiguration.DestinationPath != configuration.DestinationPath ||
+		oldConfiguration.EndpointURL != configuration.EndpointURL {
+		warnings = append(warnings, fmt.Sprintf(
+			"the location of ObjectStore %s changed: the backups and WAL files stored in %s "+
+				"will not be available for recovery, nor removed by the retention policy",
+			objectStore.Name,
+			oldConfiguration.DestinationPath,
+		))
+	}
+
+	if !reflect.DeepEqual(oldConfiguration.BarmanCredentials, configuration.BarmanCredentials) {
+		warnings = append(warnings, fmt.Sprintf(
+			"the credentials of ObjectStore %s changed: make sure they can still access "+
+				"the existing backups and WAL files",
+			objectStore.Name,
+		))
+	}
+
+	return warnings
+}
@@ -0,0 +1,266 @@
+/*
+Copyright © contributors to CloudNativePG, established as
+CloudNativePG a Series of LF Projects, LLC.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package v1
+
+import (
+	"context"
+
+	barmanapi "github.com/cloudnative-pg/barman-cloud/pkg/api"
+	machineryapi "github.com/cloudnative-pg/machinery/pkg/api"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	barmancloudv1 "github.com/cloudnative-pg/plugin-barman-cloud/api/v1"
+)
+
+var _ = Describe("ObjectStore webhook", func() {
+	var (
+		ctx         context.Context
+		objectStore *barmancloudv1.ObjectStore
+		secret      *corev1.Secret
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		objectStore = &barmancloudv1.ObjectStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "my-store",
+				Namespace: "default",
+			},
+			Spec: barmancloudv1.ObjectStoreSpec{
+				Configuration: barmanapi.BarmanObjectStoreConfiguration{
+					DestinationPath: "s3://bucket/path",
+					BarmanCredentials: barmanapi.BarmanCredentials{
+						AWS: &barmanapi.S3Credentials{
+							AccessKeyIDReference: &machineryapi.SecretKeySelector{
+								LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+								Key:                  "ACCESS_KEY_ID",
+							},
+							SecretAccessKeyReference: &machineryapi.SecretKeySelector{
+								LocalObjectReference: machineryapi.LocalObjectReference{Name: "aws-creds"},
+								Key:                  "ACCESS_SECRET_KEY",
+							},
+						},
+					},
+				},
+			},
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "aws-creds",
+				Namespace: "default",
+			},
+			Data: map[string][]byte{
+				"ACCESS_KEY_ID":     []byte("id"),
+				"ACCESS_SECRET_KEY": []byte("key"),
+			},
+		}
+	})
+
+	newValidator := func(objs ...client.Object) *ObjectStoreCustomValidator {
+		scheme := runtime.NewScheme()
+		utilruntime.Must(corev1.AddToScheme(scheme))
+		return &ObjectStoreCustomValidator{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		}
+	}
+
+	expectInvalid := func(err error, fieldPath string) {
+		Expect(err).To(HaveOccurred())
+		Expect(apierrs.IsInvalid(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring(fieldPath))
+	}
+
+	It("accepts a valid ObjectStore", func() {
+		warnings, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(BeEmpty())
+	})
+
+	It("rejects an ObjectStore without credentials", func() {
+		objectStore.Spec.Configuration.AWS = nil
+		_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		expectInvalid(err, "spec.configuration")
+	})
+
+	It("rejects an ObjectStore with more than one provider", func() {
+		objectStore.Spec.Configuration.Google = &barmanapi.GoogleCredentials{GKEEnvironment: true}
+		_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		expectInvalid(err, "exactly one of")
+	})
+
+	DescribeTable("checks the scheme of the destination path",
+		func(credentials barmanapi.BarmanCredentials, destinationPath string, valid bool) {
+			objectStore.Spec.Configuration.BarmanCredentials = credentials
+			objectStore.Spec.Configuration.DestinationPath = destinationPath
+			_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+			if valid {
+				Expect(err).NotTo(HaveOccurred())
+			} else {
+				expectInvalid(err, "spec.configuration.destinationPath")
+			}
+		},
+		Entry("s3 on AWS",
+			barmanapi.BarmanCredentials{AWS: &barmanapi.S3Credentials{InheritFromIAMRole: true}},
+			"s3://bucket/path", true),
+		Entry("gs on AWS",
+			barmanapi.BarmanCredentials{AWS: &barmanapi.S3Credentials{InheritFromIAMRole: true}},
+			"gs://bucket/path", false),
+		Entry("https on Azure",
+			barmanapi.BarmanCredentials{Azure: &barmanapi.AzureCredentials{InheritFromAzureAD: true}},
+			"https://account.blob.core.windows.net/container/path", true),
+		Entry("s3 on Azure",
+			barmanapi.BarmanCredentials{Azure: &barmanapi.AzureCredentials{InheritFromAzureAD: true}},
+			"s3://bucket/path", false),
+		Entry("gs on Google",
+			barmanapi.BarmanCredentials{Google: &barmanapi.GoogleCredentials{GKEEnvironment: true}},
+			"gs://bucket/path", true),
+		Entry("https on Google",
+			barmanapi.BarmanCredentials{Google: &barmanapi.GoogleCredentials{GKEEnvironment: true}},
+			"https://storage.googleapis.com/bucket", false),
+	)
+
+	It("warns about a missing secret", func() {
+		warnings, err := newValidator().ValidateCreate(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(HaveLen(1))
+		Expect(warnings[0]).To(ContainSubstring("secret aws-creds not found"))
+	})
+
+	It("warns about a missing secret key", func() {
+		delete(secret.Data, "ACCESS_SECRET_KEY")
+		warnings, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(HaveLen(1))
+		Expect(warnings[0]).To(ContainSubstring("missing key ACCESS_SECRET_KEY"))
+	})
+
+	It("rejects incomplete credentials", func() {
+		objectStore.Spec.Configuration.AWS.SecretAccessKeyReference = nil
+		_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		expectInvalid(err, "the access key ID and the secret access key are required")
+	})
+
+	It("rejects a too high wal.maxParallel", func() {
+		objectStore.Spec.Configuration.Wal = &barmanapi.WalBackupConfiguration{MaxParallel: MaxWALParallel + 1}
+		_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		expectInvalid(err, "spec.configuration.wal.maxParallel")
+
+		objectStore.Spec.Configuration.Wal.MaxParallel = MaxWALParallel
+		_, err = newValidator(secret).ValidateCreate(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects a container template overriding the fields managed by the plugin", func() {
+		objectStore.Spec.InstanceSidecarConfiguration.ContainerTemplate = &corev1.Container{
+			Image: "other:latest",
+			Args:  []string{"backup"},
+		}
+		_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		expectInvalid(err, "spec.instanceSidecarConfiguration.containerTemplate.image")
+		expectInvalid(err, "spec.instanceSidecarConfiguration.containerTemplate.args")
+
+		objectStore.Spec.InstanceSidecarConfiguration.ContainerTemplate = &corev1.Container{
+			StartupProbe: &corev1.Probe{PeriodSeconds: 5, FailureThreshold: 60},
+			VolumeMounts: []corev1.VolumeMount{{Name: "projected", MountPath: "/etc/proxy"}},
+		}
+		_, err = newValidator(secret).ValidateCreate(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("rejects a sidecar image that is not in the allow-list", func() {
+		objectStore.Spec.InstanceSidecarConfiguration.Image = "registry.example.com/barman:v2"
+		_, err := newValidator(secret).ValidateCreate(ctx, objectStore)
+		expectInvalid(err, "spec.instanceSidecarConfiguration.image")
+
+		validator := newValidator(secret)
+		validator.SidecarImageAllowList = []string{"registry.example.com/barman:*"}
+		_, err = validator.ValidateCreate(ctx, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("warns when the destination path changes", func() {
+		oldObjectStore := objectStore.DeepCopy()
+		objectStore.Spec.Configuration.DestinationPath = "s3://other-bucket/path"
+
+		warnings, err := newValidator(secret).ValidateUpdate(ctx, oldObjectStore, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(HaveLen(1))
+		Expect(warnings[0]).To(ContainSubstring("s3://bucket/path"))
+	})
+
+	It("warns when the credentials change", func() {
+		oldObjectStore := objectStore.DeepCopy()
+		objectStore.Spec.Configuration.AWS = &barmanapi.S3Credentials{InheritFromIAMRole: true}
+
+		warnings, err := newValidator(secret).ValidateUpdate(ctx, oldObjectStore, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(HaveLen(1))
+		Expect(warnings[0]).To(ContainSubstring("credentials"))
+	})
+
+	It("lets the finalizer be removed while a referenced secret is missing", func() {
+		objectStore.Finalizers = []string{"barmancloud.cnpg.io/objectstore-protection"}
+		objectStore.DeletionTimestamp = ptr.To(metav1.Now())
+		oldObjectStore := objectStore.DeepCopy()
+		objectStore.Finalizers = nil
+
+		_, err := newValidator().ValidateUpdate(ctx, oldObjectStore, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("accepts a metadata-only change of an ObjectStore that is not valid anymore", func() {
+		oldObjectStore := objectStore.DeepCopy()
+		objectStore.Finalizers = []string{"barmancloud.cnpg.io/objectstore-protection"}
+
+		_, err := newValidator().ValidateUpdate(ctx, oldObjectStore, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("validates the spec changes", func() {
+		oldObjectStore := objectStore.DeepCopy()
+		objectStore.Spec.RetentionPolicy = "30d"
+
+		warnings, err := newValidator().ValidateUpdate(ctx, oldObjectStore, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(ConsistOf(ContainSubstring("aws-creds")))
+
+		objectStore.Spec.Configuration.Wal = &barmanapi.WalBackupConfiguration{MaxParallel: MaxWALParallel + 1}
+		_, err = newValidator().ValidateUpdate(ctx, oldObjectStore, objectStore)
+		expectInvalid(err, "spec.configuration.wal.maxParallel")
+	})
+
+	It("does not warn when unrelated fields change", func() {
+		oldObjectStore := objectStore.DeepCopy()
+		objectStore.Spec.RetentionPolicy = "30d"
+
+		warnings, err := newValidator(secret).ValidateUpdate(ctx, oldObjectStore, objectStore)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(warnings).To(BeEmpty())
+	})
+})